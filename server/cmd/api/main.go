@@ -58,10 +58,20 @@ var defaultBotCommands = []telegram.BotCommand{
 	{Command: "settings", Description: "打开个人设置 / 绑定 Notion"},
 	{Command: "bind", Description: "群聊绑定当前 Database"},
 	{Command: "todo", Description: "在群内快速创建任务"},
+	{Command: "list", Description: "我的未完成任务，可加条件如 status:todo due:<7d"},
+	{Command: "mine", Description: "指派给我的任务"},
+	{Command: "done", Description: "完成列表中的任务，如 /done 3"},
+	{Command: "assign", Description: "指派列表中的任务，如 /assign 3 @成员"},
+	{Command: "due", Description: "设置截止时间，如 /due 3 明天"},
 	{Command: "menu", Description: "显示快捷菜单"},
 	{Command: "close", Description: "隐藏快捷菜单"},
 }
 
+// groupBotCommands adds the commands that only work in groups
+var groupBotCommands = append(append([]telegram.BotCommand{}, defaultBotCommands...),
+	telegram.BotCommand{Command: "group_tasks", Description: "本群的未完成任务"},
+)
+
 func main() {
 	// 1. Load Config
 	cfg, err := config.Load("config/default.yml")
@@ -111,9 +121,18 @@ func main() {
 		&models.UserNotionToken{},
 		&repository.Task{},
 		&repository.TaskAssignee{},
+		&repository.TaskWatcher{},
 		&repository.TaskContextSnapshot{},
 		&repository.TaskEvent{},
 		&repository.TaskComment{},
+		&repository.Label{},
+		&repository.TaskLabel{},
+		&repository.TaskDependency{},
+		&repository.TimeEntry{},
+		&repository.TaskTemplate{},
+		&repository.TaskAttachment{},
+		&repository.WorkflowStatus{},
+		&repository.CustomField{},
 	); err != nil {
 		logger.Fatal("failed to migrate models", zap.Error(err))
 	}
@@ -186,7 +205,9 @@ func main() {
 	// Groups Service
 	groupRepo := repository.NewGroupRepository(gormDB)
 	userGroupRepo := repository.NewUserGroupRepository(gormDB)
-	groupService := groupsvc.NewService(logger, groupRepo, notionService)
+	workflowRepo := repository.NewWorkflowRepository(gormDB)
+	fieldRepo := repository.NewCustomFieldRepository(gormDB)
+	groupService := groupsvc.NewService(logger, groupRepo, workflowRepo, fieldRepo, notionService)
 
 	// Telegram Client (Hoist for Notification Service)
 	tgClient := telegram.NewClient(cfg.Telegram.BotToken)
@@ -200,7 +221,7 @@ func main() {
 		logger.Warn("failed to set private telegram bot commands", zap.Error(err))
 	}
 	if err := tgClient.SetMyCommands(telegram.SetMyCommandsRequest{
-		Commands: defaultBotCommands,
+		Commands: groupBotCommands,
 		Scope:    &telegram.CommandScope{Type: telegram.CommandScopeAllGroupChats},
 	}); err != nil {
 		logger.Warn("failed to set group telegram bot commands", zap.Error(err))
@@ -210,21 +231,43 @@ func main() {
 	logger.Info("Initializing notification service",
 		zap.String("bot_name", cfg.Telegram.BotName),
 		zap.String("app_short_name", cfg.Telegram.AppShortName))
-	notificationService := notification.NewService(logger, taskRepo, userRepo, tgClient, cfg.Telegram.BotName, cfg.Telegram.AppShortName)
+	// Task action buttons are signed so callback data cannot target other
+	// tasks; without ENCRYPTION_KEY they are not offered
+	actionSigner := telegram.NewCallbackSigner(cfg.Encryption.Key)
+	notificationService := notification.NewService(logger, taskRepo, userRepo, tgClient, cfg.Telegram.BotName, cfg.Telegram.AppShortName, actionSigner)
 
 	// -- Task Service (Injects Notification Service)
 	pendingRepo := repository.NewPendingAssignmentRepository(gormDB)
+	labelRepo := repository.NewLabelRepository(gormDB)
+	depRepo := repository.NewDependencyRepository(gormDB)
+	timeRepo := repository.NewTimeEntryRepository(gormDB)
+	templateRepo := repository.NewTemplateRepository(gormDB)
+	attachmentRepo := repository.NewAttachmentRepository(gormDB)
 	taskService := task.NewService(task.ServiceConfig{
-		Logger:        logger,
-		Repo:          taskRepo,
-		UserRepo:      userRepo,
-		PendingRepo:   pendingRepo,
-		Notifier:      notificationService,
-		EncryptionKey: cfg.Encryption.Key,
+		Logger:             logger,
+		Repo:               taskRepo,
+		UserRepo:           userRepo,
+		PendingRepo:        pendingRepo,
+		LabelRepo:          labelRepo,
+		DepRepo:            depRepo,
+		TimeRepo:           timeRepo,
+		TemplateRepo:       templateRepo,
+		GroupRepo:          groupRepo,
+		UserGroupRepo:      userGroupRepo,
+		WorkflowRepo:       workflowRepo,
+		FieldRepo:          fieldRepo,
+		Notifier:           notificationService,
+		EncryptionKey:      cfg.Encryption.Key,
+		NotionTimeProperty: cfg.Notion.TimeProperty,
+		AttachmentRepo:     attachmentRepo,
+		Files:              tgClient,
+		PublicBaseURL:      cfg.HTTP.BaseURL,
+		FileLinkSecret:     cfg.Encryption.Key,
 	})
 
-	// -- Scheduler Service (Daily Digest)
-	schedulerService := scheduler.NewService(logger, userRepo, taskRepo, notificationService, tgClient)
+	// -- Scheduler Service (Daily Digest, Reminders, Recurring Tasks, Auto-Archive, Trash Purge)
+	trashRetention := time.Duration(cfg.Scheduler.TrashRetentionDays) * 24 * time.Hour
+	schedulerService := scheduler.NewService(logger, userRepo, taskRepo, taskService, taskService, notificationService, tgClient, trashRetention)
 	schedulerService.Start()
 	// defer schedulerService.Stop() // Optional: Stop on graceful shutdown
 
@@ -246,6 +289,11 @@ func main() {
 	groupGroup.POST("/refresh", groupHandler.RefreshGroups)
 	groupGroup.POST("/:group_id/bind", groupHandler.BindGroup)
 	groupGroup.POST("/:group_id/unbind", groupHandler.UnbindGroup)
+	groupGroup.PATCH("/:group_id/settings", groupHandler.UpdateSettings)
+	groupGroup.GET("/:group_id/statuses", groupHandler.ListStatuses)
+	groupGroup.PUT("/:group_id/statuses", groupHandler.UpdateStatuses)
+	groupGroup.GET("/:group_id/fields", groupHandler.ListCustomFields)
+	groupGroup.POST("/:group_id/fields/refresh", groupHandler.RefreshCustomFields)
 	groupGroup.POST("/:group_id/db/validate", groupHandler.ValidateGroupDatabase)
 	groupGroup.POST("/:group_id/db/init", groupHandler.InitGroupDatabase)
 
@@ -253,12 +301,50 @@ func main() {
 	taskGroup.Use(middleware.TelegramAuth(cfg.Telegram.BotToken, userRepo))
 	taskGroup.GET("", taskHandler.List)
 	taskGroup.GET("/counts", taskHandler.GetCounts)
+	taskGroup.GET("/search", taskHandler.Search)
+	taskGroup.GET("/trash", taskHandler.ListTrash)
 	taskGroup.GET("/:task_id", taskHandler.Get)
 	taskGroup.PATCH("/:task_id", taskHandler.Update)
 	taskGroup.DELETE("/:task_id", taskHandler.Delete)
+	taskGroup.POST("/:task_id/restore", taskHandler.Restore)
+	taskGroup.POST("/:task_id/archive", taskHandler.Archive)
+	taskGroup.POST("/:task_id/unarchive", taskHandler.Unarchive)
+	taskGroup.POST("/:task_id/assignees/:user_id", taskHandler.AddAssignee)
+	taskGroup.DELETE("/:task_id/assignees/:user_id", taskHandler.RemoveAssignee)
+	taskGroup.POST("/:task_id/watchers", taskHandler.Follow)
+	taskGroup.DELETE("/:task_id/watchers", taskHandler.Unfollow)
 	taskGroup.POST("", taskHandler.CreateWebTask)
+	taskGroup.POST("/batch", taskHandler.Batch)
 	taskGroup.GET("/:task_id/comments", taskHandler.ListComments)
 	taskGroup.POST("/:task_id/comments", taskHandler.CreateComment)
+	taskGroup.PATCH("/:task_id/comments/:comment_id", taskHandler.UpdateComment)
+	taskGroup.DELETE("/:task_id/comments/:comment_id", taskHandler.DeleteComment)
+	taskGroup.GET("/:task_id/subtasks", taskHandler.ListSubtasks)
+	taskGroup.POST("/:task_id/subtasks", taskHandler.CreateSubtask)
+	taskGroup.GET("/:task_id/dependencies", taskHandler.ListDependencies)
+	taskGroup.POST("/:task_id/dependencies", taskHandler.AddDependency)
+	taskGroup.DELETE("/:task_id/dependencies/:blocked_by_id", taskHandler.RemoveDependency)
+	taskGroup.GET("/:task_id/events", taskHandler.ListEvents)
+	taskGroup.POST("/:task_id/timer/start", taskHandler.StartTimer)
+	taskGroup.POST("/:task_id/timer/stop", taskHandler.StopTimer)
+	taskGroup.GET("/:task_id/time-entries", taskHandler.ListTimeEntries)
+	taskGroup.POST("/:task_id/time-entries", taskHandler.AddTimeEntry)
+	taskGroup.DELETE("/:task_id/time-entries/:entry_id", taskHandler.DeleteTimeEntry)
+	taskGroup.POST("/:task_id/move", taskHandler.MoveTask)
+	taskGroup.POST("/:task_id/clone", taskHandler.CloneTask)
+	taskGroup.GET("/:task_id/attachments", taskHandler.ListAttachments)
+	taskGroup.GET("/:task_id/attachments/:attachment_id/download", taskHandler.DownloadAttachment)
+
+	timeGroup := api.Group("/time")
+	timeGroup.Use(middleware.TelegramAuth(cfg.Telegram.BotToken, userRepo))
+	timeGroup.GET("/report", taskHandler.TimeReport)
+
+	templateGroup := api.Group("/templates")
+	templateGroup.Use(middleware.TelegramAuth(cfg.Telegram.BotToken, userRepo))
+	templateGroup.GET("", taskHandler.ListTemplates)
+	templateGroup.POST("", taskHandler.CreateTemplate)
+	templateGroup.PUT("/:template_id", taskHandler.UpdateTemplate)
+	templateGroup.DELETE("/:template_id", taskHandler.DeleteTemplate)
 
 	meGroup := api.Group("/me")
 	meGroup.Use(middleware.TelegramAuth(cfg.Telegram.BotToken, userRepo))
@@ -277,17 +363,23 @@ func main() {
 	// Calendar Feed (Public, no auth - token is the auth)
 	r.GET("/cal/:token/todo.ics", calHandler.GetCalendarFeed)
 
+	// Attachment links embedded in Notion pages (Public, the signature is the auth)
+	r.GET("/files/:attachment_id", taskHandler.DownloadSignedAttachment)
+
 	// Telegram Webhook (Keeping at root or moving to /api/webhook)
 	// Let's keep it at /webhook/telegram for now as it's typically configured once in BotFather
 	tgUpdateRepo := repository.NewTelegramUpdateRepository(gormDB)
 	taskCreator := task.NewCreator(task.CreatorConfig{
-		Logger:      logger,
-		TaskRepo:    taskRepo,
-		TaskService: taskService,
-		UpdateRepo:  tgUpdateRepo,
-		UserRepo:    userRepo,
-		GroupRepo:   groupRepo,
-		PendingRepo: pendingRepo,
+		Logger:       logger,
+		TaskRepo:     taskRepo,
+		TaskService:  taskService,
+		UpdateRepo:   tgUpdateRepo,
+		UserRepo:     userRepo,
+		GroupRepo:    groupRepo,
+		PendingRepo:  pendingRepo,
+		LabelRepo:    labelRepo,
+		TemplateRepo: templateRepo,
+		WorkflowRepo: workflowRepo,
 	})
 	deduplicator := telegram.NewDeduplicator(rdb)
 
@@ -296,9 +388,12 @@ func main() {
 		Deduplicator: deduplicator,
 		Repo:         tgUpdateRepo,
 		UserRepo:     userRepo,
+		GroupRoles:   userGroupRepo,
 		TaskCreator:  taskCreator,
 		TaskService:  taskService, // Injected TaskService
 		GroupService: groupService,
+		ChatLists:    telegram.NewChatListStore(rdb),
+		ActionSigner: actionSigner,
 		TgClient:     tgClient,
 		SecretToken:  os.Getenv("TELEGRAM_SECRET_TOKEN"),
 		BotUsername:  cfg.Telegram.BotName,
@@ -34,10 +34,16 @@ type Config struct {
 		ClientID     string `mapstructure:"client_id"`
 		ClientSecret string `mapstructure:"client_secret"`
 		RedirectURI  string `mapstructure:"redirect_uri"`
+		// TimeProperty is the number property receiving the hours logged on a task; empty disables it
+		TimeProperty string `mapstructure:"time_property"`
 	} `mapstructure:"notion"`
 	Encryption struct {
 		Key string `mapstructure:"key"`
 	} `mapstructure:"encryption"`
+	Scheduler struct {
+		// TrashRetentionDays is how long deleted tasks stay restorable; 0 disables the purge
+		TrashRetentionDays int `mapstructure:"trash_retention_days"`
+	} `mapstructure:"scheduler"`
 }
 
 func Load(path string) (*Config, error) {
@@ -46,6 +52,7 @@ func Load(path string) (*Config, error) {
 	// Defaults
 	v.SetDefault("app_env", "development")
 	v.SetDefault("http.addr", ":8080")
+	v.SetDefault("scheduler.trash_retention_days", 30)
 
 	// Env vars
 	v.AutomaticEnv()
@@ -68,7 +75,9 @@ func Load(path string) (*Config, error) {
 	_ = v.BindEnv("notion.client_id", "NOTION_CLIENT_ID")
 	_ = v.BindEnv("notion.client_secret", "NOTION_CLIENT_SECRET")
 	_ = v.BindEnv("notion.redirect_uri", "NOTION_REDIRECT_URI")
+	_ = v.BindEnv("notion.time_property", "NOTION_TIME_PROPERTY")
 	_ = v.BindEnv("encryption.key", "ENCRYPTION_KEY")
+	_ = v.BindEnv("scheduler.trash_retention_days", "TRASH_RETENTION_DAYS")
 
 	// Config file
 	if path != "" {
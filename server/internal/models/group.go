@@ -15,8 +15,9 @@ type Group struct {
 	Title             string      `json:"title"`
 	Status            GroupStatus `json:"status" gorm:"default:'Unbound'"`
 	DatabaseID        *string     `json:"database_id"`
-	NotionAccessToken string      `json:"-"`             // Encrypted
-	DatabaseName      string      `json:"database_name"` // Cached name for UI
+	NotionAccessToken string      `json:"-"`                                  // Encrypted
+	DatabaseName      string      `json:"database_name"`                      // Cached name for UI
+	AutoArchiveDays   int         `json:"auto_archive_days" gorm:"default:0"` // Archive tasks Done for this many days; 0 disables
 	CreatedAt         time.Time   `json:"created_at"`
 	UpdatedAt         time.Time   `json:"updated_at"`
 }
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
 // User represents a Telegram user in the system
@@ -14,7 +16,7 @@ type User struct {
 	Name              string         `gorm:"type:text;not null" json:"name"`
 	PhotoURL          string         `gorm:"type:text" json:"photo_url,omitempty"`
 	Avatar            string         `gorm:"type:text" json:"avatar,omitempty"` // Deprecated, use PhotoURL
-	Timezone          string         `gorm:"type:text;not null;default:'UTC+0'" json:"timezone"`
+	Timezone          string         `gorm:"type:text;not null;default:'UTC'" json:"timezone"`
 	DefaultDatabaseID *string        `gorm:"type:text" json:"default_database_id,omitempty"`
 	NotionConnected   bool           `gorm:"not null;default:false" json:"notion_connected"`
 	CalendarToken     *string        `gorm:"type:text;uniqueIndex:uni_users_calendar_token" json:"calendar_token,omitempty"`
@@ -28,6 +30,11 @@ func (User) TableName() string {
 	return "users"
 }
 
+// Location returns the user's time zone, UTC when unset or unknown
+func (u *User) Location() *time.Location {
+	return timezone.Load(u.Timezone)
+}
+
 // UserNotionToken represents encrypted Notion OAuth tokens for a user
 type UserNotionToken struct {
 	ID              string         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -38,6 +45,7 @@ type UserNotionToken struct {
 	ExpiresAt       *time.Time     `json:"expires_at,omitempty"`
 	WorkspaceID     string         `gorm:"type:text;not null" json:"workspace_id"`
 	WorkspaceName   string         `gorm:"type:text;not null" json:"workspace_name"`
+	NotionUserID    string         `gorm:"type:text" json:"notion_user_id,omitempty"` // Notion user who authorized the integration
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
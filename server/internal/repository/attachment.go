@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AttachmentKind is the Telegram media type of an attachment
+type AttachmentKind string
+
+const (
+	AttachmentKindPhoto    AttachmentKind = "photo"
+	AttachmentKindDocument AttachmentKind = "document"
+	AttachmentKindVoice    AttachmentKind = "voice"
+	AttachmentKindVideo    AttachmentKind = "video"
+	AttachmentKindAudio    AttachmentKind = "audio"
+)
+
+// TaskAttachment represents the task_attachments table: a Telegram photo,
+// document or voice message kept with a task. The file stays on Telegram and
+// is fetched by FileID, which is only valid for this bot.
+type TaskAttachment struct {
+	ID           string         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TaskID       string         `gorm:"type:uuid;not null;index" json:"task_id"`
+	Kind         AttachmentKind `gorm:"type:text;not null" json:"kind"`
+	FileID       string         `gorm:"type:text;not null" json:"file_id"`
+	FileUniqueID string         `gorm:"type:text" json:"file_unique_id"`
+	FileName     string         `gorm:"type:text" json:"file_name"`
+	MimeType     string         `gorm:"type:text" json:"mime_type"`
+	FileSize     int64          `gorm:"not null;default:0" json:"file_size"`
+	Width        int            `gorm:"not null;default:0" json:"width"`    // Photos and videos
+	Height       int            `gorm:"not null;default:0" json:"height"`   // Photos and videos
+	Duration     int            `gorm:"not null;default:0" json:"duration"` // Seconds, for voice, audio and video
+	Caption      string         `gorm:"type:text" json:"caption"`
+	TgMessageID  int64          `gorm:"type:bigint" json:"tg_message_id"`
+	UploadedBy   *string        `gorm:"type:uuid" json:"uploaded_by"`
+	CreatedAt    time.Time      `gorm:"default:now()" json:"created_at"`
+}
+
+// AttachmentRepository handles database operations for task attachments
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *TaskAttachment) error
+	// GetByID returns nil if the attachment does not exist
+	GetByID(ctx context.Context, id string) (*TaskAttachment, error)
+	ListByTask(ctx context.Context, taskID string) ([]TaskAttachment, error)
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+// NewAttachmentRepository creates a new attachment repository
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+// Create inserts an attachment
+func (r *attachmentRepository) Create(ctx context.Context, attachment *TaskAttachment) error {
+	return r.db.WithContext(ctx).Create(attachment).Error
+}
+
+// GetByID retrieves an attachment by ID
+func (r *attachmentRepository) GetByID(ctx context.Context, id string) (*TaskAttachment, error) {
+	var attachment TaskAttachment
+	if err := r.db.WithContext(ctx).First(&attachment, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// ListByTask lists the attachments of a task in the order they were added
+func (r *attachmentRepository) ListByTask(ctx context.Context, taskID string) ([]TaskAttachment, error) {
+	var attachments []TaskAttachment
+	err := r.db.WithContext(ctx).
+		Where("task_id = ?", taskID).
+		Order("created_at ASC").
+		Find(&attachments).Error
+	return attachments, err
+}
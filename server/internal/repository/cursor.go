@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCursor is returned when a list cursor cannot be decoded or does
+// not belong to the requested view and sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sortKeyKind identifies a column of a keyset ordering and how its cursor
+// value is encoded
+type sortKeyKind string
+
+const (
+	keySelfCreated sortKeyKind = "self"     // creator is the listing user (assigned view)
+	keyPriority    sortKeyKind = "priority" // priorityOrderExpr
+	keyDueIsNull   sortKeyKind = "due_null" // keeps tasks without due date last
+	keyDueAt       sortKeyKind = "due"
+	keyCreatedAt   sortKeyKind = "created"
+	keyUpdatedAt   sortKeyKind = "updated"
+	keyTitle       sortKeyKind = "title"
+	keyID          sortKeyKind = "id"
+)
+
+type sortKey struct {
+	kind sortKeyKind
+	desc bool
+}
+
+// taskCursor is the decoded form of the opaque next_cursor
+type taskCursor struct {
+	View   TaskView  `json:"w"`
+	Sort   TaskSort  `json:"s"`
+	Values []*string `json:"v"` // One per sort key; nil for NULL
+}
+
+// sortKeys returns the full keyset ordering for a view and sort. The task ID
+// is always the final tie-breaker so that the ordering is total.
+func sortKeys(view TaskView, sort TaskSort) []sortKey {
+	var keys []sortKey
+	if view == TaskViewAssigned {
+		// Self-created tasks first for grouped display
+		keys = append(keys, sortKey{kind: keySelfCreated, desc: true})
+	}
+
+	switch sort {
+	case TaskSortPriority:
+		keys = append(keys, sortKey{kind: keyPriority}, sortKey{kind: keyCreatedAt, desc: true}, sortKey{kind: keyID, desc: true})
+	case TaskSortDue:
+		keys = append(keys, sortKey{kind: keyDueIsNull}, sortKey{kind: keyDueAt}, sortKey{kind: keyID})
+	case TaskSortUpdated:
+		keys = append(keys, sortKey{kind: keyUpdatedAt, desc: true}, sortKey{kind: keyID, desc: true})
+	case TaskSortTitle:
+		keys = append(keys, sortKey{kind: keyTitle}, sortKey{kind: keyID})
+	default: // created
+		keys = append(keys, sortKey{kind: keyCreatedAt, desc: true}, sortKey{kind: keyID, desc: true})
+	}
+	return keys
+}
+
+// expr returns the SQL expression of a sort key
+func (k sortKey) expr(userID string) clause.Expr {
+	switch k.kind {
+	case keySelfCreated:
+		return clause.Expr{SQL: "(CASE WHEN tasks.creator_id = ? THEN 1 ELSE 0 END)", Vars: []interface{}{userID}}
+	case keyPriority:
+		return clause.Expr{SQL: "(" + priorityOrderExpr + ")"}
+	case keyDueIsNull:
+		return clause.Expr{SQL: "(CASE WHEN tasks.due_at IS NULL THEN 1 ELSE 0 END)"}
+	case keyDueAt:
+		return clause.Expr{SQL: "tasks.due_at"}
+	case keyCreatedAt:
+		return clause.Expr{SQL: "tasks.created_at"}
+	case keyUpdatedAt:
+		return clause.Expr{SQL: "tasks.updated_at"}
+	case keyTitle:
+		return clause.Expr{SQL: "tasks.title"}
+	default:
+		return clause.Expr{SQL: "tasks.id"}
+	}
+}
+
+// value extracts the cursor value of a sort key from a task
+func (k sortKey) value(userID string, task *Task) *string {
+	var v string
+	switch k.kind {
+	case keySelfCreated:
+		v = "0"
+		if task.CreatorID != nil && *task.CreatorID == userID {
+			v = "1"
+		}
+	case keyPriority:
+		v = strconv.Itoa(priorityRank(task.Priority))
+	case keyDueIsNull:
+		v = "0"
+		if task.DueAt == nil {
+			v = "1"
+		}
+	case keyDueAt:
+		if task.DueAt == nil {
+			return nil
+		}
+		v = task.DueAt.UTC().Format(time.RFC3339Nano)
+	case keyCreatedAt:
+		v = task.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case keyUpdatedAt:
+		v = task.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case keyTitle:
+		v = task.Title
+	default:
+		v = task.ID
+	}
+	return &v
+}
+
+// parse converts a cursor value back to the type of the sort key
+func (k sortKey) parse(v string) (interface{}, error) {
+	switch k.kind {
+	case keySelfCreated, keyPriority, keyDueIsNull:
+		return strconv.Atoi(v)
+	case keyDueAt, keyCreatedAt, keyUpdatedAt:
+		return time.Parse(time.RFC3339Nano, v)
+	default:
+		return v, nil
+	}
+}
+
+func priorityRank(p TaskPriority) int {
+	switch p {
+	case TaskPriorityHigh:
+		return 0
+	case TaskPriorityMedium, "":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// EncodeTaskCursor returns the opaque cursor pointing after task in a listing
+func EncodeTaskCursor(userID string, filter TaskListFilter, task *Task) string {
+	keys := sortKeys(filter.View, filter.Sort)
+	c := taskCursor{View: filter.View, Sort: filter.Sort, Values: make([]*string, 0, len(keys))}
+	for _, k := range keys {
+		c.Values = append(c.Values, k.value(userID, task))
+	}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// applyOrder adds the keyset ORDER BY for the filter to query
+func applyOrder(query *gorm.DB, userID string, filter TaskListFilter) *gorm.DB {
+	for _, k := range sortKeys(filter.View, filter.Sort) {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: orderSQL(k, userID), Raw: true}, Desc: k.desc})
+	}
+	return query
+}
+
+// orderSQL inlines the user ID (a UUID from the auth middleware) since ORDER BY
+// columns cannot carry bind variables
+func orderSQL(k sortKey, userID string) string {
+	e := k.expr(userID)
+	if len(e.Vars) == 0 {
+		return e.SQL
+	}
+	return strings.Replace(e.SQL, "?", "'"+strings.ReplaceAll(userID, "'", "''")+"'", 1)
+}
+
+// applyCursor restricts query to rows strictly after the cursor position
+func applyCursor(query *gorm.DB, userID string, filter TaskListFilter) (*gorm.DB, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c taskCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	keys := sortKeys(filter.View, filter.Sort)
+	if c.View != filter.View || c.Sort != filter.Sort || len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		if c.Values[i] == nil {
+			continue
+		}
+		v, err := k.parse(*c.Values[i])
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = v
+	}
+
+	// (k0 > v0) OR (k0 = v0 AND k1 > v1) OR ... with ">" flipped for DESC keys.
+	// A NULL value only occurs for due_at, whose rows are all grouped by the
+	// preceding due_null key, so NULL compares equal and never "after".
+	var (
+		ors  []string
+		vars []interface{}
+	)
+	for i, k := range keys {
+		var (
+			parts    []string
+			partVars []interface{}
+		)
+		for j := 0; j < i; j++ {
+			e := keys[j].expr(userID)
+			if values[j] == nil {
+				parts = append(parts, e.SQL+" IS NULL")
+				partVars = append(partVars, e.Vars...)
+				continue
+			}
+			parts = append(parts, e.SQL+" = ?")
+			partVars = append(partVars, append(append([]interface{}{}, e.Vars...), values[j])...)
+		}
+		if values[i] == nil {
+			continue
+		}
+		op := " > ?"
+		if k.desc {
+			op = " < ?"
+		}
+		e := k.expr(userID)
+		parts = append(parts, e.SQL+op)
+		partVars = append(partVars, append(append([]interface{}{}, e.Vars...), values[i])...)
+
+		ors = append(ors, "("+strings.Join(parts, " AND ")+")")
+		vars = append(vars, partVars...)
+	}
+	if len(ors) == 0 {
+		return query.Where("1 = 0"), nil
+	}
+	return query.Where("("+strings.Join(ors, " OR ")+")", vars...), nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// CustomFieldType is the type of the Notion property behind a custom field,
+// named as in the Notion API
+type CustomFieldType string
+
+const (
+	CustomFieldText        CustomFieldType = "rich_text"
+	CustomFieldNumber      CustomFieldType = "number"
+	CustomFieldSelect      CustomFieldType = "select"
+	CustomFieldMultiSelect CustomFieldType = "multi_select"
+	CustomFieldDate        CustomFieldType = "date"
+	CustomFieldCheckbox    CustomFieldType = "checkbox"
+	CustomFieldURL         CustomFieldType = "url"
+)
+
+// CustomField represents the group_custom_fields table: an extra column of
+// the group's Notion database, discovered from its properties. Values live in
+// Task.CustomFields under the field name.
+type CustomField struct {
+	ID       string          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	GroupID  string          `gorm:"type:text;not null;index" json:"group_id"`
+	Name     string          `gorm:"type:text;not null" json:"name"` // Notion property name
+	Type     CustomFieldType `gorm:"type:text;not null" json:"type"`
+	Position int             `gorm:"not null;default:0" json:"position"`
+	// Options of select and multi-select fields, as defined in Notion
+	Options   datatypes.JSONSlice[string] `gorm:"type:jsonb;not null;default:'[]'" json:"options"`
+	CreatedAt time.Time                   `gorm:"default:now()" json:"created_at"`
+	UpdatedAt time.Time                   `gorm:"default:now()" json:"updated_at"`
+}
+
+// TableName overrides the table name used by CustomField
+func (CustomField) TableName() string {
+	return "group_custom_fields"
+}
+
+// CustomFields is the field schema of a group, in display order
+type CustomFields []CustomField
+
+// Find returns the field called name; property names are case-sensitive in Notion
+func (f CustomFields) Find(name string) (CustomField, bool) {
+	name = strings.TrimSpace(name)
+	for _, field := range f {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return CustomField{}, false
+}
+
+// CustomFieldRepository handles database operations for group field schemas
+type CustomFieldRepository interface {
+	// ListByGroup returns the group's fields in order
+	ListByGroup(ctx context.Context, groupID string) (CustomFields, error)
+	// ListByDatabase returns the fields of the group bound to a Notion database
+	ListByDatabase(ctx context.Context, databaseID string) (CustomFields, error)
+	// Replace stores fields as the group's schema. Task values of removed
+	// fields are kept, so they come back if the column is restored.
+	Replace(ctx context.Context, groupID string, fields CustomFields) error
+}
+
+type customFieldRepository struct {
+	db *gorm.DB
+}
+
+// NewCustomFieldRepository creates a new custom field repository
+func NewCustomFieldRepository(db *gorm.DB) CustomFieldRepository {
+	return &customFieldRepository{db: db}
+}
+
+func (r *customFieldRepository) ListByGroup(ctx context.Context, groupID string) (CustomFields, error) {
+	var fields CustomFields
+	err := r.db.WithContext(ctx).
+		Where("group_id = ?", groupID).
+		Order("position ASC").
+		Find(&fields).Error
+	return fields, err
+}
+
+func (r *customFieldRepository) ListByDatabase(ctx context.Context, databaseID string) (CustomFields, error) {
+	var fields CustomFields
+	err := r.db.WithContext(ctx).
+		Where("group_id = (SELECT id FROM groups WHERE database_id = ? ORDER BY updated_at DESC LIMIT 1)", databaseID).
+		Order("position ASC").
+		Find(&fields).Error
+	return fields, err
+}
+
+func (r *customFieldRepository) Replace(ctx context.Context, groupID string, fields CustomFields) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", groupID).Delete(&CustomField{}).Error; err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		for i := range fields {
+			fields[i].ID = uuid.NewString()
+			fields[i].GroupID = groupID
+			fields[i].Position = i
+			if fields[i].Options == nil {
+				fields[i].Options = datatypes.NewJSONSlice([]string{})
+			}
+		}
+		return tx.Create(&fields).Error
+	})
+}
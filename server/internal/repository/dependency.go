@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TaskDependency represents the task_dependencies table:
+// TaskID cannot start before BlockedByID is Done
+type TaskDependency struct {
+	TaskID      string    `gorm:"type:uuid;primary_key" json:"task_id"`
+	BlockedByID string    `gorm:"type:uuid;primary_key" json:"blocked_by_id"`
+	CreatedBy   *string   `gorm:"type:uuid" json:"created_by"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+// DependencyRepository handles database operations for task dependencies
+type DependencyRepository interface {
+	Add(ctx context.Context, dep *TaskDependency) error
+	Remove(ctx context.Context, taskID, blockedByID string) error
+	// ListBlockerIDs returns the IDs of the tasks blocking any of the given tasks
+	ListBlockerIDs(ctx context.Context, taskIDs []string) ([]string, error)
+	// ListBlockers returns the tasks blocking taskID
+	ListBlockers(ctx context.Context, taskID string) ([]Task, error)
+	// ListBlocked returns the tasks blocked by blockerID
+	ListBlocked(ctx context.Context, blockerID string) ([]Task, error)
+	// CountOpenBlockers counts blockers of taskID that are not Done
+	CountOpenBlockers(ctx context.Context, taskID string) (int64, error)
+}
+
+type dependencyRepository struct {
+	db *gorm.DB
+}
+
+// NewDependencyRepository creates a new dependency repository
+func NewDependencyRepository(db *gorm.DB) DependencyRepository {
+	return &dependencyRepository{db: db}
+}
+
+// Add creates a dependency edge, ignoring duplicates
+func (r *dependencyRepository) Add(ctx context.Context, dep *TaskDependency) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(dep).Error
+}
+
+// Remove deletes a dependency edge
+func (r *dependencyRepository) Remove(ctx context.Context, taskID, blockedByID string) error {
+	return r.db.WithContext(ctx).
+		Where("task_id = ? AND blocked_by_id = ?", taskID, blockedByID).
+		Delete(&TaskDependency{}).Error
+}
+
+// ListBlockerIDs returns the IDs of the tasks blocking any of the given tasks
+func (r *dependencyRepository) ListBlockerIDs(ctx context.Context, taskIDs []string) ([]string, error) {
+	if len(taskIDs) == 0 {
+		return nil, nil
+	}
+	var ids []string
+	err := r.db.WithContext(ctx).Model(&TaskDependency{}).
+		Where("task_id IN ?", taskIDs).
+		Distinct().
+		Pluck("blocked_by_id", &ids).Error
+	return ids, err
+}
+
+// ListBlockers returns the tasks blocking taskID
+func (r *dependencyRepository) ListBlockers(ctx context.Context, taskID string) ([]Task, error) {
+	var tasks []Task
+	err := r.db.WithContext(ctx).
+		Preload("Assignees").
+		Joins("JOIN task_dependencies td ON td.blocked_by_id = tasks.id").
+		Where("td.task_id = ?", taskID).
+		Order("tasks.created_at ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// ListBlocked returns the tasks blocked by blockerID
+func (r *dependencyRepository) ListBlocked(ctx context.Context, blockerID string) ([]Task, error) {
+	var tasks []Task
+	err := r.db.WithContext(ctx).
+		Preload("Assignees").
+		Joins("JOIN task_dependencies td ON td.task_id = tasks.id").
+		Where("td.blocked_by_id = ?", blockerID).
+		Order("tasks.created_at ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// CountOpenBlockers counts blockers of taskID that are not Done
+func (r *dependencyRepository) CountOpenBlockers(ctx context.Context, taskID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Task{}).
+		Joins("JOIN task_dependencies td ON td.blocked_by_id = tasks.id").
+		Where("td.task_id = ? AND tasks.status_category <> ?", taskID, StatusCategoryDone).
+		Count(&count).Error
+	return count, err
+}
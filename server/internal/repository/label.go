@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Label represents the labels table (e.g. #bug, #frontend)
+type Label struct {
+	ID        string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name      string    `gorm:"type:text;not null;uniqueIndex" json:"name"` // Lower-cased, without '#'
+	CreatedAt time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+// TaskLabel represents the task_labels join table
+type TaskLabel struct {
+	TaskID  string `gorm:"type:uuid;primary_key"`
+	LabelID string `gorm:"type:uuid;primary_key"`
+}
+
+// NormalizeLabel trims the leading '#' and lower-cases a label name
+func NormalizeLabel(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(name), "#")))
+}
+
+// LabelRepository handles database operations for labels
+type LabelRepository interface {
+	FindOrCreate(ctx context.Context, names []string) ([]Label, error)
+	List(ctx context.Context) ([]Label, error)
+	ReplaceTaskLabels(ctx context.Context, taskID string, labels []Label) error
+}
+
+type labelRepository struct {
+	db *gorm.DB
+}
+
+// NewLabelRepository creates a new label repository
+func NewLabelRepository(db *gorm.DB) LabelRepository {
+	return &labelRepository{db: db}
+}
+
+// FindOrCreate returns labels for the given names, creating missing ones
+func (r *labelRepository) FindOrCreate(ctx context.Context, names []string) ([]Label, error) {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, name := range names {
+		n := NormalizeLabel(name)
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		normalized = append(normalized, n)
+	}
+	if len(normalized) == 0 {
+		return []Label{}, nil
+	}
+
+	rows := make([]Label, 0, len(normalized))
+	for _, n := range normalized {
+		rows = append(rows, Label{Name: n})
+	}
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+		Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var labels []Label
+	if err := r.db.WithContext(ctx).Where("name IN ?", normalized).Order("name ASC").Find(&labels).Error; err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// List returns all labels ordered by name
+func (r *labelRepository) List(ctx context.Context) ([]Label, error) {
+	var labels []Label
+	if err := r.db.WithContext(ctx).Order("name ASC").Find(&labels).Error; err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// ReplaceTaskLabels sets the labels of a task, removing any others
+func (r *labelRepository) ReplaceTaskLabels(ctx context.Context, taskID string, labels []Label) error {
+	var task Task
+	task.ID = taskID
+	return r.db.WithContext(ctx).Model(&task).Association("Labels").Replace(labels)
+}
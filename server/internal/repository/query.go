@@ -0,0 +1,396 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"github.com/layababa/tg_todo/server/pkg/timezone"
+)
+
+// maxQueryTokens bounds the number of tokens in a filter expression
+const maxQueryTokens = 20
+
+// QueryError describes an invalid filter expression. Pos is the 0-based
+// character offset of Token in the expression.
+type QueryError struct {
+	Token   string `json:"token"`
+	Pos     int    `json:"position"`
+	Message string `json:"message"`
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s at position %d: %q", e.Message, e.Pos, e.Token)
+}
+
+// TaskQuery is a compiled filter expression such as
+//
+//	status:"In Progress" due:<7d group:"Project Alpha" assignee:@alice -label:wontfix
+//
+// Terms are ANDed; a leading "-" negates a term. Comma separated values of
+// status, priority and label match any of them. Bare words match the title
+// or description.
+type TaskQuery struct {
+	conds []queryCond
+}
+
+// queryCond is a single SQL condition. Vars equal to queryMe are replaced by
+// the listing user's ID when the query is applied.
+type queryCond struct {
+	sql    string
+	vars   []interface{}
+	negate bool
+}
+
+type queryMeVar struct{}
+
+var queryMe = queryMeVar{}
+
+// queryToken is a raw term of the expression
+type queryToken struct {
+	raw    string
+	pos    int
+	negate bool
+	key    string // Lower-cased; empty for free text
+	value  string // Unquoted
+}
+
+// ParseTaskQuery compiles a filter expression. Relative dates (due:<7d,
+// due:today) are resolved against now in now's location.
+func ParseTaskQuery(q string, now time.Time) (*TaskQuery, error) {
+	tokens, err := tokenizeQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) > maxQueryTokens {
+		t := tokens[maxQueryTokens]
+		return nil, &QueryError{Token: t.raw, Pos: t.pos, Message: fmt.Sprintf("too many terms (max %d)", maxQueryTokens)}
+	}
+
+	query := &TaskQuery{}
+	for _, t := range tokens {
+		cond, err := compileQueryToken(t, now)
+		if err != nil {
+			return nil, err
+		}
+		cond.negate = t.negate
+		query.conds = append(query.conds, cond)
+	}
+	return query, nil
+}
+
+// IsEmpty reports whether the query has no conditions
+func (q *TaskQuery) IsEmpty() bool {
+	return q == nil || len(q.conds) == 0
+}
+
+// apply adds the query's conditions to a tasks query
+func (q *TaskQuery) apply(db *gorm.DB, userID string) *gorm.DB {
+	if q == nil {
+		return db
+	}
+	for _, c := range q.conds {
+		vars := make([]interface{}, len(c.vars))
+		for i, v := range c.vars {
+			if v == queryMe {
+				v = userID
+			}
+			vars[i] = v
+		}
+		sql := "(" + c.sql + ")"
+		if c.negate {
+			// COALESCE keeps rows where the condition is NULL (e.g. no group)
+			sql = "NOT COALESCE(" + sql + ", FALSE)"
+		}
+		db = db.Where(sql, vars...)
+	}
+	return db
+}
+
+// tokenizeQuery splits an expression on whitespace outside double quotes
+func tokenizeQuery(q string) ([]queryToken, error) {
+	runes := []rune(q)
+	var tokens []queryToken
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		inQuote := false
+		quoteAt := 0
+		var sb strings.Builder
+		for ; i < len(runes); i++ {
+			r := runes[i]
+			if r == '"' {
+				inQuote = !inQuote
+				quoteAt = i
+				continue
+			}
+			if !inQuote && unicode.IsSpace(r) {
+				break
+			}
+			sb.WriteRune(r)
+		}
+		raw := string(runes[start:i])
+		if inQuote {
+			return nil, &QueryError{Token: string(runes[quoteAt:i]), Pos: quoteAt, Message: "unterminated quote"}
+		}
+
+		t := queryToken{raw: raw, pos: start, value: sb.String()}
+		// Quotes are stripped from the value, so locate key/negation on the raw text
+		body := raw
+		if strings.HasPrefix(body, "-") && len(body) > 1 {
+			t.negate = true
+			body = body[1:]
+			t.value = strings.TrimPrefix(t.value, "-")
+		}
+		if idx := strings.Index(body, ":"); idx > 0 && !strings.Contains(body[:idx], `"`) {
+			t.key = strings.ToLower(body[:idx])
+			t.value = t.value[idx+1:]
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+func compileQueryToken(t queryToken, now time.Time) (queryCond, error) {
+	fail := func(format string, args ...interface{}) (queryCond, error) {
+		return queryCond{}, &QueryError{Token: t.raw, Pos: t.pos, Message: fmt.Sprintf(format, args...)}
+	}
+	if strings.TrimSpace(t.value) == "" {
+		if t.key == "" {
+			return fail("empty term")
+		}
+		return fail("missing value for %s", t.key)
+	}
+
+	switch t.key {
+	case "":
+		p := likePattern(strings.ToLower(t.value))
+		return queryCond{sql: searchTitleCond + " OR " + searchDescriptionCond, vars: []interface{}{p, p}}, nil
+
+	case "status", "is":
+		var categories []StatusCategory
+		var names []string
+		for _, v := range splitQueryValues(t.value) {
+			if c, ok := parseQueryStatus(v); ok {
+				categories = append(categories, c)
+				continue
+			}
+			// Anything else is the name of a group's own status, e.g. "Review"
+			names = append(names, strings.ToLower(v))
+		}
+		switch {
+		case len(names) == 0:
+			return queryCond{sql: "tasks.status_category IN ?", vars: []interface{}{categories}}, nil
+		case len(categories) == 0:
+			return queryCond{sql: "LOWER(tasks.status) IN ?", vars: []interface{}{names}}, nil
+		}
+		return queryCond{sql: "tasks.status_category IN ? OR LOWER(tasks.status) IN ?", vars: []interface{}{categories, names}}, nil
+
+	case "priority":
+		var priorities []TaskPriority
+		for _, v := range splitQueryValues(t.value) {
+			p := TaskPriority(strings.ToUpper(v[:1]) + strings.ToLower(v[1:]))
+			if !p.IsValid() {
+				return fail("unknown priority %q", v)
+			}
+			priorities = append(priorities, p)
+		}
+		return queryCond{sql: "tasks.priority IN ?", vars: []interface{}{priorities}}, nil
+
+	case "label", "tag":
+		var names []string
+		for _, v := range splitQueryValues(t.value) {
+			if n := NormalizeLabel(v); n != "" {
+				names = append(names, n)
+			}
+		}
+		if len(names) == 0 {
+			return fail("invalid label")
+		}
+		return queryCond{sql: "tasks.id IN (SELECT tl.task_id FROM task_labels tl JOIN labels l ON l.id = tl.label_id WHERE l.name IN ?)", vars: []interface{}{names}}, nil
+
+	case "group":
+		if strings.EqualFold(t.value, "none") {
+			return queryCond{sql: "tasks.group_id IS NULL"}, nil
+		}
+		return queryCond{
+			sql:  "tasks.group_id IN (SELECT g.id FROM groups g WHERE g.id = ? OR LOWER(g.title) = ?)",
+			vars: []interface{}{t.value, strings.ToLower(t.value)},
+		}, nil
+
+	case "assignee":
+		switch who := strings.ToLower(strings.TrimPrefix(t.value, "@")); who {
+		case "none":
+			return queryCond{sql: "NOT EXISTS (SELECT 1 FROM task_assignees qa WHERE qa.task_id = tasks.id)"}, nil
+		case "me":
+			return queryCond{sql: "EXISTS (SELECT 1 FROM task_assignees qa WHERE qa.task_id = tasks.id AND qa.user_id = ?)", vars: []interface{}{queryMe}}, nil
+		default:
+			return queryCond{
+				sql:  "EXISTS (SELECT 1 FROM task_assignees qa JOIN users qu ON qu.id = qa.user_id WHERE qa.task_id = tasks.id AND LOWER(qu.tg_username) = ?)",
+				vars: []interface{}{who},
+			}, nil
+		}
+
+	case "creator", "author":
+		if who := strings.ToLower(strings.TrimPrefix(t.value, "@")); who != "me" {
+			return queryCond{sql: "tasks.creator_id IN (SELECT qu.id FROM users qu WHERE LOWER(qu.tg_username) = ?)", vars: []interface{}{who}}, nil
+		}
+		return queryCond{sql: "tasks.creator_id = ?", vars: []interface{}{queryMe}}, nil
+
+	case "due":
+		cond, msg := compileDueQuery(t.value, now)
+		if msg != "" {
+			return fail("%s", msg)
+		}
+		return cond, nil
+	}
+
+	return fail("unknown filter %q", t.key)
+}
+
+// compileDueQuery handles due:today|tomorrow|overdue|none, due:<7d, due:>=2d,
+// due:2025-01-31 and due:<2025-01-31. Durations use h, d or w units. It
+// returns an error message instead of an error so the caller can attach the
+// token position.
+func compileDueQuery(value string, now time.Time) (queryCond, string) {
+	switch strings.ToLower(value) {
+	case "none":
+		return queryCond{sql: "tasks.due_at IS NULL"}, ""
+	case "overdue":
+		// All-day tasks are overdue once their date has passed
+		return queryCond{
+			sql:  "((tasks.all_day = true AND tasks.due_at < ?) OR (tasks.all_day = false AND tasks.due_at < ?)) AND tasks.status_category <> ?",
+			vars: []interface{}{timezone.Date(now, now.Location()), now.UTC(), StatusCategoryDone},
+		}, ""
+	case "today", "tomorrow":
+		day := startOfDay(now)
+		if strings.EqualFold(value, "tomorrow") {
+			day = day.AddDate(0, 0, 1)
+		}
+		return dueBetween(day, day.AddDate(0, 0, 1)), ""
+	}
+
+	op := ""
+	for _, candidate := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(value, candidate) {
+			op = candidate
+			break
+		}
+	}
+	operand := strings.TrimPrefix(value, op)
+
+	if date, err := time.ParseInLocation("2006-01-02", operand, now.Location()); err == nil {
+		next := date.AddDate(0, 0, 1)
+		switch op {
+		case "", "=":
+			return dueBetween(date, next), ""
+		case "<":
+			return dueBefore(date), ""
+		case "<=":
+			return dueBefore(next), ""
+		case ">":
+			return dueFrom(next), ""
+		default:
+			return dueFrom(date), ""
+		}
+	}
+
+	d, ok := parseQueryDuration(operand)
+	if !ok {
+		return queryCond{}, "invalid due value, expected today, tomorrow, overdue, none, YYYY-MM-DD or <7d"
+	}
+	at := now.Add(d)
+	switch op {
+	case "", "<", "<=":
+		// due:7d and due:<7d mean "due within the next 7 days"
+		return queryCond{sql: "tasks.due_at >= ? AND tasks.due_at <= ?", vars: []interface{}{now, at}}, ""
+	case ">", ">=":
+		return queryCond{sql: "tasks.due_at >= ?", vars: []interface{}{at}}, ""
+	default:
+		return queryCond{}, "use due:YYYY-MM-DD for an exact date"
+	}
+}
+
+// dueBetween matches tasks due between two local midnights. All-day tasks
+// are compared by calendar date, timed tasks by instant.
+func dueBetween(from, to time.Time) queryCond {
+	return queryCond{
+		sql:  "((tasks.all_day = true AND tasks.due_at >= ? AND tasks.due_at < ?) OR (tasks.all_day = false AND tasks.due_at >= ? AND tasks.due_at < ?))",
+		vars: []interface{}{timezone.Date(from, from.Location()), timezone.Date(to, to.Location()), from.UTC(), to.UTC()},
+	}
+}
+
+// dueBefore matches tasks due before a local midnight
+func dueBefore(at time.Time) queryCond {
+	return queryCond{
+		sql:  "((tasks.all_day = true AND tasks.due_at < ?) OR (tasks.all_day = false AND tasks.due_at < ?))",
+		vars: []interface{}{timezone.Date(at, at.Location()), at.UTC()},
+	}
+}
+
+// dueFrom matches tasks due at or after a local midnight
+func dueFrom(at time.Time) queryCond {
+	return queryCond{
+		sql:  "((tasks.all_day = true AND tasks.due_at >= ?) OR (tasks.all_day = false AND tasks.due_at >= ?))",
+		vars: []interface{}{timezone.Date(at, at.Location()), at.UTC()},
+	}
+}
+
+// parseQueryDuration parses durations like 12h, 7d or 2w
+func parseQueryDuration(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	switch unicode.ToLower(rune(s[len(s)-1])) {
+	case 'h':
+		return time.Duration(n) * time.Hour, true
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, true
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// parseQueryStatus reads status categories case-insensitively, ignoring
+// spaces, dashes and underscores ("todo", "in_progress", "In Progress"). The
+// default status names select their whole category.
+func parseQueryStatus(v string) (StatusCategory, bool) {
+	key := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(v))
+	switch key {
+	case "todo":
+		return StatusCategoryTodo, true
+	case "inprogress", "doing", "active":
+		return StatusCategoryActive, true
+	case "done":
+		return StatusCategoryDone, true
+	}
+	return "", false
+}
+
+func splitQueryValues(v string) []string {
+	var values []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
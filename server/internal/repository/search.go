@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"strings"
+)
+
+// maxSearchTerms bounds the number of terms in a search query
+const maxSearchTerms = 5
+
+// TaskSearchFilter holds parameters for a full-text task search
+type TaskSearchFilter struct {
+	Terms  []string // Lower-cased terms; every term must match some field
+	Limit  int
+	Offset int
+}
+
+// TaskSearchHit is a task matched by a search with its rank and the matching
+// comment / context snapshot text (empty when those did not match)
+type TaskSearchHit struct {
+	Task          Task
+	Rank          float64
+	CommentMatch  string
+	SnapshotMatch string
+}
+
+// SplitSearchTerms splits a query into lower-cased, de-duplicated terms.
+// Chinese text has no spaces, so a CJK phrase stays a single substring term.
+func SplitSearchTerms(q string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, f := range strings.Fields(strings.ToLower(q)) {
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		terms = append(terms, f)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// likePattern builds a LIKE substring pattern, escaping wildcards with '\'
+func likePattern(term string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(term) + "%"
+}
+
+// Search conditions are substring matches on lower-cased text so that they are
+// served by the pg_trgm GIN indexes (see migration 000015) and work for Chinese,
+// which the built-in tsvector parsers cannot segment.
+const (
+	searchTitleCond       = `LOWER(tasks.title) LIKE ? ESCAPE '\'`
+	searchDescriptionCond = `LOWER(COALESCE(tasks.description, '')) LIKE ? ESCAPE '\'`
+	searchCommentCond     = `EXISTS (SELECT 1 FROM task_comments c WHERE c.task_id = tasks.id AND LOWER(c.content) LIKE ? ESCAPE '\')`
+	searchSnapshotCond    = `EXISTS (SELECT 1 FROM task_context_snapshots s WHERE s.task_id = tasks.id AND LOWER(s.text) LIKE ? ESCAPE '\')`
+)
+
+type searchRow struct {
+	ID            string
+	Rank          float64
+	CommentMatch  *string
+	SnapshotMatch *string
+}
+
+// Search finds tasks visible to userID (creator, assignee or member of the
+// task's group) whose title, description, comments or context snapshots
+// contain every term. Results are ranked by where the terms matched
+// (title > description > comment > snapshot), then by recency.
+func (r *taskRepository) Search(ctx context.Context, userID string, filter TaskSearchFilter) ([]TaskSearchHit, error) {
+	if len(filter.Terms) == 0 {
+		return []TaskSearchHit{}, nil
+	}
+
+	var (
+		rankParts []string
+		rankArgs  []interface{}
+	)
+	query := r.db.WithContext(ctx).
+		Table("tasks").
+		Where("tasks.deleted_at IS NULL").
+		Where(`(tasks.creator_id = ?
+			OR EXISTS (SELECT 1 FROM task_assignees ta WHERE ta.task_id = tasks.id AND ta.user_id = ?)
+			OR tasks.group_id IN (SELECT ug.group_id FROM user_groups ug WHERE ug.user_id = ?))`, userID, userID, userID)
+
+	for _, term := range filter.Terms {
+		p := likePattern(term)
+		query = query.Where("("+strings.Join([]string{searchTitleCond, searchDescriptionCond, searchCommentCond, searchSnapshotCond}, " OR ")+")", p, p, p, p)
+		rankParts = append(rankParts,
+			"CASE WHEN "+searchTitleCond+" THEN 8 ELSE 0 END",
+			"CASE WHEN "+searchDescriptionCond+" THEN 4 ELSE 0 END",
+			"CASE WHEN "+searchCommentCond+" THEN 2 ELSE 0 END",
+			"CASE WHEN "+searchSnapshotCond+" THEN 1 ELSE 0 END",
+		)
+		rankArgs = append(rankArgs, p, p, p, p)
+	}
+
+	// Exact title match ranks first
+	exact := strings.Join(filter.Terms, " ")
+	rankParts = append(rankParts, "CASE WHEN LOWER(tasks.title) = ? THEN 16 ELSE 0 END")
+	rankArgs = append(rankArgs, exact)
+
+	first := likePattern(filter.Terms[0])
+	selectArgs := append(rankArgs, first, first)
+	query = query.Select(
+		"tasks.id AS id, ("+strings.Join(rankParts, " + ")+") AS rank, "+
+			`(SELECT c.content FROM task_comments c WHERE c.task_id = tasks.id AND LOWER(c.content) LIKE ? ESCAPE '\' ORDER BY c.created_at DESC LIMIT 1) AS comment_match, `+
+			`(SELECT s.text FROM task_context_snapshots s WHERE s.task_id = tasks.id AND LOWER(s.text) LIKE ? ESCAPE '\' ORDER BY s.created_at DESC LIMIT 1) AS snapshot_match`,
+		selectArgs...,
+	).Order("rank DESC").Order("tasks.updated_at DESC")
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var rows []searchRow
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []TaskSearchHit{}, nil
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	var tasks []Task
+	if err := r.db.WithContext(ctx).
+		Preload("Assignees").
+		Preload("Creator").
+		Preload("Group").
+		Preload("Labels").
+		Where("id IN ?", ids).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+
+	hits := make([]TaskSearchHit, 0, len(rows))
+	for _, row := range rows {
+		t, ok := byID[row.ID]
+		if !ok {
+			continue
+		}
+		hit := TaskSearchHit{Task: t, Rank: row.Rank}
+		if row.CommentMatch != nil {
+			hit.CommentMatch = *row.CommentMatch
+		}
+		if row.SnapshotMatch != nil {
+			hit.SnapshotMatch = *row.SnapshotMatch
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
@@ -5,12 +5,19 @@ import (
 	"errors"
 	"time"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/layababa/tg_todo/server/internal/models"
 )
 
-// TaskStatus represents the status of a task
+// ErrVersionConflict is returned by Update when the task was changed after it
+// was read, i.e. its version no longer matches
+var ErrVersionConflict = errors.New("task version conflict")
+
+// TaskStatus is the name of a task status. Groups define their own statuses
+// (see WorkflowStatus); these are the ones of the default workflow.
 type TaskStatus string
 
 const (
@@ -19,6 +26,24 @@ const (
 	TaskStatusDone       TaskStatus = "Done"
 )
 
+// TaskPriority represents the priority of a task
+type TaskPriority string
+
+const (
+	TaskPriorityHigh   TaskPriority = "High"
+	TaskPriorityMedium TaskPriority = "Medium"
+	TaskPriorityLow    TaskPriority = "Low"
+)
+
+// IsValid reports whether p is one of the known priorities
+func (p TaskPriority) IsValid() bool {
+	switch p {
+	case TaskPriorityHigh, TaskPriorityMedium, TaskPriorityLow:
+		return true
+	}
+	return false
+}
+
 // TaskSyncStatus represents the sync status of a task
 type TaskSyncStatus string
 
@@ -41,29 +66,88 @@ const (
 type Task struct {
 	ID              string         `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	NotionPageID    *string        `gorm:"type:text"`
+	ParentID        *string        `gorm:"type:uuid;index"` // Parent task for subtasks
 	Title           string         `gorm:"type:text;not null"`
 	Description     string         `gorm:"type:text"`
-	Status          TaskStatus     `gorm:"type:task_status;default:'To Do';not null"`
+	Status          TaskStatus     `gorm:"type:text;default:'To Do';not null"`
+	StatusCategory  StatusCategory `gorm:"type:text;default:'todo';not null"` // Category of Status in the task's workflow
+	Priority        TaskPriority   `gorm:"type:task_priority;default:'Medium';not null"`
 	SyncStatus      TaskSyncStatus `gorm:"type:task_sync_status;default:'Pending';not null"`
 	GroupID         *string        `gorm:"type:text"` // Telegram Chat ID (matches groups.id)
 	DatabaseID      *string        `gorm:"type:text"`
 	Topic           string         `gorm:"type:text"`
 	DueAt           *time.Time     `gorm:"type:timestamptz"`
+	StartAt         *time.Time     `gorm:"type:timestamptz"`       // Optional start of the work, not after DueAt
+	AllDay          bool           `gorm:"not null;default:false"` // DueAt/StartAt are dates, stored as midnight UTC
 	CreatorID       *string        `gorm:"type:uuid"`
 	ChatJumpURL     string         `gorm:"type:text"`
 	NotionURL       *string        `gorm:"type:text"`
 	Archived        bool           `gorm:"default:false"`
-	Reminder1hSent  bool           `gorm:"column:reminder_1h_sent;default:false"`
-	ReminderDueSent bool           `gorm:"column:reminder_due_sent;default:false"`
-	CreatedAt       time.Time      `gorm:"default:now()"`
-	UpdatedAt       time.Time      `gorm:"default:now()"`
-	DeletedAt       gorm.DeletedAt `gorm:"index"`
+	CompletedAt     *time.Time     `gorm:"type:timestamptz"`
+	AutoComplete    bool           `gorm:"default:false"` // Mark Done once all subtasks are Done
+	EstimateMinutes *int           `gorm:"type:integer"`  // Estimated effort; nil when not estimated
+	// CustomFields holds the values of the group's custom fields by Notion
+	// property name, in the format of pkg/notion.PropertyValue
+	CustomFields    datatypes.JSONMap `gorm:"type:jsonb;not null;default:'{}'"`
+	Reminder1hSent  bool              `gorm:"column:reminder_1h_sent;default:false"`
+	ReminderDueSent bool              `gorm:"column:reminder_due_sent;default:false"`
+	// ReminderSnoozedUntil holds back reminders snoozed from the bot
+	ReminderSnoozedUntil *time.Time `gorm:"column:reminder_snoozed_until"`
+	// Recurrence is an RFC 5545 RRULE value (e.g. "FREQ=WEEKLY;BYDAY=FR"); empty for one-off tasks
+	Recurrence         string         `gorm:"type:text"`
+	RecurrenceParentID *string        `gorm:"type:uuid;index"` // First task of the series
+	RecurrenceIndex    int            `gorm:"default:0"`       // 0-based occurrence number within the series
+	RecurrenceSpawned  bool           `gorm:"default:false"`   // Next occurrence has been generated
+	Version            int            `gorm:"default:1"`       // Bumped by every Update, exposed as the ETag
+	CreatedAt          time.Time      `gorm:"default:now()"`
+	UpdatedAt          time.Time      `gorm:"default:now()"`
+	DeletedAt          gorm.DeletedAt `gorm:"index"`
+
+	// Subtask progress (n/m done), filled on read
+	SubtaskTotal int64 `gorm:"-"`
+	SubtaskDone  int64 `gorm:"-"`
 
-	Creator   *models.User          `gorm:"foreignKey:CreatorID"`
-	Group     *models.Group         `gorm:"foreignKey:GroupID"`
-	Assignees []models.User         `gorm:"many2many:task_assignees;"`
-	Snapshots []TaskContextSnapshot `gorm:"foreignKey:TaskID"`
-	Events    []TaskEvent           `gorm:"foreignKey:TaskID"`
+	Creator     *models.User          `gorm:"foreignKey:CreatorID"`
+	Group       *models.Group         `gorm:"foreignKey:GroupID"`
+	Assignees   []models.User         `gorm:"many2many:task_assignees;"`
+	Watchers    []models.User         `gorm:"many2many:task_watchers;"`
+	Labels      []Label               `gorm:"many2many:task_labels;"`
+	Snapshots   []TaskContextSnapshot `gorm:"foreignKey:TaskID"`
+	Attachments []TaskAttachment      `gorm:"foreignKey:TaskID"`
+	Events      []TaskEvent           `gorm:"foreignKey:TaskID"`
+}
+
+// BeforeSave fills in the category of tasks saved without one from the
+// default workflow, e.g. "Done" tasks imported before workflows existed, and
+// stores missing custom fields as an empty object
+func (t *Task) BeforeSave(tx *gorm.DB) error {
+	if t.StatusCategory == "" && t.Status != "" {
+		t.StatusCategory = t.Category()
+	}
+	if t.CustomFields == nil {
+		t.CustomFields = datatypes.JSONMap{}
+	}
+	return nil
+}
+
+// SetStatus moves the task to st, keeping StatusCategory in step
+func (t *Task) SetStatus(st WorkflowStatus) {
+	t.Status = st.Name
+	t.StatusCategory = st.Category
+}
+
+// Category returns the category of the task's status, reading unsaved tasks
+// without one from the default workflow
+func (t *Task) Category() StatusCategory {
+	if t.StatusCategory != "" {
+		return t.StatusCategory
+	}
+	return DefaultWorkflow.CategoryOf(t.Status)
+}
+
+// IsDone reports whether the task is in a done status
+func (t *Task) IsDone() bool {
+	return t.Category() == StatusCategoryDone
 }
 
 // TaskAssignee represents the task_assignees join table
@@ -74,6 +158,14 @@ type TaskAssignee struct {
 	AssignedAt time.Time `gorm:"default:now()"`
 }
 
+// TaskWatcher represents the task_watchers join table: users following a task
+// without being assigned to it
+type TaskWatcher struct {
+	TaskID    string    `gorm:"type:uuid;primary_key"`
+	UserID    string    `gorm:"type:uuid;primary_key"`
+	CreatedAt time.Time `gorm:"default:now()"`
+}
+
 // TaskContextSnapshot represents the task_context_snapshots table
 type TaskContextSnapshot struct {
 	ID          string      `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
@@ -85,28 +177,62 @@ type TaskContextSnapshot struct {
 	CreatedAt   time.Time   `gorm:"default:now()"`
 }
 
-// TaskEvent represents the task_events table
+// TaskEventType represents the task_event_type enum
+type TaskEventType string
+
+const (
+	TaskEventCreate     TaskEventType = "Create"
+	TaskEventAssign     TaskEventType = "Assign"
+	TaskEventStatus     TaskEventType = "Status"
+	TaskEventDue        TaskEventType = "Due"
+	TaskEventDelete     TaskEventType = "Delete"
+	TaskEventRestore    TaskEventType = "Restore"
+	TaskEventComment    TaskEventType = "Comment"
+	TaskEventUpdate     TaskEventType = "Update"     // Title, description, priority, labels, recurrence...
+	TaskEventDependency TaskEventType = "Dependency" // Blocker added or removed
+)
+
+// TaskEventSource identifies where a task change originated
+type TaskEventSource string
+
+const (
+	TaskEventSourceApp    TaskEventSource = "app"    // Mini App / HTTP API
+	TaskEventSourceBot    TaskEventSource = "bot"    // Telegram bot commands and buttons
+	TaskEventSourceNotion TaskEventSource = "notion" // Notion poller
+	TaskEventSourceSystem TaskEventSource = "system" // Scheduler and automatic transitions
+)
+
+// TaskEvent represents the task_events table. Before and After hold the
+// changed fields as JSON objects.
 type TaskEvent struct {
-	ID        string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	TaskID    string    `gorm:"type:uuid;not null"`
-	ActorID   *string   `gorm:"type:uuid"`
-	Event     string    `gorm:"type:task_event_type;not null"`
-	Before    []byte    `gorm:"type:jsonb"`
-	After     []byte    `gorm:"type:jsonb"`
-	CreatedAt time.Time `gorm:"default:now()"`
+	ID        string          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TaskID    string          `gorm:"type:uuid;not null" json:"task_id"`
+	ActorID   *string         `gorm:"type:uuid" json:"actor_id"`
+	Event     TaskEventType   `gorm:"type:task_event_type;not null" json:"event"`
+	Source    TaskEventSource `gorm:"type:text;not null;default:'app'" json:"source"`
+	Before    datatypes.JSON  `gorm:"type:jsonb" json:"before"`
+	After     datatypes.JSON  `gorm:"type:jsonb" json:"after"`
+	CreatedAt time.Time       `gorm:"default:now()" json:"created_at"`
+
+	Actor *models.User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
 }
 
 // TaskComment represents the task_comments table
 type TaskComment struct {
-	ID        string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	TaskID    string    `gorm:"type:uuid;not null;index" json:"task_id"`
-	ParentID  *string   `gorm:"type:uuid;index" json:"parent_id"`
-	UserID    string    `gorm:"type:uuid;not null" json:"user_id"`
-	Content   string    `gorm:"type:text;not null" json:"content"`
-	CreatedAt time.Time `gorm:"default:now()" json:"created_at"`
-	UpdatedAt time.Time `gorm:"default:now()" json:"updated_at"`
+	ID        string     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TaskID    string     `gorm:"type:uuid;not null;index" json:"task_id"`
+	ParentID  *string    `gorm:"type:uuid;index" json:"parent_id"`
+	UserID    string     `gorm:"type:uuid;not null" json:"user_id"`
+	Content   string     `gorm:"type:text;not null" json:"content"`
+	CreatedAt time.Time  `gorm:"default:now()" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"default:now()" json:"updated_at"`
+	EditedAt  *time.Time `json:"edited_at"` // Set when the author changed the content
+	// DeletedAt is set on deleted comments kept so that their replies stay in
+	// the thread; their content is cleared
+	DeletedAt *time.Time `json:"deleted_at"`
 
-	User models.User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	User    models.User    `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Replies []*TaskComment `gorm:"-" json:"replies,omitempty"` // Filled in threaded listings
 }
 
 // TaskRepository handles database operations for tasks
@@ -115,19 +241,58 @@ type TaskRepository interface {
 	GetByID(ctx context.Context, id string) (*Task, error)
 	UpdateStatus(ctx context.Context, task *Task) error
 	Update(ctx context.Context, task *Task) error
+	UpdateRecurrence(ctx context.Context, task *Task) error
+	// Move puts tasks into another group and Notion database; statuses sets
+	// the new status of tasks (by ID) whose status the target group lacks
+	Move(ctx context.Context, ids []string, groupID, databaseID *string, statuses map[string]WorkflowStatus) error
 	ListByUser(ctx context.Context, userID string, filter TaskListFilter) ([]Task, error)
 	SoftDelete(ctx context.Context, id string) error
 
+	// Trash methods
+	ListDeleted(ctx context.Context, userID string, limit, offset int) ([]Task, error)
+	GetDeletedByID(ctx context.Context, id string) (*Task, error)
+	Restore(ctx context.Context, id string) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+
 	// Comment methods
 	CreateComment(ctx context.Context, comment *TaskComment) (*TaskComment, error)
 	ListComments(ctx context.Context, taskID string) ([]TaskComment, error)
 	GetCommentByID(ctx context.Context, id string) (*TaskComment, error)
+	UpdateComment(ctx context.Context, comment *TaskComment) error
+	// DeleteComment removes a comment, or clears it when it has replies. It
+	// reports whether the comment was kept.
+	DeleteComment(ctx context.Context, id string) (bool, error)
 	GetByNotionPageID(ctx context.Context, pageID string) (*Task, error)
 	ListPendingByGroup(ctx context.Context, groupID string) ([]Task, error)
 	ListForReminders(ctx context.Context, now time.Time) ([]Task, error)
 	UpdateReminderFlags(ctx context.Context, id string, reminder1h, reminderDue bool) error
-	AssignTask(ctx context.Context, taskID, userID string) error
+	SnoozeReminders(ctx context.Context, id string, until time.Time) error
+	ListSubtasks(ctx context.Context, parentID string) ([]Task, error)
+	GetSubtaskProgress(ctx context.Context, parentIDs []string) (map[string]SubtaskProgress, error)
+	ListRecurringToSpawn(ctx context.Context, now time.Time) ([]Task, error)
+	SetRecurrenceSpawned(ctx context.Context, id string, spawned bool) (bool, error)
+	SetArchived(ctx context.Context, id string, archived bool) error
+	ListAutoArchivable(ctx context.Context, now time.Time) ([]Task, error)
+	Search(ctx context.Context, userID string, filter TaskSearchFilter) ([]TaskSearchHit, error)
 	GetTaskCounts(ctx context.Context, userID string) (*TaskCounts, error)
+
+	// Assignee methods
+	AddAssignee(ctx context.Context, taskID, userID string, assignedBy *string) (bool, error)
+	RemoveAssignee(ctx context.Context, taskID, userID string) (bool, error)
+	SetAssignees(ctx context.Context, taskID string, userIDs []string, assignedBy *string) error
+
+	// Watcher methods
+	AddWatcher(ctx context.Context, taskID, userID string) (bool, error)
+	RemoveWatcher(ctx context.Context, taskID, userID string) (bool, error)
+	ListWatcherIDs(ctx context.Context, taskID string) ([]string, error)
+
+	// Event methods
+	CreateEvent(ctx context.Context, event *TaskEvent) error
+	ListEvents(ctx context.Context, taskID string, limit, offset int) ([]TaskEvent, error)
+
+	// Transaction runs fn with a repository bound to a single database
+	// transaction, committed if fn returns nil and rolled back otherwise
+	Transaction(ctx context.Context, fn func(tx TaskRepository) error) error
 }
 
 type taskRepository struct {
@@ -139,9 +304,31 @@ func NewTaskRepository(db *gorm.DB) TaskRepository {
 	return &taskRepository{db: db}
 }
 
+// Transaction runs fn inside a database transaction
+func (r *taskRepository) Transaction(ctx context.Context, fn func(tx TaskRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&taskRepository{db: tx})
+	})
+}
+
 // Create creates a new task with associations
 func (r *taskRepository) Create(ctx context.Context, task *Task) error {
-	return r.db.WithContext(ctx).Create(task).Error
+	if task.Version == 0 {
+		task.Version = 1
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		// The join rows written by the association only carry the keys; the
+		// initial assignees are assigned by the creator
+		if task.CreatorID == nil || len(task.Assignees) == 0 {
+			return nil
+		}
+		return tx.Model(&TaskAssignee{}).
+			Where("task_id = ? AND assigned_by IS NULL", task.ID).
+			Updates(map[string]interface{}{"assigned_by": *task.CreatorID, "assigned_at": time.Now()}).Error
+	})
 }
 
 // GetByID retrieves a task by ID with associations
@@ -149,8 +336,11 @@ func (r *taskRepository) GetByID(ctx context.Context, id string) (*Task, error)
 	var task Task
 	err := r.db.WithContext(ctx).
 		Preload("Assignees").
+		Preload("Watchers").
 		Preload("Creator").
+		Preload("Labels").
 		Preload("Snapshots").
+		Preload("Attachments").
 		First(&task, "id = ?", id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -158,7 +348,12 @@ func (r *taskRepository) GetByID(ctx context.Context, id string) (*Task, error)
 		}
 		return nil, err
 	}
-	return &task, nil
+
+	tasks := []Task{task}
+	if err := r.fillSubtaskProgress(ctx, tasks); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
 }
 
 // GetByNotionPageID retrieves a task by Notion Page ID
@@ -176,14 +371,65 @@ func (r *taskRepository) GetByNotionPageID(ctx context.Context, pageID string) (
 	return &task, nil
 }
 
-// UpdateStatus updates the status and sync status of a task
+// UpdateStatus updates the Notion sync state (sync status, page ID and URL) of
+// a task. User-editable fields are left alone so that a slow sync working on
+// an old copy of the task cannot overwrite a newer edit.
 func (r *taskRepository) UpdateStatus(ctx context.Context, task *Task) error {
-	return r.db.WithContext(ctx).Model(task).Select("Status", "SyncStatus", "NotionPageID", "NotionURL").Updates(task).Error
+	return r.db.WithContext(ctx).Model(task).Select("SyncStatus", "NotionPageID", "NotionURL").Updates(task).Error
 }
 
 // Update updates main task fields (Title, Description, Status, DueAt, etc)
+// and bumps the version. It returns ErrVersionConflict if the stored version
+// no longer matches task.Version.
 func (r *taskRepository) Update(ctx context.Context, task *Task) error {
-	return r.db.WithContext(ctx).Model(task).Select("Title", "Description", "Status", "SyncStatus", "Topic", "DueAt", "Reminder1hSent", "ReminderDueSent").Updates(task).Error
+	// Compare-and-swap on version so concurrent writers cannot overwrite each other
+	read := task.Version
+	task.Version = read + 1
+	res := r.db.WithContext(ctx).Model(task).Where("version = ?", read).
+		Select("Title", "Description", "Status", "StatusCategory", "CompletedAt", "Priority", "SyncStatus", "Topic", "DueAt", "StartAt", "AllDay", "AutoComplete", "EstimateMinutes", "CustomFields", "Reminder1hSent", "ReminderDueSent", "ReminderSnoozedUntil", "Version").
+		Updates(task)
+	if res.Error != nil {
+		task.Version = read
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		task.Version = read
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// UpdateRecurrence updates the recurrence rule of a task
+func (r *taskRepository) UpdateRecurrence(ctx context.Context, task *Task) error {
+	return r.db.WithContext(ctx).Model(task).Select("Recurrence").Updates(task).Error
+}
+
+// Move puts tasks into another group and Notion database and bumps their
+// version. Topics are cleared since they belong to the old group's chat.
+func (r *taskRepository) Move(ctx context.Context, ids []string, groupID, databaseID *string, statuses map[string]WorkflowStatus) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Task{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"group_id":    groupID,
+				"database_id": databaseID,
+				"topic":       "",
+				"version":     gorm.Expr("version + 1"),
+				"updated_at":  time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+		for id, st := range statuses {
+			if err := tx.Model(&Task{}).Where("id = ?", id).
+				Updates(map[string]interface{}{"status": st.Name, "status_category": st.Category}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // TaskView represents the type of list view
@@ -194,12 +440,45 @@ const (
 	TaskViewAssigned TaskView = "assigned"
 	TaskViewCreated  TaskView = "created"
 	TaskViewDone     TaskView = "done"
+	TaskViewArchived TaskView = "archived"
+	// TaskViewGroup lists every task of TaskListFilter.GroupID, whoever created
+	// or is assigned to it. Callers check group membership.
+	TaskViewGroup TaskView = "group"
+)
+
+// TaskSort represents the ordering of a task list
+type TaskSort string
+
+const (
+	TaskSortCreated  TaskSort = "created"  // created_at DESC
+	TaskSortUpdated  TaskSort = "updated"  // updated_at DESC
+	TaskSortDue      TaskSort = "due"      // due_at ASC, tasks without due date last
+	TaskSortPriority TaskSort = "priority" // High -> Low, then created_at DESC
+	TaskSortTitle    TaskSort = "title"    // title ASC
 )
 
+// IsValid reports whether the sort is supported
+func (s TaskSort) IsValid() bool {
+	switch s {
+	case TaskSortCreated, TaskSortUpdated, TaskSortDue, TaskSortPriority, TaskSortTitle:
+		return true
+	}
+	return false
+}
+
+// priorityOrderExpr ranks priorities High -> Medium -> Low
+const priorityOrderExpr = "CASE tasks.priority WHEN 'High' THEN 0 WHEN 'Medium' THEN 1 ELSE 2 END"
+
 // TaskListFilter contains filters for listing tasks
 type TaskListFilter struct {
 	View       TaskView
 	DatabaseID *string
+	GroupID    *string // Required by TaskViewGroup
+	Priorities []TaskPriority
+	Labels     []string   // Normalized label names, any-of
+	Query      *TaskQuery // Compiled filter expression, see ParseTaskQuery
+	Sort       TaskSort
+	Cursor     string // Opaque keyset cursor from EncodeTaskCursor; takes precedence over Offset
 	Limit      int
 	Offset     int
 }
@@ -213,52 +492,146 @@ func (r *taskRepository) ListByUser(ctx context.Context, userID string, filter T
 		Preload("Assignees").
 		Preload("Creator").
 		Preload("Group").
+		Preload("Labels").
 		Preload("Snapshots").
 		Where("tasks.deleted_at IS NULL")
 
 	if filter.DatabaseID != nil {
 		query = query.Where("tasks.database_id = ?", *filter.DatabaseID)
 	}
+	if len(filter.Priorities) > 0 {
+		query = query.Where("tasks.priority IN ?", filter.Priorities)
+	}
+	if len(filter.Labels) > 0 {
+		// Match tasks carrying any of the labels
+		query = query.Where("tasks.id IN (SELECT tl.task_id FROM task_labels tl JOIN labels l ON l.id = tl.label_id WHERE l.name IN ?)", filter.Labels)
+	}
+	query = filter.Query.apply(query, userID)
+
+	if filter.View == TaskViewArchived {
+		query = query.Where("tasks.archived = ?", true)
+	} else {
+		query = query.Where("tasks.archived = ?", false)
+	}
 
 	switch filter.View {
 	case TaskViewAssigned:
 		query = query.Group("tasks.id").
 			Joins("JOIN task_assignees ta ON ta.task_id = tasks.id").
-			Where("ta.user_id = ? AND tasks.status != ?", userID, TaskStatusDone).
-			// Prioritize self-created tasks for grouped display
-			Order("tasks.creator_id = '" + userID + "' DESC")
+			Where("ta.user_id = ? AND tasks.status_category != ?", userID, StatusCategoryDone)
 	case TaskViewCreated:
-		query = query.Where("tasks.creator_id = ? AND tasks.status != ?", userID, TaskStatusDone).
-			// Prioritize assigned to others (not self)
-			// Need a subquery or join to determine if assigned to self?
-			// Simplest: Created items usually sorted by time.
-			// Let's keep standard sort for now, grouping can be done in frontend or reliable on generic sort.
-			// Revert to time sort primarily.
-			Order("tasks.created_at DESC")
+		query = query.Where("tasks.creator_id = ? AND tasks.status_category != ?", userID, StatusCategoryDone)
 	case TaskViewDone:
 		// Done items (Created OR Assigned) AND Status=Done
 		query = query.Group("tasks.id").
 			Joins("LEFT JOIN task_assignees ta ON ta.task_id = tasks.id").
-			Where("(tasks.creator_id = ? OR ta.user_id = ?) AND tasks.status = ?", userID, userID, TaskStatusDone)
-	default: // All
+			Where("(tasks.creator_id = ? OR ta.user_id = ?) AND tasks.status_category = ?", userID, userID, StatusCategoryDone)
+	case TaskViewGroup:
+		if filter.GroupID == nil {
+			return nil, errors.New("group view requires a group id")
+		}
+		query = query.Where("tasks.group_id = ?", *filter.GroupID)
+	default: // All, Archived
 		query = query.Group("tasks.id").
 			Joins("LEFT JOIN task_assignees ta ON ta.task_id = tasks.id").
 			Where("tasks.creator_id = ? OR ta.user_id = ?", userID, userID)
 	}
 
+	if filter.Cursor != "" {
+		var err error
+		if query, err = applyCursor(query, userID, filter); err != nil {
+			return nil, err
+		}
+	} else if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+	query = applyOrder(query, userID, filter)
+
 	if filter.Limit > 0 {
 		query = query.Limit(filter.Limit)
 	}
-	if filter.Offset > 0 {
-		query = query.Offset(filter.Offset)
+
+	if err := query.Find(&tasks).Error; err != nil {
+		return nil, err
 	}
+	if err := r.fillSubtaskProgress(ctx, tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// SubtaskProgress holds the number of subtasks and how many are done
+type SubtaskProgress struct {
+	Total int64
+	Done  int64
+}
 
-	if err := query.Order("tasks.created_at DESC").Find(&tasks).Error; err != nil {
+// ListSubtasks lists the subtasks of a task in creation order
+func (r *taskRepository) ListSubtasks(ctx context.Context, parentID string) ([]Task, error) {
+	var tasks []Task
+	err := r.db.WithContext(ctx).
+		Preload("Assignees").
+		Preload("Creator").
+		Preload("Labels").
+		Where("parent_id = ? AND deleted_at IS NULL", parentID).
+		Order("created_at ASC").
+		Find(&tasks).Error
+	if err != nil {
 		return nil, err
 	}
 	return tasks, nil
 }
 
+// GetSubtaskProgress returns subtask counts keyed by parent ID; parents without subtasks are omitted
+func (r *taskRepository) GetSubtaskProgress(ctx context.Context, parentIDs []string) (map[string]SubtaskProgress, error) {
+	progress := make(map[string]SubtaskProgress)
+	if len(parentIDs) == 0 {
+		return progress, nil
+	}
+
+	var rows []struct {
+		ParentID string
+		Total    int64
+		Done     int64
+	}
+	err := r.db.WithContext(ctx).Model(&Task{}).
+		Select("parent_id, COUNT(*) AS total, SUM(CASE WHEN status_category = ? THEN 1 ELSE 0 END) AS done", StatusCategoryDone).
+		Where("parent_id IN ? AND deleted_at IS NULL", parentIDs).
+		Group("parent_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		progress[row.ParentID] = SubtaskProgress{Total: row.Total, Done: row.Done}
+	}
+	return progress, nil
+}
+
+// fillSubtaskProgress sets SubtaskTotal/SubtaskDone on the given tasks
+func (r *taskRepository) fillSubtaskProgress(ctx context.Context, tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+	}
+
+	progress, err := r.GetSubtaskProgress(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		if p, ok := progress[tasks[i].ID]; ok {
+			tasks[i].SubtaskTotal = p.Total
+			tasks[i].SubtaskDone = p.Done
+		}
+	}
+	return nil
+}
+
 // TaskCounts represents counts for different tabs
 type TaskCounts struct {
 	Assigned int64 `json:"assigned"`
@@ -273,14 +646,14 @@ func (r *taskRepository) GetTaskCounts(ctx context.Context, userID string) (*Tas
 	// 1. Assigned (Active)
 	if err := r.db.WithContext(ctx).Model(&Task{}).
 		Joins("JOIN task_assignees ta ON ta.task_id = tasks.id").
-		Where("ta.user_id = ? AND tasks.status != ? AND tasks.deleted_at IS NULL", userID, TaskStatusDone).
+		Where("ta.user_id = ? AND tasks.status_category != ? AND tasks.archived = ? AND tasks.deleted_at IS NULL", userID, StatusCategoryDone, false).
 		Count(&counts.Assigned).Error; err != nil {
 		return nil, err
 	}
 
 	// 2. Created (Active)
 	if err := r.db.WithContext(ctx).Model(&Task{}).
-		Where("creator_id = ? AND status != ? AND deleted_at IS NULL", userID, TaskStatusDone).
+		Where("creator_id = ? AND status_category != ? AND archived = ? AND deleted_at IS NULL", userID, StatusCategoryDone, false).
 		Count(&counts.Created).Error; err != nil {
 		return nil, err
 	}
@@ -290,7 +663,7 @@ func (r *taskRepository) GetTaskCounts(ctx context.Context, userID string) (*Tas
 	if err := r.db.WithContext(ctx).Model(&Task{}).
 		Group("tasks.id").
 		Joins("LEFT JOIN task_assignees ta ON ta.task_id = tasks.id").
-		Where("(tasks.creator_id = ? OR ta.user_id = ?) AND tasks.status = ? AND tasks.deleted_at IS NULL", userID, userID, TaskStatusDone).
+		Where("(tasks.creator_id = ? OR ta.user_id = ?) AND tasks.status_category = ? AND tasks.archived = ? AND tasks.deleted_at IS NULL", userID, userID, StatusCategoryDone, false).
 		Count(&counts.Done).Error; err != nil {
 		return nil, err
 	}
@@ -303,6 +676,99 @@ func (r *taskRepository) SoftDelete(ctx context.Context, id string) error {
 	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&Task{}).Error
 }
 
+// ListDeleted lists the soft-deleted tasks created by or assigned to the user,
+// most recently deleted first
+func (r *taskRepository) ListDeleted(ctx context.Context, userID string, limit, offset int) ([]Task, error) {
+	var tasks []Task
+	query := r.db.WithContext(ctx).
+		Unscoped().
+		Model(&Task{}).
+		Preload("Assignees").
+		Preload("Creator").
+		Preload("Group").
+		Preload("Labels").
+		Group("tasks.id").
+		Joins("LEFT JOIN task_assignees ta ON ta.task_id = tasks.id").
+		Where("tasks.deleted_at IS NOT NULL").
+		Where("tasks.creator_id = ? OR ta.user_id = ?", userID, userID).
+		Order("tasks.deleted_at DESC").
+		Order("tasks.id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+	if err := query.Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// GetDeletedByID retrieves a soft-deleted task by ID. It returns nil if the
+// task does not exist or is not deleted.
+func (r *taskRepository) GetDeletedByID(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Preload("Assignees").
+		Preload("Creator").
+		Preload("Labels").
+		Where("deleted_at IS NOT NULL").
+		First(&task, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Restore clears the soft delete of a task
+func (r *taskRepository) Restore(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Unscoped().
+		Model(&Task{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "updated_at": time.Now()}).Error
+}
+
+// PurgeDeleted permanently deletes tasks soft-deleted before the given time and
+// returns how many were removed. Live subtasks of purged tasks are detached
+// instead of being cascaded.
+func (r *taskRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		if err := tx.Unscoped().Model(&Task{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Unscoped().Model(&Task{}).
+			Where("parent_id IN ? AND id NOT IN ?", ids, ids).
+			UpdateColumn("parent_id", nil).Error; err != nil {
+			return err
+		}
+		// Comments have no foreign key; the other task tables cascade
+		if err := tx.Where("task_id IN ?", ids).Delete(&TaskComment{}).Error; err != nil {
+			return err
+		}
+		res := tx.Unscoped().Where("id IN ?", ids).Delete(&Task{})
+		if res.Error != nil {
+			return res.Error
+		}
+		purged = res.RowsAffected
+		return nil
+	})
+	return purged, err
+}
+
 // CreateComment creates a new comment and returns the preloaded version
 func (r *taskRepository) CreateComment(ctx context.Context, comment *TaskComment) (*TaskComment, error) {
 	if err := r.db.WithContext(ctx).Create(comment).Error; err != nil {
@@ -333,6 +799,31 @@ func (r *taskRepository) ListComments(ctx context.Context, taskID string) ([]Tas
 	return comments, nil
 }
 
+// CreateEvent appends an entry to the task's audit trail
+func (r *taskRepository) CreateEvent(ctx context.Context, event *TaskEvent) error {
+	return r.db.WithContext(ctx).Omit("Actor").Create(event).Error
+}
+
+// ListEvents lists the audit trail of a task, newest first
+func (r *taskRepository) ListEvents(ctx context.Context, taskID string, limit, offset int) ([]TaskEvent, error) {
+	var events []TaskEvent
+	query := r.db.WithContext(ctx).
+		Where("task_id = ?", taskID).
+		Preload("Actor").
+		Order("created_at DESC").
+		Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+	if err := query.Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
 // GetCommentByID retrieves a comment by ID
 func (r *taskRepository) GetCommentByID(ctx context.Context, id string) (*TaskComment, error) {
 	var comment TaskComment
@@ -348,6 +839,37 @@ func (r *taskRepository) GetCommentByID(ctx context.Context, id string) (*TaskCo
 	return &comment, nil
 }
 
+// UpdateComment saves the content of a comment and marks it edited
+func (r *taskRepository) UpdateComment(ctx context.Context, comment *TaskComment) error {
+	now := time.Now()
+	comment.EditedAt = &now
+	comment.UpdatedAt = now
+	return r.db.WithContext(ctx).Model(&TaskComment{}).
+		Where("id = ?", comment.ID).
+		Updates(map[string]interface{}{"content": comment.Content, "edited_at": now, "updated_at": now}).Error
+}
+
+// DeleteComment deletes a comment. A comment with replies is cleared and
+// marked deleted instead, so that the replies keep their place in the thread.
+func (r *taskRepository) DeleteComment(ctx context.Context, id string) (bool, error) {
+	kept := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var replies int64
+		if err := tx.Model(&TaskComment{}).Where("parent_id = ?", id).Count(&replies).Error; err != nil {
+			return err
+		}
+		if replies == 0 {
+			return tx.Where("id = ?", id).Delete(&TaskComment{}).Error
+		}
+		kept = true
+		now := time.Now()
+		return tx.Model(&TaskComment{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{"content": "", "deleted_at": now, "updated_at": now}).Error
+	})
+	return kept, err
+}
+
 // ListPendingByGroup lists tasks in a group that are pending sync
 func (r *taskRepository) ListPendingByGroup(ctx context.Context, groupID string) ([]Task, error) {
 	var tasks []Task
@@ -356,7 +878,9 @@ func (r *taskRepository) ListPendingByGroup(ctx context.Context, groupID string)
 			groupID, TaskSyncStatusPending).
 		Preload("Assignees").
 		Preload("Group").
+		Preload("Labels").
 		Preload("Snapshots").
+		Preload("Attachments").
 		Find(&tasks).Error
 	if err != nil {
 		return nil, err
@@ -369,11 +893,16 @@ func (r *taskRepository) ListForReminders(ctx context.Context, now time.Time) ([
 	var tasks []Task
 	// 1. Tasks due in <= 1 hour but reminder_1h not sent
 	// 2. Tasks past due but reminder_due not sent
+	// 3. All-day tasks due within a day, whose reminders depend on the
+	//    creator's time zone and are timed by the caller
 	err := r.db.WithContext(ctx).
 		Preload("Assignees").
-		Where("status != ? AND due_at IS NOT NULL AND archived = false AND deleted_at IS NULL", TaskStatusDone).
-		Where("(due_at <= ? AND reminder_1h_sent = false) OR (due_at <= ? AND reminder_due_sent = false)",
-			now.Add(1*time.Hour), now).
+		Preload("Creator").
+		Where("status_category != ? AND due_at IS NOT NULL AND archived = false AND deleted_at IS NULL", StatusCategoryDone).
+		Where("reminder_snoozed_until IS NULL OR reminder_snoozed_until <= ?", now).
+		Where("(all_day = false AND ((due_at <= ? AND reminder_1h_sent = false) OR (due_at <= ? AND reminder_due_sent = false))) OR "+
+			"(all_day = true AND due_at <= ? AND (reminder_1h_sent = false OR reminder_due_sent = false))",
+			now.Add(1*time.Hour), now, now.Add(24*time.Hour)).
 		Find(&tasks).Error
 	return tasks, err
 }
@@ -393,24 +922,152 @@ func (r *taskRepository) UpdateReminderFlags(ctx context.Context, id string, rem
 	return r.db.WithContext(ctx).Model(&Task{}).Where("id = ?", id).Updates(updates).Error
 }
 
-// AssignTask assigns a user to a task (replacing existing assignees for simple assignment)
-func (r *taskRepository) AssignTask(ctx context.Context, taskID, userID string) error {
-	// First check if user exists? Association Replace expects User model or ID.
-	// We can just append to association.
-	// For "Claim", we might want to Add or Replace.
-	// Let's assume Replace for single assignee model for now, or Append.
-	// The requirement implies "Assign to Me", so user becomes an assignee.
-	// If we want multiple assignees, we append.
+// SnoozeReminders holds back the task's reminders until the given time, then
+// sends the due reminder (or the early one, if not yet due) again
+func (r *taskRepository) SnoozeReminders(ctx context.Context, id string, until time.Time) error {
+	return r.db.WithContext(ctx).Model(&Task{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"reminder_snoozed_until": until,
+		"reminder_1h_sent":       false,
+		"reminder_due_sent":      false,
+	}).Error
+}
 
-	// Create a dummy user object with ID
-	var user models.User
-	user.ID = userID
+// ListRecurringToSpawn finds recurring tasks whose next occurrence is due to be generated:
+// the task was marked Done, or its due date passed so the next window has opened
+func (r *taskRepository) ListRecurringToSpawn(ctx context.Context, now time.Time) ([]Task, error) {
+	var tasks []Task
+	err := r.db.WithContext(ctx).
+		Preload("Assignees").
+		Where("recurrence <> '' AND recurrence_spawned = false AND archived = false AND deleted_at IS NULL").
+		Where("status_category = ? OR (due_at IS NOT NULL AND due_at <= ?)", StatusCategoryDone, now).
+		Find(&tasks).Error
+	return tasks, err
+}
 
-	var task Task
-	task.ID = taskID
+// SetRecurrenceSpawned flips the spawned flag and reports whether this call changed it,
+// so concurrent schedulers never generate the same occurrence twice
+func (r *taskRepository) SetRecurrenceSpawned(ctx context.Context, id string, spawned bool) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&Task{}).
+		Where("id = ? AND recurrence_spawned = ?", id, !spawned).
+		Update("recurrence_spawned", spawned)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// SetArchived archives or unarchives a task
+func (r *taskRepository) SetArchived(ctx context.Context, id string, archived bool) error {
+	return r.db.WithContext(ctx).Model(&Task{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"archived": archived, "updated_at": time.Now()}).Error
+}
+
+// ListAutoArchivable finds Done tasks of groups with auto-archiving enabled that
+// have been Done for at least the group's auto_archive_days
+func (r *taskRepository) ListAutoArchivable(ctx context.Context, now time.Time) ([]Task, error) {
+	var candidates []Task
+	err := r.db.WithContext(ctx).
+		Preload("Group").
+		Joins("JOIN groups g ON g.id = tasks.group_id").
+		Where("g.auto_archive_days > 0").
+		Where("tasks.status_category = ? AND tasks.archived = ? AND tasks.completed_at IS NOT NULL AND tasks.deleted_at IS NULL", StatusCategoryDone, false).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
 
-	// Use Association Replace to set specific assignee (clears others) or Append to add?
-	// Linear/Notion usually support multiple, but "Claim" usually means "I take it".
-	// Let's use Replace to ensure clear ownership for this feature.
-	return r.db.WithContext(ctx).Model(&task).Association("Assignees").Replace(&user)
+	// The per-group threshold is applied here to keep the query portable
+	tasks := candidates[:0]
+	for _, t := range candidates {
+		if t.Group == nil {
+			continue
+		}
+		if !t.CompletedAt.After(now.AddDate(0, 0, -t.Group.AutoArchiveDays)) {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
+
+// AddAssignee adds a user to the task assignees, recording who assigned them.
+// It reports false if the user was already assigned.
+func (r *taskRepository) AddAssignee(ctx context.Context, taskID, userID string, assignedBy *string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&TaskAssignee{TaskID: taskID, UserID: userID, AssignedBy: assignedBy, AssignedAt: time.Now()})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// RemoveAssignee removes a user from the task assignees. It reports false if
+// the user was not assigned.
+func (r *taskRepository) RemoveAssignee(ctx context.Context, taskID, userID string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Where("task_id = ? AND user_id = ?", taskID, userID).
+		Delete(&TaskAssignee{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// SetAssignees replaces the task assignees with userIDs. Users who stay
+// assigned keep their original assigner.
+func (r *taskRepository) SetAssignees(ctx context.Context, taskID string, userIDs []string, assignedBy *string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		remove := tx.Where("task_id = ?", taskID)
+		if len(userIDs) > 0 {
+			remove = remove.Where("user_id NOT IN ?", userIDs)
+		}
+		if err := remove.Delete(&TaskAssignee{}).Error; err != nil {
+			return err
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+		now := time.Now()
+		rows := make([]TaskAssignee, 0, len(userIDs))
+		for _, id := range userIDs {
+			rows = append(rows, TaskAssignee{TaskID: taskID, UserID: id, AssignedBy: assignedBy, AssignedAt: now})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+	})
+}
+
+// AddWatcher subscribes a user to a task. It reports false if the user was
+// already watching.
+func (r *taskRepository) AddWatcher(ctx context.Context, taskID, userID string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&TaskWatcher{TaskID: taskID, UserID: userID, CreatedAt: time.Now()})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// RemoveWatcher unsubscribes a user from a task. It reports false if the user
+// was not watching.
+func (r *taskRepository) RemoveWatcher(ctx context.Context, taskID, userID string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Where("task_id = ? AND user_id = ?", taskID, userID).
+		Delete(&TaskWatcher{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// ListWatcherIDs returns the IDs of the users watching a task
+func (r *taskRepository) ListWatcherIDs(ctx context.Context, taskID string) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).
+		Model(&TaskWatcher{}).
+		Where("task_id = ?", taskID).
+		Order("created_at").
+		Pluck("user_id", &ids).Error
+	return ids, err
 }
@@ -2,12 +2,19 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+
+	"github.com/layababa/tg_todo/server/internal/models"
 )
 
 func setupTaskTestDB(t *testing.T) *gorm.DB {
@@ -19,20 +26,35 @@ func setupTaskTestDB(t *testing.T) *gorm.DB {
 		`CREATE TABLE tasks (
 			id TEXT PRIMARY KEY,
 			notion_page_id TEXT,
+			parent_id TEXT,
 			title TEXT NOT NULL,
 			description TEXT,
 			status TEXT,
+			status_category TEXT NOT NULL DEFAULT 'todo',
+			custom_fields TEXT NOT NULL DEFAULT '{}',
+			priority TEXT DEFAULT 'Medium',
 			sync_status TEXT,
 			group_id TEXT,
 			database_id TEXT,
 			topic TEXT,
 			due_at DATETIME,
+			start_at DATETIME,
+			all_day BOOLEAN NOT NULL DEFAULT 0,
 			creator_id TEXT,
 			chat_jump_url TEXT,
 			notion_url TEXT,
 			archived BOOLEAN DEFAULT 0,
+			completed_at DATETIME,
+			auto_complete BOOLEAN DEFAULT 0,
+			estimate_minutes INTEGER,
 			reminder_1h_sent BOOLEAN DEFAULT 0,
 			reminder_due_sent BOOLEAN DEFAULT 0,
+			reminder_snoozed_until DATETIME,
+			recurrence TEXT DEFAULT '',
+			recurrence_parent_id TEXT,
+			recurrence_index INTEGER DEFAULT 0,
+			recurrence_spawned BOOLEAN DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 1,
 			created_at DATETIME,
 			updated_at DATETIME,
 			deleted_at DATETIME
@@ -41,7 +63,58 @@ func setupTaskTestDB(t *testing.T) *gorm.DB {
 			task_id TEXT,
 			user_id TEXT,
 			assigned_by TEXT,
-			assigned_at DATETIME
+			assigned_at DATETIME,
+			PRIMARY KEY (task_id, user_id)
+		);`,
+		`CREATE TABLE time_entries (
+			id TEXT PRIMARY KEY,
+			task_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			ended_at DATETIME,
+			duration_seconds INTEGER NOT NULL DEFAULT 0,
+			note TEXT,
+			source TEXT NOT NULL DEFAULT 'timer',
+			created_at DATETIME
+		);`,
+		`CREATE TABLE task_templates (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			user_id TEXT,
+			group_id TEXT,
+			title_pattern TEXT NOT NULL,
+			description TEXT,
+			priority TEXT NOT NULL DEFAULT 'Medium',
+			assignee_ids TEXT NOT NULL DEFAULT '[]',
+			labels TEXT NOT NULL DEFAULT '[]',
+			due_offset_minutes INTEGER,
+			subtasks TEXT NOT NULL DEFAULT '[]',
+			created_by TEXT,
+			created_at DATETIME,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE task_watchers (
+			task_id TEXT,
+			user_id TEXT,
+			created_at DATETIME,
+			PRIMARY KEY (task_id, user_id)
+		);`,
+		`CREATE TABLE labels (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			created_at DATETIME
+		);`,
+		`CREATE TABLE task_labels (
+			task_id TEXT,
+			label_id TEXT,
+			PRIMARY KEY (task_id, label_id)
+		);`,
+		`CREATE TABLE task_dependencies (
+			task_id TEXT,
+			blocked_by_id TEXT,
+			created_by TEXT,
+			created_at DATETIME,
+			PRIMARY KEY (task_id, blocked_by_id)
 		);`,
 		`CREATE TABLE task_context_snapshots (
 			id TEXT PRIMARY KEY,
@@ -52,9 +125,95 @@ func setupTaskTestDB(t *testing.T) *gorm.DB {
 			tg_message_id INTEGER,
 			created_at DATETIME
 		);`,
+		`CREATE TABLE task_attachments (
+			id TEXT PRIMARY KEY,
+			task_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			file_id TEXT NOT NULL,
+			file_unique_id TEXT,
+			file_name TEXT,
+			mime_type TEXT,
+			file_size INTEGER NOT NULL DEFAULT 0,
+			width INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			duration INTEGER NOT NULL DEFAULT 0,
+			caption TEXT,
+			tg_message_id INTEGER,
+			uploaded_by TEXT,
+			created_at DATETIME
+		);`,
+		`CREATE TABLE task_comments (
+			id TEXT PRIMARY KEY,
+			task_id TEXT,
+			parent_id TEXT,
+			user_id TEXT,
+			content TEXT,
+			created_at DATETIME,
+			updated_at DATETIME,
+			edited_at DATETIME,
+			deleted_at DATETIME
+		);`,
+		`CREATE TABLE groups (
+			id TEXT PRIMARY KEY,
+			title TEXT,
+			status TEXT,
+			database_id TEXT,
+			notion_access_token TEXT,
+			database_name TEXT,
+			auto_archive_days INTEGER DEFAULT 0,
+			created_at DATETIME,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE workflow_statuses (
+			id TEXT PRIMARY KEY,
+			group_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			category TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			next TEXT NOT NULL DEFAULT '[]',
+			created_at DATETIME,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE group_custom_fields (
+			id TEXT PRIMARY KEY,
+			group_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			options TEXT NOT NULL DEFAULT '[]',
+			created_at DATETIME,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE user_groups (
+			user_id TEXT,
+			group_id TEXT,
+			role TEXT,
+			created_at DATETIME,
+			PRIMARY KEY (user_id, group_id)
+		);`,
+		`CREATE TABLE task_events (
+			id TEXT PRIMARY KEY,
+			task_id TEXT,
+			actor_id TEXT,
+			event TEXT NOT NULL,
+			source TEXT NOT NULL DEFAULT 'app',
+			before TEXT,
+			after TEXT,
+			created_at DATETIME
+		);`,
 		`CREATE TABLE users (
 			id TEXT PRIMARY KEY,
 			tg_id INTEGER,
+			tg_username TEXT,
+			name TEXT,
+			photo_url TEXT,
+			avatar TEXT,
+			timezone TEXT,
+			default_database_id TEXT,
+			notion_connected BOOLEAN,
+			calendar_token TEXT,
+			created_at DATETIME,
+			updated_at DATETIME,
 			deleted_at DATETIME
 		);`,
 	}
@@ -97,6 +256,18 @@ func TestListByUserFiltersViews(t *testing.T) {
 	res, err = repo.ListByUser(ctx, creatorID, TaskListFilter{View: TaskViewCreated})
 	require.NoError(t, err)
 	require.Len(t, res, 2) // both tasks created by creator
+
+	// The group view ignores who created or is assigned to the task
+	groupID := "-100123"
+	taskInGroup := Task{ID: uuid.NewString(), Title: "In group", CreatorID: &creatorID, GroupID: &groupID}
+	insertTask(t, db, taskInGroup)
+	res, err = repo.ListByUser(ctx, uuid.NewString(), TaskListFilter{View: TaskViewGroup, GroupID: &groupID})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, taskInGroup.ID, res[0].ID)
+
+	_, err = repo.ListByUser(ctx, creatorID, TaskListFilter{View: TaskViewGroup})
+	require.Error(t, err)
 }
 
 func TestSoftDeleteRemovesTask(t *testing.T) {
@@ -119,3 +290,1041 @@ func TestSoftDeleteRemovesTask(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, res, 0)
 }
+
+func TestUpdateRejectsStaleVersion(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	task := &Task{ID: uuid.NewString(), Title: "Original"}
+	require.NoError(t, repo.Create(ctx, task))
+	require.Equal(t, 1, task.Version)
+
+	first, err := repo.GetByID(ctx, task.ID)
+	require.NoError(t, err)
+	second, err := repo.GetByID(ctx, task.ID)
+	require.NoError(t, err)
+
+	first.Title = "First"
+	require.NoError(t, repo.Update(ctx, first))
+	require.Equal(t, 2, first.Version)
+
+	second.Title = "Second"
+	require.ErrorIs(t, repo.Update(ctx, second), ErrVersionConflict)
+	require.Equal(t, 1, second.Version)
+
+	stored, err := repo.GetByID(ctx, task.ID)
+	require.NoError(t, err)
+	require.Equal(t, "First", stored.Title)
+	require.Equal(t, 2, stored.Version)
+}
+
+func TestTransactionRollsBackOnError(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	task := &Task{ID: uuid.NewString(), Title: "Original"}
+	require.NoError(t, repo.Create(ctx, task))
+
+	boom := errors.New("boom")
+	err := repo.Transaction(ctx, func(tx TaskRepository) error {
+		loaded, err := tx.GetByID(ctx, task.ID)
+		require.NoError(t, err)
+		loaded.Title = "Changed"
+		require.NoError(t, tx.Update(ctx, loaded))
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+
+	stored, err := repo.GetByID(ctx, task.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Original", stored.Title)
+	require.Equal(t, 1, stored.Version)
+}
+
+func TestAssigneesRecordAssigner(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	creator := models.User{ID: uuid.NewString(), TgID: 1, Name: "creator"}
+	alice := models.User{ID: uuid.NewString(), TgID: 2, Name: "alice"}
+	bob := models.User{ID: uuid.NewString(), TgID: 3, Name: "bob"}
+	for _, u := range []*models.User{&creator, &alice, &bob} {
+		require.NoError(t, db.Create(u).Error)
+	}
+
+	task := &Task{ID: uuid.NewString(), Title: "Shared", CreatorID: &creator.ID, Assignees: []models.User{alice}}
+	require.NoError(t, repo.Create(ctx, task))
+
+	assigners := func() map[string]*string {
+		var rows []TaskAssignee
+		require.NoError(t, db.Where("task_id = ?", task.ID).Find(&rows).Error)
+		out := make(map[string]*string, len(rows))
+		for _, row := range rows {
+			out[row.UserID] = row.AssignedBy
+		}
+		return out
+	}
+	require.Equal(t, map[string]*string{alice.ID: &creator.ID}, assigners())
+
+	// Claiming appends instead of replacing
+	added, err := repo.AddAssignee(ctx, task.ID, bob.ID, &bob.ID)
+	require.NoError(t, err)
+	require.True(t, added)
+	added, err = repo.AddAssignee(ctx, task.ID, bob.ID, &creator.ID)
+	require.NoError(t, err)
+	require.False(t, added)
+	require.Equal(t, map[string]*string{alice.ID: &creator.ID, bob.ID: &bob.ID}, assigners())
+
+	removed, err := repo.RemoveAssignee(ctx, task.ID, alice.ID)
+	require.NoError(t, err)
+	require.True(t, removed)
+
+	// Replacing keeps the original assigner of users who stay assigned
+	require.NoError(t, repo.SetAssignees(ctx, task.ID, []string{bob.ID, alice.ID}, &creator.ID))
+	require.Equal(t, map[string]*string{alice.ID: &creator.ID, bob.ID: &bob.ID}, assigners())
+	require.NoError(t, repo.SetAssignees(ctx, task.ID, nil, &creator.ID))
+	require.Empty(t, assigners())
+}
+
+func TestWatchers(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	pm := uuid.NewString()
+	require.NoError(t, db.Exec("INSERT INTO users (id, tg_id, name) VALUES (?, 1, 'pm')", pm).Error)
+	task := &Task{ID: uuid.NewString(), Title: "Launch"}
+	require.NoError(t, repo.Create(ctx, task))
+
+	added, err := repo.AddWatcher(ctx, task.ID, pm)
+	require.NoError(t, err)
+	require.True(t, added)
+	added, err = repo.AddWatcher(ctx, task.ID, pm)
+	require.NoError(t, err)
+	require.False(t, added)
+
+	ids, err := repo.ListWatcherIDs(ctx, task.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{pm}, ids)
+	loaded, err := repo.GetByID(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, loaded.Watchers, 1)
+
+	removed, err := repo.RemoveWatcher(ctx, task.ID, pm)
+	require.NoError(t, err)
+	require.True(t, removed)
+	ids, err = repo.ListWatcherIDs(ctx, task.ID)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+}
+
+func TestTimeEntriesTimerAndReport(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTimeEntryRepository(db)
+	ctx := context.Background()
+
+	alice, bob := uuid.NewString(), uuid.NewString()
+	groupID := "-100123"
+	groupTask, personalTask := uuid.NewString(), uuid.NewString()
+	insertTask(t, db, Task{ID: groupTask, Title: "Design", GroupID: &groupID})
+	insertTask(t, db, Task{ID: personalTask, Title: "Notes"})
+
+	day1 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	running := &TimeEntry{ID: uuid.NewString(), TaskID: groupTask, UserID: alice, StartedAt: day1, Source: TimeEntrySourceTimer}
+	require.NoError(t, repo.Create(ctx, running))
+
+	got, err := repo.GetRunning(ctx, alice)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Equal(t, running.ID, got.ID)
+	require.NoError(t, repo.Stop(ctx, got, day1.Add(90*time.Minute)))
+	require.Equal(t, int64(5400), got.DurationSeconds)
+	got, err = repo.GetRunning(ctx, alice)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	ended := day2.Add(30 * time.Minute)
+	require.NoError(t, repo.Create(ctx, &TimeEntry{ID: uuid.NewString(), TaskID: personalTask, UserID: alice, StartedAt: day2, EndedAt: &ended, DurationSeconds: 1800, Source: TimeEntrySourceManual}))
+	require.NoError(t, repo.Create(ctx, &TimeEntry{ID: uuid.NewString(), TaskID: groupTask, UserID: bob, StartedAt: day2, EndedAt: &ended, DurationSeconds: 1800, Source: TimeEntrySourceManual}))
+	// Running entries are not counted
+	require.NoError(t, repo.Create(ctx, &TimeEntry{ID: uuid.NewString(), TaskID: groupTask, UserID: bob, StartedAt: day2.Add(time.Hour), Source: TimeEntrySourceTimer}))
+
+	total, err := repo.SumByTask(ctx, groupTask)
+	require.NoError(t, err)
+	require.Equal(t, int64(7200), total)
+
+	rows, err := repo.Report(ctx, TimeReportFilter{GroupBy: TimeReportByGroup})
+	require.NoError(t, err)
+	require.Equal(t, []TimeReportRow{{Key: "", TotalSeconds: 1800, Entries: 1}, {Key: groupID, TotalSeconds: 7200, Entries: 2}}, rows)
+
+	rows, err = repo.Report(ctx, TimeReportFilter{GroupBy: TimeReportByDay, UserID: alice})
+	require.NoError(t, err)
+	require.Equal(t, []TimeReportRow{{Key: "2026-03-02", TotalSeconds: 5400, Entries: 1}, {Key: "2026-03-03", TotalSeconds: 1800, Entries: 1}}, rows)
+
+	from := day2
+	rows, err = repo.Report(ctx, TimeReportFilter{GroupBy: TimeReportByUser, GroupID: groupID, From: &from})
+	require.NoError(t, err)
+	require.Equal(t, []TimeReportRow{{Key: bob, TotalSeconds: 1800, Entries: 1}}, rows)
+
+	entries, err := repo.ListByTask(ctx, groupTask)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+}
+
+func TestTemplatesLookupAndList(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTemplateRepository(db)
+	ctx := context.Background()
+
+	alice, bob, groupID := uuid.NewString(), uuid.NewString(), "-1001"
+	newTemplate := func(name string, userID, group *string) *TaskTemplate {
+		return &TaskTemplate{
+			ID:           uuid.NewString(),
+			Name:         name,
+			UserID:       userID,
+			GroupID:      group,
+			TitlePattern: "[" + name + "] {input}",
+			Priority:     TaskPriorityMedium,
+			AssigneeIDs:  datatypes.NewJSONSlice([]string{bob}),
+			Labels:       datatypes.NewJSONSlice([]string{}),
+			Subtasks:     datatypes.NewJSONSlice([]string{"复现", "修复"}),
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+	}
+	personal := newTemplate("bug", &alice, nil)
+	require.NoError(t, repo.Create(ctx, personal))
+	require.NoError(t, repo.Create(ctx, newTemplate("bug", nil, &groupID)))
+	require.NoError(t, repo.Create(ctx, newTemplate("daily", &bob, nil)))
+
+	got, err := repo.GetUserTemplate(ctx, alice, " Bug ")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Equal(t, personal.ID, got.ID)
+	require.Equal(t, []string{"复现", "修复"}, []string(got.Subtasks))
+	require.Equal(t, []string{bob}, []string(got.AssigneeIDs))
+
+	got, err = repo.GetGroupTemplate(ctx, groupID, "bug")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Nil(t, got.UserID)
+
+	got, err = repo.GetUserTemplate(ctx, alice, "daily")
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	list, err := repo.List(ctx, alice, "")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	list, err = repo.List(ctx, alice, groupID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	personal.Name = "crash"
+	personal.Subtasks = datatypes.NewJSONSlice([]string{})
+	require.NoError(t, repo.Update(ctx, personal))
+	got, err = repo.GetByID(ctx, personal.ID)
+	require.NoError(t, err)
+	require.Equal(t, "crash", got.Name)
+	require.Empty(t, got.Subtasks)
+
+	require.NoError(t, repo.Delete(ctx, personal.ID))
+	got, err = repo.GetByID(ctx, personal.ID)
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestTrashListRestoreAndPurge(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	creator := uuid.NewString()
+	stranger := uuid.NewString()
+	oldID, recentID, childID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	insertTask(t, db, Task{ID: oldID, Title: "Old", CreatorID: &creator})
+	insertTask(t, db, Task{ID: recentID, Title: "Recent", CreatorID: &creator})
+	insertTask(t, db, Task{ID: childID, Title: "Child", CreatorID: &creator, ParentID: &oldID})
+	require.NoError(t, db.Create(&TaskComment{ID: uuid.NewString(), TaskID: oldID, UserID: creator, Content: "bye"}).Error)
+
+	now := time.Now()
+	require.NoError(t, db.Unscoped().Model(&Task{}).Where("id = ?", oldID).Update("deleted_at", now.Add(-40*24*time.Hour)).Error)
+	require.NoError(t, repo.SoftDelete(ctx, recentID))
+
+	trash, err := repo.ListDeleted(ctx, creator, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	require.Equal(t, recentID, trash[0].ID) // Most recently deleted first
+
+	trash, err = repo.ListDeleted(ctx, stranger, 10, 0)
+	require.NoError(t, err)
+	require.Empty(t, trash)
+
+	deleted, err := repo.GetDeletedByID(ctx, childID)
+	require.NoError(t, err)
+	require.Nil(t, deleted) // Live tasks are not in the trash
+
+	purged, err := repo.PurgeDeleted(ctx, now.Add(-30*24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), purged)
+
+	var count int64
+	require.NoError(t, db.Unscoped().Model(&Task{}).Where("id = ?", oldID).Count(&count).Error)
+	require.Zero(t, count)
+	require.NoError(t, db.Model(&TaskComment{}).Where("task_id = ?", oldID).Count(&count).Error)
+	require.Zero(t, count)
+
+	child, err := repo.GetByID(ctx, childID)
+	require.NoError(t, err)
+	require.NotNil(t, child)
+	require.Nil(t, child.ParentID) // Detached rather than cascaded
+
+	require.NoError(t, repo.Restore(ctx, recentID))
+	restored, err := repo.GetByID(ctx, recentID)
+	require.NoError(t, err)
+	require.NotNil(t, restored)
+	deleted, err = repo.GetDeletedByID(ctx, recentID)
+	require.NoError(t, err)
+	require.Nil(t, deleted)
+}
+
+func TestArchivedViewCountsAndAutoArchive(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	creator := uuid.NewString()
+	groupID, quietGroupID := "-1001", "-1002"
+	require.NoError(t, db.Create(&models.Group{ID: groupID, Title: "Ops", AutoArchiveDays: 7}).Error)
+	require.NoError(t, db.Create(&models.Group{ID: quietGroupID, Title: "Quiet"}).Error)
+
+	staleDone := now.AddDate(0, 0, -10)
+	freshDone := now.AddDate(0, 0, -2)
+	stale := Task{ID: uuid.NewString(), Title: "Stale", Status: TaskStatusDone, CreatorID: &creator, GroupID: &groupID, CompletedAt: &staleDone}
+	fresh := Task{ID: uuid.NewString(), Title: "Fresh", Status: TaskStatusDone, CreatorID: &creator, GroupID: &groupID, CompletedAt: &freshDone}
+	quiet := Task{ID: uuid.NewString(), Title: "Quiet", Status: TaskStatusDone, CreatorID: &creator, GroupID: &quietGroupID, CompletedAt: &staleDone}
+	open := Task{ID: uuid.NewString(), Title: "Open", Status: TaskStatusToDo, CreatorID: &creator, GroupID: &groupID}
+	for _, task := range []Task{stale, fresh, quiet, open} {
+		insertTask(t, db, task)
+	}
+
+	due, err := repo.ListAutoArchivable(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, stale.ID, due[0].ID)
+
+	require.NoError(t, repo.SetArchived(ctx, stale.ID, true))
+	require.NoError(t, repo.SetArchived(ctx, open.ID, true))
+
+	archived, err := repo.ListByUser(ctx, creator, TaskListFilter{View: TaskViewArchived})
+	require.NoError(t, err)
+	require.Len(t, archived, 2)
+
+	all, err := repo.ListByUser(ctx, creator, TaskListFilter{View: TaskViewAll})
+	require.NoError(t, err)
+	require.Len(t, all, 2) // fresh and quiet
+
+	counts, err := repo.GetTaskCounts(ctx, creator)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), counts.Created)
+	require.Equal(t, int64(2), counts.Done)
+
+	due, err = repo.ListAutoArchivable(ctx, now)
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
+
+func TestListByUserFiltersAndSortsByPriority(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+
+	creatorID := uuid.NewString()
+	low := Task{ID: uuid.NewString(), Title: "Low", CreatorID: &creatorID, Priority: TaskPriorityLow}
+	high := Task{ID: uuid.NewString(), Title: "High", CreatorID: &creatorID, Priority: TaskPriorityHigh}
+	medium := Task{ID: uuid.NewString(), Title: "Medium", CreatorID: &creatorID}
+
+	insertTask(t, db, low)
+	insertTask(t, db, high)
+	insertTask(t, db, medium)
+
+	ctx := context.Background()
+
+	res, err := repo.ListByUser(ctx, creatorID, TaskListFilter{View: TaskViewCreated, Sort: TaskSortPriority})
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	require.Equal(t, high.ID, res[0].ID)
+	require.Equal(t, medium.ID, res[1].ID)
+	require.Equal(t, TaskPriorityMedium, res[1].Priority) // column default
+	require.Equal(t, low.ID, res[2].ID)
+
+	res, err = repo.ListByUser(ctx, creatorID, TaskListFilter{
+		View:       TaskViewCreated,
+		Priorities: []TaskPriority{TaskPriorityHigh, TaskPriorityLow},
+	})
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	for _, task := range res {
+		require.NotEqual(t, TaskPriorityMedium, task.Priority)
+	}
+}
+
+func TestListRecurringToSpawnAndClaim(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+
+	creatorID := uuid.NewString()
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(24 * time.Hour)
+
+	done := Task{ID: uuid.NewString(), Title: "Done", CreatorID: &creatorID, Status: TaskStatusDone, DueAt: &future, Recurrence: "FREQ=DAILY"}
+	overdue := Task{ID: uuid.NewString(), Title: "Overdue", CreatorID: &creatorID, Status: TaskStatusToDo, DueAt: &past, Recurrence: "FREQ=DAILY"}
+	upcoming := Task{ID: uuid.NewString(), Title: "Upcoming", CreatorID: &creatorID, Status: TaskStatusToDo, DueAt: &future, Recurrence: "FREQ=DAILY"}
+	oneOff := Task{ID: uuid.NewString(), Title: "One-off", CreatorID: &creatorID, Status: TaskStatusDone, DueAt: &past}
+
+	for _, task := range []Task{done, overdue, upcoming, oneOff} {
+		insertTask(t, db, task)
+	}
+
+	ctx := context.Background()
+	res, err := repo.ListRecurringToSpawn(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	claimed, err := repo.SetRecurrenceSpawned(ctx, done.ID, true)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	claimed, err = repo.SetRecurrenceSpawned(ctx, done.ID, true)
+	require.NoError(t, err)
+	require.False(t, claimed, "second claim must not succeed")
+
+	res, err = repo.ListRecurringToSpawn(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, overdue.ID, res[0].ID)
+}
+
+func TestSubtasksAndProgress(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+
+	creatorID := uuid.NewString()
+	parent := Task{ID: uuid.NewString(), Title: "Release", CreatorID: &creatorID}
+	insertTask(t, db, parent)
+	insertTask(t, db, Task{ID: uuid.NewString(), ParentID: &parent.ID, Title: "Build", CreatorID: &creatorID, Status: TaskStatusDone})
+	insertTask(t, db, Task{ID: uuid.NewString(), ParentID: &parent.ID, Title: "Deploy", CreatorID: &creatorID, Status: TaskStatusToDo})
+
+	ctx := context.Background()
+
+	subtasks, err := repo.ListSubtasks(ctx, parent.ID)
+	require.NoError(t, err)
+	require.Len(t, subtasks, 2)
+
+	progress, err := repo.GetSubtaskProgress(ctx, []string{parent.ID, subtasks[0].ID})
+	require.NoError(t, err)
+	require.Equal(t, SubtaskProgress{Total: 2, Done: 1}, progress[parent.ID])
+	_, hasChildren := progress[subtasks[0].ID]
+	require.False(t, hasChildren)
+
+	got, err := repo.GetByID(ctx, parent.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), got.SubtaskTotal)
+	require.Equal(t, int64(1), got.SubtaskDone)
+}
+
+func TestLabelsFindOrCreateAndFilter(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	labelRepo := NewLabelRepository(db)
+	ctx := context.Background()
+
+	// sqlite has no gen_random_uuid(), so seed IDs explicitly
+	require.NoError(t, db.Create(&Label{ID: uuid.NewString(), Name: "bug"}).Error)
+
+	labels, err := labelRepo.FindOrCreate(ctx, []string{"#Bug", "bug"})
+	require.NoError(t, err)
+	require.Len(t, labels, 1)
+	require.Equal(t, "bug", labels[0].Name)
+
+	creatorID := uuid.NewString()
+	tagged := Task{ID: uuid.NewString(), Title: "Crash on login", CreatorID: &creatorID}
+	plain := Task{ID: uuid.NewString(), Title: "Write docs", CreatorID: &creatorID}
+	insertTask(t, db, tagged)
+	insertTask(t, db, plain)
+	require.NoError(t, labelRepo.ReplaceTaskLabels(ctx, tagged.ID, labels))
+
+	res, err := repo.ListByUser(ctx, creatorID, TaskListFilter{View: TaskViewCreated, Labels: []string{"bug"}})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, tagged.ID, res[0].ID)
+	require.Len(t, res[0].Labels, 1)
+
+	require.NoError(t, labelRepo.ReplaceTaskLabels(ctx, tagged.ID, []Label{}))
+	got, err := repo.GetByID(ctx, tagged.ID)
+	require.NoError(t, err)
+	require.Empty(t, got.Labels)
+}
+
+func TestNormalizeLabel(t *testing.T) {
+	require.Equal(t, "frontend", NormalizeLabel(" #Frontend "))
+	require.Equal(t, "缺陷", NormalizeLabel("#缺陷"))
+}
+
+func TestDependenciesBlockersAndOpenCount(t *testing.T) {
+	db := setupTaskTestDB(t)
+	depRepo := NewDependencyRepository(db)
+	ctx := context.Background()
+
+	qa := Task{ID: uuid.NewString(), Title: "QA sign-off", Status: TaskStatusToDo}
+	review := Task{ID: uuid.NewString(), Title: "Code review", Status: TaskStatusDone}
+	deploy := Task{ID: uuid.NewString(), Title: "Deploy", Status: TaskStatusToDo}
+	insertTask(t, db, qa)
+	insertTask(t, db, review)
+	insertTask(t, db, deploy)
+
+	require.NoError(t, depRepo.Add(ctx, &TaskDependency{TaskID: deploy.ID, BlockedByID: qa.ID}))
+	require.NoError(t, depRepo.Add(ctx, &TaskDependency{TaskID: deploy.ID, BlockedByID: review.ID}))
+	require.NoError(t, depRepo.Add(ctx, &TaskDependency{TaskID: deploy.ID, BlockedByID: qa.ID}))
+
+	blockers, err := depRepo.ListBlockers(ctx, deploy.ID)
+	require.NoError(t, err)
+	require.Len(t, blockers, 2)
+
+	blocked, err := depRepo.ListBlocked(ctx, qa.ID)
+	require.NoError(t, err)
+	require.Len(t, blocked, 1)
+	require.Equal(t, deploy.ID, blocked[0].ID)
+
+	ids, err := depRepo.ListBlockerIDs(ctx, []string{deploy.ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{qa.ID, review.ID}, ids)
+
+	open, err := depRepo.CountOpenBlockers(ctx, deploy.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), open)
+
+	require.NoError(t, depRepo.Remove(ctx, deploy.ID, qa.ID))
+	open, err = depRepo.CountOpenBlockers(ctx, deploy.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), open)
+}
+
+func TestSearchRanksMatchesAndRespectsVisibility(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	me := uuid.NewString()
+	other := uuid.NewString()
+	groupID := "-100123"
+	now := time.Now()
+
+	titleHit := Task{ID: uuid.NewString(), Title: "上个月的发票报销", CreatorID: &me, UpdatedAt: now.Add(-time.Hour)}
+	commentHit := Task{ID: uuid.NewString(), Title: "财务对账", CreatorID: &other, GroupID: &groupID, UpdatedAt: now}
+	snapshotHit := Task{ID: uuid.NewString(), Title: "Follow up", Description: "ask finance", CreatorID: &other, UpdatedAt: now}
+	hidden := Task{ID: uuid.NewString(), Title: "发票 (someone else)", CreatorID: &other, UpdatedAt: now}
+	insertTask(t, db, titleHit)
+	insertTask(t, db, commentHit)
+	insertTask(t, db, snapshotHit, me)
+	insertTask(t, db, hidden)
+
+	require.NoError(t, db.Exec("INSERT INTO user_groups (user_id, group_id, role) VALUES (?, ?, 'Member')", me, groupID).Error)
+	require.NoError(t, db.Create(&TaskComment{ID: uuid.NewString(), TaskID: commentHit.ID, UserID: other, Content: "发票已经寄出", CreatedAt: now}).Error)
+	require.NoError(t, db.Create(&TaskContextSnapshot{ID: uuid.NewString(), TaskID: snapshotHit.ID, Role: "other", Text: "记得开发票", CreatedAt: now}).Error)
+
+	hits, err := repo.Search(ctx, me, TaskSearchFilter{Terms: SplitSearchTerms("发票")})
+	require.NoError(t, err)
+	require.Len(t, hits, 3)
+	require.Equal(t, titleHit.ID, hits[0].Task.ID)
+	require.Equal(t, commentHit.ID, hits[1].Task.ID)
+	require.Equal(t, "发票已经寄出", hits[1].CommentMatch)
+	require.Equal(t, snapshotHit.ID, hits[2].Task.ID)
+	require.Equal(t, "记得开发票", hits[2].SnapshotMatch)
+
+	// Every term must match
+	hits, err = repo.Search(ctx, me, TaskSearchFilter{Terms: SplitSearchTerms("FOLLOW finance")})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	require.Equal(t, snapshotHit.ID, hits[0].Task.ID)
+
+	// LIKE wildcards are matched literally
+	hits, err = repo.Search(ctx, me, TaskSearchFilter{Terms: SplitSearchTerms("%")})
+	require.NoError(t, err)
+	require.Empty(t, hits)
+}
+
+func TestSplitSearchTerms(t *testing.T) {
+	require.Equal(t, []string{"invoice", "上个月"}, SplitSearchTerms("  Invoice 上个月 invoice "))
+	require.Empty(t, SplitSearchTerms("   "))
+}
+
+func TestListByUserCursorPagination(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	creatorID := uuid.NewString()
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	due := func(d int) *time.Time {
+		v := base.AddDate(0, 0, d)
+		return &v
+	}
+	titles := []string{"delta", "alpha", "echo", "charlie", "bravo"}
+	priorities := []TaskPriority{TaskPriorityLow, TaskPriorityHigh, TaskPriorityMedium, TaskPriorityHigh, TaskPriorityMedium}
+	dues := []*time.Time{due(3), nil, due(1), due(1), nil}
+	for i, title := range titles {
+		insertTask(t, db, Task{
+			ID:        uuid.NewString(),
+			Title:     title,
+			CreatorID: &creatorID,
+			Priority:  priorities[i],
+			DueAt:     dues[i],
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: base.Add(time.Duration(len(titles)-i) * time.Hour),
+		})
+	}
+
+	for _, sort := range []TaskSort{TaskSortCreated, TaskSortUpdated, TaskSortDue, TaskSortPriority, TaskSortTitle} {
+		t.Run(string(sort), func(t *testing.T) {
+			full, err := repo.ListByUser(ctx, creatorID, TaskListFilter{View: TaskViewCreated, Sort: sort})
+			require.NoError(t, err)
+			require.Len(t, full, len(titles))
+
+			var paged []string
+			filter := TaskListFilter{View: TaskViewCreated, Sort: sort, Limit: 2}
+			for page := 0; page < 5; page++ {
+				res, err := repo.ListByUser(ctx, creatorID, filter)
+				require.NoError(t, err)
+				for _, task := range res {
+					paged = append(paged, task.Title)
+				}
+				if len(res) < filter.Limit {
+					break
+				}
+				filter.Cursor = EncodeTaskCursor(creatorID, filter, &res[len(res)-1])
+			}
+
+			var want []string
+			for _, task := range full {
+				want = append(want, task.Title)
+			}
+			require.Equal(t, want, paged)
+		})
+	}
+
+	// Sort specific orderings
+	res, err := repo.ListByUser(ctx, creatorID, TaskListFilter{View: TaskViewCreated, Sort: TaskSortTitle})
+	require.NoError(t, err)
+	require.Equal(t, "alpha", res[0].Title)
+	res, err = repo.ListByUser(ctx, creatorID, TaskListFilter{View: TaskViewCreated, Sort: TaskSortDue})
+	require.NoError(t, err)
+	require.Nil(t, res[len(res)-1].DueAt)
+	require.Equal(t, "delta", res[2].Title)
+}
+
+func TestListByUserCursorStableWhenTasksAdded(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	creatorID := uuid.NewString()
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		insertTask(t, db, Task{ID: uuid.NewString(), Title: strconv.Itoa(i), CreatorID: &creatorID, CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	filter := TaskListFilter{View: TaskViewCreated, Sort: TaskSortCreated, Limit: 2}
+	first, err := repo.ListByUser(ctx, creatorID, filter)
+	require.NoError(t, err)
+	require.Equal(t, []string{"3", "2"}, []string{first[0].Title, first[1].Title})
+
+	// A task created mid-scroll does not shift the next page
+	insertTask(t, db, Task{ID: uuid.NewString(), Title: "new", CreatorID: &creatorID, CreatedAt: base.Add(time.Hour)})
+
+	filter.Cursor = EncodeTaskCursor(creatorID, filter, &first[1])
+	second, err := repo.ListByUser(ctx, creatorID, filter)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "0"}, []string{second[0].Title, second[1].Title})
+
+	// Cursors are bound to their sort
+	filter.Sort = TaskSortTitle
+	_, err = repo.ListByUser(ctx, creatorID, filter)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+	filter.Cursor = "not-a-cursor"
+	_, err = repo.ListByUser(ctx, creatorID, filter)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestListByUserWithQuery(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	me := uuid.NewString()
+	alice := uuid.NewString()
+	require.NoError(t, db.Exec("INSERT INTO users (id, tg_id, tg_username) VALUES (?, 1, 'me'), (?, 2, 'Alice')", me, alice).Error)
+	require.NoError(t, db.Exec("INSERT INTO groups (id, title) VALUES ('-100', 'Project Alpha')").Error)
+	groupID := "-100"
+
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	in := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+	insertTask(t, db, Task{ID: uuid.NewString(), Title: "alpha review", CreatorID: &me, Status: TaskStatusInProgress, GroupID: &groupID, DueAt: in(48 * time.Hour)}, alice)
+	insertTask(t, db, Task{ID: uuid.NewString(), Title: "alpha later", CreatorID: &me, Status: TaskStatusInProgress, GroupID: &groupID, DueAt: in(10 * 24 * time.Hour)}, alice)
+	insertTask(t, db, Task{ID: uuid.NewString(), Title: "personal", CreatorID: &me, Status: TaskStatusToDo, DueAt: in(-time.Hour)}, me)
+	wontfix := Task{ID: uuid.NewString(), Title: "alpha wontfix", CreatorID: &me, Status: TaskStatusInProgress, GroupID: &groupID, DueAt: in(time.Hour)}
+	insertTask(t, db, wontfix, alice)
+	label := Label{ID: uuid.NewString(), Name: "wontfix"}
+	require.NoError(t, db.Create(&label).Error)
+	require.NoError(t, NewLabelRepository(db).ReplaceTaskLabels(ctx, wontfix.ID, []Label{label}))
+
+	titles := func(q string) []string {
+		t.Helper()
+		query, err := ParseTaskQuery(q, now)
+		require.NoError(t, err)
+		res, err := repo.ListByUser(ctx, me, TaskListFilter{View: TaskViewAll, Sort: TaskSortTitle, Query: query})
+		require.NoError(t, err)
+		out := []string{}
+		for _, task := range res {
+			out = append(out, task.Title)
+		}
+		return out
+	}
+
+	require.Equal(t, []string{"alpha review"}, titles(`status:"In Progress" due:<7d group:"Project Alpha" assignee:@alice -label:wontfix`))
+	require.Equal(t, []string{"alpha later", "alpha review", "alpha wontfix"}, titles(`group:"project alpha"`))
+	require.Equal(t, []string{"personal"}, titles("-group:-100"))
+	// Keywords match categories, other values match status names
+	require.Equal(t, []string{"alpha later", "alpha review", "alpha wontfix"}, titles("status:doing"))
+	require.Equal(t, []string{"personal"}, titles(`status:"to do"`))
+	require.Equal(t, []string{"personal"}, titles("assignee:@me due:overdue"))
+	require.Equal(t, []string{"alpha later"}, titles("alpha due:>7d"))
+	require.Equal(t, []string{"alpha later", "alpha review", "personal"}, titles("-tag:wontfix status:todo,doing"))
+	require.Equal(t, []string{"alpha review", "alpha wontfix"}, titles("due:<=2025-03-12 -status:todo"))
+}
+
+func TestDueQueryMatchesAllDayDatesInUserZone(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	me := uuid.NewString()
+	date := func(y int, m time.Month, d int) *time.Time {
+		v := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &v
+	}
+	at := func(v time.Time) *time.Time { return &v }
+	insertTask(t, db, Task{ID: uuid.NewString(), Title: "all-day today", CreatorID: &me, DueAt: date(2026, 3, 2), AllDay: true})
+	insertTask(t, db, Task{ID: uuid.NewString(), Title: "all-day yesterday", CreatorID: &me, DueAt: date(2026, 3, 1), AllDay: true})
+	insertTask(t, db, Task{ID: uuid.NewString(), Title: "timed tonight", CreatorID: &me, DueAt: at(time.Date(2026, 3, 3, 2, 0, 0, 0, time.UTC))})
+
+	// 20:00 on March 2nd in New York, already March 3rd in UTC
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	now := time.Date(2026, 3, 2, 20, 0, 0, 0, ny)
+
+	titles := func(q string) []string {
+		t.Helper()
+		query, err := ParseTaskQuery(q, now)
+		require.NoError(t, err)
+		res, err := repo.ListByUser(ctx, me, TaskListFilter{View: TaskViewAll, Sort: TaskSortTitle, Query: query})
+		require.NoError(t, err)
+		out := []string{}
+		for _, task := range res {
+			out = append(out, task.Title)
+		}
+		return out
+	}
+
+	require.Equal(t, []string{"all-day today", "timed tonight"}, titles("due:today"))
+	require.Equal(t, []string{"all-day yesterday"}, titles("due:overdue"))
+	require.Equal(t, []string{"all-day yesterday"}, titles("due:<2026-03-02"))
+}
+
+func TestParseTaskQueryErrors(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		q     string
+		token string
+		pos   int
+	}{
+		{`status:`, "status:", 0},
+		{`label:x colour:red`, "colour:red", 8},
+		{`group:"Project Alpha`, `"Project Alpha`, 6},
+		{`due:<7x`, "due:<7x", 0},
+		{`a priority:urgent`, "priority:urgent", 2},
+		{`assignee:`, "assignee:", 0},
+	}
+	for _, tc := range cases {
+		_, err := ParseTaskQuery(tc.q, now)
+		var qe *QueryError
+		require.ErrorAs(t, err, &qe, tc.q)
+		require.Equal(t, tc.token, qe.Token, tc.q)
+		require.Equal(t, tc.pos, qe.Pos, tc.q)
+	}
+
+	q, err := ParseTaskQuery("   ", now)
+	require.NoError(t, err)
+	require.True(t, q.IsEmpty())
+}
+
+func TestTaskEventsNewestFirstWithActor(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	actorID := uuid.NewString()
+	require.NoError(t, db.Exec("INSERT INTO users (id, tg_id, tg_username) VALUES (?, 1, 'alice')", actorID).Error)
+	taskID := uuid.NewString()
+	insertTask(t, db, Task{ID: taskID, Title: "Audit", CreatorID: &actorID})
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.CreateEvent(ctx, &TaskEvent{
+		ID: uuid.NewString(), TaskID: taskID, ActorID: &actorID, Event: TaskEventCreate, Source: TaskEventSourceBot,
+		After: []byte(`{"title":"Audit"}`), CreatedAt: base,
+	}))
+	require.NoError(t, repo.CreateEvent(ctx, &TaskEvent{
+		ID: uuid.NewString(), TaskID: taskID, Event: TaskEventStatus, Source: TaskEventSourceNotion,
+		Before: []byte(`{"status":"To Do"}`), After: []byte(`{"status":"Done"}`), CreatedAt: base.Add(time.Minute),
+	}))
+
+	events, err := repo.ListEvents(ctx, taskID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, TaskEventStatus, events[0].Event)
+	require.Nil(t, events[0].Actor)
+	require.JSONEq(t, `{"status":"Done"}`, string(events[0].After))
+	require.Equal(t, TaskEventCreate, events[1].Event)
+	require.NotNil(t, events[1].Actor)
+	require.Equal(t, "alice", events[1].Actor.TgUsername)
+
+	page, err := repo.ListEvents(ctx, taskID, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, TaskEventCreate, page[0].Event)
+}
+
+func TestAttachmentsCreatedWithTaskAndPreloaded(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	attachments := NewAttachmentRepository(db)
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	task := &Task{ID: uuid.NewString(), Title: "[图片]", Attachments: []TaskAttachment{
+		{ID: uuid.NewString(), Kind: AttachmentKindPhoto, FileID: "photo-1", Caption: "登录页报错", TgMessageID: 42, CreatedAt: base},
+	}}
+	require.NoError(t, repo.Create(ctx, task))
+	require.NoError(t, attachments.Create(ctx, &TaskAttachment{
+		ID: uuid.NewString(), TaskID: task.ID, Kind: AttachmentKindDocument, FileID: "doc-1", FileName: "log.txt", CreatedAt: base.Add(time.Minute),
+	}))
+
+	got, err := repo.GetByID(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, got.Attachments, 2)
+
+	list, err := attachments.ListByTask(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "photo-1", list[0].FileID)
+	require.Equal(t, task.ID, list[0].TaskID)
+	require.Equal(t, "log.txt", list[1].FileName)
+
+	one, err := attachments.GetByID(ctx, list[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, AttachmentKindDocument, one.Kind)
+
+	missing, err := attachments.GetByID(ctx, uuid.NewString())
+	require.NoError(t, err)
+	require.Nil(t, missing)
+}
+
+func TestUpdateAndDeleteComments(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	taskID := uuid.NewString()
+	insertTask(t, db, Task{ID: taskID, Title: "Discuss"})
+	root, err := repo.CreateComment(ctx, &TaskComment{ID: uuid.NewString(), TaskID: taskID, UserID: uuid.NewString(), Content: "root"})
+	require.NoError(t, err)
+	reply, err := repo.CreateComment(ctx, &TaskComment{ID: uuid.NewString(), TaskID: taskID, ParentID: &root.ID, UserID: uuid.NewString(), Content: "reply"})
+	require.NoError(t, err)
+
+	root.Content = "edited"
+	require.NoError(t, repo.UpdateComment(ctx, root))
+	got, err := repo.GetCommentByID(ctx, root.ID)
+	require.NoError(t, err)
+	require.Equal(t, "edited", got.Content)
+	require.NotNil(t, got.EditedAt)
+
+	// The root has a reply, so it stays as a cleared placeholder
+	kept, err := repo.DeleteComment(ctx, root.ID)
+	require.NoError(t, err)
+	require.True(t, kept)
+	got, err = repo.GetCommentByID(ctx, root.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.DeletedAt)
+	require.Empty(t, got.Content)
+
+	kept, err = repo.DeleteComment(ctx, reply.ID)
+	require.NoError(t, err)
+	require.False(t, kept)
+	got, err = repo.GetCommentByID(ctx, reply.ID)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	comments, err := repo.ListComments(ctx, taskID)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+}
+
+func TestMoveTasks(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	from, database := "g1", "db-1"
+	moved, other := uuid.NewString(), uuid.NewString()
+	insertTask(t, db, Task{ID: moved, Title: "Move me", Status: "Review", StatusCategory: StatusCategoryActive, GroupID: &from, DatabaseID: &database, Topic: "42"})
+	insertTask(t, db, Task{ID: other, Title: "Stay", GroupID: &from, DatabaseID: &database, Topic: "42"})
+
+	to := "g2"
+	doing := WorkflowStatus{Name: "进行中", Category: StatusCategoryActive}
+	require.NoError(t, repo.Move(ctx, []string{moved}, &to, nil, map[string]WorkflowStatus{moved: doing}))
+
+	got, err := repo.GetByID(ctx, moved)
+	require.NoError(t, err)
+	require.Equal(t, "g2", *got.GroupID)
+	require.Equal(t, TaskStatus("进行中"), got.Status)
+	require.Equal(t, StatusCategoryActive, got.StatusCategory)
+	require.Nil(t, got.DatabaseID)
+	require.Empty(t, got.Topic)
+	require.Equal(t, 2, got.Version)
+
+	got, err = repo.GetByID(ctx, other)
+	require.NoError(t, err)
+	require.Equal(t, "g1", *got.GroupID)
+	require.Equal(t, "42", got.Topic)
+}
+
+func TestWorkflowReplaceRemapsTasks(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewWorkflowRepository(db)
+	tasks := NewTaskRepository(db)
+	ctx := context.Background()
+
+	groupID, other, databaseID, otherDatabase := "g1", "g2", "db-1", "db-2"
+	require.NoError(t, db.Exec(`INSERT INTO groups (id, title, database_id, updated_at) VALUES (?, 'G1', ?, ?), (?, 'G2', ?, ?)`,
+		groupID, databaseID, time.Now(), other, otherDatabase, time.Now()).Error)
+	doing, kept, elsewhere := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	personal, personalElsewhere := uuid.NewString(), uuid.NewString()
+	insertTask(t, db, Task{ID: doing, Title: "Doing", Status: TaskStatusInProgress, StatusCategory: StatusCategoryActive, GroupID: &groupID})
+	insertTask(t, db, Task{ID: kept, Title: "Done", Status: TaskStatusDone, StatusCategory: StatusCategoryDone, GroupID: &groupID})
+	insertTask(t, db, Task{ID: elsewhere, Title: "Other group", Status: TaskStatusInProgress, StatusCategory: StatusCategoryActive, GroupID: &other})
+	// Personal tasks follow the workflow of the group bound to their database
+	insertTask(t, db, Task{ID: personal, Title: "Personal", Status: TaskStatusInProgress, StatusCategory: StatusCategoryActive, DatabaseID: &databaseID})
+	insertTask(t, db, Task{ID: personalElsewhere, Title: "Personal elsewhere", Status: TaskStatusInProgress, StatusCategory: StatusCategoryActive, DatabaseID: &otherDatabase})
+
+	workflow := Workflow{
+		{Name: "Backlog", Category: StatusCategoryTodo},
+		{Name: "Review", Category: StatusCategoryActive, Next: []string{"Done"}},
+		{Name: "Done", Category: StatusCategoryDone},
+	}
+	remap := map[TaskStatus]TaskStatus{TaskStatusToDo: "Backlog", TaskStatusInProgress: "Review"}
+	require.NoError(t, repo.Replace(ctx, groupID, workflow, remap))
+
+	got, err := repo.ListByGroup(ctx, groupID)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	require.Equal(t, TaskStatus("Review"), got[1].Name)
+	require.Equal(t, 1, got[1].Position)
+	require.True(t, got.Allows("Review", "done"))
+	require.False(t, got.Allows("Review", "Backlog"))
+
+	task, err := tasks.GetByID(ctx, doing)
+	require.NoError(t, err)
+	require.Equal(t, TaskStatus("Review"), task.Status)
+	require.Equal(t, StatusCategoryActive, task.StatusCategory)
+	require.Equal(t, TaskSyncStatusPending, task.SyncStatus)
+
+	task, err = tasks.GetByID(ctx, personal)
+	require.NoError(t, err)
+	require.Equal(t, TaskStatus("Review"), task.Status)
+	require.Equal(t, TaskSyncStatusPending, task.SyncStatus)
+
+	for _, id := range []string{elsewhere, personalElsewhere} {
+		task, err = tasks.GetByID(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, TaskStatusInProgress, task.Status)
+	}
+}
+
+func TestCustomFieldsStoredOnTasksAndSchemaReplaced(t *testing.T) {
+	db := setupTaskTestDB(t)
+	fields := NewCustomFieldRepository(db)
+	tasks := NewTaskRepository(db)
+	ctx := context.Background()
+
+	groupID, database := "g1", "db-1"
+	require.NoError(t, db.Exec("INSERT INTO groups (id, title, database_id) VALUES (?, 'Alpha', ?)", groupID, database).Error)
+	require.NoError(t, fields.Replace(ctx, groupID, CustomFields{
+		{Name: "Sprint", Type: CustomFieldSelect, Options: []string{"S1", "S2"}},
+		{Name: "Story Points", Type: CustomFieldNumber},
+	}))
+	require.NoError(t, fields.Replace(ctx, groupID, CustomFields{{Name: "Story Points", Type: CustomFieldNumber}}))
+
+	got, err := fields.ListByDatabase(ctx, database)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	_, ok := got.Find("Story Points")
+	require.True(t, ok)
+
+	id := uuid.NewString()
+	insertTask(t, db, Task{ID: id, Title: "Estimate me", GroupID: &groupID})
+	task, err := tasks.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Empty(t, task.CustomFields)
+
+	task.CustomFields["Story Points"] = 5.0
+	task.CustomFields["Labels"] = []string{"web"}
+	require.NoError(t, tasks.Update(ctx, task))
+
+	task, err = tasks.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, json.Number("5"), task.CustomFields["Story Points"])
+	require.Equal(t, []interface{}{"web"}, task.CustomFields["Labels"])
+}
+
+func TestSnoozedTasksSkipRemindersUntilDue(t *testing.T) {
+	db := setupTaskTestDB(t)
+	repo := NewTaskRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	dueAt := now.Add(30 * time.Minute)
+	id := uuid.NewString()
+	insertTask(t, db, Task{ID: id, Title: "Ship it", DueAt: &dueAt})
+	require.NoError(t, repo.UpdateReminderFlags(ctx, id, true, false))
+
+	until := now.Add(time.Hour)
+	require.NoError(t, repo.SnoozeReminders(ctx, id, until))
+
+	got, err := repo.ListForReminders(ctx, now)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	got, err = repo.ListForReminders(ctx, until.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.False(t, got[0].Reminder1hSent)
+	require.False(t, got[0].ReminderDueSent)
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// TaskTemplate represents the task_templates table: a reusable task layout
+// owned by a user (UserID) or shared with a Telegram group (GroupID).
+// Text fields may contain placeholders such as {date} or {chat}.
+type TaskTemplate struct {
+	ID           string                      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name         string                      `gorm:"type:text;not null" json:"name"` // Key of tpl:<name>, lower case
+	UserID       *string                     `gorm:"type:uuid;index" json:"user_id"` // Personal template owner
+	GroupID      *string                     `gorm:"type:text;index" json:"group_id"`
+	TitlePattern string                      `gorm:"type:text;not null" json:"title_pattern"`
+	Description  string                      `gorm:"type:text" json:"description"`
+	Priority     TaskPriority                `gorm:"type:task_priority;default:'Medium';not null" json:"priority"`
+	AssigneeIDs  datatypes.JSONSlice[string] `gorm:"type:jsonb;not null;default:'[]'" json:"assignee_ids"` // Used when the command mentions nobody
+	Labels       datatypes.JSONSlice[string] `gorm:"type:jsonb;not null;default:'[]'" json:"labels"`
+	// DueOffsetMinutes sets the due date relative to the creation time; nil for no due date
+	DueOffsetMinutes *int                        `gorm:"type:integer" json:"due_offset_minutes"`
+	Subtasks         datatypes.JSONSlice[string] `gorm:"type:jsonb;not null;default:'[]'" json:"subtasks"` // Subtask title patterns
+	CreatedBy        *string                     `gorm:"type:uuid" json:"created_by"`
+	CreatedAt        time.Time                   `gorm:"default:now()" json:"created_at"`
+	UpdatedAt        time.Time                   `gorm:"default:now()" json:"updated_at"`
+}
+
+// NormalizeTemplateName turns "Bug" / " bug " into the stored key "bug"
+func NormalizeTemplateName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// TemplateRepository handles database operations for task templates
+type TemplateRepository interface {
+	Create(ctx context.Context, tmpl *TaskTemplate) error
+	Update(ctx context.Context, tmpl *TaskTemplate) error
+	Delete(ctx context.Context, id string) error
+	// GetByID returns nil if the template does not exist
+	GetByID(ctx context.Context, id string) (*TaskTemplate, error)
+	// GetUserTemplate returns the user's personal template called name, or nil
+	GetUserTemplate(ctx context.Context, userID, name string) (*TaskTemplate, error)
+	// GetGroupTemplate returns the group's template called name, or nil
+	GetGroupTemplate(ctx context.Context, groupID, name string) (*TaskTemplate, error)
+	// List returns the user's personal templates and, when groupID is set, the
+	// templates of that group, ordered by name
+	List(ctx context.Context, userID, groupID string) ([]TaskTemplate, error)
+}
+
+type templateRepository struct {
+	db *gorm.DB
+}
+
+// NewTemplateRepository creates a new template repository
+func NewTemplateRepository(db *gorm.DB) TemplateRepository {
+	return &templateRepository{db: db}
+}
+
+// Create inserts a template
+func (r *templateRepository) Create(ctx context.Context, tmpl *TaskTemplate) error {
+	return r.db.WithContext(ctx).Create(tmpl).Error
+}
+
+// Update saves the editable fields of a template
+func (r *templateRepository) Update(ctx context.Context, tmpl *TaskTemplate) error {
+	tmpl.UpdatedAt = time.Now()
+	return r.db.WithContext(ctx).Model(tmpl).
+		Select("Name", "TitlePattern", "Description", "Priority", "AssigneeIDs", "Labels", "DueOffsetMinutes", "Subtasks", "UpdatedAt").
+		Updates(tmpl).Error
+}
+
+// Delete removes a template
+func (r *templateRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&TaskTemplate{}).Error
+}
+
+// GetByID retrieves a template by ID
+func (r *templateRepository) GetByID(ctx context.Context, id string) (*TaskTemplate, error) {
+	return r.first(r.db.WithContext(ctx).Where("id = ?", id))
+}
+
+// GetUserTemplate returns the user's personal template called name
+func (r *templateRepository) GetUserTemplate(ctx context.Context, userID, name string) (*TaskTemplate, error) {
+	return r.first(r.db.WithContext(ctx).Where("user_id = ? AND group_id IS NULL AND name = ?", userID, NormalizeTemplateName(name)))
+}
+
+// GetGroupTemplate returns the group's template called name
+func (r *templateRepository) GetGroupTemplate(ctx context.Context, groupID, name string) (*TaskTemplate, error) {
+	return r.first(r.db.WithContext(ctx).Where("group_id = ? AND name = ?", groupID, NormalizeTemplateName(name)))
+}
+
+func (r *templateRepository) first(query *gorm.DB) (*TaskTemplate, error) {
+	var tmpl TaskTemplate
+	if err := query.First(&tmpl).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// List returns the user's personal templates and the templates of groupID
+func (r *templateRepository) List(ctx context.Context, userID, groupID string) ([]TaskTemplate, error) {
+	var templates []TaskTemplate
+	query := r.db.WithContext(ctx)
+	if groupID != "" {
+		query = query.Where("(user_id = ? AND group_id IS NULL) OR group_id = ?", userID, groupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", userID)
+	}
+	err := query.Order("name ASC").Order("group_id ASC").Find(&templates).Error
+	return templates, err
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+)
+
+// TimeEntrySource tells how a time entry was recorded
+type TimeEntrySource string
+
+const (
+	TimeEntrySourceTimer  TimeEntrySource = "timer"  // Started and stopped with the timer
+	TimeEntrySourceManual TimeEntrySource = "manual" // Entered afterwards
+)
+
+// TimeEntry represents the time_entries table: time spent by a user on a task.
+// A timer entry is running while EndedAt is nil; each user has at most one.
+type TimeEntry struct {
+	ID              string          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TaskID          string          `gorm:"type:uuid;not null;index" json:"task_id"`
+	UserID          string          `gorm:"type:uuid;not null;index" json:"user_id"`
+	StartedAt       time.Time       `gorm:"type:timestamptz;not null" json:"started_at"`
+	EndedAt         *time.Time      `gorm:"type:timestamptz" json:"ended_at"`
+	DurationSeconds int64           `gorm:"not null;default:0" json:"duration_seconds"` // Filled when the entry ends
+	Note            string          `gorm:"type:text" json:"note"`
+	Source          TimeEntrySource `gorm:"type:text;not null;default:'timer'" json:"source"`
+	CreatedAt       time.Time       `gorm:"default:now()" json:"created_at"`
+
+	User *models.User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// TimeReportGroupBy is the dimension a time report is aggregated on
+type TimeReportGroupBy string
+
+const (
+	TimeReportByUser  TimeReportGroupBy = "user"
+	TimeReportByGroup TimeReportGroupBy = "group" // Telegram group of the task; empty key for personal tasks
+	TimeReportByDay   TimeReportGroupBy = "day"   // Day the entry started in the database time zone, as YYYY-MM-DD
+)
+
+// IsValid reports whether g is a known report dimension
+func (g TimeReportGroupBy) IsValid() bool {
+	switch g {
+	case TimeReportByUser, TimeReportByGroup, TimeReportByDay:
+		return true
+	}
+	return false
+}
+
+// TimeReportFilter restricts the entries of a time report. Only finished
+// entries are counted.
+type TimeReportFilter struct {
+	From    *time.Time // Entries started at or after From
+	To      *time.Time // Entries started before To
+	UserID  string
+	GroupID string
+	GroupBy TimeReportGroupBy
+}
+
+// TimeReportRow is one aggregated line of a time report
+type TimeReportRow struct {
+	Key          string `json:"key"`
+	TotalSeconds int64  `json:"total_seconds"`
+	Entries      int64  `json:"entries"`
+}
+
+// TimeEntryRepository handles database operations for time entries
+type TimeEntryRepository interface {
+	Create(ctx context.Context, entry *TimeEntry) error
+	GetByID(ctx context.Context, id string) (*TimeEntry, error)
+	// GetRunning returns the user's running timer entry, or nil
+	GetRunning(ctx context.Context, userID string) (*TimeEntry, error)
+	// Stop ends a running entry at endedAt and stores its duration
+	Stop(ctx context.Context, entry *TimeEntry, endedAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	ListByTask(ctx context.Context, taskID string) ([]TimeEntry, error)
+	// SumByTask returns the seconds logged on a task by finished entries
+	SumByTask(ctx context.Context, taskID string) (int64, error)
+	Report(ctx context.Context, filter TimeReportFilter) ([]TimeReportRow, error)
+}
+
+type timeEntryRepository struct {
+	db *gorm.DB
+}
+
+// NewTimeEntryRepository creates a new time entry repository
+func NewTimeEntryRepository(db *gorm.DB) TimeEntryRepository {
+	return &timeEntryRepository{db: db}
+}
+
+// Create inserts a time entry
+func (r *timeEntryRepository) Create(ctx context.Context, entry *TimeEntry) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// GetByID retrieves a time entry by ID, or nil if it does not exist
+func (r *timeEntryRepository) GetByID(ctx context.Context, id string) (*TimeEntry, error) {
+	var entry TimeEntry
+	if err := r.db.WithContext(ctx).First(&entry, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetRunning returns the user's running timer entry, or nil
+func (r *timeEntryRepository) GetRunning(ctx context.Context, userID string) (*TimeEntry, error) {
+	var entry TimeEntry
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND ended_at IS NULL", userID).
+		First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Stop ends a running entry at endedAt and stores its duration
+func (r *timeEntryRepository) Stop(ctx context.Context, entry *TimeEntry, endedAt time.Time) error {
+	duration := int64(endedAt.Sub(entry.StartedAt).Seconds())
+	if duration < 0 {
+		duration = 0
+	}
+	res := r.db.WithContext(ctx).Model(&TimeEntry{}).
+		Where("id = ? AND ended_at IS NULL", entry.ID).
+		Updates(map[string]interface{}{"ended_at": endedAt, "duration_seconds": duration})
+	if res.Error != nil {
+		return res.Error
+	}
+	entry.EndedAt = &endedAt
+	entry.DurationSeconds = duration
+	return nil
+}
+
+// Delete removes a time entry
+func (r *timeEntryRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&TimeEntry{}).Error
+}
+
+// ListByTask lists the time entries of a task, most recent first
+func (r *timeEntryRepository) ListByTask(ctx context.Context, taskID string) ([]TimeEntry, error) {
+	var entries []TimeEntry
+	err := r.db.WithContext(ctx).
+		Where("task_id = ?", taskID).
+		Preload("User").
+		Order("started_at DESC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// SumByTask returns the seconds logged on a task by finished entries
+func (r *timeEntryRepository) SumByTask(ctx context.Context, taskID string) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&TimeEntry{}).
+		Where("task_id = ? AND ended_at IS NOT NULL", taskID).
+		Select("COALESCE(SUM(duration_seconds), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// Report aggregates finished time entries of non-deleted tasks
+func (r *timeEntryRepository) Report(ctx context.Context, filter TimeReportFilter) ([]TimeReportRow, error) {
+	var key string
+	switch filter.GroupBy {
+	case TimeReportByGroup:
+		key = "COALESCE(tasks.group_id, '')"
+	case TimeReportByDay:
+		key = "CAST(DATE(time_entries.started_at) AS TEXT)"
+	default:
+		key = "time_entries.user_id"
+	}
+
+	query := r.db.WithContext(ctx).Table("time_entries").
+		Joins("JOIN tasks ON tasks.id = time_entries.task_id AND tasks.deleted_at IS NULL").
+		Where("time_entries.ended_at IS NOT NULL")
+	if filter.From != nil {
+		query = query.Where("time_entries.started_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("time_entries.started_at < ?", *filter.To)
+	}
+	if filter.UserID != "" {
+		query = query.Where("time_entries.user_id = ?", filter.UserID)
+	}
+	if filter.GroupID != "" {
+		query = query.Where("tasks.group_id = ?", filter.GroupID)
+	}
+
+	var rows []TimeReportRow
+	err := query.
+		Select(key + " AS key, SUM(time_entries.duration_seconds) AS total_seconds, COUNT(*) AS entries").
+		Group(key).
+		Order("key").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// StatusCategory groups the statuses of a workflow by meaning. Counts, views,
+// reminders and digests only look at the category, so groups can name and
+// order their statuses freely.
+type StatusCategory string
+
+const (
+	StatusCategoryTodo   StatusCategory = "todo"
+	StatusCategoryActive StatusCategory = "active"
+	StatusCategoryDone   StatusCategory = "done"
+)
+
+// IsValid reports whether c is one of the known categories
+func (c StatusCategory) IsValid() bool {
+	switch c {
+	case StatusCategoryTodo, StatusCategoryActive, StatusCategoryDone:
+		return true
+	}
+	return false
+}
+
+// WorkflowStatus represents the workflow_statuses table: one status of a
+// group's workflow. Names match the options of the Notion Status property.
+type WorkflowStatus struct {
+	ID       string         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	GroupID  string         `gorm:"type:text;not null;index" json:"group_id"`
+	Name     TaskStatus     `gorm:"type:text;not null" json:"name"`
+	Category StatusCategory `gorm:"type:text;not null" json:"category"`
+	Position int            `gorm:"not null;default:0" json:"position"`
+	// Next lists the statuses a task may move to from this one; empty allows any
+	Next      datatypes.JSONSlice[string] `gorm:"type:jsonb;not null;default:'[]'" json:"next"`
+	CreatedAt time.Time                   `gorm:"default:now()" json:"created_at"`
+	UpdatedAt time.Time                   `gorm:"default:now()" json:"updated_at"`
+}
+
+// Workflow is an ordered list of statuses
+type Workflow []WorkflowStatus
+
+// DefaultWorkflow is used by personal tasks and groups without their own statuses
+var DefaultWorkflow = Workflow{
+	{Name: TaskStatusToDo, Category: StatusCategoryTodo},
+	{Name: TaskStatusInProgress, Category: StatusCategoryActive},
+	{Name: TaskStatusDone, Category: StatusCategoryDone},
+}
+
+// Find returns the status called name, ignoring case and surrounding spaces
+func (w Workflow) Find(name string) (WorkflowStatus, bool) {
+	name = strings.TrimSpace(name)
+	for _, st := range w {
+		if strings.EqualFold(string(st.Name), name) {
+			return st, true
+		}
+	}
+	return WorkflowStatus{}, false
+}
+
+// First returns the first status of category c, e.g. the status new tasks
+// start in (todo) or the one "mark done" moves to (done)
+func (w Workflow) First(c StatusCategory) (WorkflowStatus, bool) {
+	for _, st := range w {
+		if st.Category == c {
+			return st, true
+		}
+	}
+	return WorkflowStatus{}, false
+}
+
+// Allows reports whether a task in status from may move to status to
+func (w Workflow) Allows(from, to TaskStatus) bool {
+	st, ok := w.Find(string(from))
+	if !ok || len(st.Next) == 0 {
+		// Tasks in unknown statuses (e.g. renamed in Notion) may move anywhere
+		return true
+	}
+	for _, next := range st.Next {
+		if strings.EqualFold(next, string(to)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoryOf returns the category of status name, falling back to todo
+func (w Workflow) CategoryOf(name TaskStatus) StatusCategory {
+	if st, ok := w.Find(string(name)); ok {
+		return st.Category
+	}
+	return StatusCategoryTodo
+}
+
+// WorkflowRepository handles database operations for group workflows
+type WorkflowRepository interface {
+	// ListByGroup returns the group's statuses in order; empty when the group
+	// uses the default workflow
+	ListByGroup(ctx context.Context, groupID string) (Workflow, error)
+	// ListByDatabase returns the statuses of the group bound to a Notion database
+	ListByDatabase(ctx context.Context, databaseID string) (Workflow, error)
+	// Replace stores statuses as the group's workflow. Tasks of the group in a
+	// status missing from it move to remap[status]; the category of all the
+	// group's tasks follows the new workflow. So do personal tasks in the
+	// group's Notion database, which use its workflow (see ListByDatabase).
+	Replace(ctx context.Context, groupID string, statuses Workflow, remap map[TaskStatus]TaskStatus) error
+}
+
+type workflowRepository struct {
+	db *gorm.DB
+}
+
+// NewWorkflowRepository creates a new workflow repository
+func NewWorkflowRepository(db *gorm.DB) WorkflowRepository {
+	return &workflowRepository{db: db}
+}
+
+func (r *workflowRepository) ListByGroup(ctx context.Context, groupID string) (Workflow, error) {
+	var statuses Workflow
+	err := r.db.WithContext(ctx).
+		Where("group_id = ?", groupID).
+		Order("position ASC").
+		Find(&statuses).Error
+	return statuses, err
+}
+
+func (r *workflowRepository) ListByDatabase(ctx context.Context, databaseID string) (Workflow, error) {
+	var statuses Workflow
+	err := r.db.WithContext(ctx).
+		Where("group_id = (SELECT id FROM groups WHERE database_id = ? ORDER BY updated_at DESC LIMIT 1)", databaseID).
+		Order("position ASC").
+		Find(&statuses).Error
+	return statuses, err
+}
+
+func (r *workflowRepository) Replace(ctx context.Context, groupID string, statuses Workflow, remap map[TaskStatus]TaskStatus) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", groupID).Delete(&WorkflowStatus{}).Error; err != nil {
+			return err
+		}
+		for i := range statuses {
+			statuses[i].ID = uuid.NewString()
+			statuses[i].GroupID = groupID
+			statuses[i].Position = i
+			if statuses[i].Next == nil {
+				statuses[i].Next = datatypes.NewJSONSlice([]string{})
+			}
+		}
+		if len(statuses) > 0 {
+			if err := tx.Create(&statuses).Error; err != nil {
+				return err
+			}
+		}
+
+		tasks := tx.Model(&Task{}).Where("deleted_at IS NULL").
+			Where("group_id = ? OR (group_id IS NULL AND database_id = (SELECT database_id FROM groups WHERE id = ?) AND ? = "+
+				"(SELECT id FROM groups WHERE database_id = tasks.database_id ORDER BY updated_at DESC LIMIT 1))", groupID, groupID, groupID)
+		for from, to := range remap {
+			// Pending pushes the new status to Notion on the next sync
+			if err := tasks.Session(&gorm.Session{}).Where("status = ?", from).
+				Updates(map[string]interface{}{"status": to, "sync_status": TaskSyncStatusPending}).Error; err != nil {
+				return err
+			}
+		}
+		workflow := statuses
+		if len(workflow) == 0 {
+			workflow = DefaultWorkflow
+		}
+		for _, st := range workflow {
+			if err := tasks.Session(&gorm.Session{}).Where("status = ?", st.Name).
+				Update("status_category", st.Category).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -243,6 +243,7 @@ func (h *Handler) NotionCallback(c *gin.Context) {
 		RefreshTokenEnc: refreshTokenEnc,
 		WorkspaceID:     tokenResp.WorkspaceID,
 		WorkspaceName:   tokenResp.WorkspaceName,
+		NotionUserID:    tokenResp.OwnerUserID(),
 	}
 
 	if err := h.userRepo.SaveNotionToken(ctx, token); err != nil {
@@ -12,6 +12,8 @@ import (
 
 	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/internal/server/http/middleware"
+	"github.com/layababa/tg_todo/server/pkg/rrule"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
 // Handler handles calendar-related requests
@@ -105,7 +107,7 @@ func (h *Handler) GetCalendarFeed(c *gin.Context) {
 	}
 
 	// Generate ICS content
-	icsContent := h.generateICS(tasks, user.Name)
+	icsContent := h.generateICS(tasks, user.Name, user.Location())
 
 	// Set headers for ICS
 	c.Header("Content-Type", "text/calendar; charset=utf-8")
@@ -113,8 +115,11 @@ func (h *Handler) GetCalendarFeed(c *gin.Context) {
 	c.String(http.StatusOK, icsContent)
 }
 
-// generateICS creates the ICS file content from tasks
-func (h *Handler) generateICS(tasks []repository.Task, calendarName string) string {
+// generateICS creates the ICS file content from tasks. All-day tasks become
+// DATE events spanning start to due date; timed tasks use UTC times, which
+// clients show in their own zone. loc is the user's zone, advertised as the
+// calendar's default.
+func (h *Handler) generateICS(tasks []repository.Task, calendarName string, loc *time.Location) string {
 	var sb strings.Builder
 
 	// ICS header
@@ -122,6 +127,10 @@ func (h *Handler) generateICS(tasks []repository.Task, calendarName string) stri
 	sb.WriteString("VERSION:2.0\r\n")
 	sb.WriteString("PRODID:-//TG Todo//Telegram To-Do Mini App//EN\r\n")
 	sb.WriteString(fmt.Sprintf("X-WR-CALNAME:%s 的待办\r\n", escapeICS(calendarName)))
+	if name, err := timezone.Validate(loc.String()); err == nil {
+		// Legacy fixed offsets have no IANA name and are left out
+		sb.WriteString(fmt.Sprintf("X-WR-TIMEZONE:%s\r\n", name))
+	}
 	sb.WriteString("CALSCALE:GREGORIAN\r\n")
 	sb.WriteString("METHOD:PUBLISH\r\n")
 
@@ -133,15 +142,29 @@ func (h *Handler) generateICS(tasks []repository.Task, calendarName string) stri
 		if task.DueAt == nil {
 			continue
 		}
+		start := *task.DueAt
+		hasStart := task.StartAt != nil && !task.StartAt.After(*task.DueAt)
+		if hasStart {
+			start = *task.StartAt
+		}
 
 		sb.WriteString("BEGIN:VEVENT\r\n")
 		sb.WriteString(fmt.Sprintf("UID:%s@tgtodo\r\n", task.ID))
 		sb.WriteString(fmt.Sprintf("DTSTAMP:%s\r\n", dtstamp))
 
-		// All-day event: use DATE format (YYYYMMDD)
-		dueDate := task.DueAt.Format("20060102")
-		sb.WriteString(fmt.Sprintf("DTSTART;VALUE=DATE:%s\r\n", dueDate))
-		sb.WriteString(fmt.Sprintf("DTEND;VALUE=DATE:%s\r\n", dueDate))
+		if task.AllDay {
+			// All-day event: DATE values, DTEND is the day after the due date
+			sb.WriteString(fmt.Sprintf("DTSTART;VALUE=DATE:%s\r\n", start.UTC().Format("20060102")))
+			sb.WriteString(fmt.Sprintf("DTEND;VALUE=DATE:%s\r\n", task.DueAt.UTC().AddDate(0, 0, 1).Format("20060102")))
+		} else {
+			sb.WriteString(fmt.Sprintf("DTSTART:%s\r\n", start.UTC().Format("20060102T150405Z")))
+			if hasStart {
+				sb.WriteString(fmt.Sprintf("DTEND:%s\r\n", task.DueAt.UTC().Format("20060102T150405Z")))
+			}
+		}
+		if rule := recurrenceRule(task); rule != "" {
+			sb.WriteString(fmt.Sprintf("RRULE:%s\r\n", rule))
+		}
 
 		sb.WriteString(fmt.Sprintf("SUMMARY:%s\r\n", escapeICS(task.Title)))
 
@@ -152,10 +175,18 @@ func (h *Handler) generateICS(tasks []repository.Task, calendarName string) stri
 		}
 		sb.WriteString(fmt.Sprintf("DESCRIPTION:%s\r\n", escapeICS(description)))
 
-		// Reminder 1 hour before (if due time is set)
+		// Reminder 1 hour before the deadline, or at 09:00 on the due date of
+		// all-day tasks (15 hours before the end of the event)
 		sb.WriteString("BEGIN:VALARM\r\n")
 		sb.WriteString("ACTION:DISPLAY\r\n")
-		sb.WriteString("TRIGGER:-PT1H\r\n")
+		switch {
+		case task.AllDay:
+			sb.WriteString("TRIGGER;RELATED=END:-PT15H\r\n")
+		case hasStart:
+			sb.WriteString("TRIGGER;RELATED=END:-PT1H\r\n")
+		default:
+			sb.WriteString("TRIGGER:-PT1H\r\n")
+		}
 		sb.WriteString(fmt.Sprintf("DESCRIPTION:任务提醒: %s\r\n", escapeICS(task.Title)))
 		sb.WriteString("END:VALARM\r\n")
 
@@ -167,6 +198,30 @@ func (h *Handler) generateICS(tasks []repository.Task, calendarName string) stri
 	return sb.String()
 }
 
+// recurrenceRule returns the RRULE for the latest open instance of a recurring
+// series. Earlier instances are emitted as one-off events so calendar clients
+// do not expand the series twice. COUNT is reduced by the occurrences already
+// generated since DTSTART is the current instance.
+func recurrenceRule(task repository.Task) string {
+	if task.Recurrence == "" || task.RecurrenceSpawned {
+		return ""
+	}
+	rule, err := rrule.Parse(task.Recurrence)
+	if err != nil {
+		return ""
+	}
+	if rule.Count > 0 {
+		rule.Count -= task.RecurrenceIndex
+		if rule.Count < 1 {
+			rule.Count = 1
+		}
+	}
+	if !task.AllDay {
+		return rule.String()
+	}
+	return rule.DateString()
+}
+
 // escapeICS escapes special characters for ICS format
 func escapeICS(s string) string {
 	s = strings.ReplaceAll(s, "\\", "\\\\")
@@ -0,0 +1,54 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
+)
+
+func TestGenerateICSEmitsRRuleForLatestInstance(t *testing.T) {
+	h := &Handler{}
+	due := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	tasks := []repository.Task{
+		{ID: "old", Title: "Weekly report", DueAt: &due, AllDay: true, Recurrence: "FREQ=WEEKLY;BYDAY=FR", RecurrenceSpawned: true},
+		{ID: "current", Title: "Weekly report", DueAt: &due, AllDay: true, Recurrence: "FREQ=WEEKLY;BYDAY=FR;COUNT=10", RecurrenceIndex: 3},
+		{ID: "once", Title: "One-off", DueAt: &due, AllDay: true},
+	}
+
+	ics := h.generateICS(tasks, "Alice", time.UTC)
+
+	assert.Equal(t, 1, strings.Count(ics, "RRULE:"))
+	assert.Contains(t, ics, "UID:current@tgtodo\r\nDTSTAMP:")
+	assert.Contains(t, ics, "DTEND;VALUE=DATE:20250104\r\nRRULE:FREQ=WEEKLY;BYDAY=FR;COUNT=7\r\n")
+}
+
+func TestGenerateICSAllDayAndTimedEvents(t *testing.T) {
+	h := &Handler{}
+	startDate := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	dueDate := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC)
+	due := time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC)
+
+	tasks := []repository.Task{
+		{ID: "trip", Title: "Trip", StartAt: &startDate, DueAt: &dueDate, AllDay: true},
+		{ID: "review", Title: "Review", StartAt: &start, DueAt: &due},
+		{ID: "call", Title: "Call", DueAt: &due, Recurrence: "FREQ=DAILY;UNTIL=20260310T000000Z"},
+	}
+
+	ics := h.generateICS(tasks, "Alice", timezone.Load("Asia/Shanghai"))
+
+	assert.Contains(t, ics, "X-WR-TIMEZONE:Asia/Shanghai\r\n")
+	assert.Contains(t, ics, "DTSTART;VALUE=DATE:20260302\r\nDTEND;VALUE=DATE:20260305\r\n")
+	assert.Contains(t, ics, "TRIGGER;RELATED=END:-PT15H\r\n")
+	assert.Contains(t, ics, "DTSTART:20260302T010000Z\r\nDTEND:20260302T103000Z\r\n")
+	assert.Contains(t, ics, "UID:call@tgtodo\r\nDTSTAMP:")
+	assert.Contains(t, ics, "DTSTART:20260302T103000Z\r\nRRULE:FREQ=DAILY;UNTIL=20260310T000000Z\r\n")
+	assert.Contains(t, ics, "TRIGGER:-PT1H\r\n")
+	assert.NotContains(t, ics, "DTSTART;VALUE=DATE:20260302\r\nDTEND;VALUE=DATE:20260302")
+}
@@ -2,10 +2,12 @@ package group
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/internal/service/group"
 	notionsvc "github.com/layababa/tg_todo/server/internal/service/notion"
 	tasksvc "github.com/layababa/tg_todo/server/internal/service/task"
@@ -24,6 +26,11 @@ type groupService interface {
 	InitDatabase(ctx context.Context, userID, groupID, dbID string) (*notionsvc.InitResult, error)
 	BindDatabase(ctx context.Context, userID, groupID, dbID string) (*models.Group, error)
 	UnbindDatabase(ctx context.Context, userID, groupID string) (*models.Group, error)
+	UpdateSettings(ctx context.Context, userID, groupID string, autoArchiveDays int) (*models.Group, error)
+	ListStatuses(ctx context.Context, userID, groupID string) (repository.Workflow, error)
+	UpdateStatuses(ctx context.Context, userID, groupID string, statuses []group.StatusInput) (repository.Workflow, error)
+	ListCustomFields(ctx context.Context, userID, groupID string) (repository.CustomFields, error)
+	RefreshCustomFields(ctx context.Context, userID, groupID string) (repository.CustomFields, error)
 }
 
 func NewHandler(logger *zap.Logger, groupService groupService, taskService *tasksvc.Service) *Handler {
@@ -185,6 +192,160 @@ func (h *Handler) UnbindGroup(c *gin.Context) {
 	})
 }
 
+type SettingsRequest struct {
+	AutoArchiveDays *int `json:"auto_archive_days" binding:"required,min=0,max=365"` // 0 disables auto-archive
+}
+
+func (h *Handler) UpdateSettings(c *gin.Context) {
+	userID := c.GetString("userID")
+	groupID := c.Param("group_id")
+
+	var req SettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	g, err := h.groupService.UpdateSettings(c.Request.Context(), userID, groupID, *req.AutoArchiveDays)
+	if err != nil {
+		if err == group.ErrNotAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+		if err == group.ErrGroupNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "group not found"})
+			return
+		}
+		h.logger.Error("failed to update group settings", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"group_id":          g.ID,
+			"auto_archive_days": g.AutoArchiveDays,
+		},
+	})
+}
+
+// ListStatuses returns the ordered statuses tasks of the group can take
+func (h *Handler) ListStatuses(c *gin.Context) {
+	userID := c.GetString("userID")
+	groupID := c.Param("group_id")
+
+	statuses, err := h.groupService.ListStatuses(c.Request.Context(), userID, groupID)
+	if err != nil {
+		if errors.Is(err, group.ErrNotMember) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "group membership required"})
+			return
+		}
+		h.logger.Error("failed to list group statuses", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch statuses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"items": statuses,
+		},
+	})
+}
+
+type StatusesRequest struct {
+	Statuses []group.StatusInput `json:"statuses" binding:"required"` // Empty restores the default workflow
+}
+
+// UpdateStatuses replaces the group's workflow
+func (h *Handler) UpdateStatuses(c *gin.Context) {
+	userID := c.GetString("userID")
+	groupID := c.Param("group_id")
+
+	var req StatusesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statuses, err := h.groupService.UpdateStatuses(c.Request.Context(), userID, groupID, req.Statuses)
+	if err != nil {
+		switch {
+		case errors.Is(err, group.ErrInvalidWorkflow):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, group.ErrNotAdmin):
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		case errors.Is(err, group.ErrGroupNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "group not found"})
+		default:
+			h.logger.Error("failed to update group statuses", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"items": statuses,
+		},
+	})
+}
+
+// ListCustomFields returns the custom fields of the group's Notion database
+func (h *Handler) ListCustomFields(c *gin.Context) {
+	userID := c.GetString("userID")
+	groupID := c.Param("group_id")
+
+	fields, err := h.groupService.ListCustomFields(c.Request.Context(), userID, groupID)
+	if err != nil {
+		if errors.Is(err, group.ErrNotMember) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "group membership required"})
+			return
+		}
+		h.logger.Error("failed to list custom fields", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch fields"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"items": fields,
+		},
+	})
+}
+
+// RefreshCustomFields reads the custom fields again from the Notion database
+func (h *Handler) RefreshCustomFields(c *gin.Context) {
+	userID := c.GetString("userID")
+	groupID := c.Param("group_id")
+
+	fields, err := h.groupService.RefreshCustomFields(c.Request.Context(), userID, groupID)
+	if err != nil {
+		switch {
+		case errors.Is(err, group.ErrNotAdmin):
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		case errors.Is(err, group.ErrGroupNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "group not found"})
+		case errors.Is(err, group.ErrNoDatabase):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group has no bound database"})
+		default:
+			h.logger.Error("failed to refresh custom fields", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh failed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"items": fields,
+		},
+	})
+}
+
 func (h *Handler) RefreshGroups(c *gin.Context) {
 	// Stub
 	c.JSON(http.StatusOK, gin.H{
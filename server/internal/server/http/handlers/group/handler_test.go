@@ -3,6 +3,7 @@ package group
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
 	groupservice "github.com/layababa/tg_todo/server/internal/service/group"
 	notionsvc "github.com/layababa/tg_todo/server/internal/service/notion"
 )
@@ -47,6 +49,31 @@ func (m *mockGroupService) UnbindDatabase(ctx context.Context, userID, groupID s
 	return args.Get(0).(*models.Group), args.Error(1)
 }
 
+func (m *mockGroupService) UpdateSettings(ctx context.Context, userID, groupID string, autoArchiveDays int) (*models.Group, error) {
+	args := m.Called(ctx, userID, groupID, autoArchiveDays)
+	return args.Get(0).(*models.Group), args.Error(1)
+}
+
+func (m *mockGroupService) ListStatuses(ctx context.Context, userID, groupID string) (repository.Workflow, error) {
+	args := m.Called(ctx, userID, groupID)
+	return args.Get(0).(repository.Workflow), args.Error(1)
+}
+
+func (m *mockGroupService) UpdateStatuses(ctx context.Context, userID, groupID string, statuses []groupservice.StatusInput) (repository.Workflow, error) {
+	args := m.Called(ctx, userID, groupID, statuses)
+	return args.Get(0).(repository.Workflow), args.Error(1)
+}
+
+func (m *mockGroupService) ListCustomFields(ctx context.Context, userID, groupID string) (repository.CustomFields, error) {
+	args := m.Called(ctx, userID, groupID)
+	return args.Get(0).(repository.CustomFields), args.Error(1)
+}
+
+func (m *mockGroupService) RefreshCustomFields(ctx context.Context, userID, groupID string) (repository.CustomFields, error) {
+	args := m.Called(ctx, userID, groupID)
+	return args.Get(0).(repository.CustomFields), args.Error(1)
+}
+
 func TestListGroupsHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	service := new(mockGroupService)
@@ -91,3 +118,57 @@ func TestBindGroupForbidden(t *testing.T) {
 
 	assert.Equal(t, http.StatusForbidden, w.Code)
 }
+
+func TestUpdateSettingsValidatesAutoArchiveDays(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockGroupService)
+	h := NewHandler(zap.NewNop(), service, nil)
+
+	service.On("UpdateSettings", mock.Anything, "user-1", "group-1", 7).Return(&models.Group{ID: "group-1", AutoArchiveDays: 7}, nil)
+
+	for body, want := range map[string]int{
+		`{"auto_archive_days":7}`:   http.StatusOK,
+		`{"auto_archive_days":-1}`:  http.StatusBadRequest,
+		`{"auto_archive_days":400}`: http.StatusBadRequest,
+		`{}`:                        http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		req := httptest.NewRequest(http.MethodPatch, "/groups/group-1/settings", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "group_id", Value: "group-1"}}
+		c.Request = req
+		c.Set("userID", "user-1")
+
+		h.UpdateSettings(c)
+
+		assert.Equal(t, want, w.Code, body)
+	}
+	service.AssertNumberOfCalls(t, "UpdateSettings", 1)
+}
+
+func TestUpdateStatusesMapsErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockGroupService)
+	h := NewHandler(zap.NewNop(), service, nil)
+
+	review := []groupservice.StatusInput{{Name: "Review", Category: repository.StatusCategoryActive}}
+	service.On("UpdateStatuses", mock.Anything, "user-1", "group-1", review).
+		Return(repository.Workflow(nil), fmt.Errorf("%w: no todo status", groupservice.ErrInvalidWorkflow))
+	service.On("UpdateStatuses", mock.Anything, "user-2", "group-1", review).
+		Return(repository.Workflow(nil), groupservice.ErrNotAdmin)
+
+	for userID, want := range map[string]int{"user-1": http.StatusBadRequest, "user-2": http.StatusForbidden} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		req := httptest.NewRequest(http.MethodPut, "/groups/group-1/statuses", strings.NewReader(`{"statuses":[{"name":"Review","category":"active"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "group_id", Value: "group-1"}}
+		c.Request = req
+		c.Set("userID", userID)
+
+		h.UpdateStatuses(c)
+
+		assert.Equal(t, want, w.Code, userID)
+	}
+}
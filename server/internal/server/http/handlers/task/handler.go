@@ -3,30 +3,97 @@ package task
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/internal/server/http/middleware"
 	"github.com/layababa/tg_todo/server/internal/service/task"
+	"github.com/layababa/tg_todo/server/internal/service/telegram"
+	"github.com/layababa/tg_todo/server/pkg/rrule"
 	"go.uber.org/zap"
 )
 
 type taskService interface {
-	ListTasks(ctx context.Context, userID string, params task.ListParams) ([]task.TaskDetail, error)
+	ListTasks(ctx context.Context, userID string, params task.ListParams) ([]task.TaskDetail, string, error)
+	SearchTasks(ctx context.Context, userID string, params task.SearchParams) ([]task.SearchResult, error)
 	GetTask(ctx context.Context, id string) (*repository.Task, error)
 	CreateWebTask(ctx context.Context, userID, title, description string) (*repository.Task, error)
-	UpdateTask(ctx context.Context, id string, params task.UpdateParams) (*repository.Task, error)
-	DeleteTask(ctx context.Context, id string) error
+	UpdateTask(ctx context.Context, userID, id string, params task.UpdateParams) (*repository.Task, error)
+	DeleteTask(ctx context.Context, userID, id string) error
+
+	// Trash methods
+	ListTrash(ctx context.Context, userID string, params task.ListTrashParams) ([]repository.Task, error)
+	GetDeletedTask(ctx context.Context, id string) (*repository.Task, error)
+	RestoreTask(ctx context.Context, userID, id string) (*repository.Task, error)
+
+	// Archive methods
+	SetArchived(ctx context.Context, userID, id string, archived bool, source repository.TaskEventSource) (*repository.Task, error)
+
+	// Assignee methods
+	AddAssignee(ctx context.Context, actorID, taskID, userID string, source repository.TaskEventSource) (*repository.Task, error)
+	RemoveAssignee(ctx context.Context, actorID, taskID, userID string, source repository.TaskEventSource) (*repository.Task, error)
+
+	// Watcher methods
+	Watch(ctx context.Context, userID, taskID string) (bool, error)
+	Unwatch(ctx context.Context, userID, taskID string) (bool, error)
+
+	// Bulk operations
+	BatchUpdate(ctx context.Context, userID string, params task.BatchParams) ([]task.BatchItemResult, error)
+
+	// Subtask methods
+	ListSubtasks(ctx context.Context, parentID string) ([]repository.Task, error)
+	CreateSubtask(ctx context.Context, userID, parentID, title, description string) (*repository.Task, error)
+
+	// Dependency methods
+	ListDependencies(ctx context.Context, taskID string) (*task.Dependencies, error)
+	AddDependency(ctx context.Context, userID, taskID, blockedByID string) error
+	RemoveDependency(ctx context.Context, userID, taskID, blockedByID string) error
 
 	// Comment methods
 	CreateComment(ctx context.Context, taskID, userID, content string, parentID *string) (*repository.TaskComment, error)
 	ListComments(ctx context.Context, taskID string) ([]repository.TaskComment, error)
+	ListCommentThreads(ctx context.Context, taskID string) ([]*repository.TaskComment, error)
+	MoveTask(ctx context.Context, userID, id string, params task.MoveParams) (*repository.Task, error)
+	CloneTask(ctx context.Context, userID, id string, params task.MoveParams) (*repository.Task, error)
+	UpdateComment(ctx context.Context, taskID, commentID, userID, content string) (*repository.TaskComment, error)
+	DeleteComment(ctx context.Context, taskID, commentID, userID string) error
 	GetTaskCounts(ctx context.Context, userID string) (*repository.TaskCounts, error)
+
+	// History
+	ListEvents(ctx context.Context, taskID string, params task.ListEventsParams) ([]repository.TaskEvent, error)
+
+	// Time tracking
+	StartTimer(ctx context.Context, userID, taskID string) (*repository.TimeEntry, *repository.TimeEntry, error)
+	StopTimer(ctx context.Context, userID, taskID string) (*repository.TimeEntry, error)
+	AddTimeEntry(ctx context.Context, userID, taskID string, params task.TimeEntryParams) (*repository.TimeEntry, error)
+	DeleteTimeEntry(ctx context.Context, userID, taskID, entryID string) error
+	ListTimeEntries(ctx context.Context, taskID string) ([]repository.TimeEntry, error)
+	TimeReport(ctx context.Context, userID string, params task.TimeReportParams) ([]repository.TimeReportRow, error)
+
+	// Templates
+	ListTemplates(ctx context.Context, userID, groupID string) ([]repository.TaskTemplate, error)
+	GetTemplate(ctx context.Context, id string) (*repository.TaskTemplate, error)
+	ResolveTemplate(ctx context.Context, userID, groupID, name string) (*repository.TaskTemplate, error)
+	CreateTemplate(ctx context.Context, userID string, params task.TemplateParams) (*repository.TaskTemplate, error)
+	UpdateTemplate(ctx context.Context, id string, params task.TemplateParams) (*repository.TaskTemplate, error)
+	DeleteTemplate(ctx context.Context, id string) error
+	CreateFromTemplate(ctx context.Context, userID string, tmpl *repository.TaskTemplate, input string) (*repository.Task, error)
+
+	// Attachments
+	ListAttachments(ctx context.Context, taskID string) ([]repository.TaskAttachment, error)
+	GetAttachment(ctx context.Context, id string) (*repository.TaskAttachment, error)
+	OpenAttachment(ctx context.Context, attachment *repository.TaskAttachment) (io.ReadCloser, int64, error)
+	VerifyAttachmentSignature(attachmentID, sig string) bool
 }
 
 type Handler struct {
@@ -52,7 +119,7 @@ func (h *Handler) List(c *gin.Context) {
 
 	view := repository.TaskView(c.DefaultQuery("view", string(repository.TaskViewAll)))
 	switch view {
-	case repository.TaskViewAll, repository.TaskViewAssigned, repository.TaskViewCreated, repository.TaskViewDone:
+	case repository.TaskViewAll, repository.TaskViewAssigned, repository.TaskViewCreated, repository.TaskViewDone, repository.TaskViewArchived:
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_view", "message": "invalid view"}})
 		return
@@ -63,21 +130,91 @@ func (h *Handler) List(c *gin.Context) {
 		dbID = &v
 	}
 
+	priorities, ok := parsePriorities(c.Query("priority"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_priority", "message": "priority must be High, Medium or Low"}})
+		return
+	}
+
+	labels := parseLabels(c.Query("label"))
+
+	sort := repository.TaskSort(c.DefaultQuery("sort", string(repository.TaskSortCreated)))
+	if !sort.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_sort", "message": "invalid sort"}})
+		return
+	}
+
 	limit := parseIntWithDefault(c.Query("limit"), 20)
 	offset := parseIntWithDefault(c.Query("offset"), 0)
 
-	items, err := h.service.ListTasks(c.Request.Context(), user.ID, task.ListParams{
+	items, nextCursor, err := h.service.ListTasks(c.Request.Context(), user.ID, task.ListParams{
 		View:       view,
 		DatabaseID: dbID,
+		Priorities: priorities,
+		Labels:     labels,
+		Query:      c.Query("q"),
+		Location:   user.Location(),
+		Sort:       sort,
+		Cursor:     c.Query("cursor"),
 		Limit:      limit,
 		Offset:     offset,
 	})
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_cursor", "message": "cursor is invalid or does not match view/sort"}})
+			return
+		}
+		var queryErr *repository.QueryError
+		if errors.As(err, &queryErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{
+				"code":     "invalid_query",
+				"message":  queryErr.Message,
+				"token":    queryErr.Token,
+				"position": queryErr.Pos,
+			}})
+			return
+		}
 		h.logger.Error("list tasks failed", zap.Error(err), zap.String("user_id", user.ID))
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list tasks"}})
 		return
 	}
 
+	var next *string
+	if nextCursor != "" {
+		next = &nextCursor
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"items": items, "next_cursor": next}})
+}
+
+// Search runs a full-text search over the tasks visible to the user
+func (h *Handler) Search(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	limit := parseIntWithDefault(c.Query("limit"), 20)
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+	offset := parseIntWithDefault(c.Query("offset"), 0)
+
+	items, err := h.service.SearchTasks(c.Request.Context(), user.ID, task.SearchParams{
+		Query:  c.Query("q"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		if errors.Is(err, task.ErrEmptySearchQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_query", "message": "q is required"}})
+			return
+		}
+		h.logger.Error("search tasks failed", zap.Error(err), zap.String("user_id", user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to search tasks"}})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"items": items}})
 }
 
@@ -117,6 +254,9 @@ func (h *Handler) Get(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
 		return
 	}
+	if task != nil {
+		c.Header("ETag", taskETag(task))
+	}
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": task})
 }
 
@@ -160,7 +300,7 @@ func (h *Handler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteTask(c.Request.Context(), id); err != nil {
+	if err := h.service.DeleteTask(c.Request.Context(), user.ID, id); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
 			return
@@ -172,13 +312,29 @@ func (h *Handler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
-type UpdateRequest struct {
-	Title  *string                `json:"title"`
-	Status *repository.TaskStatus `json:"status"`
-	DueAt  *time.Time             `json:"due_at"`
+func (h *Handler) ListTrash(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	limit := parseIntWithDefault(c.Query("limit"), 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset := parseIntWithDefault(c.Query("offset"), 0)
+
+	items, err := h.service.ListTrash(c.Request.Context(), user.ID, task.ListTrashParams{Limit: limit, Offset: offset})
+	if err != nil {
+		h.logger.Error("list trash failed", zap.Error(err), zap.String("user_id", user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list trash"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"items": items}})
 }
 
-func (h *Handler) Update(c *gin.Context) {
+func (h *Handler) Restore(c *gin.Context) {
 	user, ok := middleware.GetUserFromContext(c)
 	if !ok {
 		c.AbortWithStatus(http.StatusUnauthorized)
@@ -187,129 +343,1592 @@ func (h *Handler) Update(c *gin.Context) {
 
 	id := c.Param("task_id")
 
-	// Get existing task to check permission
-	existingTask, err := h.service.GetTask(c.Request.Context(), id)
+	deletedTask, err := h.service.GetDeletedTask(c.Request.Context(), id)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+		h.logger.Error("get deleted task failed", zap.Error(err), zap.String("task_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return
+	}
+	if deletedTask == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found in trash"}})
+		return
+	}
+
+	canModify, err := task.CanModifyTask(c.Request.Context(), user.ID, deletedTask, h.userGroupRepo)
+	if err != nil {
+		h.logger.Error("permission check failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+		return
+	}
+	if !canModify {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "forbidden",
+				"message": "您没有权限恢复此任务。只有创建人、指派人或群管理员可以恢复任务。",
+			},
+		})
+		return
+	}
+
+	restored, err := h.service.RestoreTask(c.Request.Context(), user.ID, id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found in trash"}})
 			return
 		}
+		h.logger.Error("restore task failed", zap.Error(err), zap.String("task_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to restore task"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": restored})
+}
+
+func (h *Handler) Archive(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+func (h *Handler) Unarchive(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+func (h *Handler) setArchived(c *gin.Context, archived bool) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id := c.Param("task_id")
+
+	existingTask, err := h.service.GetTask(c.Request.Context(), id)
+	if err != nil {
 		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", id))
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
 		return
 	}
+	if existingTask == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+		return
+	}
 
-	// Check permission
 	canModify, err := task.CanModifyTask(c.Request.Context(), user.ID, existingTask, h.userGroupRepo)
 	if err != nil {
 		h.logger.Error("permission check failed", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
 		return
 	}
-
 	if !canModify {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"error": gin.H{
 				"code":    "forbidden",
-				"message": "您没有权限修改此任务。只有创建人、指派人或群管理员可以修改任务。",
+				"message": "您没有权限归档此任务。只有创建人、指派人或群管理员可以归档任务。",
 			},
 		})
 		return
 	}
 
-	var req UpdateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
-		return
-	}
-
-	updatedTask, err := h.service.UpdateTask(c.Request.Context(), id, task.UpdateParams{
-		Title:  req.Title,
-		Status: req.Status,
-		DueAt:  req.DueAt,
-	})
+	updated, err := h.service.SetArchived(c.Request.Context(), user.ID, id, archived, repository.TaskEventSourceApp)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) || err.Error() == "task not found" {
+		if err.Error() == "task not found" {
 			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
 			return
 		}
-		h.logger.Error("update task failed", zap.Error(err), zap.String("task_id", id))
+		h.logger.Error("set archived failed", zap.Error(err), zap.String("task_id", id), zap.Bool("archived", archived))
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to update task"}})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
 
-	// Helper to handle wrapping if needed, but currently Get returns flat Task.
-	// We return flat Task here too.
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": updatedTask})
+// MoveTaskRequest selects the target of a move or clone. A missing field
+// keeps the current value; group_id "" makes a personal task and
+// database_id "" unlinks the task from Notion.
+type MoveTaskRequest struct {
+	GroupID    *string `json:"group_id"`
+	DatabaseID *string `json:"database_id"`
 }
 
-type CreateTaskRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
+// MoveTask moves a task and its subtasks to another group or Notion database
+func (h *Handler) MoveTask(c *gin.Context) {
+	h.moveOrClone(c, false)
 }
 
-func (h *Handler) CreateWebTask(c *gin.Context) {
+// CloneTask copies a task, with its subtasks, into a new task
+func (h *Handler) CloneTask(c *gin.Context) {
+	h.moveOrClone(c, true)
+}
+
+func (h *Handler) moveOrClone(c *gin.Context, clone bool) {
 	user, ok := middleware.GetUserFromContext(c)
 	if !ok {
 		c.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
 
-	var req CreateTaskRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	id := c.Param("task_id")
+	var req MoveTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) { // Cloning in place needs no body
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
 		return
 	}
+	if !clone && req.GroupID == nil && req.DatabaseID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "group_id or database_id is required"}})
+		return
+	}
 
-	task, err := h.service.CreateWebTask(c.Request.Context(), user.ID, req.Title, req.Description)
+	existingTask, err := h.service.GetTask(c.Request.Context(), id)
 	if err != nil {
-		h.logger.Error("create task failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to create task"}})
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return
+	}
+	if existingTask == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": task})
+	// Moving needs the right to modify the task, copying only the right to see it
+	allowed, forbiddenMsg := false, "您没有权限移动此任务。只有创建人、指派人或群管理员可以移动任务。"
+	if clone {
+		allowed, err = task.CanClaimTask(c.Request.Context(), user.ID, existingTask, h.userGroupRepo)
+		forbiddenMsg = "您没有权限复制此任务。"
+	} else {
+		allowed, err = task.CanModifyTask(c.Request.Context(), user.ID, existingTask, h.userGroupRepo)
+	}
+	if err != nil {
+		h.logger.Error("permission check failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": forbiddenMsg}})
+		return
+	}
+	if req.GroupID != nil && *req.GroupID != "" && !task.CanMoveTaskTo(c.Request.Context(), user.ID, *req.GroupID, h.userGroupRepo) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "您不是目标群组的成员。"}})
+		return
+	}
+
+	params := task.MoveParams{GroupID: req.GroupID, DatabaseID: req.DatabaseID}
+	var result *repository.Task
+	if clone {
+		result, err = h.service.CloneTask(c.Request.Context(), user.ID, id, params)
+	} else {
+		result, err = h.service.MoveTask(c.Request.Context(), user.ID, id, params)
+	}
+	switch {
+	case errors.Is(err, task.ErrMoveSubtask):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "move_subtask", "message": "subtasks move with their parent task"}})
+	case errors.Is(err, task.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "group_not_found", "message": "target group not found"}})
+	case errors.Is(err, task.ErrDatabaseForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "database_forbidden", "message": "target notion database is not accessible"}})
+	case err != nil:
+		h.logger.Error("move or clone task failed", zap.Error(err), zap.String("task_id", id), zap.Bool("clone", clone))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to update task"}})
+	default:
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+	}
 }
 
-type CreateCommentRequest struct {
-	Content  string  `json:"content" binding:"required"`
-	ParentID *string `json:"parent_id"`
+// AddAssignee adds a user to the task assignees without replacing the others.
+// Members of the task's group may add themselves (claim).
+func (h *Handler) AddAssignee(c *gin.Context) {
+	h.changeAssignee(c, true)
 }
 
-func (h *Handler) ListComments(c *gin.Context) {
-	taskID := c.Param("task_id")
-	comments, err := h.service.ListComments(c.Request.Context(), taskID)
+func (h *Handler) RemoveAssignee(c *gin.Context) {
+	h.changeAssignee(c, false)
+}
+
+func (h *Handler) changeAssignee(c *gin.Context, add bool) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id := c.Param("task_id")
+	assigneeID := c.Param("user_id")
+
+	existingTask, err := h.service.GetTask(c.Request.Context(), id)
 	if err != nil {
-		h.logger.Error("list comments failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list comments"}})
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": comments})
+	if existingTask == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+		return
+	}
+
+	var allowed bool
+	if add && assigneeID == user.ID {
+		allowed, err = task.CanClaimTask(c.Request.Context(), user.ID, existingTask, h.userGroupRepo)
+	} else {
+		allowed, err = task.CanModifyTask(c.Request.Context(), user.ID, existingTask, h.userGroupRepo)
+	}
+	if err != nil {
+		h.logger.Error("permission check failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "forbidden",
+				"message": "您没有权限修改此任务的指派人。只有创建人、指派人或群管理员可以指派他人，群成员可以认领任务。",
+			},
+		})
+		return
+	}
+
+	var updated *repository.Task
+	if add {
+		updated, err = h.service.AddAssignee(c.Request.Context(), user.ID, id, assigneeID, repository.TaskEventSourceApp)
+	} else {
+		updated, err = h.service.RemoveAssignee(c.Request.Context(), user.ID, id, assigneeID, repository.TaskEventSourceApp)
+	}
+	if err != nil {
+		if errors.Is(err, task.ErrAssigneeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "user_not_found", "message": "user not found"}})
+			return
+		}
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+			return
+		}
+		h.logger.Error("change assignee failed", zap.Error(err), zap.String("task_id", id), zap.String("user_id", assigneeID), zap.Bool("add", add))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to update assignees"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
 }
 
-func (h *Handler) CreateComment(c *gin.Context) {
+// Follow subscribes the current user to the task's status, comment and due
+// date notifications. Anyone who could claim the task may follow it.
+func (h *Handler) Follow(c *gin.Context) {
+	h.setWatching(c, true)
+}
+
+func (h *Handler) Unfollow(c *gin.Context) {
+	h.setWatching(c, false)
+}
+
+func (h *Handler) setWatching(c *gin.Context, watching bool) {
 	user, ok := middleware.GetUserFromContext(c)
 	if !ok {
 		c.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
 
-	taskID := c.Param("task_id")
-	var req CreateCommentRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+	id := c.Param("task_id")
+
+	existingTask, err := h.service.GetTask(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return
+	}
+	if existingTask == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
 		return
 	}
 
-	comment, err := h.service.CreateComment(c.Request.Context(), taskID, user.ID, req.Content, req.ParentID)
+	if watching {
+		canFollow, err := task.CanClaimTask(c.Request.Context(), user.ID, existingTask, h.userGroupRepo)
+		if err != nil {
+			h.logger.Error("permission check failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+			return
+		}
+		if !canFollow {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "forbidden",
+					"message": "您没有权限关注此任务。只有任务所在群的成员可以关注任务。",
+				},
+			})
+			return
+		}
+		_, err = h.service.Watch(c.Request.Context(), user.ID, id)
+	} else {
+		_, err = h.service.Unwatch(c.Request.Context(), user.ID, id)
+	}
 	if err != nil {
-		h.logger.Error("create comment failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to create comment"}})
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+			return
+		}
+		h.logger.Error("set watching failed", zap.Error(err), zap.String("task_id", id), zap.Bool("watching", watching))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to update watchers"}})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"task_id": id, "watching": watching}})
+}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": comment})
+type UpdateRequest struct {
+	Title        *string                  `json:"title"`
+	Status       *repository.TaskStatus   `json:"status"`
+	Priority     *repository.TaskPriority `json:"priority"`
+	DueAt        *string                  `json:"due_at"`           // RFC 3339, "2006-01-02T15:04" in the user's zone, or a date for all-day tasks
+	StartAt      *string                  `json:"start_at"`         // Same formats as due_at; "" clears the start date
+	AllDay       *bool                    `json:"all_day"`          // Dates without time of day; implied by date-only values
+	Recurrence   *string                  `json:"recurrence"`       // RRULE, e.g. "FREQ=WEEKLY;BYDAY=FR"; "" stops repeating
+	AutoComplete *bool                    `json:"auto_complete"`    // Complete once all subtasks are done
+	Labels       *[]string                `json:"labels"`           // Replaces all labels, e.g. ["bug","frontend"]
+	Estimate     *int                     `json:"estimate_minutes"` // Estimated effort in minutes; 0 clears it
+	// Values of the group's custom fields by name, e.g. {"Story Points": 3}; null clears a field
+	CustomFields map[string]interface{} `json:"custom_fields"`
+}
+
+func (h *Handler) Update(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id := c.Param("task_id")
+
+	// Get existing task to check permission
+	existingTask, err := h.service.GetTask(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+			return
+		}
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return
+	}
+
+	// Check permission
+	canModify, err := task.CanModifyTask(c.Request.Context(), user.ID, existingTask, h.userGroupRepo)
+	if err != nil {
+		h.logger.Error("permission check failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+		return
+	}
+
+	if !canModify {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "forbidden",
+				"message": "您没有权限修改此任务。只有创建人、指派人或群管理员可以修改任务。",
+			},
+		})
+		return
+	}
+
+	ifVersion, ok := parseIfMatch(c.GetHeader("If-Match"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_if_match", "message": "If-Match must be an ETag returned by this API"}})
+		return
+	}
+
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	if req.Priority != nil && !req.Priority.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_priority", "message": "priority must be High, Medium or Low"}})
+		return
+	}
+	if req.Estimate != nil && *req.Estimate < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "estimate_minutes must not be negative"}})
+		return
+	}
+	if req.Recurrence != nil && *req.Recurrence != "" {
+		if _, err := rrule.Parse(*req.Recurrence); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_recurrence", "message": err.Error()}})
+			return
+		}
+	}
+
+	loc := user.Location()
+	allDay := req.AllDay
+	var dueAt, startAt *time.Time
+	if req.DueAt != nil {
+		t, dateOnly, err := parseTaskTime(*req.DueAt, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_date", "message": "due_at: " + err.Error()}})
+			return
+		}
+		if dateOnly && allDay == nil {
+			allDay = &dateOnly
+		}
+		dueAt = &t
+	}
+	if req.StartAt != nil {
+		var t time.Time // Zero clears the start date
+		if *req.StartAt != "" {
+			parsed, dateOnly, err := parseTaskTime(*req.StartAt, loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_date", "message": "start_at: " + err.Error()}})
+				return
+			}
+			if dateOnly && allDay == nil {
+				allDay = &dateOnly
+			}
+			t = parsed
+		}
+		startAt = &t
+	}
+
+	updatedTask, err := h.service.UpdateTask(c.Request.Context(), user.ID, id, task.UpdateParams{
+		Title:        req.Title,
+		Status:       req.Status,
+		Priority:     req.Priority,
+		DueAt:        dueAt,
+		StartAt:      startAt,
+		AllDay:       allDay,
+		Location:     loc,
+		Recurrence:   req.Recurrence,
+		AutoComplete: req.AutoComplete,
+		Estimate:     req.Estimate,
+		Labels:       req.Labels,
+		CustomFields: req.CustomFields,
+		IfVersion:    ifVersion,
+	})
+	if err != nil {
+		var conflict *task.ConflictError
+		if errors.As(err, &conflict) {
+			c.Header("ETag", taskETag(conflict.Current))
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   gin.H{"code": "version_conflict", "message": "任务已被他人修改，请确认最新内容后重试"},
+				"data":    conflict.Current,
+			})
+			return
+		}
+		if errors.Is(err, task.ErrTaskBlocked) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": gin.H{"code": "task_blocked", "message": "前置任务尚未完成，暂不能开始此任务"}})
+			return
+		}
+		if errors.Is(err, task.ErrInvalidCustomField) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_custom_field", "message": err.Error()}})
+			return
+		}
+		if errors.Is(err, task.ErrUnknownStatus) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_status", "message": "状态不在该群的状态流中"}})
+			return
+		}
+		if errors.Is(err, task.ErrTransitionNotAllowed) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": gin.H{"code": "transition_not_allowed", "message": "当前状态不能直接流转到该状态"}})
+			return
+		}
+		if errors.Is(err, task.ErrRecurrenceRequiresDueDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_recurrence", "message": "重复任务需要先设置截止时间"}})
+			return
+		}
+		if errors.Is(err, task.ErrStartAfterDue) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_date", "message": "开始时间不能晚于截止时间"}})
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) || err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+			return
+		}
+		h.logger.Error("update task failed", zap.Error(err), zap.String("task_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to update task"}})
+		return
+	}
+
+	// Helper to handle wrapping if needed, but currently Get returns flat Task.
+	// We return flat Task here too.
+	c.Header("ETag", taskETag(updatedTask))
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updatedTask})
+}
+
+// parseTaskTime parses a due or start value: RFC 3339, a local date and time
+// ("2006-01-02T15:04", optionally with seconds) in loc, or a date
+// ("2006-01-02", dateOnly) at midnight in loc
+func parseTaskTime(value string, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid time %q, expected RFC 3339, YYYY-MM-DDTHH:MM or YYYY-MM-DD", value)
+}
+
+// taskETag is the entity tag of a task's current version
+func taskETag(t *repository.Task) string {
+	return `"` + strconv.Itoa(t.Version) + `"`
+}
+
+// parseIfMatch extracts the version from an If-Match header. An empty header
+// or "*" means no precondition (nil version); weak tags are accepted.
+func parseIfMatch(header string) (*int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, true
+	}
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return nil, false
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil {
+		return nil, false
+	}
+	return &version, true
+}
+
+type BatchRequest struct {
+	TaskIDs    []string              `json:"task_ids" binding:"required"`
+	Action     task.BatchAction      `json:"action" binding:"required"`
+	Status     repository.TaskStatus `json:"status"`      // status
+	AssigneeID string                `json:"assignee_id"` // assign
+	ShiftHours int                   `json:"shift_hours"` // shift_due, may be negative
+}
+
+func (h *Handler) Batch(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	if len(req.TaskIDs) > task.MaxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "batch_too_large", "message": "too many task_ids (max " + strconv.Itoa(task.MaxBatchSize) + ")"}})
+		return
+	}
+
+	results, err := h.service.BatchUpdate(c.Request.Context(), user.ID, task.BatchParams{
+		TaskIDs:    req.TaskIDs,
+		Action:     req.Action,
+		Status:     req.Status,
+		AssigneeID: req.AssigneeID,
+		DueShift:   time.Duration(req.ShiftHours) * time.Hour,
+	})
+	if err != nil {
+		if errors.Is(err, task.ErrInvalidBatch) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "action must be status (with status), assign (with assignee_id), shift_due (with shift_hours), archive or delete"}})
+			return
+		}
+		if errors.Is(err, task.ErrAssigneeNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_assignee", "message": "assignee not found"}})
+			return
+		}
+		h.logger.Error("batch update failed", zap.Error(err), zap.String("action", string(req.Action)))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to update tasks"}})
+		return
+	}
+
+	succeeded := 0
+	for _, r := range results {
+		if r.OK {
+			succeeded++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"items":     results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	}})
+}
+
+type CreateTaskRequest struct {
+	Title       string `json:"title"` // Required unless created from a template, where it fills {input}
+	Description string `json:"description"`
+}
+
+func (h *Handler) CreateWebTask(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+
+	if name := c.Query("template"); name != "" {
+		h.createFromTemplate(c, user.ID, name, req.Title)
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "title is required"}})
+		return
+	}
+
+	task, err := h.service.CreateWebTask(c.Request.Context(), user.ID, req.Title, req.Description)
+	if err != nil {
+		h.logger.Error("create task failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to create task"}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": task})
+}
+
+func (h *Handler) ListSubtasks(c *gin.Context) {
+	taskID := c.Param("task_id")
+	subtasks, err := h.service.ListSubtasks(c.Request.Context(), taskID)
+	if err != nil {
+		h.logger.Error("list subtasks failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list subtasks"}})
+		return
+	}
+
+	done := 0
+	for _, t := range subtasks {
+		if t.IsDone() {
+			done++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"items":    subtasks,
+		"progress": gin.H{"done": done, "total": len(subtasks)},
+	}})
+}
+
+func (h *Handler) CreateSubtask(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	parentID := c.Param("task_id")
+	parent, err := h.service.GetTask(c.Request.Context(), parentID)
+	if err != nil {
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", parentID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return
+	}
+	if parent == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+		return
+	}
+
+	canModify, err := task.CanModifyTask(c.Request.Context(), user.ID, parent, h.userGroupRepo)
+	if err != nil {
+		h.logger.Error("permission check failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+		return
+	}
+	if !canModify {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "forbidden",
+				"message": "您没有权限为此任务添加子任务。只有创建人、指派人或群管理员可以操作。",
+			},
+		})
+		return
+	}
+
+	var req CreateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+
+	subtask, err := h.service.CreateSubtask(c.Request.Context(), user.ID, parentID, req.Title, req.Description)
+	if err != nil {
+		if errors.Is(err, task.ErrNestedSubtask) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "nested_subtask", "message": "子任务下不能再创建子任务"}})
+			return
+		}
+		h.logger.Error("create subtask failed", zap.Error(err), zap.String("task_id", parentID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to create subtask"}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": subtask})
+}
+
+func (h *Handler) ListDependencies(c *gin.Context) {
+	taskID := c.Param("task_id")
+	deps, err := h.service.ListDependencies(c.Request.Context(), taskID)
+	if err != nil {
+		h.logger.Error("list dependencies failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list dependencies"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": deps})
+}
+
+type AddDependencyRequest struct {
+	BlockedByID string `json:"blocked_by_id" binding:"required"`
+}
+
+func (h *Handler) AddDependency(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID := c.Param("task_id")
+	if !h.authorizeDependencyChange(c, user.ID, taskID) {
+		return
+	}
+
+	var req AddDependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	if !h.authorizeBlocker(c, user.ID, taskID, req.BlockedByID) {
+		return
+	}
+
+	if err := h.service.AddDependency(c.Request.Context(), user.ID, taskID, req.BlockedByID); err != nil {
+		switch {
+		case errors.Is(err, task.ErrSelfDependency):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_dependency", "message": "任务不能依赖自身"}})
+		case errors.Is(err, task.ErrDependencyCycle):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "dependency_cycle", "message": "添加该依赖会形成循环"}})
+		case err.Error() == "task not found":
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+		default:
+			h.logger.Error("add dependency failed", zap.Error(err), zap.String("task_id", taskID))
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to add dependency"}})
+		}
+		return
+	}
+
+	h.ListDependencies(c)
+}
+
+func (h *Handler) RemoveDependency(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID := c.Param("task_id")
+	if !h.authorizeDependencyChange(c, user.ID, taskID) {
+		return
+	}
+
+	blockedByID := c.Param("blocked_by_id")
+	if err := h.service.RemoveDependency(c.Request.Context(), user.ID, taskID, blockedByID); err != nil {
+		h.logger.Error("remove dependency failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to remove dependency"}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// authorizeDependencyChange checks that the user may change the dependencies
+// of the task, writing the error response when not
+func (h *Handler) authorizeDependencyChange(c *gin.Context, userID, taskID string) bool {
+	t, err := h.service.GetTask(c.Request.Context(), taskID)
+	if err != nil {
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return false
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+		return false
+	}
+
+	canModify, err := task.CanModifyTask(c.Request.Context(), userID, t, h.userGroupRepo)
+	if err != nil {
+		h.logger.Error("permission check failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+		return false
+	}
+	if !canModify {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "forbidden",
+				"message": "您没有权限修改此任务的依赖。只有创建人、指派人或群管理员可以操作。",
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// authorizeBlocker checks that the user may see the blocking task, as its
+// creator, assignee or group member, or that it belongs to the same group as
+// the blocked task. Dependency lists show the blockers' titles and statuses,
+// so hidden tasks are answered as not found.
+func (h *Handler) authorizeBlocker(c *gin.Context, userID, taskID, blockedByID string) bool {
+	blocker, err := h.service.GetTask(c.Request.Context(), blockedByID)
+	if err != nil {
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", blockedByID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return false
+	}
+	if blocker != nil {
+		canView, err := task.CanClaimTask(c.Request.Context(), userID, blocker, h.userGroupRepo)
+		if err != nil {
+			h.logger.Error("permission check failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+			return false
+		}
+		if canView {
+			return true
+		}
+		if blocker.GroupID != nil {
+			if t, err := h.service.GetTask(c.Request.Context(), taskID); err == nil && t != nil && t.GroupID != nil && *t.GroupID == *blocker.GroupID {
+				return true
+			}
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+	return false
+}
+
+// ListEvents returns the change history of a task, newest first
+func (h *Handler) ListEvents(c *gin.Context) {
+	taskID := c.Param("task_id")
+	limit := parseIntWithDefault(c.Query("limit"), 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset := parseIntWithDefault(c.Query("offset"), 0)
+
+	events, err := h.service.ListEvents(c.Request.Context(), taskID, task.ListEventsParams{Limit: limit, Offset: offset})
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+			return
+		}
+		h.logger.Error("list task events failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list task events"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": events})
+}
+
+// StartTimer starts the current user's timer on a task, stopping the timer
+// they were running on another task
+func (h *Handler) StartTimer(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID := c.Param("task_id")
+	if !h.authorizeTimeTracking(c, user.ID, taskID) {
+		return
+	}
+
+	entry, stopped, err := h.service.StartTimer(c.Request.Context(), user.ID, taskID)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+			return
+		}
+		h.logger.Error("start timer failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to start timer"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"entry": entry, "stopped": stopped}})
+}
+
+// StopTimer stops the current user's timer on a task
+func (h *Handler) StopTimer(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID := c.Param("task_id")
+	entry, err := h.service.StopTimer(c.Request.Context(), user.ID, taskID)
+	if err != nil {
+		if errors.Is(err, task.ErrNoRunningTimer) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": gin.H{"code": "timer_not_running", "message": "此任务没有正在进行的计时"}})
+			return
+		}
+		h.logger.Error("stop timer failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to stop timer"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": entry})
+}
+
+// ListTimeEntries returns the time logged on a task, most recent first
+func (h *Handler) ListTimeEntries(c *gin.Context) {
+	taskID := c.Param("task_id")
+	entries, err := h.service.ListTimeEntries(c.Request.Context(), taskID)
+	if err != nil {
+		h.logger.Error("list time entries failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list time entries"}})
+		return
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.DurationSeconds
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"items": entries, "total_seconds": total}})
+}
+
+type TimeEntryRequest struct {
+	Minutes   int        `json:"minutes" binding:"required"`
+	StartedAt *time.Time `json:"started_at"` // Defaults to minutes before now
+	Note      string     `json:"note"`
+}
+
+// AddTimeEntry records time the current user spent on a task
+func (h *Handler) AddTimeEntry(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID := c.Param("task_id")
+	var req TimeEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	if !h.authorizeTimeTracking(c, user.ID, taskID) {
+		return
+	}
+
+	entry, err := h.service.AddTimeEntry(c.Request.Context(), user.ID, taskID, task.TimeEntryParams{
+		StartedAt: req.StartedAt,
+		Duration:  time.Duration(req.Minutes) * time.Minute,
+		Note:      req.Note,
+	})
+	if err != nil {
+		if errors.Is(err, task.ErrInvalidTimeEntry) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "minutes must be between 1 and 1440"}})
+			return
+		}
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+			return
+		}
+		h.logger.Error("add time entry failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to add time entry"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": entry})
+}
+
+// DeleteTimeEntry deletes one of the current user's time entries
+func (h *Handler) DeleteTimeEntry(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID, entryID := c.Param("task_id"), c.Param("entry_id")
+	if err := h.service.DeleteTimeEntry(c.Request.Context(), user.ID, taskID, entryID); err != nil {
+		if errors.Is(err, task.ErrTimeEntryForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "只能删除自己的工时记录"}})
+			return
+		}
+		if err.Error() == "time entry not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "time entry not found"}})
+			return
+		}
+		h.logger.Error("delete time entry failed", zap.Error(err), zap.String("entry_id", entryID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to delete time entry"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// TimeReport aggregates logged time by user, group or day. Users see their
+// own time; group admins filtering by group_id see the whole group.
+func (h *Handler) TimeReport(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	groupBy := repository.TimeReportGroupBy(c.DefaultQuery("group_by", string(repository.TimeReportByUser)))
+	if !groupBy.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "group_by must be user, group or day"}})
+		return
+	}
+	from, ok := parseReportTime(c.Query("from"), false)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "from must be YYYY-MM-DD or RFC 3339"}})
+		return
+	}
+	to, ok := parseReportTime(c.Query("to"), true)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "to must be YYYY-MM-DD or RFC 3339"}})
+		return
+	}
+
+	rows, err := h.service.TimeReport(c.Request.Context(), user.ID, task.TimeReportParams{
+		From:    from,
+		To:      to,
+		GroupID: c.Query("group_id"),
+		GroupBy: groupBy,
+	})
+	if err != nil {
+		h.logger.Error("time report failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to build time report"}})
+		return
+	}
+
+	var total int64
+	for _, r := range rows {
+		total += r.TotalSeconds
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"group_by": groupBy, "rows": rows, "total_seconds": total}})
+}
+
+// parseReportTime parses a report bound given as RFC 3339 or YYYY-MM-DD. A
+// date-only upper bound includes that whole day.
+func parseReportTime(val string, upper bool) (*time.Time, bool) {
+	if val == "" {
+		return nil, true
+	}
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return &t, true
+	}
+	t, err := time.Parse("2006-01-02", val)
+	if err != nil {
+		return nil, false
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, true
+}
+
+// authorizeTimeTracking checks that the user may log time on the task,
+// writing the error response when not
+func (h *Handler) authorizeTimeTracking(c *gin.Context, userID, taskID string) bool {
+	t, err := h.service.GetTask(c.Request.Context(), taskID)
+	if err != nil {
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return false
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+		return false
+	}
+
+	canModify, err := task.CanModifyTask(c.Request.Context(), userID, t, h.userGroupRepo)
+	if err != nil {
+		h.logger.Error("permission check failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+		return false
+	}
+	if !canModify {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "forbidden",
+				"message": "您没有权限为此任务记录工时。只有创建人、指派人或群管理员可以操作。",
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// createFromTemplate handles POST /tasks?template=, where template is a
+// template ID or a name resolved against group_id and the user's templates
+func (h *Handler) createFromTemplate(c *gin.Context, userID, nameOrID, input string) {
+	ctx := c.Request.Context()
+	var tmpl *repository.TaskTemplate
+	var err error
+	if _, parseErr := uuid.Parse(nameOrID); parseErr == nil {
+		tmpl, err = h.service.GetTemplate(ctx, nameOrID)
+	} else {
+		tmpl, err = h.service.ResolveTemplate(ctx, userID, c.Query("group_id"), nameOrID)
+	}
+	if errors.Is(err, task.ErrTemplateNotFound) || (err == nil && tmpl == nil) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "template not found"}})
+		return
+	}
+	if err != nil {
+		h.logger.Error("get template failed", zap.Error(err), zap.String("template", nameOrID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get template"}})
+		return
+	}
+	if !task.CanUseTemplate(ctx, userID, tmpl, h.userGroupRepo) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "您没有权限使用此模板。群模板仅限群成员使用。"}})
+		return
+	}
+
+	created, err := h.service.CreateFromTemplate(ctx, userID, tmpl, input)
+	if err != nil {
+		h.logger.Error("create task from template failed", zap.Error(err), zap.String("template_id", tmpl.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to create task"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": created})
+}
+
+type TemplateRequest struct {
+	Name             string   `json:"name" binding:"required"`
+	GroupID          string   `json:"group_id"` // Only on creation; empty for a personal template
+	TitlePattern     string   `json:"title_pattern" binding:"required"`
+	Description      string   `json:"description"`
+	Priority         string   `json:"priority"`
+	AssigneeIDs      []string `json:"assignee_ids"`
+	Labels           []string `json:"labels"`
+	DueOffsetMinutes *int     `json:"due_offset_minutes"`
+	Subtasks         []string `json:"subtasks"`
+}
+
+func (r TemplateRequest) params() task.TemplateParams {
+	return task.TemplateParams{
+		Name:             r.Name,
+		GroupID:          r.GroupID,
+		TitlePattern:     r.TitlePattern,
+		Description:      r.Description,
+		Priority:         repository.TaskPriority(r.Priority),
+		AssigneeIDs:      r.AssigneeIDs,
+		Labels:           r.Labels,
+		DueOffsetMinutes: r.DueOffsetMinutes,
+		Subtasks:         r.Subtasks,
+	}
+}
+
+func (h *Handler) ListTemplates(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	groupID := c.Query("group_id")
+	if groupID != "" {
+		if userGroup, err := h.userGroupRepo.FindByUserAndGroup(c.Request.Context(), user.ID, groupID); err != nil || userGroup == nil {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "您不是该群成员，无法查看群模板。"}})
+			return
+		}
+	}
+
+	templates, err := h.service.ListTemplates(c.Request.Context(), user.ID, groupID)
+	if err != nil {
+		h.logger.Error("list templates failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list templates"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": templates})
+}
+
+func (h *Handler) CreateTemplate(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	if req.GroupID != "" {
+		probe := &repository.TaskTemplate{GroupID: &req.GroupID}
+		if !task.CanManageTemplate(c.Request.Context(), user.ID, probe, h.userGroupRepo) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "只有群管理员可以创建群模板。"}})
+			return
+		}
+	}
+
+	tmpl, err := h.service.CreateTemplate(c.Request.Context(), user.ID, req.params())
+	if err != nil {
+		h.writeTemplateError(c, err, "create template failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tmpl})
+}
+
+func (h *Handler) UpdateTemplate(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	templateID := c.Param("template_id")
+	if !h.authorizeTemplateChange(c, user.ID, templateID) {
+		return
+	}
+
+	tmpl, err := h.service.UpdateTemplate(c.Request.Context(), templateID, req.params())
+	if err != nil {
+		h.writeTemplateError(c, err, "update template failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tmpl})
+}
+
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	templateID := c.Param("template_id")
+	if !h.authorizeTemplateChange(c, user.ID, templateID) {
+		return
+	}
+	if err := h.service.DeleteTemplate(c.Request.Context(), templateID); err != nil {
+		h.writeTemplateError(c, err, "delete template failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// authorizeTemplateChange checks that the user may edit or delete the
+// template, writing the error response when not
+func (h *Handler) authorizeTemplateChange(c *gin.Context, userID, templateID string) bool {
+	tmpl, err := h.service.GetTemplate(c.Request.Context(), templateID)
+	if err != nil {
+		h.logger.Error("get template failed", zap.Error(err), zap.String("template_id", templateID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get template"}})
+		return false
+	}
+	if tmpl == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "template not found"}})
+		return false
+	}
+	if !task.CanManageTemplate(c.Request.Context(), userID, tmpl, h.userGroupRepo) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "您没有权限修改此模板。个人模板仅限本人，群模板仅限群管理员。"}})
+		return false
+	}
+	return true
+}
+
+// writeTemplateError maps template service errors to responses
+func (h *Handler) writeTemplateError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, task.ErrInvalidTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "name must be letters, digits, _ or -; title_pattern is required; at most 20 subtasks"}})
+	case errors.Is(err, task.ErrAssigneeNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_assignee", "message": "assignee not found"}})
+	case errors.Is(err, task.ErrTemplateExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": gin.H{"code": "template_exists", "message": "template name already in use"}})
+	case errors.Is(err, task.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "template not found"}})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": msg}})
+	}
+}
+
+func (h *Handler) ListAttachments(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID := c.Param("task_id")
+	if !h.authorizeAttachmentAccess(c, user.ID, taskID) {
+		return
+	}
+	attachments, err := h.service.ListAttachments(c.Request.Context(), taskID)
+	if err != nil {
+		h.logger.Error("list attachments failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list attachments"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": attachments})
+}
+
+// DownloadAttachment proxies an attachment from Telegram
+func (h *Handler) DownloadAttachment(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID := c.Param("task_id")
+	if !h.authorizeAttachmentAccess(c, user.ID, taskID) {
+		return
+	}
+	attachment, err := h.service.GetAttachment(c.Request.Context(), c.Param("attachment_id"))
+	if err != nil {
+		h.logger.Error("get attachment failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get attachment"}})
+		return
+	}
+	if attachment == nil || attachment.TaskID != taskID {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "attachment not found"}})
+		return
+	}
+	h.streamAttachment(c, attachment)
+}
+
+// DownloadSignedAttachment serves the public attachment links embedded in
+// Notion pages, authorized by their signature instead of Telegram login
+func (h *Handler) DownloadSignedAttachment(c *gin.Context) {
+	attachmentID := c.Param("attachment_id")
+	if !h.service.VerifyAttachmentSignature(attachmentID, c.Query("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "invalid signature"}})
+		return
+	}
+	attachment, err := h.service.GetAttachment(c.Request.Context(), attachmentID)
+	if err != nil {
+		h.logger.Error("get attachment failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get attachment"}})
+		return
+	}
+	if attachment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "attachment not found"}})
+		return
+	}
+	h.streamAttachment(c, attachment)
+}
+
+// streamAttachment writes the attachment content fetched from Telegram
+func (h *Handler) streamAttachment(c *gin.Context, attachment *repository.TaskAttachment) {
+	c.Header("X-Content-Type-Options", "nosniff")
+	body, size, err := h.service.OpenAttachment(c.Request.Context(), attachment)
+	if errors.Is(err, telegram.ErrFileTooBig) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": gin.H{"code": "file_too_big", "message": "telegram bots can only download files up to 20 MB"}})
+		return
+	}
+	if err != nil {
+		h.logger.Error("download attachment failed", zap.Error(err), zap.String("attachment_id", attachment.ID))
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": gin.H{"code": "download_failed", "message": "failed to download file from telegram"}})
+		return
+	}
+	defer body.Close()
+
+	contentType, name := attachment.MimeType, attachment.FileName
+	switch attachment.Kind {
+	case repository.AttachmentKindPhoto:
+		contentType, name = "image/jpeg", fmt.Sprintf("photo_%d.jpg", attachment.TgMessageID)
+	case repository.AttachmentKindVoice:
+		if name == "" {
+			name = fmt.Sprintf("voice_%d.ogg", attachment.TgMessageID)
+		}
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if name == "" {
+		name = fmt.Sprintf("%s_%d", attachment.Kind, attachment.TgMessageID)
+	}
+	// Types come from the uploader, so only raster images, which cannot run
+	// scripts, open in the browser
+	disposition := "attachment"
+	if inlineAttachmentTypes[contentType] {
+		disposition = "inline"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, body, map[string]string{
+		"Content-Disposition": fmt.Sprintf("%s; filename*=UTF-8''%s", disposition, url.PathEscape(name)),
+		"Cache-Control":       "private, max-age=3600",
+	})
+}
+
+// inlineAttachmentTypes are the content types served inline
+var inlineAttachmentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// authorizeAttachmentAccess checks that the user may see the task's files:
+// its creator, assignees or members of its group
+func (h *Handler) authorizeAttachmentAccess(c *gin.Context, userID, taskID string) bool {
+	t, err := h.service.GetTask(c.Request.Context(), taskID)
+	if err != nil {
+		h.logger.Error("get task failed", zap.Error(err), zap.String("task_id", taskID))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to get task"}})
+		return false
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "task not found"}})
+		return false
+	}
+
+	canView, err := task.CanClaimTask(c.Request.Context(), userID, t, h.userGroupRepo)
+	if err != nil {
+		h.logger.Error("permission check failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "permission check failed"}})
+		return false
+	}
+	if !canView {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "您没有权限查看此任务的附件。"}})
+		return false
+	}
+	return true
+}
+
+type CreateCommentRequest struct {
+	Content  string  `json:"content" binding:"required"`
+	ParentID *string `json:"parent_id"`
+}
+
+// ListComments lists the comments of a task, flat in creation order or, with
+// view=tree, as threads with replies nested under their parent
+func (h *Handler) ListComments(c *gin.Context) {
+	taskID := c.Param("task_id")
+	if c.Query("view") == "tree" {
+		threads, err := h.service.ListCommentThreads(c.Request.Context(), taskID)
+		if err != nil {
+			h.logger.Error("list comments failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list comments"}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": threads})
+		return
+	}
+
+	comments, err := h.service.ListComments(c.Request.Context(), taskID)
+	if err != nil {
+		h.logger.Error("list comments failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to list comments"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": comments})
+}
+
+func (h *Handler) CreateComment(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	taskID := c.Param("task_id")
+	var req CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+
+	comment, err := h.service.CreateComment(c.Request.Context(), taskID, user.ID, req.Content, req.ParentID)
+	if errors.Is(err, task.ErrCommentNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_parent", "message": "parent comment not found in this task"}})
+		return
+	}
+	if err != nil {
+		h.logger.Error("create comment failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": "failed to create comment"}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": comment})
+}
+
+type UpdateCommentRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// UpdateComment edits a comment; only its author may
+func (h *Handler) UpdateComment(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var req UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_request", "message": "content is required"}})
+		return
+	}
+
+	comment, err := h.service.UpdateComment(c.Request.Context(), c.Param("task_id"), c.Param("comment_id"), user.ID, req.Content)
+	if err != nil {
+		h.writeCommentError(c, err, "update comment failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": comment})
+}
+
+// DeleteComment deletes a comment; only its author may
+func (h *Handler) DeleteComment(c *gin.Context) {
+	user, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	commentID := c.Param("comment_id")
+	if err := h.service.DeleteComment(c.Request.Context(), c.Param("task_id"), commentID, user.ID); err != nil {
+		h.writeCommentError(c, err, "delete comment failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"id": commentID, "deleted": true}})
+}
+
+// writeCommentError maps comment service errors to responses
+func (h *Handler) writeCommentError(c *gin.Context, err error, logMsg string) {
+	switch {
+	case errors.Is(err, task.ErrCommentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": gin.H{"code": "not_found", "message": "comment not found"}})
+	case errors.Is(err, task.ErrCommentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": gin.H{"code": "forbidden", "message": "只能编辑或删除自己的评论。"}})
+	default:
+		h.logger.Error(logMsg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": gin.H{"code": "internal_error", "message": logMsg}})
+	}
+}
+
+// parsePriorities parses a comma separated priority list (e.g. "High,Medium")
+func parsePriorities(val string) ([]repository.TaskPriority, bool) {
+	if val == "" {
+		return nil, true
+	}
+	var priorities []repository.TaskPriority
+	for _, part := range strings.Split(val, ",") {
+		p := repository.TaskPriority(strings.TrimSpace(part))
+		if !p.IsValid() {
+			return nil, false
+		}
+		priorities = append(priorities, p)
+	}
+	return priorities, true
+}
+
+// parseLabels parses a comma separated label list (e.g. "bug,#frontend")
+func parseLabels(val string) []string {
+	var labels []string
+	for _, part := range strings.Split(val, ",") {
+		if l := repository.NormalizeLabel(part); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
 }
 
 func parseIntWithDefault(val string, def int) int {
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -24,9 +27,9 @@ type mockTaskService struct {
 	mock.Mock
 }
 
-func (m *mockTaskService) ListTasks(ctx context.Context, userID string, params taskservice.ListParams) ([]taskservice.TaskDetail, error) {
+func (m *mockTaskService) ListTasks(ctx context.Context, userID string, params taskservice.ListParams) ([]taskservice.TaskDetail, string, error) {
 	args := m.Called(ctx, userID, params)
-	return args.Get(0).([]taskservice.TaskDetail), args.Error(1)
+	return args.Get(0).([]taskservice.TaskDetail), args.String(1), args.Error(2)
 }
 
 func (m *mockTaskService) GetTask(ctx context.Context, id string) (*repository.Task, error) {
@@ -37,18 +40,58 @@ func (m *mockTaskService) GetTask(ctx context.Context, id string) (*repository.T
 	return args.Get(0).(*repository.Task), args.Error(1)
 }
 
-func (m *mockTaskService) DeleteTask(ctx context.Context, id string) error {
-	return m.Called(ctx, id).Error(0)
+func (m *mockTaskService) DeleteTask(ctx context.Context, userID, id string) error {
+	return m.Called(ctx, userID, id).Error(0)
 }
 
-func (m *mockTaskService) UpdateTask(ctx context.Context, id string, params taskservice.UpdateParams) (*repository.Task, error) {
-	args := m.Called(ctx, id, params)
+func (m *mockTaskService) UpdateTask(ctx context.Context, userID, id string, params taskservice.UpdateParams) (*repository.Task, error) {
+	args := m.Called(ctx, userID, id, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) ListSubtasks(ctx context.Context, parentID string) ([]repository.Task, error) {
+	args := m.Called(ctx, parentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) CreateSubtask(ctx context.Context, userID, parentID, title, description string) (*repository.Task, error) {
+	args := m.Called(ctx, userID, parentID, title, description)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*repository.Task), args.Error(1)
 }
 
+func (m *mockTaskService) SearchTasks(ctx context.Context, userID string, params taskservice.SearchParams) ([]taskservice.SearchResult, error) {
+	args := m.Called(ctx, userID, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]taskservice.SearchResult), args.Error(1)
+}
+
+func (m *mockTaskService) ListDependencies(ctx context.Context, taskID string) (*taskservice.Dependencies, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*taskservice.Dependencies), args.Error(1)
+}
+
+func (m *mockTaskService) AddDependency(ctx context.Context, userID, taskID, blockedByID string) error {
+	return m.Called(ctx, userID, taskID, blockedByID).Error(0)
+}
+
+func (m *mockTaskService) RemoveDependency(ctx context.Context, userID, taskID, blockedByID string) error {
+	return m.Called(ctx, userID, taskID, blockedByID).Error(0)
+}
+
 func (m *mockTaskService) CreateWebTask(ctx context.Context, userID, title, description string) (*repository.Task, error) {
 	args := m.Called(ctx, userID, title, description)
 	if args.Get(0) == nil {
@@ -65,6 +108,26 @@ func (m *mockTaskService) CreateComment(ctx context.Context, taskID, userID, con
 	return args.Get(0).(*repository.TaskComment), args.Error(1)
 }
 
+func (m *mockTaskService) ListCommentThreads(ctx context.Context, taskID string) ([]*repository.TaskComment, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*repository.TaskComment), args.Error(1)
+}
+
+func (m *mockTaskService) UpdateComment(ctx context.Context, taskID, commentID, userID, content string) (*repository.TaskComment, error) {
+	args := m.Called(ctx, taskID, commentID, userID, content)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TaskComment), args.Error(1)
+}
+
+func (m *mockTaskService) DeleteComment(ctx context.Context, taskID, commentID, userID string) error {
+	return m.Called(ctx, taskID, commentID, userID).Error(0)
+}
+
 func (m *mockTaskService) ListComments(ctx context.Context, taskID string) ([]repository.TaskComment, error) {
 	args := m.Called(ctx, taskID)
 	if args.Get(0) == nil {
@@ -81,6 +144,222 @@ func (m *mockTaskService) GetTaskCounts(ctx context.Context, userID string) (*re
 	return args.Get(0).(*repository.TaskCounts), args.Error(1)
 }
 
+func (m *mockTaskService) ListEvents(ctx context.Context, taskID string, params taskservice.ListEventsParams) ([]repository.TaskEvent, error) {
+	args := m.Called(ctx, taskID, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.TaskEvent), args.Error(1)
+}
+
+func (m *mockTaskService) ListTrash(ctx context.Context, userID string, params taskservice.ListTrashParams) ([]repository.Task, error) {
+	args := m.Called(ctx, userID, params)
+	return args.Get(0).([]repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) GetDeletedTask(ctx context.Context, id string) (*repository.Task, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) RestoreTask(ctx context.Context, userID, id string) (*repository.Task, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) SetArchived(ctx context.Context, userID, id string, archived bool, source repository.TaskEventSource) (*repository.Task, error) {
+	args := m.Called(ctx, userID, id, archived, source)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) AddAssignee(ctx context.Context, actorID, taskID, userID string, source repository.TaskEventSource) (*repository.Task, error) {
+	args := m.Called(ctx, actorID, taskID, userID, source)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) RemoveAssignee(ctx context.Context, actorID, taskID, userID string, source repository.TaskEventSource) (*repository.Task, error) {
+	args := m.Called(ctx, actorID, taskID, userID, source)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) Watch(ctx context.Context, userID, taskID string) (bool, error) {
+	args := m.Called(ctx, userID, taskID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockTaskService) Unwatch(ctx context.Context, userID, taskID string) (bool, error) {
+	args := m.Called(ctx, userID, taskID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockTaskService) StartTimer(ctx context.Context, userID, taskID string) (*repository.TimeEntry, *repository.TimeEntry, error) {
+	args := m.Called(ctx, userID, taskID)
+	var entry, stopped *repository.TimeEntry
+	if args.Get(0) != nil {
+		entry = args.Get(0).(*repository.TimeEntry)
+	}
+	if args.Get(1) != nil {
+		stopped = args.Get(1).(*repository.TimeEntry)
+	}
+	return entry, stopped, args.Error(2)
+}
+
+func (m *mockTaskService) StopTimer(ctx context.Context, userID, taskID string) (*repository.TimeEntry, error) {
+	args := m.Called(ctx, userID, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TimeEntry), args.Error(1)
+}
+
+func (m *mockTaskService) AddTimeEntry(ctx context.Context, userID, taskID string, params taskservice.TimeEntryParams) (*repository.TimeEntry, error) {
+	args := m.Called(ctx, userID, taskID, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TimeEntry), args.Error(1)
+}
+
+func (m *mockTaskService) DeleteTimeEntry(ctx context.Context, userID, taskID, entryID string) error {
+	args := m.Called(ctx, userID, taskID, entryID)
+	return args.Error(0)
+}
+
+func (m *mockTaskService) ListTimeEntries(ctx context.Context, taskID string) ([]repository.TimeEntry, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.TimeEntry), args.Error(1)
+}
+
+func (m *mockTaskService) TimeReport(ctx context.Context, userID string, params taskservice.TimeReportParams) ([]repository.TimeReportRow, error) {
+	args := m.Called(ctx, userID, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.TimeReportRow), args.Error(1)
+}
+
+func (m *mockTaskService) ListTemplates(ctx context.Context, userID, groupID string) ([]repository.TaskTemplate, error) {
+	args := m.Called(ctx, userID, groupID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.TaskTemplate), args.Error(1)
+}
+
+func (m *mockTaskService) GetTemplate(ctx context.Context, id string) (*repository.TaskTemplate, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TaskTemplate), args.Error(1)
+}
+
+func (m *mockTaskService) ResolveTemplate(ctx context.Context, userID, groupID, name string) (*repository.TaskTemplate, error) {
+	args := m.Called(ctx, userID, groupID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TaskTemplate), args.Error(1)
+}
+
+func (m *mockTaskService) CreateTemplate(ctx context.Context, userID string, params taskservice.TemplateParams) (*repository.TaskTemplate, error) {
+	args := m.Called(ctx, userID, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TaskTemplate), args.Error(1)
+}
+
+func (m *mockTaskService) UpdateTemplate(ctx context.Context, id string, params taskservice.TemplateParams) (*repository.TaskTemplate, error) {
+	args := m.Called(ctx, id, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TaskTemplate), args.Error(1)
+}
+
+func (m *mockTaskService) DeleteTemplate(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *mockTaskService) CreateFromTemplate(ctx context.Context, userID string, tmpl *repository.TaskTemplate, input string) (*repository.Task, error) {
+	args := m.Called(ctx, userID, tmpl, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) BatchUpdate(ctx context.Context, userID string, params taskservice.BatchParams) ([]taskservice.BatchItemResult, error) {
+	args := m.Called(ctx, userID, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]taskservice.BatchItemResult), args.Error(1)
+}
+
+func (m *mockTaskService) MoveTask(ctx context.Context, userID, id string, params taskservice.MoveParams) (*repository.Task, error) {
+	args := m.Called(ctx, userID, id, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) CloneTask(ctx context.Context, userID, id string, params taskservice.MoveParams) (*repository.Task, error) {
+	args := m.Called(ctx, userID, id, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Task), args.Error(1)
+}
+
+func (m *mockTaskService) ListAttachments(ctx context.Context, taskID string) ([]repository.TaskAttachment, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.TaskAttachment), args.Error(1)
+}
+
+func (m *mockTaskService) GetAttachment(ctx context.Context, id string) (*repository.TaskAttachment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TaskAttachment), args.Error(1)
+}
+
+func (m *mockTaskService) OpenAttachment(ctx context.Context, attachment *repository.TaskAttachment) (io.ReadCloser, int64, error) {
+	args := m.Called(ctx, attachment)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).(io.ReadCloser), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *mockTaskService) VerifyAttachmentSignature(attachmentID, sig string) bool {
+	return m.Called(attachmentID, sig).Bool(0)
+}
+
 type mockUserGroupRepo struct {
 	mock.Mock
 }
@@ -107,10 +386,10 @@ func TestListTasksHandler(t *testing.T) {
 	logger := zap.NewNop()
 	h := NewHandler(logger, service, new(mockUserGroupRepo))
 
-	params := taskservice.ListParams{View: repository.TaskViewAssigned, Limit: 10}
+	params := taskservice.ListParams{View: repository.TaskViewAssigned, Location: time.UTC, Sort: repository.TaskSortCreated, Limit: 10}
 	service.On("ListTasks", mock.Anything, "user-1", params).Return([]taskservice.TaskDetail{
 		{Task: &repository.Task{ID: "task-1"}},
-	}, nil)
+	}, "", nil)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -155,7 +434,7 @@ func TestDeleteTaskFailure(t *testing.T) {
 
 	creatorID := "user-1"
 	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID}, nil)
-	service.On("DeleteTask", mock.Anything, "task-1").Return(errors.New("boom"))
+	service.On("DeleteTask", mock.Anything, "user-1", "task-1").Return(errors.New("boom"))
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -168,3 +447,1022 @@ func TestDeleteTaskFailure(t *testing.T) {
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestListTasksHandlerPriorityFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	logger := zap.NewNop()
+	h := NewHandler(logger, service, new(mockUserGroupRepo))
+
+	params := taskservice.ListParams{
+		View:       repository.TaskViewAll,
+		Priorities: []repository.TaskPriority{repository.TaskPriorityHigh, repository.TaskPriorityLow},
+		Location:   time.UTC,
+		Sort:       repository.TaskSortPriority,
+		Limit:      20,
+	}
+	service.On("ListTasks", mock.Anything, "user-1", params).Return([]taskservice.TaskDetail{}, "", nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks?priority=High,Low&sort=priority", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.List(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	service.AssertExpectations(t)
+}
+
+func TestListTasksHandlerLabelFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	params := taskservice.ListParams{
+		View:     repository.TaskViewAll,
+		Labels:   []string{"bug", "frontend"},
+		Location: time.UTC,
+		Sort:     repository.TaskSortCreated,
+		Limit:    20,
+	}
+	service.On("ListTasks", mock.Anything, "user-1", params).Return([]taskservice.TaskDetail{}, "", nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks?label=Bug,%23frontend", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.List(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	service.AssertExpectations(t)
+}
+
+func TestListTasksHandlerInvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	params := taskservice.ListParams{
+		View:     repository.TaskViewAll,
+		Query:    "status:todo colour:red",
+		Location: time.UTC,
+		Sort:     repository.TaskSortCreated,
+		Limit:    20,
+	}
+	queryErr := &repository.QueryError{Token: "colour:red", Pos: 12, Message: `unknown filter "colour"`}
+	service.On("ListTasks", mock.Anything, "user-1", params).Return([]taskservice.TaskDetail(nil), "", queryErr)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks?q=status%3Atodo+colour%3Ared", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.List(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp struct {
+		Error struct {
+			Code     string `json:"code"`
+			Token    string `json:"token"`
+			Position int    `json:"position"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_query", resp.Error.Code)
+	assert.Equal(t, "colour:red", resp.Error.Token)
+	assert.Equal(t, 12, resp.Error.Position)
+}
+
+func TestListTasksHandlerRejectsUnknownPriority(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks?priority=Urgent", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.List(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	service.AssertNotCalled(t, "ListTasks")
+}
+
+func TestListSubtasksReportsProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	service.On("ListSubtasks", mock.Anything, "task-1").Return([]repository.Task{
+		{ID: "s1", Status: repository.TaskStatusDone},
+		{ID: "s2", Status: repository.TaskStatusToDo},
+		{ID: "s3", Status: repository.TaskStatusDone},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks/task-1/subtasks", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+
+	h.ListSubtasks(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data struct {
+			Progress struct {
+				Done  int `json:"done"`
+				Total int `json:"total"`
+			} `json:"progress"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Data.Progress.Done)
+	assert.Equal(t, 3, resp.Data.Progress.Total)
+}
+
+func TestCreateSubtaskForbidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creator := "someone-else"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creator}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/subtasks", strings.NewReader(`{"title":"Step 1"}`))
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.CreateSubtask(c)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNotCalled(t, "CreateSubtask", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateSubtaskRejectsNesting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	userID := "user-1"
+	service.On("GetTask", mock.Anything, "sub-1").Return(&repository.Task{ID: "sub-1", CreatorID: &userID}, nil)
+	service.On("CreateSubtask", mock.Anything, userID, "sub-1", "Step 1", "").Return(nil, taskservice.ErrNestedSubtask)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/sub-1/subtasks", strings.NewReader(`{"title":"Step 1"}`))
+	c.Params = gin.Params{{Key: "task_id", Value: "sub-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: userID})
+
+	h.CreateSubtask(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "nested_subtask")
+}
+
+func TestAddDependencyRejectsCycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creator := "user-1"
+	service.On("GetTask", mock.Anything, "deploy").Return(&repository.Task{ID: "deploy", CreatorID: &creator}, nil)
+	service.On("GetTask", mock.Anything, "qa").Return(&repository.Task{ID: "qa", CreatorID: &creator}, nil)
+	service.On("AddDependency", mock.Anything, "user-1", "deploy", "qa").Return(taskservice.ErrDependencyCycle)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/deploy/dependencies", strings.NewReader(`{"blocked_by_id":"qa"}`))
+	c.Params = gin.Params{{Key: "task_id", Value: "deploy"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.AddDependency(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "dependency_cycle")
+}
+
+func TestAddDependencyRequiresVisibleBlocker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	creator, other, group, elsewhere := "user-1", "user-2", "g1", "g2"
+	service.On("GetTask", mock.Anything, "deploy").Return(&repository.Task{ID: "deploy", CreatorID: &creator, GroupID: &group}, nil)
+	service.On("GetTask", mock.Anything, "secret").Return(&repository.Task{ID: "secret", CreatorID: &other, GroupID: &elsewhere}, nil)
+	service.On("GetTask", mock.Anything, "sibling").Return(&repository.Task{ID: "sibling", CreatorID: &other, GroupID: &group}, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "user-1", mock.Anything).Return(nil, errors.New("not found"))
+	service.On("AddDependency", mock.Anything, "user-1", "deploy", "sibling").Return(nil)
+	service.On("ListDependencies", mock.Anything, "deploy").Return(&taskservice.Dependencies{}, nil)
+
+	add := func(blockedByID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/tasks/deploy/dependencies", strings.NewReader(`{"blocked_by_id":"`+blockedByID+`"}`))
+		c.Params = gin.Params{{Key: "task_id", Value: "deploy"}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+		h.AddDependency(c)
+		return w
+	}
+
+	w := add("secret")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	service.AssertNotCalled(t, "AddDependency", mock.Anything, "user-1", "deploy", "secret")
+
+	// Tasks of the same group may block each other
+	w = add("sibling")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUpdateBlockedTaskReturnsConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creator := "user-1"
+	service.On("GetTask", mock.Anything, "deploy").Return(&repository.Task{ID: "deploy", CreatorID: &creator}, nil)
+	service.On("UpdateTask", mock.Anything, "user-1", "deploy", mock.Anything).Return(nil, taskservice.ErrTaskBlocked)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/tasks/deploy", strings.NewReader(`{"status":"In Progress"}`))
+	c.Params = gin.Params{{Key: "task_id", Value: "deploy"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.Update(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "task_blocked")
+}
+
+func TestUpdateHonoursIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creator := "user-1"
+	current := &repository.Task{ID: "t1", Title: "Theirs", CreatorID: &creator, Version: 4}
+	service.On("GetTask", mock.Anything, "t1").Return(current, nil)
+	service.On("UpdateTask", mock.Anything, "user-1", "t1", mock.MatchedBy(func(p taskservice.UpdateParams) bool {
+		return p.IfVersion != nil && *p.IfVersion == 3
+	})).Return(nil, &taskservice.ConflictError{Current: current})
+	service.On("UpdateTask", mock.Anything, "user-1", "t1", mock.MatchedBy(func(p taskservice.UpdateParams) bool {
+		return p.IfVersion != nil && *p.IfVersion == 4
+	})).Return(&repository.Task{ID: "t1", Title: "Mine", CreatorID: &creator, Version: 5}, nil)
+
+	patch := func(ifMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPatch, "/tasks/t1", strings.NewReader(`{"title":"Mine"}`))
+		c.Request.Header.Set("If-Match", ifMatch)
+		c.Params = gin.Params{{Key: "task_id", Value: "t1"}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+		h.Update(c)
+		return w
+	}
+
+	w := patch(`"3"`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "version_conflict")
+	assert.Contains(t, w.Body.String(), "Theirs")
+
+	w = patch(`W/"4"`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+
+	w = patch(`abc`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateParsesDatesInUserZone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creator := "user-1"
+	service.On("GetTask", mock.Anything, "t1").Return(&repository.Task{ID: "t1", CreatorID: &creator}, nil)
+	service.On("UpdateTask", mock.Anything, "user-1", "t1", mock.MatchedBy(func(p taskservice.UpdateParams) bool {
+		// A date-only due_at implies all_day; the local start time is read in the user's zone
+		return p.AllDay != nil && *p.AllDay &&
+			p.DueAt.Equal(time.Date(2026, 3, 1, 16, 0, 0, 0, time.UTC)) &&
+			p.StartAt.Equal(time.Date(2026, 2, 28, 1, 30, 0, 0, time.UTC)) &&
+			p.Location.String() == "Asia/Shanghai"
+	})).Return(&repository.Task{ID: "t1", CreatorID: &creator}, nil)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPatch, "/tasks/t1", strings.NewReader(body))
+		c.Params = gin.Params{{Key: "task_id", Value: "t1"}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1", Timezone: "Asia/Shanghai"})
+		h.Update(c)
+		return w
+	}
+
+	w := patch(`{"due_at":"2026-03-02","start_at":"2026-02-28T09:30"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = patch(`{"due_at":"next friday"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_date")
+	service.AssertNumberOfCalls(t, "UpdateTask", 1)
+}
+
+func TestSearchHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	results := []taskservice.SearchResult{{
+		Task:       &repository.Task{ID: "t1", Title: "发票报销"},
+		Rank:       8,
+		Highlights: map[string]string{"title": "<mark>发票</mark>报销"},
+	}}
+	service.On("SearchTasks", mock.Anything, "user-1", taskservice.SearchParams{Query: "发票", Limit: 20}).Return(results, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks/search?q=%E5%8F%91%E7%A5%A8", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.Search(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "highlights")
+	service.AssertExpectations(t)
+}
+
+func TestSearchHandlerRejectsEmptyQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	service.On("SearchTasks", mock.Anything, "user-1", mock.Anything).Return(nil, taskservice.ErrEmptySearchQuery)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks/search", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.Search(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_query")
+}
+
+func TestListTasksHandlerCursorAndSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	params := taskservice.ListParams{View: repository.TaskViewAll, Location: time.UTC, Sort: repository.TaskSortDue, Cursor: "abc", Limit: 20}
+	service.On("ListTasks", mock.Anything, "user-1", params).Return([]taskservice.TaskDetail{}, "next", nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks?sort=due&cursor=abc", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.List(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_cursor":"next"`)
+	service.AssertExpectations(t)
+}
+
+func TestListTasksHandlerRejectsInvalidCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	service.On("ListTasks", mock.Anything, "user-1", mock.Anything).Return([]taskservice.TaskDetail(nil), "", repository.ErrInvalidCursor)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks?cursor=bogus", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.List(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_cursor")
+}
+
+func TestListEventsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	actor := "user-1"
+	service.On("ListEvents", mock.Anything, "task-1", taskservice.ListEventsParams{Limit: 50}).Return([]repository.TaskEvent{
+		{ID: "e1", TaskID: "task-1", ActorID: &actor, Event: repository.TaskEventStatus, Source: repository.TaskEventSourceApp,
+			Before: []byte(`{"status":"To Do"}`), After: []byte(`{"status":"Done"}`)},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks/task-1/events?limit=1000", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.ListEvents(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data []struct {
+			Event  string          `json:"event"`
+			Source string          `json:"source"`
+			After  json.RawMessage `json:"after"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data, 1) {
+		assert.Equal(t, "Status", resp.Data[0].Event)
+		assert.Equal(t, "app", resp.Data[0].Source)
+		assert.JSONEq(t, `{"status":"Done"}`, string(resp.Data[0].After))
+	}
+	service.AssertExpectations(t)
+}
+
+func TestRestoreTaskHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creatorID := "user-1"
+	task := &repository.Task{ID: "task-1", CreatorID: &creatorID}
+	service.On("GetDeletedTask", mock.Anything, "task-1").Return(task, nil)
+	service.On("RestoreTask", mock.Anything, "user-1", "task-1").Return(task, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/restore", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.Restore(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	service.AssertExpectations(t)
+}
+
+func TestRestoreTaskHandlerForbiddenAndMissing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creatorID := "user-2"
+	service.On("GetDeletedTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID}, nil)
+	service.On("GetDeletedTask", mock.Anything, "task-404").Return(nil, nil)
+
+	for id, want := range map[string]int{"task-1": http.StatusForbidden, "task-404": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/tasks/"+id+"/restore", nil)
+		c.Params = gin.Params{{Key: "task_id", Value: id}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+		h.Restore(c)
+
+		assert.Equal(t, want, w.Code, id)
+	}
+	service.AssertNotCalled(t, "RestoreTask", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestArchiveTaskHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creatorID := "user-1"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID}, nil)
+	service.On("SetArchived", mock.Anything, "user-1", "task-1", true, repository.TaskEventSourceApp).
+		Return(&repository.Task{ID: "task-1", CreatorID: &creatorID, Archived: true}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/archive", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.Archive(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	service.AssertExpectations(t)
+}
+
+func TestUnarchiveTaskHandlerForbidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creatorID := "user-2"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID, Archived: true}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/unarchive", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.Unarchive(c)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNotCalled(t, "SetArchived", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBatchHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	service.On("BatchUpdate", mock.Anything, "user-1", taskservice.BatchParams{
+		TaskIDs:  []string{"t1", "t2"},
+		Action:   taskservice.BatchActionShiftDue,
+		DueShift: -48 * time.Hour,
+	}).Return([]taskservice.BatchItemResult{{TaskID: "t1", OK: true}, {TaskID: "t2", Error: taskservice.BatchErrForbidden}}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/batch", strings.NewReader(`{"task_ids":["t1","t2"],"action":"shift_due","shift_hours":-48}`))
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.Batch(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"success":true,"data":{"items":[{"task_id":"t1","ok":true},{"task_id":"t2","ok":false,"error":"forbidden"}],"succeeded":1,"failed":1}}`, w.Body.String())
+}
+
+func TestBatchHandlerRejectsInvalidAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	service.On("BatchUpdate", mock.Anything, "user-1", mock.Anything).Return(nil, taskservice.ErrInvalidBatch)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/batch", strings.NewReader(`{"task_ids":["t1"],"action":"explode"}`))
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.Batch(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestClaimTaskAsGroupMember(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	creatorID, groupID := "user-2", "g1"
+	existing := &repository.Task{ID: "task-1", CreatorID: &creatorID, GroupID: &groupID, Assignees: []models.User{{ID: "user-3"}}}
+	service.On("GetTask", mock.Anything, "task-1").Return(existing, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "user-1", "g1").Return(&models.UserGroup{Role: models.GroupRoleMember}, nil)
+	service.On("AddAssignee", mock.Anything, "user-1", "task-1", "user-1", repository.TaskEventSourceApp).
+		Return(&repository.Task{ID: "task-1", Assignees: []models.User{{ID: "user-3"}, {ID: "user-1"}}}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/assignees/user-1", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}, {Key: "user_id", Value: "user-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.AddAssignee(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	service.AssertExpectations(t)
+}
+
+func TestAssignOthersRequiresModifyPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	creatorID, groupID := "user-2", "g1"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID, GroupID: &groupID}, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "user-1", "g1").Return(&models.UserGroup{Role: models.GroupRoleMember}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/tasks/task-1/assignees/user-3", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}, {Key: "user_id", Value: "user-3"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+
+	h.RemoveAssignee(c)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNotCalled(t, "RemoveAssignee", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestFollowTaskRequiresGroupMembership(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	creatorID, groupID := "user-2", "g1"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID, GroupID: &groupID}, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "pm", "g1").Return(&models.UserGroup{Role: models.GroupRoleMember}, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "stranger", "g1").Return(nil, gorm.ErrRecordNotFound)
+	service.On("Watch", mock.Anything, "pm", "task-1").Return(true, nil)
+
+	follow := func(userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/watchers", nil)
+		c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: userID})
+		h.Follow(c)
+		return w
+	}
+
+	w := follow("pm")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"success":true,"data":{"task_id":"task-1","watching":true}}`, w.Body.String())
+
+	w = follow("stranger")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNumberOfCalls(t, "Watch", 1)
+}
+
+func TestStartTimerRequiresModifyPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	creatorID := "user-1"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID}, nil)
+	service.On("StartTimer", mock.Anything, "user-1", "task-1").Return(&repository.TimeEntry{ID: "entry-1", TaskID: "task-1", UserID: "user-1"}, nil, nil)
+
+	start := func(userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/timer/start", nil)
+		c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: userID})
+		h.StartTimer(c)
+		return w
+	}
+
+	w := start("user-1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"entry-1"`)
+
+	w = start("stranger")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNumberOfCalls(t, "StartTimer", 1)
+}
+
+func TestStopTimerNotRunning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	service.On("StopTimer", mock.Anything, "user-1", "task-1").Return(nil, taskservice.ErrNoRunningTimer)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/timer/stop", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+	h.StopTimer(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "timer_not_running")
+}
+
+func TestTimeReportHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	service.On("TimeReport", mock.Anything, "user-1", taskservice.TimeReportParams{
+		From:    &from,
+		To:      &to,
+		GroupID: "g1",
+		GroupBy: repository.TimeReportByDay,
+	}).Return([]repository.TimeReportRow{
+		{Key: "2026-03-02", TotalSeconds: 3600, Entries: 2},
+		{Key: "2026-03-03", TotalSeconds: 1800, Entries: 1},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/time/report?from=2026-03-01&to=2026-03-31&group_id=g1&group_by=day", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+	h.TimeReport(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"success":true,"data":{"group_by":"day","total_seconds":5400,"rows":[
+		{"key":"2026-03-02","total_seconds":3600,"entries":2},
+		{"key":"2026-03-03","total_seconds":1800,"entries":1}]}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/time/report?group_by=week", nil)
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+	h.TimeReport(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateTaskFromTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	groupID := "g1"
+	tmpl := &repository.TaskTemplate{ID: "b3c1f0a4-5d6e-4f70-8a9b-0c1d2e3f4a5b", Name: "bug", GroupID: &groupID, TitlePattern: "[Bug] {input}"}
+	service.On("ResolveTemplate", mock.Anything, "user-1", "g1", "bug").Return(tmpl, nil)
+	service.On("ResolveTemplate", mock.Anything, "user-1", "", "nope").Return(nil, taskservice.ErrTemplateNotFound)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "user-1", "g1").Return(&models.UserGroup{Role: models.GroupRoleMember}, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "stranger", "g1").Return(nil, gorm.ErrRecordNotFound)
+	service.On("GetTemplate", mock.Anything, tmpl.ID).Return(tmpl, nil)
+	service.On("CreateFromTemplate", mock.Anything, "user-1", tmpl, "Login broken").
+		Return(&repository.Task{ID: "task-1", Title: "[Bug] Login broken"}, nil)
+
+	create := func(userID, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/tasks?"+query, strings.NewReader(`{"title":"Login broken"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set(middleware.ContextKeyUser, &models.User{ID: userID})
+		h.CreateWebTask(c)
+		return w
+	}
+
+	w := create("user-1", "template=bug&group_id=g1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"Title":"[Bug] Login broken"`)
+
+	w = create("user-1", "template=nope")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = create("stranger", "template="+tmpl.ID)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNumberOfCalls(t, "CreateFromTemplate", 1)
+}
+
+func TestTemplateHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "admin", "g1").Return(&models.UserGroup{Role: models.GroupRoleAdmin}, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "member", "g1").Return(&models.UserGroup{Role: models.GroupRoleMember}, nil)
+
+	request := func(userID, method, body string, handle func(*gin.Context), params ...gin.Param) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/templates", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = params
+		c.Set(middleware.ContextKeyUser, &models.User{ID: userID})
+		handle(c)
+		return w
+	}
+
+	// Only group admins create group templates
+	body := `{"name":"bug","group_id":"g1","title_pattern":"[Bug] {input}","subtasks":["复现"]}`
+	params := taskservice.TemplateParams{Name: "bug", GroupID: "g1", TitlePattern: "[Bug] {input}", Subtasks: []string{"复现"}}
+	groupID := "g1"
+	service.On("CreateTemplate", mock.Anything, "admin", params).Return(&repository.TaskTemplate{ID: "tpl-1", Name: "bug", GroupID: &groupID}, nil)
+	w := request("member", http.MethodPost, body, h.CreateTemplate)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("admin", http.MethodPost, body, h.CreateTemplate)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Duplicate personal name
+	dup := taskservice.TemplateParams{Name: "daily", TitlePattern: "日报 {date}"}
+	service.On("CreateTemplate", mock.Anything, "member", dup).Return(nil, taskservice.ErrTemplateExists)
+	w = request("member", http.MethodPost, `{"name":"daily","title_pattern":"日报 {date}"}`, h.CreateTemplate)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Members may not delete group templates
+	service.On("GetTemplate", mock.Anything, "tpl-1").Return(&repository.TaskTemplate{ID: "tpl-1", GroupID: &groupID}, nil)
+	service.On("DeleteTemplate", mock.Anything, "tpl-1").Return(nil)
+	w = request("member", http.MethodDelete, "", h.DeleteTemplate, gin.Param{Key: "template_id", Value: "tpl-1"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("admin", http.MethodDelete, "", h.DeleteTemplate, gin.Param{Key: "template_id", Value: "tpl-1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	service.AssertNumberOfCalls(t, "DeleteTemplate", 1)
+}
+
+func TestListAttachmentsRequiresTaskAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	creatorID := "user-1"
+	groupID := "g1"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID, GroupID: &groupID}, nil)
+	service.On("ListAttachments", mock.Anything, "task-1").Return([]repository.TaskAttachment{
+		{ID: "att-1", TaskID: "task-1", Kind: repository.AttachmentKindPhoto, FileID: "file-1"},
+	}, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "stranger", "g1").Return(nil, gorm.ErrRecordNotFound)
+
+	list := func(userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/tasks/task-1/attachments", nil)
+		c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: userID})
+		h.ListAttachments(c)
+		return w
+	}
+
+	w := list("user-1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"kind":"photo"`)
+
+	w = list("stranger")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNumberOfCalls(t, "ListAttachments", 1)
+}
+
+func TestDownloadSignedAttachment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	attachment := &repository.TaskAttachment{ID: "att-1", TaskID: "task-1", Kind: repository.AttachmentKindDocument, FileID: "file-1", FileName: "日志.txt", MimeType: "text/plain"}
+	service.On("VerifyAttachmentSignature", "att-1", "good").Return(true)
+	service.On("VerifyAttachmentSignature", "att-1", "bad").Return(false)
+	service.On("GetAttachment", mock.Anything, "att-1").Return(attachment, nil)
+	service.On("OpenAttachment", mock.Anything, attachment).Return(io.NopCloser(strings.NewReader("hello")), int64(5), nil)
+
+	download := func(sig string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/files/att-1?sig="+sig, nil)
+		c.Params = gin.Params{{Key: "attachment_id", Value: "att-1"}}
+		h.DownloadSignedAttachment(c)
+		return w
+	}
+
+	w := download("bad")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNotCalled(t, "OpenAttachment", mock.Anything, mock.Anything)
+
+	w = download("good")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename*=UTF-8''%E6%97%A5%E5%BF%97.txt", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+
+	// SVG can carry scripts, so it is downloaded rather than rendered
+	attachment.FileName, attachment.MimeType = "logo.svg", "image/svg+xml"
+	w = download("good")
+	assert.Equal(t, "attachment; filename*=UTF-8''logo.svg", w.Header().Get("Content-Disposition"))
+
+	attachment.FileName, attachment.MimeType = "shot.png", "image/png"
+	w = download("good")
+	assert.Equal(t, "inline; filename*=UTF-8''shot.png", w.Header().Get("Content-Disposition"))
+}
+
+func TestCommentEditAndDeleteHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	edited := time.Now()
+	service.On("UpdateComment", mock.Anything, "task-1", "c1", "user-1", "修正后的内容").
+		Return(&repository.TaskComment{ID: "c1", TaskID: "task-1", Content: "修正后的内容", EditedAt: &edited}, nil)
+	service.On("UpdateComment", mock.Anything, "task-1", "c1", "user-2", "hijack").Return(nil, taskservice.ErrCommentForbidden)
+	service.On("DeleteComment", mock.Anything, "task-1", "missing", "user-1").Return(taskservice.ErrCommentNotFound)
+	service.On("DeleteComment", mock.Anything, "task-1", "c1", "user-1").Return(nil)
+
+	call := func(method, commentID, userID, body string, handle gin.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/tasks/task-1/comments/"+commentID, strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "task_id", Value: "task-1"}, {Key: "comment_id", Value: commentID}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: userID})
+		handle(c)
+		return w
+	}
+
+	w := call(http.MethodPatch, "c1", "user-1", `{"content":"修正后的内容"}`, h.UpdateComment)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"edited_at":"`)
+
+	w = call(http.MethodPatch, "c1", "user-2", `{"content":"hijack"}`, h.UpdateComment)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = call(http.MethodPatch, "c1", "user-1", `{"content":"  "}`, h.UpdateComment)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call(http.MethodDelete, "missing", "user-1", "", h.DeleteComment)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = call(http.MethodDelete, "c1", "user-1", "", h.DeleteComment)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"deleted":true`)
+}
+
+func TestListCommentsTreeView(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	parentID := "c1"
+	service.On("ListCommentThreads", mock.Anything, "task-1").Return([]*repository.TaskComment{
+		{ID: "c1", Content: "root", Replies: []*repository.TaskComment{{ID: "c2", ParentID: &parentID, Content: "reply"}}},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks/task-1/comments?view=tree", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+	h.ListComments(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Replies []struct {
+				ID string `json:"id"`
+			} `json:"replies"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data, 1) && assert.Len(t, resp.Data[0].Replies, 1) {
+		assert.Equal(t, "c2", resp.Data[0].Replies[0].ID)
+	}
+	service.AssertNotCalled(t, "ListComments", mock.Anything, mock.Anything)
+}
+
+func TestMoveTaskChecksTargetGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	groupRepo := new(mockUserGroupRepo)
+	h := NewHandler(zap.NewNop(), service, groupRepo)
+
+	creatorID := "user-1"
+	groupID := "g1"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", CreatorID: &creatorID, GroupID: &groupID}, nil)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "user-1", "g2").Return(nil, gorm.ErrRecordNotFound)
+	groupRepo.On("FindByUserAndGroup", mock.Anything, "user-1", "g3").Return(&models.UserGroup{UserID: "user-1", GroupID: "g3", Role: models.GroupRoleMember}, nil)
+	target := "g3"
+	service.On("MoveTask", mock.Anything, "user-1", "task-1", taskservice.MoveParams{GroupID: &target}).
+		Return(&repository.Task{ID: "task-1", CreatorID: &creatorID, GroupID: &target}, nil)
+
+	move := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/move", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+		c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+		h.MoveTask(c)
+		return w
+	}
+
+	w := move(`{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = move(`{"group_id":"g2"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "目标群组")
+
+	w = move(`{"group_id":"g3"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	service.AssertNumberOfCalls(t, "MoveTask", 1)
+}
+
+func TestCloneTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := new(mockTaskService)
+	h := NewHandler(zap.NewNop(), service, new(mockUserGroupRepo))
+
+	creatorID := "user-1"
+	service.On("GetTask", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1", Title: "周报", CreatorID: &creatorID}, nil)
+	service.On("CloneTask", mock.Anything, "user-1", "task-1", taskservice.MoveParams{}).
+		Return(&repository.Task{ID: "task-2", Title: "周报", CreatorID: &creatorID}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/task-1/clone", nil)
+	c.Params = gin.Params{{Key: "task_id", Value: "task-1"}}
+	c.Set(middleware.ContextKeyUser, &models.User{ID: "user-1"})
+	h.CloneTask(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"ID":"task-2"`)
+}
@@ -0,0 +1,291 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/notification"
+	"github.com/layababa/tg_todo/server/internal/service/task"
+	"github.com/layababa/tg_todo/server/internal/service/telegram"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
+)
+
+const (
+	// snoozeDuration is how long the snooze button holds back reminders
+	snoozeDuration = time.Hour
+	// actionNotePrefix starts the line a task action appends to its message;
+	// a later action replaces it
+	actionNotePrefix = "\n\n🕹 "
+)
+
+// handleTaskActionCallback handles the signed task action buttons of task
+// cards and notifications, then edits the message to show the new state
+func (h *Handler) handleTaskActionCallback(ctx context.Context, cq *CallbackQuery) {
+	if h.taskService == nil || h.userRepo == nil || h.groupRoles == nil || h.actionSigner == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 无效的操作")
+		return
+	}
+	action, taskID, ok := h.actionSigner.Verify(cq.Data)
+	if !ok {
+		h.logger.Warn("invalid task action callback", zap.String("data", cq.Data), zap.Int64("tg_id", cq.From.ID))
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 无效的操作")
+		return
+	}
+
+	user, err := h.userRepo.FindByTgID(ctx, cq.From.ID)
+	if err != nil || user == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "⚠️ 请先私聊机器人发送 /start 完成注册。")
+		return
+	}
+	t, err := h.taskService.GetTask(ctx, taskID)
+	if err != nil || t == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 任务不存在或已删除")
+		return
+	}
+	canModify, err := task.CanModifyTask(ctx, user.ID, t, h.groupRoles)
+	if err != nil || !canModify {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 只有创建人、指派人或群管理员可以操作任务，可先认领任务")
+		return
+	}
+
+	updated, note, answer := h.applyTaskAction(ctx, user, t, action)
+	if updated == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, answer)
+		return
+	}
+	h.refreshActionMessage(cq, updated, note)
+	h.tgClient.AnswerCallbackQuery(cq.ID, answer)
+}
+
+// applyTaskAction performs action on t for user. It returns the updated task
+// with the note to add to the message, or a nil task when nothing changed, and
+// the text of the callback answer.
+func (h *Handler) applyTaskAction(ctx context.Context, user *models.User, t *repository.Task, action telegram.TaskAction) (*repository.Task, string, string) {
+	name := escapeHTML(user.Name)
+	switch action {
+	case telegram.TaskActionStart, telegram.TaskActionDone:
+		category, verb := repository.StatusCategoryActive, "开始处理"
+		if action == telegram.TaskActionDone {
+			category, verb = repository.StatusCategoryDone, "完成"
+		}
+		if t.Category() == category {
+			return nil, "", "ℹ️ 任务状态未变化"
+		}
+		status := h.taskService.StatusForCategory(ctx, t, category)
+		updated, err := h.taskService.UpdateTaskFromBot(ctx, user.ID, t.ID, task.UpdateParams{Status: &status})
+		switch {
+		case errors.Is(err, task.ErrTaskBlocked):
+			return nil, "", "⛔️ 任务仍有未完成的前置任务"
+		case errors.Is(err, task.ErrTransitionNotAllowed):
+			return nil, "", fmt.Sprintf("⚠️ 当前状态不能直接流转到 %s", status)
+		case err != nil:
+			h.logger.Error("task action failed", zap.Error(err), zap.String("task_id", t.ID), zap.String("action", string(action)))
+			return nil, "", "❌ 操作失败，请稍后再试"
+		}
+		return updated, fmt.Sprintf("%s 已将任务标记为「%s」", name, escapeHTML(string(updated.Status))), "✅ 已" + verb
+
+	case telegram.TaskActionSnooze:
+		updated, err := h.taskService.SnoozeReminders(ctx, t.ID, snoozeDuration)
+		if errors.Is(err, task.ErrNoReminder) {
+			return nil, "", "ℹ️ 任务还没有发出提醒，无需推迟"
+		}
+		if err != nil {
+			h.logger.Error("failed to snooze reminders", zap.Error(err), zap.String("task_id", t.ID))
+			return nil, "", "❌ 操作失败，请稍后再试"
+		}
+		return updated, fmt.Sprintf("%s 已将提醒推迟到 %s", name, updated.ReminderSnoozedUntil.In(user.Location()).Format("15:04")), "⏰ 1小时后再提醒"
+
+	case telegram.TaskActionPostpone:
+		if t.DueAt == nil {
+			return nil, "", "ℹ️ 任务还没有截止时间"
+		}
+		// Stored all-day dates are midnight UTC, so they move in UTC
+		dueAt := t.DueAt.AddDate(0, 0, 1)
+		updated, err := h.taskService.UpdateTaskFromBot(ctx, user.ID, t.ID, task.UpdateParams{DueAt: &dueAt, Location: time.UTC})
+		if err != nil {
+			h.logger.Error("failed to postpone task", zap.Error(err), zap.String("task_id", t.ID))
+			return nil, "", "❌ 操作失败，请稍后再试"
+		}
+		due := timezone.FormatDue(*updated.DueAt, updated.AllDay, user.Location(), "2006-01-02")
+		return updated, fmt.Sprintf("%s 已将截止时间延后到 %s", name, due), "📅 已延后一天"
+
+	case telegram.TaskActionClaim:
+		if len(t.Assignees) == 1 && t.Assignees[0].ID == user.ID {
+			return nil, "", "ℹ️ 你已经是该任务的指派人"
+		}
+		updated, err := h.taskService.ReassignTask(ctx, user.ID, t.ID, user.ID, repository.TaskEventSourceBot)
+		if err != nil {
+			h.logger.Error("failed to reassign task", zap.Error(err), zap.String("task_id", t.ID))
+			return nil, "", "❌ 操作失败，请稍后再试"
+		}
+		return updated, fmt.Sprintf("任务已改派给 %s", name), "🙋 已改派给你"
+	}
+	return nil, "", "❌ 无效的操作"
+}
+
+// refreshActionMessage shows the task's new state on the message whose button
+// was pressed. Shared cards are rendered again; other messages keep their
+// formatted text and get their task action rows replaced.
+func (h *Handler) refreshActionMessage(cq *CallbackQuery, t *repository.Task, note string) {
+	if cq.Message == nil {
+		if cq.InlineMessageID == "" {
+			return
+		}
+		text, markup := h.buildShareCard(t)
+		if err := h.tgClient.EditMessageText(cq.InlineMessageID, text+actionNotePrefix+note, markup); err != nil {
+			h.logger.Warn("failed to update shared task card", zap.Error(err), zap.String("task_id", t.ID))
+		}
+		return
+	}
+
+	text := messageText(cq.Message)
+	if i := strings.LastIndex(text, strings.TrimPrefix(actionNotePrefix, "\n\n")); i >= 0 {
+		text = strings.TrimRight(text[:i], "\n")
+	}
+	text = entitiesHTML(text, messageEntities(cq.Message)) + actionNotePrefix + note
+
+	actionRows := notification.TaskActionRows(h.actionSigner, t)
+	if t.IsDone() && !t.Archived {
+		actionRows = [][]telegram.InlineKeyboardButton{notification.ArchiveButtonRow(t.ID, false)}
+	}
+	var markup *telegram.InlineKeyboardMarkup
+	if cq.Message.ReplyMarkup != nil {
+		var rows [][]telegram.InlineKeyboardButton
+		replaced := false
+		for _, row := range cq.Message.ReplyMarkup.InlineKeyboard {
+			if len(row) > 0 && telegram.IsTaskAction(row[0].CallbackData) {
+				if !replaced {
+					rows = append(rows, actionRows...)
+					replaced = true
+				}
+				continue
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) > 0 {
+			markup = &telegram.InlineKeyboardMarkup{InlineKeyboard: rows}
+		}
+	}
+	if err := h.tgClient.EditChatMessageText(cq.Message.Chat.ID, cq.Message.MessageID, text, markup); err != nil {
+		h.logger.Warn("failed to update task message", zap.Error(err), zap.String("task_id", t.ID))
+	}
+}
+
+// entitiesHTML renders text with its Telegram formatting entities as HTML, so
+// an edited message keeps its bold, links and code. Entities reaching past the
+// end of text are cut there.
+func entitiesHTML(text string, entities []MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	sorted := make([]MessageEntity, 0, len(entities))
+	for _, e := range entities {
+		if e.Length <= 0 || e.Offset >= len(units) {
+			continue
+		}
+		if e.Offset+e.Length > len(units) {
+			e.Length = len(units) - e.Offset
+		}
+		sorted = append(sorted, e)
+	}
+	// Entities nest, so the outer one of two starting together is the longer
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Offset != sorted[j].Offset {
+			return sorted[i].Offset < sorted[j].Offset
+		}
+		return sorted[i].Length > sorted[j].Length
+	})
+
+	var b strings.Builder
+	var open []MessageEntity
+	next, pos := 0, 0
+	for _, r := range text {
+		for len(open) > 0 && open[len(open)-1].Offset+open[len(open)-1].Length <= pos {
+			b.WriteString(entityCloseTag(open[len(open)-1]))
+			open = open[:len(open)-1]
+		}
+		for next < len(sorted) && sorted[next].Offset <= pos {
+			b.WriteString(entityOpenTag(sorted[next]))
+			open = append(open, sorted[next])
+			next++
+		}
+		b.WriteString(escapeHTML(string(r)))
+		pos += len(utf16.Encode([]rune{r}))
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString(entityCloseTag(open[i]))
+	}
+	return b.String()
+}
+
+func entityOpenTag(e MessageEntity) string {
+	switch e.Type {
+	case "bold":
+		return "<b>"
+	case "italic":
+		return "<i>"
+	case "underline":
+		return "<u>"
+	case "strikethrough":
+		return "<s>"
+	case "spoiler":
+		return "<tg-spoiler>"
+	case "code":
+		return "<code>"
+	case "pre":
+		if e.Language != "" {
+			return fmt.Sprintf(`<pre><code class="language-%s">`, escapeHTML(e.Language))
+		}
+		return "<pre>"
+	case "text_link":
+		return fmt.Sprintf(`<a href="%s">`, escapeHTML(e.URL))
+	case "text_mention":
+		if e.User != nil {
+			return fmt.Sprintf(`<a href="tg://user?id=%d">`, e.User.ID)
+		}
+	case "blockquote":
+		return "<blockquote>"
+	case "expandable_blockquote":
+		return "<blockquote expandable>"
+	}
+	// Mentions, hashtags, URLs and the like are detected again by Telegram
+	return ""
+}
+
+func entityCloseTag(e MessageEntity) string {
+	switch e.Type {
+	case "bold":
+		return "</b>"
+	case "italic":
+		return "</i>"
+	case "underline":
+		return "</u>"
+	case "strikethrough":
+		return "</s>"
+	case "spoiler":
+		return "</tg-spoiler>"
+	case "code":
+		return "</code>"
+	case "pre":
+		if e.Language != "" {
+			return "</code></pre>"
+		}
+		return "</pre>"
+	case "text_link":
+		return "</a>"
+	case "text_mention":
+		if e.User != nil {
+			return "</a>"
+		}
+	case "blockquote", "expandable_blockquote":
+		return "</blockquote>"
+	}
+	return ""
+}
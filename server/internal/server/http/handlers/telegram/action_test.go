@@ -0,0 +1,285 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/task"
+	"github.com/layababa/tg_todo/server/internal/service/telegram"
+)
+
+// botCall is a request the handler made to the Bot API
+type botCall struct {
+	Method string
+	Body   map[string]interface{}
+}
+
+// botRecorder fakes the Bot API and records the calls made to it
+type botRecorder struct {
+	mu    sync.Mutex
+	calls []botCall
+}
+
+func newBotRecorder(t *testing.T) (*botRecorder, *telegram.Client) {
+	rec := &botRecorder{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(raw, &body)
+		rec.mu.Lock()
+		rec.calls = append(rec.calls, botCall{Method: path.Base(r.URL.Path), Body: body})
+		rec.mu.Unlock()
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	t.Cleanup(ts.Close)
+	client := telegram.NewClient("token")
+	client.SetBaseURL(ts.URL + "/")
+	return rec, client
+}
+
+// find returns the calls of a Bot API method
+func (r *botRecorder) find(method string) []botCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var calls []botCall
+	for _, c := range r.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// stubTaskRepo serves tasks from memory; methods the tests do not reach are
+// left to the embedded interface
+type stubTaskRepo struct {
+	repository.TaskRepository
+	tasks   map[string]*repository.Task
+	listed  []repository.Task // Results of ListByUser, paged by the filter
+	snoozed map[string]time.Time
+}
+
+func (r *stubTaskRepo) GetByID(ctx context.Context, id string) (*repository.Task, error) {
+	return r.tasks[id], nil
+}
+
+func (r *stubTaskRepo) ListByUser(ctx context.Context, userID string, filter repository.TaskListFilter) ([]repository.Task, error) {
+	tasks := r.listed
+	if filter.Offset >= len(tasks) {
+		return nil, nil
+	}
+	tasks = tasks[filter.Offset:]
+	if filter.Limit > 0 && len(tasks) > filter.Limit {
+		tasks = tasks[:filter.Limit]
+	}
+	return tasks, nil
+}
+
+func (r *stubTaskRepo) SnoozeReminders(ctx context.Context, id string, until time.Time) error {
+	if r.snoozed == nil {
+		r.snoozed = make(map[string]time.Time)
+	}
+	r.snoozed[id] = until
+	return nil
+}
+
+type stubUserRepo struct {
+	repository.UserRepository
+	byTgID map[int64]*models.User
+}
+
+func (r *stubUserRepo) FindByTgID(ctx context.Context, tgID int64) (*models.User, error) {
+	if u, ok := r.byTgID[tgID]; ok {
+		return u, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+// stubGroupRoles maps "<user id>/<group id>" to the user's role in the group
+type stubGroupRoles map[string]models.GroupRole
+
+func (r stubGroupRoles) FindByUserAndGroup(ctx context.Context, userID, groupID string) (*models.UserGroup, error) {
+	role, ok := r[userID+"/"+groupID]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return &models.UserGroup{UserID: userID, GroupID: groupID, Role: role}, nil
+}
+
+type memChatLists map[int64]*telegram.ChatList
+
+func (m memChatLists) Save(ctx context.Context, chatID int64, list *telegram.ChatList) error {
+	saved := *list
+	m[chatID] = &saved
+	return nil
+}
+
+func (m memChatLists) Load(ctx context.Context, chatID int64) (*telegram.ChatList, error) {
+	return m[chatID], nil
+}
+
+// botTestHandler wires a handler to in-memory repositories and a fake Bot API
+type botTestHandler struct {
+	handler *Handler
+	tasks   *stubTaskRepo
+	lists   memChatLists
+	bot     *botRecorder
+	signer  *telegram.CallbackSigner
+}
+
+func newBotTestHandler(t *testing.T, users []*models.User, roles stubGroupRoles) *botTestHandler {
+	bot, client := newBotRecorder(t)
+	dedup := new(MockDeduplicator)
+	dedup.On("IsDuplicate", mock.Anything, mock.Anything).Return(false, nil)
+	updates := new(MockUpdateRepo)
+	updates.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+	userRepo := &stubUserRepo{byTgID: make(map[int64]*models.User)}
+	for _, u := range users {
+		userRepo.byTgID[u.TgID] = u
+	}
+	tasks := &stubTaskRepo{tasks: make(map[string]*repository.Task)}
+	lists := memChatLists{}
+	signer := telegram.NewCallbackSigner("secret")
+	handler := NewHandler(Config{
+		Logger:       zap.NewNop(),
+		Deduplicator: dedup,
+		Repo:         updates,
+		UserRepo:     userRepo,
+		GroupRoles:   roles,
+		TaskService:  task.NewService(task.ServiceConfig{Repo: tasks, UserRepo: userRepo, Logger: zap.NewNop()}),
+		ChatLists:    lists,
+		ActionSigner: signer,
+		TgClient:     client,
+	})
+	return &botTestHandler{handler: handler, tasks: tasks, lists: lists, bot: bot, signer: signer}
+}
+
+// post sends an update to the webhook
+func (b *botTestHandler) post(t *testing.T, update interface{}) {
+	body, err := json.Marshal(update)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/webhook", bytes.NewReader(body))
+	b.handler.HandleWebhook(c)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+// actionUpdate is the callback of a task action button pressed by tgID on
+// a bold task card with a follow button below the actions
+func (b *botTestHandler) actionUpdate(tgID int64, action telegram.TaskAction, taskID string) map[string]interface{} {
+	return map[string]interface{}{
+		"update_id": 1,
+		"callback_query": map[string]interface{}{
+			"id":   "cq-1",
+			"from": map[string]interface{}{"id": tgID},
+			"data": b.signer.Sign(action, taskID),
+			"message": map[string]interface{}{
+				"message_id": 10,
+				"chat":       map[string]interface{}{"id": 500, "type": "private"},
+				"text":       "⏰ 任务即将到期\n任务：Ship\n\n🕹 Bob 已将任务标记为「To Do」",
+				"entities": []map[string]interface{}{
+					{"type": "bold", "offset": 0, "length": 8},
+					{"type": "text_link", "offset": 12, "length": 4, "url": "https://t.me/c/1/2"},
+				},
+				"reply_markup": map[string]interface{}{"inline_keyboard": [][]map[string]interface{}{
+					{{"text": "⏰ 1小时后提醒", "callback_data": b.signer.Sign(telegram.TaskActionSnooze, taskID)}},
+					{{"text": "🔔 关注", "callback_data": "follow_task:" + taskID}},
+				}},
+			},
+		},
+	}
+}
+
+func TestTaskActionCallbackEditsMessage(t *testing.T) {
+	alice := &models.User{ID: "user-1", TgID: 300, Name: "Alice", Timezone: "UTC"}
+	b := newBotTestHandler(t, []*models.User{alice}, nil)
+	taskID := uuid.NewString()
+	dueAt := time.Now().Add(30 * time.Minute)
+	b.tasks.tasks[taskID] = &repository.Task{ID: taskID, Title: "Ship", CreatorID: &alice.ID, DueAt: &dueAt, Reminder1hSent: true}
+
+	b.post(t, b.actionUpdate(300, telegram.TaskActionSnooze, taskID))
+
+	require.Contains(t, b.tasks.snoozed, taskID)
+	edits := b.bot.find("editMessageText")
+	require.Len(t, edits, 1)
+	until := b.tasks.snoozed[taskID].UTC().Format("15:04")
+	// The formatting is kept and the previous action note replaced
+	assert.Equal(t, "<b>⏰ 任务即将到期</b>\n任务：<a href=\"https://t.me/c/1/2\">Ship</a>\n\n🕹 Alice 已将提醒推迟到 "+until, edits[0].Body["text"])
+	keyboard := edits[0].Body["reply_markup"].(map[string]interface{})["inline_keyboard"].([]interface{})
+	require.Len(t, keyboard, 4)
+	assert.Equal(t, b.signer.Sign(telegram.TaskActionStart, taskID), keyboard[0].([]interface{})[0].(map[string]interface{})["callback_data"])
+	// The reminder was reset, so only postponing is offered until it fires again
+	due := keyboard[1].([]interface{})
+	require.Len(t, due, 1)
+	assert.Equal(t, b.signer.Sign(telegram.TaskActionPostpone, taskID), due[0].(map[string]interface{})["callback_data"])
+	assert.Equal(t, "follow_task:"+taskID, keyboard[3].([]interface{})[0].(map[string]interface{})["callback_data"])
+
+	answers := b.bot.find("answerCallbackQuery")
+	require.Len(t, answers, 1)
+	assert.Equal(t, "⏰ 1小时后再提醒", answers[0].Body["text"])
+}
+
+func TestTaskActionCallbackRequiresModifyPermission(t *testing.T) {
+	alice := &models.User{ID: "user-1", TgID: 300, Name: "Alice"}
+	bob := &models.User{ID: "user-2", TgID: 301, Name: "Bob"}
+	b := newBotTestHandler(t, []*models.User{alice, bob}, stubGroupRoles{"user-2/g1": models.GroupRoleMember})
+	taskID := uuid.NewString()
+	due := time.Now().Add(30 * time.Minute)
+	groupID := "g1"
+	b.tasks.tasks[taskID] = &repository.Task{ID: taskID, Title: "Ship", CreatorID: &alice.ID, GroupID: &groupID, DueAt: &due, Reminder1hSent: true}
+
+	// A group member who did not claim the task cannot act on it
+	b.post(t, b.actionUpdate(301, telegram.TaskActionSnooze, taskID))
+
+	assert.Empty(t, b.tasks.snoozed)
+	assert.Empty(t, b.bot.find("editMessageText"))
+	answers := b.bot.find("answerCallbackQuery")
+	require.Len(t, answers, 1)
+	assert.Equal(t, "❌ 只有创建人、指派人或群管理员可以操作任务，可先认领任务", answers[0].Body["text"])
+
+	// Neither can a forged button
+	data := b.actionUpdate(300, telegram.TaskActionSnooze, taskID)
+	data["callback_query"].(map[string]interface{})["data"] = telegram.NewCallbackSigner("forged").Sign(telegram.TaskActionSnooze, taskID)
+	b.post(t, data)
+	assert.Empty(t, b.tasks.snoozed)
+	assert.Equal(t, "❌ 无效的操作", b.bot.find("answerCallbackQuery")[1].Body["text"])
+}
+
+func TestEntitiesHTML(t *testing.T) {
+	// "📝" is two UTF-16 code units, so the entities after it start at 3
+	text := "📝 任务 <b>\n截止：明天\n详情"
+	entities := []MessageEntity{
+		{Type: "bold", Offset: 3, Length: 6},
+		{Type: "italic", Offset: 3, Length: 2},
+		{Type: "code", Offset: 13, Length: 2},
+		{Type: "text_link", Offset: 16, Length: 2, URL: "https://t.me/x?a=1&b=2"},
+		{Type: "hashtag", Offset: 0, Length: 1},
+	}
+	assert.Equal(t,
+		"📝 <b><i>任务</i> &lt;b&gt;</b>\n截止：<code>明天</code>\n<a href=\"https://t.me/x?a=1&amp;b=2\">详情</a>",
+		entitiesHTML(text, entities))
+
+	// The note line is cut off the text before rendering, and with it the end
+	// of entities reaching into it
+	assert.Equal(t, "📝 <b>任务</b>", entitiesHTML("📝 任务", []MessageEntity{{Type: "bold", Offset: 3, Length: 10}, {Type: "code", Offset: 8, Length: 2}}))
+}
@@ -0,0 +1,65 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/notification"
+	"github.com/layababa/tg_todo/server/internal/service/task"
+	"github.com/layababa/tg_todo/server/internal/service/telegram"
+)
+
+// handleArchiveCallback handles the archive_task:<id> and unarchive_task:<id>
+// buttons attached to "task done" notifications
+func (h *Handler) handleArchiveCallback(ctx context.Context, cq *CallbackQuery) {
+	if h.taskService == nil || h.userRepo == nil || h.groupRoles == nil {
+		return
+	}
+	archived := strings.HasPrefix(cq.Data, "archive_task:")
+	taskID := cq.Data[strings.Index(cq.Data, ":")+1:]
+
+	user, err := h.userRepo.FindByTgID(ctx, cq.From.ID)
+	if err != nil || user == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "⚠️ 请先私聊机器人发送 /start 完成注册。")
+		return
+	}
+
+	t, err := h.taskService.GetTask(ctx, taskID)
+	if err != nil || t == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 任务不存在或已删除")
+		return
+	}
+	canModify, err := task.CanModifyTask(ctx, user.ID, t, h.groupRoles)
+	if err != nil || !canModify {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 只有创建人、指派人或群管理员可以归档任务")
+		return
+	}
+
+	if _, err := h.taskService.SetArchived(ctx, user.ID, taskID, archived, repository.TaskEventSourceBot); err != nil {
+		h.logger.Error("failed to set task archived", zap.Error(err), zap.String("task_id", taskID), zap.Bool("archived", archived))
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 操作失败，请稍后再试")
+		return
+	}
+
+	// Swap the button so the action can be undone from the same message
+	if cq.Message != nil {
+		markup := &telegram.InlineKeyboardMarkup{InlineKeyboard: [][]telegram.InlineKeyboardButton{
+			notification.ArchiveButtonRow(taskID, archived),
+		}}
+		if base := notification.BuildTaskMarkup(taskID, h.botUsername, ""); base.InlineKeyboard != nil {
+			markup.InlineKeyboard = append(base.InlineKeyboard, markup.InlineKeyboard...)
+		}
+		if err := h.tgClient.EditMessageReplyMarkup(cq.Message.Chat.ID, cq.Message.MessageID, markup); err != nil {
+			h.logger.Warn("failed to update archive button", zap.Error(err), zap.String("task_id", taskID))
+		}
+	}
+
+	if archived {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "📦 已归档")
+	} else {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "↩️ 已取消归档")
+	}
+}
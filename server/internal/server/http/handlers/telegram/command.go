@@ -0,0 +1,197 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/task"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
+)
+
+// handleDoneCommand handles /done <n>: moves task n of the chat's last list to
+// the first done status of its workflow
+func (h *Handler) handleDoneCommand(ctx context.Context, msg *Message) {
+	_, _, args := extractCommand(msg.Text)
+	if len(args) != 1 {
+		h.sendMessage(msg.Chat.ID, "用法：/done 编号，编号见 /list、/mine 或 /group_tasks", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	user, t, ok := h.listedTaskForUpdate(ctx, msg, args[0])
+	if !ok {
+		return
+	}
+	if t.Category() == repository.StatusCategoryDone {
+		h.sendMessage(msg.Chat.ID, fmt.Sprintf("ℹ️ 「%s」已经完成了", escapeHTML(t.Title)), nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+
+	status := h.taskService.StatusForCategory(ctx, t, repository.StatusCategoryDone)
+	if _, err := h.taskService.UpdateTaskFromBot(ctx, user.ID, t.ID, task.UpdateParams{Status: &status}); err != nil {
+		if errors.Is(err, task.ErrTransitionNotAllowed) {
+			h.sendMessage(msg.Chat.ID, fmt.Sprintf("⚠️ 「%s」当前状态不能直接流转到 %s", escapeHTML(t.Title), escapeHTML(string(status))), nil, msg.MessageID, msg.MessageThreadID)
+			return
+		}
+		h.logger.Error("done command failed", zap.Error(err), zap.String("task_id", t.ID))
+		h.sendMessage(msg.Chat.ID, "❌ 操作失败，请稍后再试。", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	h.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ 已完成：<b>%s</b>", escapeHTML(t.Title)), nil, msg.MessageID, msg.MessageThreadID)
+}
+
+// handleAssignCommand handles /assign <n> @user: adds the user to the
+// assignees of task n of the chat's last list
+func (h *Handler) handleAssignCommand(ctx context.Context, msg *Message) {
+	_, _, args := extractCommand(msg.Text)
+	if len(args) != 2 || !strings.HasPrefix(args[1], "@") || len(args[1]) < 2 {
+		h.sendMessage(msg.Chat.ID, "用法：/assign 编号 @成员，编号见 /list、/mine 或 /group_tasks", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	user, t, ok := h.listedTaskForUpdate(ctx, msg, args[0])
+	if !ok {
+		return
+	}
+
+	username := strings.TrimPrefix(args[1], "@")
+	assignee, err := h.userRepo.GetByUsername(ctx, username)
+	if err != nil || assignee == nil {
+		h.sendMessage(msg.Chat.ID, fmt.Sprintf("⚠️ @%s 还没有使用过机器人，请对方先私聊机器人发送 /start", escapeHTML(username)), nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	if _, err := h.taskService.AddAssignee(ctx, user.ID, t.ID, assignee.ID, repository.TaskEventSourceBot); err != nil {
+		h.logger.Error("assign command failed", zap.Error(err), zap.String("task_id", t.ID))
+		h.sendMessage(msg.Chat.ID, "❌ 操作失败，请稍后再试。", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	h.sendMessage(msg.Chat.ID, fmt.Sprintf("👤 已将「%s」指派给 @%s", escapeHTML(t.Title), escapeHTML(username)), nil, msg.MessageID, msg.MessageThreadID)
+}
+
+// handleDueCommand handles /due <n> <date>: sets the due date of task n of the
+// chat's last list, see parseDueArgs for the accepted dates
+func (h *Handler) handleDueCommand(ctx context.Context, msg *Message) {
+	_, _, args := extractCommand(msg.Text)
+	if len(args) < 2 {
+		h.sendMessage(msg.Chat.ID, "用法：/due 编号 日期，如 /due 3 明天、/due 3 2026-03-02 18:00", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	user, t, ok := h.listedTaskForUpdate(ctx, msg, args[0])
+	if !ok {
+		return
+	}
+
+	loc := user.Location()
+	dueAt, allDay, err := parseDueArgs(args[1:], time.Now().In(loc))
+	if err != nil {
+		h.sendMessage(msg.Chat.ID, "⚠️ 无法识别日期，支持：今天、明天、03-02、2026-03-02，可在后面加时间如 18:00", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	if _, err := h.taskService.UpdateTaskFromBot(ctx, user.ID, t.ID, task.UpdateParams{
+		DueAt:    &dueAt,
+		AllDay:   &allDay,
+		Location: loc,
+	}); err != nil {
+		if errors.Is(err, task.ErrStartAfterDue) {
+			h.sendMessage(msg.Chat.ID, "⚠️ 截止时间不能早于开始时间", nil, msg.MessageID, msg.MessageThreadID)
+			return
+		}
+		h.logger.Error("due command failed", zap.Error(err), zap.String("task_id", t.ID))
+		h.sendMessage(msg.Chat.ID, "❌ 操作失败，请稍后再试。", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	h.sendMessage(msg.Chat.ID, fmt.Sprintf("📅 「%s」截止时间已设为 %s", escapeHTML(t.Title), timezone.FormatDue(dueAt, allDay, loc, "2006-01-02")), nil, msg.MessageID, msg.MessageThreadID)
+}
+
+// listedTaskForUpdate resolves the task numbered arg in the chat's last list
+// and checks the sender may modify it, replying with the reason if not
+func (h *Handler) listedTaskForUpdate(ctx context.Context, msg *Message, arg string) (*models.User, *repository.Task, bool) {
+	if h.taskService == nil || h.userRepo == nil || h.groupRoles == nil || h.chatLists == nil {
+		return nil, nil, false
+	}
+	user, err := h.userRepo.FindByTgID(ctx, msg.From.ID)
+	if err != nil || user == nil {
+		h.sendMessage(msg.Chat.ID, "⚠️ 请先私聊机器人发送 /start 完成注册。", nil, msg.MessageID, msg.MessageThreadID)
+		return nil, nil, false
+	}
+
+	list, err := h.chatLists.Load(ctx, msg.Chat.ID)
+	if err != nil {
+		h.logger.Error("failed to load chat list", zap.Error(err), zap.Int64("chat_id", msg.Chat.ID))
+		h.sendMessage(msg.Chat.ID, "❌ 操作失败，请稍后再试。", nil, msg.MessageID, msg.MessageThreadID)
+		return nil, nil, false
+	}
+	if list == nil {
+		h.sendMessage(msg.Chat.ID, "⚠️ 请先发送 /list、/mine 或 /group_tasks 查看任务编号", nil, msg.MessageID, msg.MessageThreadID)
+		return nil, nil, false
+	}
+	// The numbers of personal lists belong to the one who asked for them
+	if list.Kind != listKindGroup && list.UserID != user.ID {
+		h.sendMessage(msg.Chat.ID, "⚠️ 最近的任务列表是其他成员的，请先发送 /list 或 /mine 查看自己的任务编号", nil, msg.MessageID, msg.MessageThreadID)
+		return nil, nil, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	taskID, ok := list.TaskID(n)
+	if err != nil || !ok {
+		h.sendMessage(msg.Chat.ID, fmt.Sprintf("⚠️ 编号 %s 不在最近的任务列表中，请重新发送 /list 查看", escapeHTML(arg)), nil, msg.MessageID, msg.MessageThreadID)
+		return nil, nil, false
+	}
+
+	t, err := h.taskService.GetTask(ctx, taskID)
+	if err != nil || t == nil {
+		h.sendMessage(msg.Chat.ID, "❌ 任务不存在或已删除", nil, msg.MessageID, msg.MessageThreadID)
+		return nil, nil, false
+	}
+	canModify, err := task.CanModifyTask(ctx, user.ID, t, h.groupRoles)
+	if err != nil || !canModify {
+		h.sendMessage(msg.Chat.ID, "❌ 只有创建人、指派人或群管理员可以修改任务", nil, msg.MessageID, msg.MessageThreadID)
+		return nil, nil, false
+	}
+	return user, t, true
+}
+
+// parseDueArgs parses the date of /due: 今天/today, 明天/tomorrow, a date
+// (2006-01-02) or a day of the year (01-02, the next one to come), optionally
+// followed by a time (15:04). Dates without a time are all-day.
+func parseDueArgs(args []string, now time.Time) (time.Time, bool, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return time.Time{}, false, errors.New("expected a date and an optional time")
+	}
+	loc := now.Location()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var date time.Time
+	switch strings.ToLower(args[0]) {
+	case "今天", "today":
+		date = today
+	case "明天", "tomorrow":
+		date = today.AddDate(0, 0, 1)
+	default:
+		if d, err := time.ParseInLocation("2006-01-02", args[0], loc); err == nil {
+			date = d
+			break
+		}
+		d, err := time.ParseInLocation("01-02", args[0], loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date %q", args[0])
+		}
+		date = time.Date(now.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+		if date.Before(today) {
+			date = date.AddDate(1, 0, 0)
+		}
+	}
+	if len(args) == 1 {
+		return date, true, nil
+	}
+
+	clock, err := time.Parse("15:04", args[1])
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time %q", args[1])
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, loc), false, nil
+}
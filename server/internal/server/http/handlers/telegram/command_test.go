@@ -0,0 +1,142 @@
+package telegram
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+func TestParseDueArgs(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	now := time.Date(2026, 3, 2, 22, 30, 0, 0, loc)
+
+	tests := []struct {
+		args   []string
+		want   time.Time
+		allDay bool
+	}{
+		{[]string{"今天"}, time.Date(2026, 3, 2, 0, 0, 0, 0, loc), true},
+		{[]string{"Tomorrow"}, time.Date(2026, 3, 3, 0, 0, 0, 0, loc), true},
+		{[]string{"明天", "18:00"}, time.Date(2026, 3, 3, 18, 0, 0, 0, loc), false},
+		{[]string{"2026-04-01"}, time.Date(2026, 4, 1, 0, 0, 0, 0, loc), true},
+		{[]string{"03-05", "09:15"}, time.Date(2026, 3, 5, 9, 15, 0, 0, loc), false},
+		{[]string{"01-15"}, time.Date(2027, 1, 15, 0, 0, 0, 0, loc), true}, // Already passed this year
+	}
+	for _, tt := range tests {
+		got, allDay, err := parseDueArgs(tt.args, now)
+		require.NoError(t, err, tt.args)
+		assert.True(t, tt.want.Equal(got), "%v: got %v", tt.args, got)
+		assert.Equal(t, tt.allDay, allDay, tt.args)
+	}
+
+	for _, args := range [][]string{nil, {"next week"}, {"2026-13-01"}, {"明天", "25:00"}, {"明天", "18:00", "extra"}} {
+		_, _, err := parseDueArgs(args, now)
+		assert.Error(t, err, args)
+	}
+}
+
+// messageUpdate is a message sent by tgID in a chat
+func messageUpdate(tgID, chatID int64, chatType, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"update_id": 1,
+		"message": map[string]interface{}{
+			"message_id": 1,
+			"from":       map[string]interface{}{"id": tgID},
+			"chat":       map[string]interface{}{"id": chatID, "type": chatType},
+			"text":       text,
+		},
+	}
+}
+
+// lastReply returns the text of the last message the bot sent
+func lastReply(t *testing.T, b *botTestHandler) string {
+	sent := b.bot.find("sendMessage")
+	require.NotEmpty(t, sent)
+	return sent[len(sent)-1].Body["text"].(string)
+}
+
+func TestListCommandNumbersAcrossPages(t *testing.T) {
+	alice := &models.User{ID: "user-1", TgID: 300, Name: "Alice"}
+	b := newBotTestHandler(t, []*models.User{alice}, nil)
+	for i := 1; i <= 12; i++ {
+		b.tasks.listed = append(b.tasks.listed, repository.Task{ID: fmt.Sprintf("task-%d", i), Title: fmt.Sprintf("Task %d", i), CreatorID: &alice.ID})
+	}
+
+	b.post(t, messageUpdate(300, 300, "private", "/list"))
+	reply := lastReply(t, b)
+	assert.Contains(t, reply, "1. ⬜️ <b>Task 1</b>")
+	assert.Contains(t, reply, "10. ⬜️ <b>Task 10</b>")
+	assert.NotContains(t, reply, "Task 11")
+	list := b.lists[300]
+	require.NotNil(t, list)
+	assert.Equal(t, 0, list.Offset)
+	assert.Len(t, list.TaskIDs, 10)
+
+	b.post(t, map[string]interface{}{
+		"update_id": 2,
+		"callback_query": map[string]interface{}{
+			"id":      "cq-1",
+			"from":    map[string]interface{}{"id": 300},
+			"data":    fmt.Sprintf("tasks_page:%s:1", list.ID),
+			"message": map[string]interface{}{"message_id": 2, "chat": map[string]interface{}{"id": 300, "type": "private"}},
+		},
+	})
+	edits := b.bot.find("editMessageText")
+	require.Len(t, edits, 1)
+	// The second page continues the numbering of the first
+	assert.Contains(t, edits[0].Body["text"], "11. ⬜️ <b>Task 11</b>")
+	assert.Contains(t, edits[0].Body["text"], "12. ⬜️ <b>Task 12</b>")
+	assert.Equal(t, 10, b.lists[300].Offset)
+	assert.Equal(t, []string{"task-11", "task-12"}, b.lists[300].TaskIDs)
+
+	// Numbers of the first page are no longer in the chat's list
+	b.post(t, messageUpdate(300, 300, "private", "/done 3"))
+	assert.Contains(t, lastReply(t, b), "⚠️ 编号 3 不在最近的任务列表中")
+	b.post(t, messageUpdate(300, 300, "private", "/done 13"))
+	assert.Contains(t, lastReply(t, b), "⚠️ 编号 13 不在最近的任务列表中")
+	// Task 12 was deleted since the list was sent
+	b.post(t, messageUpdate(300, 300, "private", "/done 12"))
+	assert.Equal(t, "❌ 任务不存在或已删除", lastReply(t, b))
+}
+
+func TestListedTaskCommandsRequirePermission(t *testing.T) {
+	alice := &models.User{ID: "user-1", TgID: 300, Name: "Alice"}
+	bob := &models.User{ID: "user-2", TgID: 301, Name: "Bob"}
+	b := newBotTestHandler(t, []*models.User{alice, bob}, stubGroupRoles{"user-2/-100": models.GroupRoleMember})
+	groupID := "-100"
+	owned := repository.Task{ID: "task-1", Title: "Ship", CreatorID: &alice.ID, GroupID: &groupID}
+	b.tasks.listed = []repository.Task{owned}
+	b.tasks.tasks[owned.ID] = &owned
+
+	// Group lists are numbered for every member, who still needs the right
+	// to modify the task
+	b.post(t, messageUpdate(301, -100, "supergroup", "/group_tasks"))
+	require.Equal(t, listKindGroup, b.lists[-100].Kind)
+	for _, cmd := range []string{"/done 1", "/assign 1 @alice", "/due 1 明天"} {
+		b.post(t, messageUpdate(301, -100, "supergroup", cmd))
+		assert.Equal(t, "❌ 只有创建人、指派人或群管理员可以修改任务", lastReply(t, b), cmd)
+	}
+
+	// The numbers of a personal list are only for the one who asked for it
+	b.post(t, messageUpdate(300, -100, "supergroup", "/list"))
+	require.Equal(t, listKindAll, b.lists[-100].Kind)
+	b.post(t, messageUpdate(301, -100, "supergroup", "/done 1"))
+	assert.Contains(t, lastReply(t, b), "最近的任务列表是其他成员的")
+	assert.Equal(t, "Ship", b.tasks.tasks[owned.ID].Title)
+}
+
+func TestGroupTasksCommandInPrivateChat(t *testing.T) {
+	alice := &models.User{ID: "user-1", TgID: 300, Name: "Alice"}
+	b := newBotTestHandler(t, []*models.User{alice}, nil)
+
+	b.post(t, messageUpdate(300, 300, "private", "/group_tasks"))
+	assert.Equal(t, "⚠️ /group_tasks 只能在群聊中使用。", lastReply(t, b))
+	assert.Empty(t, b.lists)
+}
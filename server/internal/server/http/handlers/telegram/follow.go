@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/service/task"
+)
+
+// handleFollowCallback handles the follow_task:<id> button on task cards. The
+// card may be shared with a whole group, so the button toggles the state of
+// whoever presses it.
+func (h *Handler) handleFollowCallback(ctx context.Context, cq *CallbackQuery) {
+	if h.taskService == nil || h.userRepo == nil || h.groupRoles == nil {
+		return
+	}
+	taskID := strings.TrimPrefix(cq.Data, "follow_task:")
+
+	user, err := h.userRepo.FindByTgID(ctx, cq.From.ID)
+	if err != nil || user == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "⚠️ 请先私聊机器人发送 /start 完成注册。")
+		return
+	}
+
+	t, err := h.taskService.GetTask(ctx, taskID)
+	if err != nil || t == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 任务不存在或已删除")
+		return
+	}
+	// Pressing the button in the task's own group proves membership
+	inTaskGroup := t.GroupID != nil && cq.Message != nil && strconv.FormatInt(cq.Message.Chat.ID, 10) == *t.GroupID
+	if !inTaskGroup {
+		canFollow, err := task.CanClaimTask(ctx, user.ID, t, h.groupRoles)
+		if err != nil || !canFollow {
+			h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 只有任务所在群的成员可以关注任务")
+			return
+		}
+	}
+
+	added, err := h.taskService.Watch(ctx, user.ID, taskID)
+	if err == nil && !added {
+		_, err = h.taskService.Unwatch(ctx, user.ID, taskID)
+	}
+	if err != nil {
+		h.logger.Error("failed to toggle task watcher", zap.Error(err), zap.String("task_id", taskID))
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 操作失败，请稍后再试")
+		return
+	}
+
+	if added {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "👀 已关注，状态、评论和截止时间变更会私聊通知你")
+	} else {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "🙈 已取消关注")
+	}
+}
@@ -83,7 +83,7 @@ func TestHandleWebhook_MyChatMember_Added(t *testing.T) {
 	mockGroupRepo := new(MockGroupRepo)
 
 	// Group Service (NotionService nil is fine for EnsureGroup)
-	groupService := groupsvc.NewService(logger, mockGroupRepo, nil)
+	groupService := groupsvc.NewService(logger, mockGroupRepo, nil, nil, nil)
 
 	tgClient := telegram.NewClient("token")
 	tgClient.SetBaseURL(ts.URL + "/") // Trailing slash important if client logic expects it? code: ts.baseURL, token. code: "%s%s/%s" -> "URL/token/method"
@@ -0,0 +1,324 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/task"
+	"github.com/layababa/tg_todo/server/internal/service/telegram"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
+)
+
+const (
+	// listCommandLimit is the number of tasks on a page of /list, /mine and /group_tasks
+	listCommandLimit = 10
+	// inlineListLimit is the number of tasks returned for an inline "list" query
+	inlineListLimit = 20
+	// defaultListQuery hides finished tasks when /list has no expression
+	defaultListQuery = "-status:done"
+)
+
+// Kinds of task lists, named after their commands
+const (
+	listKindAll   = "list"        // Tasks the user created or is assigned to
+	listKindMine  = "mine"        // Open tasks assigned to the user
+	listKindGroup = "group_tasks" // Tasks of the group the command is sent in
+)
+
+// handleListCommand replies with the first page of a task list, optionally
+// narrowed by a filter expression, e.g. /list status:"In Progress" due:<7d
+// -label:wontfix. The tasks are numbered for /done, /assign and /due.
+func (h *Handler) handleListCommand(ctx context.Context, msg *Message, kind string) {
+	if h.taskService == nil || h.userRepo == nil {
+		return
+	}
+	user, err := h.userRepo.FindByTgID(ctx, msg.From.ID)
+	if err != nil || user == nil {
+		h.sendMessage(msg.Chat.ID, "⚠️ 请先私聊机器人发送 /start 完成注册。", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	if kind == listKindGroup && msg.Chat.Type != "group" && msg.Chat.Type != "supergroup" {
+		h.sendMessage(msg.Chat.ID, "⚠️ /group_tasks 只能在群聊中使用。", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+
+	_, _, args := extractCommand(msg.Text)
+	list := &telegram.ChatList{
+		ID:     uuid.NewString()[:8],
+		Kind:   kind,
+		UserID: user.ID,
+		Query:  strings.TrimSpace(strings.Join(args, " ")),
+	}
+	text, markup, err := h.renderTaskList(ctx, msg.Chat.ID, msg.Chat.Type == "private", user.Location(), list)
+	if err != nil {
+		var queryErr *repository.QueryError
+		if errors.As(err, &queryErr) {
+			h.sendMessage(msg.Chat.ID, formatQueryError(list.Query, queryErr), nil, msg.MessageID, msg.MessageThreadID)
+			return
+		}
+		h.logger.Error("list command failed", zap.Error(err), zap.String("user_id", user.ID), zap.String("kind", kind))
+		h.sendMessage(msg.Chat.ID, "⚠️ 查询任务失败，请稍后再试。", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	if markup == nil {
+		h.sendMessage(msg.Chat.ID, text, nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
+	h.sendMessage(msg.Chat.ID, text, markup, msg.MessageID, msg.MessageThreadID)
+}
+
+// handleListPageCallback handles the tasks_page:<list id>:<page> buttons of
+// task lists by editing the list in place. Only the chat's latest list can be
+// paged, since its numbers are the ones commands refer to.
+func (h *Handler) handleListPageCallback(ctx context.Context, cq *CallbackQuery) {
+	if h.taskService == nil || h.userRepo == nil || h.chatLists == nil || cq.Message == nil {
+		return
+	}
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 3 {
+		return
+	}
+	page, err := strconv.Atoi(parts[2])
+	if err != nil || page < 0 {
+		return
+	}
+
+	user, err := h.userRepo.FindByTgID(ctx, cq.From.ID)
+	if err != nil || user == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "⚠️ 请先私聊机器人发送 /start 完成注册。")
+		return
+	}
+	chatID := cq.Message.Chat.ID
+	list, err := h.chatLists.Load(ctx, chatID)
+	if err != nil {
+		h.logger.Error("failed to load chat list", zap.Error(err), zap.Int64("chat_id", chatID))
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 操作失败，请稍后再试")
+		return
+	}
+	if list == nil || list.ID != parts[1] {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "⌛️ 列表已过期，请重新发送命令")
+		return
+	}
+	if list.Kind != listKindGroup && list.UserID != user.ID {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "ℹ️ 只有发送命令的人可以翻页")
+		return
+	}
+
+	list.Page = page
+	text, markup, err := h.renderTaskList(ctx, chatID, cq.Message.Chat.Type == "private", user.Location(), list)
+	if err != nil {
+		h.logger.Error("failed to render task list page", zap.Error(err), zap.Int64("chat_id", chatID))
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 操作失败，请稍后再试")
+		return
+	}
+	if err := h.tgClient.EditChatMessageText(chatID, cq.Message.MessageID, text, markup); err != nil {
+		h.logger.Warn("failed to edit task list", zap.Error(err), zap.Int64("chat_id", chatID))
+	}
+	h.tgClient.AnswerCallbackQuery(cq.ID, "")
+}
+
+// renderTaskList loads the current page of list and renders it with its page
+// buttons, then remembers the page's tasks as the chat's numbered list
+func (h *Handler) renderTaskList(ctx context.Context, chatID int64, private bool, loc *time.Location, list *telegram.ChatList) (string, *telegram.InlineKeyboardMarkup, error) {
+	params := task.ListParams{
+		View:     repository.TaskViewAll,
+		Query:    list.Query,
+		Location: loc,
+		Sort:     repository.TaskSortDue,
+		Limit:    listCommandLimit + 1,
+		Offset:   list.Page * listCommandLimit,
+	}
+	title := "📋 我的任务"
+	switch list.Kind {
+	case listKindMine:
+		// The assigned view already hides finished tasks
+		params.View = repository.TaskViewAssigned
+		title = "🙋 指派给我的任务"
+	case listKindGroup:
+		groupID := strconv.FormatInt(chatID, 10)
+		params.View = repository.TaskViewGroup
+		params.GroupID = &groupID
+		title = "👥 本群任务"
+	}
+	if params.Query == "" && params.View != repository.TaskViewAssigned {
+		params.Query = defaultListQuery
+	}
+
+	items, _, err := h.taskService.ListTasks(ctx, list.UserID, params)
+	if err != nil {
+		return "", nil, err
+	}
+	hasMore := len(items) > listCommandLimit
+	if hasMore {
+		items = items[:listCommandLimit]
+	}
+
+	list.Offset = params.Offset
+	list.TaskIDs = make([]string, 0, len(items))
+	for _, item := range items {
+		list.TaskIDs = append(list.TaskIDs, item.Task.ID)
+	}
+	if h.chatLists != nil {
+		if err := h.chatLists.Save(ctx, chatID, list); err != nil {
+			h.logger.Warn("failed to save chat list", zap.Error(err), zap.Int64("chat_id", chatID))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(title)
+	if list.Query != "" {
+		sb.WriteString(fmt.Sprintf(" · <code>%s</code>", escapeHTML(list.Query)))
+	}
+	if list.Page > 0 {
+		sb.WriteString(fmt.Sprintf(" · 第 %d 页", list.Page+1))
+	}
+	sb.WriteString("\n\n")
+	if len(items) == 0 {
+		sb.WriteString("🔍 没有匹配的任务。")
+	}
+	for i, item := range items {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", list.Offset+i+1, formatTaskLine(item.Task, loc)))
+	}
+	if len(items) > 0 {
+		sb.WriteString("\n/done 编号 完成 · /assign 编号 @成员 指派 · /due 编号 日期 设置截止")
+	}
+
+	var rows [][]telegram.InlineKeyboardButton
+	var nav []telegram.InlineKeyboardButton
+	if list.Page > 0 {
+		nav = append(nav, telegram.InlineKeyboardButton{Text: "⬅️ 上一页", CallbackData: listPageData(list, list.Page-1)})
+	}
+	if hasMore {
+		nav = append(nav, telegram.InlineKeyboardButton{Text: "下一页 ➡️", CallbackData: listPageData(list, list.Page+1)})
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	if private {
+		// Web app buttons only work in private chats
+		if app := h.buildWebAppMarkup("打开 Mini App", ""); app != nil {
+			rows = append(rows, app.InlineKeyboard...)
+		}
+	}
+	if len(rows) == 0 {
+		return sb.String(), nil, nil
+	}
+	return sb.String(), &telegram.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+}
+
+func listPageData(list *telegram.ChatList, page int) string {
+	return fmt.Sprintf("tasks_page:%s:%d", list.ID, page)
+}
+
+// handleInlineListQuery answers "list <expr>" inline queries with matching
+// tasks as shareable cards
+func (h *Handler) handleInlineListQuery(ctx context.Context, iq *InlineQuery, expr string) {
+	if h.taskService == nil || h.userRepo == nil {
+		return
+	}
+	user, err := h.userRepo.FindByTgID(ctx, iq.From.ID)
+	if err != nil || user == nil {
+		return
+	}
+	if expr == "" {
+		expr = defaultListQuery
+	}
+
+	items, _, err := h.taskService.ListTasks(ctx, user.ID, task.ListParams{
+		View:     repository.TaskViewAll,
+		Query:    expr,
+		Location: user.Location(),
+		Sort:     repository.TaskSortDue,
+		Limit:    inlineListLimit,
+	})
+	if err != nil {
+		var queryErr *repository.QueryError
+		if !errors.As(err, &queryErr) {
+			h.logger.Error("inline list query failed", zap.Error(err), zap.String("user_id", user.ID))
+			return
+		}
+		article := telegram.InlineQueryResultArticle{
+			Type:        "article",
+			ID:          "query_error",
+			Title:       "查询语法错误",
+			Description: fmt.Sprintf("%s: %s", queryErr.Message, queryErr.Token),
+			InputMessageContent: telegram.InputMessageContent{
+				MessageText: formatQueryError(expr, queryErr),
+				ParseMode:   "HTML",
+			},
+		}
+		if err := h.tgClient.AnswerInlineQuery(iq.ID, []telegram.InlineQueryResultArticle{article}); err != nil {
+			h.logger.Error("failed to answer inline query (list error)", zap.Error(err))
+		}
+		return
+	}
+
+	articles := make([]telegram.InlineQueryResultArticle, 0, len(items))
+	for _, item := range items {
+		msgText, markup := h.buildShareCard(item.Task)
+		articles = append(articles, telegram.InlineQueryResultArticle{
+			Type:        "article",
+			ID:          item.Task.ID,
+			Title:       item.Task.Title,
+			Description: formatTaskMeta(item.Task, user.Location()),
+			InputMessageContent: telegram.InputMessageContent{
+				MessageText: msgText,
+				ParseMode:   "HTML",
+			},
+			ReplyMarkup: markup,
+		})
+	}
+	if err := h.tgClient.AnswerInlineQuery(iq.ID, articles); err != nil {
+		h.logger.Error("failed to answer inline query (list)", zap.Error(err))
+	}
+}
+
+// formatQueryError renders a filter syntax error with a caret under the
+// offending token
+func formatQueryError(expr string, qe *repository.QueryError) string {
+	width := utf8.RuneCountInString(qe.Token)
+	if width == 0 {
+		width = 1
+	}
+	pointer := strings.Repeat(" ", qe.Pos) + strings.Repeat("^", width)
+	return fmt.Sprintf("⚠️ 查询语法错误：%s\n<pre>%s\n%s</pre>\n示例：<code>status:\"In Progress\" due:&lt;7d assignee:@me -label:wontfix</code>",
+		escapeHTML(qe.Message), escapeHTML(expr), pointer)
+}
+
+// formatTaskLine renders a task as a single HTML line for list replies, with
+// the due date in loc
+func formatTaskLine(t *repository.Task, loc *time.Location) string {
+	return fmt.Sprintf("%s <b>%s</b> · %s", statusIcon(t), escapeHTML(t.Title), escapeHTML(formatTaskMeta(t, loc)))
+}
+
+// formatTaskMeta summarizes status, priority and due date of a task
+func formatTaskMeta(t *repository.Task, loc *time.Location) string {
+	parts := []string{string(t.Status)}
+	if t.Priority != "" {
+		parts = append(parts, string(t.Priority))
+	}
+	if t.DueAt != nil {
+		parts = append(parts, "📅 "+timezone.FormatDue(*t.DueAt, t.AllDay, loc, "01-02"))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func statusIcon(t *repository.Task) string {
+	switch t.Category() {
+	case repository.StatusCategoryDone:
+		return "✅"
+	case repository.StatusCategoryActive:
+		return "🔄"
+	default:
+		return "⬜️"
+	}
+}
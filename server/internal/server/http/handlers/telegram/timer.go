@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/service/task"
+)
+
+// handleTimerCallback handles the start_timer:<id> and stop_timer:<id> buttons
+// on task cards. Like the follow button, it acts for whoever presses it.
+func (h *Handler) handleTimerCallback(ctx context.Context, cq *CallbackQuery) {
+	if h.taskService == nil || h.userRepo == nil || h.groupRoles == nil {
+		return
+	}
+	start := strings.HasPrefix(cq.Data, "start_timer:")
+	taskID := cq.Data[strings.Index(cq.Data, ":")+1:]
+
+	user, err := h.userRepo.FindByTgID(ctx, cq.From.ID)
+	if err != nil || user == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "⚠️ 请先私聊机器人发送 /start 完成注册。")
+		return
+	}
+
+	if !start {
+		entry, err := h.taskService.StopTimer(ctx, user.ID, taskID)
+		if errors.Is(err, task.ErrNoRunningTimer) {
+			h.tgClient.AnswerCallbackQuery(cq.ID, "ℹ️ 你没有在为此任务计时")
+			return
+		}
+		if err != nil {
+			h.logger.Error("failed to stop timer", zap.Error(err), zap.String("task_id", taskID))
+			h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 操作失败，请稍后再试")
+			return
+		}
+		h.tgClient.AnswerCallbackQuery(cq.ID, "⏹ 已停止计时，本次 "+formatTrackedDuration(time.Duration(entry.DurationSeconds)*time.Second))
+		return
+	}
+
+	t, err := h.taskService.GetTask(ctx, taskID)
+	if err != nil || t == nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 任务不存在或已删除")
+		return
+	}
+	canModify, err := task.CanModifyTask(ctx, user.ID, t, h.groupRoles)
+	if err != nil || !canModify {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 只有创建人、指派人或群管理员可以为任务计时，可先认领任务")
+		return
+	}
+
+	_, stopped, err := h.taskService.StartTimer(ctx, user.ID, taskID)
+	if err != nil {
+		h.logger.Error("failed to start timer", zap.Error(err), zap.String("task_id", taskID))
+		h.tgClient.AnswerCallbackQuery(cq.ID, "❌ 操作失败，请稍后再试")
+		return
+	}
+	if stopped != nil {
+		h.tgClient.AnswerCallbackQuery(cq.ID, "⏱ 已开始计时（上一个任务的计时已停止）")
+		return
+	}
+	h.tgClient.AnswerCallbackQuery(cq.ID, "⏱ 已开始计时：「"+t.Title+"」")
+}
+
+// formatTrackedDuration renders a duration as hours and minutes, e.g. "1小时5分钟"
+func formatTrackedDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes < 60 {
+		return fmt.Sprintf("%d分钟", minutes)
+	}
+	return fmt.Sprintf("%d小时%d分钟", minutes/60, minutes%60)
+}
@@ -3,12 +3,14 @@ package telegram
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -17,8 +19,10 @@ import (
 	"github.com/layababa/tg_todo/server/internal/models"
 	"github.com/layababa/tg_todo/server/internal/repository"
 	groupsvc "github.com/layababa/tg_todo/server/internal/service/group"
+	"github.com/layababa/tg_todo/server/internal/service/notification"
 	"github.com/layababa/tg_todo/server/internal/service/task"
 	"github.com/layababa/tg_todo/server/internal/service/telegram"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
 const (
@@ -31,9 +35,12 @@ type Handler struct {
 	deduplicator telegram.Deduplicator
 	repo         repository.TelegramUpdateRepository
 	userRepo     repository.UserRepository
+	groupRoles   task.UserGroupRepository
 	taskCreator  *task.Creator
 	taskService  *task.Service // Added TaskService
 	groupService *groupsvc.Service
+	chatLists    telegram.ChatListStore
+	actionSigner *telegram.CallbackSigner
 	tgClient     *telegram.Client
 	secretToken  string
 	botUsername  string
@@ -46,9 +53,12 @@ type Config struct {
 	Deduplicator telegram.Deduplicator
 	Repo         repository.TelegramUpdateRepository
 	UserRepo     repository.UserRepository
+	GroupRoles   task.UserGroupRepository // Used for permission checks of bot actions
 	TaskCreator  *task.Creator
 	TaskService  *task.Service // Added TaskService
 	GroupService *groupsvc.Service
+	ChatLists    telegram.ChatListStore   // Numbered task lists for /done, /assign and /due
+	ActionSigner *telegram.CallbackSigner // Signs the task action buttons; none when nil
 	TgClient     *telegram.Client
 	SecretToken  string
 	BotUsername  string
@@ -62,9 +72,12 @@ func NewHandler(cfg Config) *Handler {
 		deduplicator: cfg.Deduplicator,
 		repo:         cfg.Repo,
 		userRepo:     cfg.UserRepo,
+		groupRoles:   cfg.GroupRoles,
 		taskCreator:  cfg.TaskCreator,
 		taskService:  cfg.TaskService, // Added TaskService
 		groupService: cfg.GroupService,
+		chatLists:    cfg.ChatLists,
+		actionSigner: cfg.ActionSigner,
 		tgClient:     cfg.TgClient,
 		secretToken:  cfg.SecretToken,
 		botUsername:  strings.TrimPrefix(cfg.BotUsername, "@"),
@@ -85,15 +98,20 @@ type Message struct {
 		Type  string `json:"type"` // private, group, supergroup
 		Title string `json:"title"`
 	} `json:"chat"`
-	Text            string `json:"text"`
-	Caption         string `json:"caption"` // Added Caption support
-	MessageThreadID int64  `json:"message_thread_id"`
-	ReplyToMessage  *struct {
+	Text            string          `json:"text"`
+	Caption         string          `json:"caption"` // Added Caption support
+	MessageThreadID int64           `json:"message_thread_id"`
+	Entities        []MessageEntity `json:"entities"`         // Formatting of Text
+	CaptionEntities []MessageEntity `json:"caption_entities"` // Formatting of Caption
+	task.MessageMedia
+	ReplyToMessage *struct {
 		MessageID int64  `json:"message_id"`
 		Text      string `json:"text"`
 		Caption   string `json:"caption"` // For photo/video/document messages
+		task.MessageMedia
 	} `json:"reply_to_message"`
-	ForwardDate int64 `json:"forward_date"`
+	ReplyMarkup *telegram.InlineKeyboardMarkup `json:"reply_markup"` // Inline keyboard of bot messages
+	ForwardDate int64                          `json:"forward_date"`
 	ForwardFrom *struct {
 		ID        int64  `json:"id"`
 		Username  string `json:"username"`
@@ -107,6 +125,19 @@ type Message struct {
 	} `json:"forward_from_chat"`
 }
 
+// MessageEntity marks a formatted part of a message text. Offset and Length
+// count UTF-16 code units.
+type MessageEntity struct {
+	Type     string `json:"type"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	URL      string `json:"url,omitempty"`
+	Language string `json:"language,omitempty"`
+	User     *struct {
+		ID int64 `json:"id"`
+	} `json:"user,omitempty"`
+}
+
 // Update represents the basic structure we need to extract update_id and content
 type Update struct {
 	UpdateID     int64    `json:"update_id"`
@@ -299,7 +330,7 @@ func (h *Handler) HandleWebhook(c *gin.Context) {
 			return
 		}
 
-		cmd, target, args := extractCommand(msg.Text)
+		cmd, target, args := extractCommand(messageText(msg))
 
 		// Check target (if command targeting is used)
 		if target != "" && h.botUsername != "" {
@@ -322,6 +353,18 @@ func (h *Handler) HandleWebhook(c *gin.Context) {
 			h.handleBind(ctx, msg.Chat.ID, msg.From.ID, msg.MessageThreadID, msg.Chat.Title)
 		case "/todo":
 			h.handleTaskCommand(ctx, msg)
+		case "/list":
+			h.handleListCommand(ctx, msg, listKindAll)
+		case "/mine":
+			h.handleListCommand(ctx, msg, listKindMine)
+		case "/group_tasks":
+			h.handleListCommand(ctx, msg, listKindGroup)
+		case "/done":
+			h.handleDoneCommand(ctx, msg)
+		case "/assign":
+			h.handleAssignCommand(ctx, msg)
+		case "/due":
+			h.handleDueCommand(ctx, msg)
 		case "/menu":
 			h.handleMenu(msg.Chat.ID, msg.MessageThreadID)
 
@@ -360,6 +403,12 @@ func (h *Handler) handleInlineQuery(ctx context.Context, iq *InlineQuery) {
 	// format: assign <TaskID> or share <TaskID> <Title> or share (Title)[TaskID]
 	var taskID string
 
+	// format: list <filter expression>
+	if query == "list" || strings.HasPrefix(query, "list ") {
+		h.handleInlineListQuery(ctx, iq, strings.TrimSpace(strings.TrimPrefix(query, "list")))
+		return
+	}
+
 	if strings.HasPrefix(query, "assign ") {
 		taskID = strings.TrimPrefix(query, "assign ")
 	} else if strings.HasPrefix(query, "share ") {
@@ -458,6 +507,26 @@ func (h *Handler) handleInlineCreateTaskQuery(ctx context.Context, iq *InlineQue
 
 func (h *Handler) handleCallbackQuery(ctx context.Context, cq *CallbackQuery) {
 	data := cq.Data
+	if strings.HasPrefix(data, "archive_task:") || strings.HasPrefix(data, "unarchive_task:") {
+		h.handleArchiveCallback(ctx, cq)
+		return
+	}
+	if strings.HasPrefix(data, "follow_task:") {
+		h.handleFollowCallback(ctx, cq)
+		return
+	}
+	if strings.HasPrefix(data, "start_timer:") || strings.HasPrefix(data, "stop_timer:") {
+		h.handleTimerCallback(ctx, cq)
+		return
+	}
+	if telegram.IsTaskAction(data) {
+		h.handleTaskActionCallback(ctx, cq)
+		return
+	}
+	if strings.HasPrefix(data, "tasks_page:") {
+		h.handleListPageCallback(ctx, cq)
+		return
+	}
 	// format: accept_task:<TaskID>
 	if strings.HasPrefix(data, "accept_task:") {
 		taskID := strings.TrimPrefix(data, "accept_task:")
@@ -542,7 +611,14 @@ func (h *Handler) handleHelp(chatID int64, threadID int64) {
 		"/help — 查看帮助与功能演示\n" +
 		"/settings — 打开个人设置（绑定 Notion、默认数据库）\n" +
 		"/bind — (群管理员) 绑定当前群的 Notion 数据库\n" +
-		"/todo — (群聊) 快速创建任务，或引用消息后 @Bot 生成任务\n\n" +
+		"/todo — (群聊) 快速创建任务，或引用消息后 @Bot 生成任务\n" +
+		"/todo tpl:bug 标题 — 按模板创建任务（模板可在 Mini App 中管理）\n" +
+		"/list — 我的未完成任务，可加条件筛选，如 /list status:todo due:<7d\n" +
+		"/mine — 指派给我的任务\n" +
+		"/group_tasks — (群聊) 本群的未完成任务\n" +
+		"/done 编号 — 完成列表中的任务\n" +
+		"/assign 编号 @成员 — 指派列表中的任务\n" +
+		"/due 编号 日期 — 设置截止时间，如 /due 3 明天 18:00\n\n" +
 		"更多使用说明：Mini App > 帮助中心。"
 	h.sendMessage(chatID, text, h.buildHelpInlineMarkup(), 0, threadID)
 }
@@ -584,7 +660,7 @@ func (h *Handler) handleTaskCommand(ctx context.Context, msg *Message) {
 	}
 
 	// Clean text: remove bot username if present to avoid assigning bot
-	text := msg.Text
+	text := messageText(msg)
 	if h.botUsername != "" {
 		// Replace @BotUsername with empty string, case insensitive
 		re, err := regexp.Compile(`(?i)@` + regexp.QuoteMeta(h.botUsername) + `\b`)
@@ -682,12 +758,17 @@ func (h *Handler) handleTaskCommand(ctx context.Context, msg *Message) {
 	if msg.ReplyToMessage != nil {
 		input.ReplyToID = msg.ReplyToMessage.MessageID
 	}
+	input.Attachments = messageAttachments(msg)
 	if input.Text == "" {
 		h.sendMessage(msg.Chat.ID, "⚠️ 任务内容不能为空", nil, msg.MessageID, msg.MessageThreadID)
 		return
 	}
 
 	createdTask, missingAssignees, err := h.taskCreator.CreateTask(ctx, input)
+	if errors.Is(err, task.ErrTemplateNotFound) {
+		h.sendMessage(msg.Chat.ID, "⚠️ 未找到该任务模板，可在 Mini App 中创建个人或群模板", nil, msg.MessageID, msg.MessageThreadID)
+		return
+	}
 	if err != nil {
 		h.logger.Error("failed to create task", zap.Error(err))
 		h.sendMessage(msg.Chat.ID, "❌ 创建任务失败，请稍后再试。", nil, msg.MessageID, msg.MessageThreadID)
@@ -756,8 +837,12 @@ func (h *Handler) handleTaskCommand(ctx context.Context, msg *Message) {
 
 	var markup interface{}
 	if isGroupChat {
-		// No buttons in group chats
-		markup = nil
+		// WebApp buttons are not supported in group chats, callback buttons are
+		rows := [][]telegram.InlineKeyboardButton{
+			notification.FollowButtonRow(createdTask.ID),
+			notification.TimerButtonRow(createdTask.ID),
+		}
+		markup = &telegram.InlineKeyboardMarkup{InlineKeyboard: append(rows, notification.TaskActionRows(h.actionSigner, createdTask)...)}
 	} else {
 		// In private chats, we can use WebApp buttons
 		if createdTask.DatabaseID == nil {
@@ -939,7 +1024,7 @@ func (h *Handler) shouldCreateTask(msg *Message) bool {
 		return false
 	}
 
-	text := msg.Text
+	text := messageText(msg)
 	if text == "" {
 		return false
 	}
@@ -1011,21 +1096,23 @@ func (h *Handler) handleForwardedMessage(ctx context.Context, msg *Message) {
 	}
 	meta["source"] = sourceName
 
-	text := msg.Text
-	if text == "" {
-		text = msg.Caption // Fallback to caption
+	text := messageText(msg)
+	attachments := messageAttachments(msg)
+	if text == "" && len(attachments) > 0 {
+		text = msg.Placeholder() // e.g. "[图片]", the user can rename the task later
 	}
 
 	if text == "" {
-		h.sendMessage(msg.Chat.ID, "⚠️ 暂不支持转发非文本消息。", nil, msg.MessageID, msg.MessageThreadID)
+		h.sendMessage(msg.Chat.ID, "⚠️ 暂不支持转发此类消息（支持文本、图片、文件、语音与视频）。", nil, msg.MessageID, msg.MessageThreadID)
 		return
 	}
 
 	input := task.CreateInput{
-		ChatID:    msg.Chat.ID,
-		CreatorID: msg.From.ID,
-		Text:      text,
-		ReplyToID: 0,
+		ChatID:      msg.Chat.ID,
+		CreatorID:   msg.From.ID,
+		Text:        text,
+		ReplyToID:   0,
+		Attachments: attachments,
 	}
 
 	createdTask, err := h.taskCreator.CreatePersonalTask(ctx, input, meta)
@@ -1048,6 +1135,37 @@ func (h *Handler) handleForwardedMessage(ctx context.Context, msg *Message) {
 	h.sendMessage(msg.Chat.ID, replyText, markup, msg.MessageID, msg.MessageThreadID)
 }
 
+// messageText returns the text of a message, or the caption of a media message
+func messageText(msg *Message) string {
+	if msg.Text != "" {
+		return msg.Text
+	}
+	return msg.Caption
+}
+
+// messageEntities returns the formatting of the text messageText returns
+func messageEntities(msg *Message) []MessageEntity {
+	if msg.Text != "" {
+		return msg.Entities
+	}
+	return msg.CaptionEntities
+}
+
+// messageAttachments collects the media of a message and of the message it
+// replies to, e.g. the screenshot a "/todo" answers
+func messageAttachments(msg *Message) []repository.TaskAttachment {
+	var attachments []repository.TaskAttachment
+	if a := msg.Attachment(msg.MessageID, msg.Caption); a != nil {
+		attachments = append(attachments, *a)
+	}
+	if reply := msg.ReplyToMessage; reply != nil {
+		if a := reply.Attachment(reply.MessageID, reply.Caption); a != nil {
+			attachments = append(attachments, *a)
+		}
+	}
+	return attachments
+}
+
 // ensureUser creates a user record if it doesn't exist when they interact with the bot
 func (h *Handler) ensureUser(ctx context.Context, msg *Message) {
 	if h.userRepo == nil || msg == nil || msg.From.ID == 0 {
@@ -1119,7 +1237,16 @@ func (h *Handler) buildShareCard(taskObj *repository.Task) (string, *telegram.In
 		},
 	})
 
-	// Row 2: View Details (if WebApp URL available)
+	// Row 2: Follow Button
+	rows = append(rows, notification.FollowButtonRow(taskObj.ID))
+
+	// Row 3: Timer Buttons
+	rows = append(rows, notification.TimerButtonRow(taskObj.ID))
+
+	// Then: Status, due date and assignee actions
+	rows = append(rows, notification.TaskActionRows(h.actionSigner, taskObj)...)
+
+	// Row 4: View Details (if WebApp URL available)
 	if h.botUsername != "" {
 		// Use "task" alias as configured by user
 		cleanBotName := strings.TrimPrefix(h.botUsername, "@")
@@ -1140,7 +1267,15 @@ func (h *Handler) buildShareCard(taskObj *repository.Task) (string, *telegram.In
 
 	dueDate := "无"
 	if taskObj.DueAt != nil {
-		dueDate = taskObj.DueAt.Format("2006-01-02 15:04")
+		// Cards are read by the whole chat, so timed deadlines name their zone
+		loc := time.UTC
+		if taskObj.Creator != nil {
+			loc = taskObj.Creator.Location()
+		}
+		dueDate = timezone.FormatDue(*taskObj.DueAt, taskObj.AllDay, loc, "2006-01-02")
+		if !taskObj.AllDay {
+			dueDate += " (" + loc.String() + ")"
+		}
 	}
 
 	msgText := fmt.Sprintf(
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
 )
 
 func TestExtractCommand(t *testing.T) {
@@ -123,3 +125,12 @@ func TestCleanBotMention(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatQueryError(t *testing.T) {
+	expr := `status:todo 截止:明天`
+	qe := &repository.QueryError{Token: "截止:明天", Pos: 12, Message: `unknown filter "截止"`}
+
+	text := formatQueryError(expr, qe)
+	assert.Contains(t, text, "unknown filter &quot;截止&quot;")
+	assert.Contains(t, text, "<pre>"+expr+"\n"+strings.Repeat(" ", 12)+"^^^^^</pre>")
+}
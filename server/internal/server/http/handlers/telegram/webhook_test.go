@@ -1,9 +1,12 @@
 package telegram
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/layababa/tg_todo/server/internal/service/task"
 )
 
 func TestShouldCreateTask(t *testing.T) {
@@ -47,6 +50,7 @@ func TestShouldCreateTask(t *testing.T) {
 					MessageID int64  `json:"message_id"`
 					Text      string `json:"text"`
 					Caption   string `json:"caption"`
+					task.MessageMedia
 				}{
 					MessageID: 999,
 				},
@@ -69,6 +73,7 @@ func TestShouldCreateTask(t *testing.T) {
 					MessageID int64  `json:"message_id"`
 					Text      string `json:"text"`
 					Caption   string `json:"caption"`
+					task.MessageMedia
 				}{
 					MessageID: 999,
 				},
@@ -129,3 +134,27 @@ func TestShouldCreateTask(t *testing.T) {
 		})
 	}
 }
+
+func TestMessageAttachmentsFromCaptionedMedia(t *testing.T) {
+	var msg Message
+	err := json.Unmarshal([]byte(`{
+		"message_id": 10,
+		"caption": "/todo 看下这个报错",
+		"photo": [{"file_id": "small", "width": 90}, {"file_id": "large", "width": 1280}],
+		"reply_to_message": {
+			"message_id": 9,
+			"document": {"file_id": "doc", "file_name": "crash.log", "mime_type": "text/plain", "file_size": 512}
+		}
+	}`), &msg)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/todo 看下这个报错", messageText(&msg))
+	attachments := messageAttachments(&msg)
+	if assert.Len(t, attachments, 2) {
+		assert.Equal(t, "large", attachments[0].FileID)
+		assert.Equal(t, "/todo 看下这个报错", attachments[0].Caption)
+		assert.Equal(t, int64(10), attachments[0].TgMessageID)
+		assert.Equal(t, "crash.log", attachments[1].FileName)
+		assert.Equal(t, int64(9), attachments[1].TgMessageID)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/internal/server/http/middleware"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
 type Handler struct {
@@ -34,7 +35,7 @@ func (h *Handler) GetMe(c *gin.Context) {
 }
 
 type UpdateSettingsRequest struct {
-	Timezone          *string `json:"timezone"`
+	Timezone          *string `json:"timezone"` // IANA name, e.g. "Asia/Shanghai"
 	DefaultDatabaseID *string `json:"default_database_id"`
 }
 
@@ -52,7 +53,12 @@ func (h *Handler) UpdateSettings(c *gin.Context) {
 	}
 
 	if req.Timezone != nil {
-		user.Timezone = *req.Timezone
+		tz, err := timezone.Validate(*req.Timezone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": gin.H{"code": "invalid_timezone", "message": "timezone must be an IANA time zone such as Asia/Shanghai"}})
+			return
+		}
+		user.Timezone = tz
 	}
 	if req.DefaultDatabaseID != nil {
 		user.DefaultDatabaseID = req.DefaultDatabaseID
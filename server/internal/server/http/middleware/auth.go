@@ -11,6 +11,7 @@ import (
 	"github.com/layababa/tg_todo/server/internal/models"
 	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/pkg/telegramauth"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
 const (
@@ -106,7 +107,7 @@ func TelegramAuth(botToken string, userRepo repository.UserRepository) gin.Handl
 				TgUsername: initData.User.Username,
 				Name:       buildUserName(initData.User),
 				PhotoURL:   initData.User.PhotoURL,
-				Timezone:   "UTC+0", // Default timezone
+				Timezone:   timezone.Default,
 			}
 			if err := userRepo.Create(c.Request.Context(), user); err != nil {
 				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
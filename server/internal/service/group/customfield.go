@@ -0,0 +1,78 @@
+package group
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+// ErrNoDatabase is returned when a group has no Notion database to read fields from
+var ErrNoDatabase = errors.New("group has no bound database")
+
+// ListCustomFields returns the custom fields of the group's Notion database,
+// as discovered when it was bound or last refreshed. Members only.
+func (s *Service) ListCustomFields(ctx context.Context, userID, groupID string) (repository.CustomFields, error) {
+	isMember, _, err := s.groupRepo.IsMember(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrNotMember
+	}
+	if s.fieldRepo == nil {
+		return repository.CustomFields{}, nil
+	}
+	return s.fieldRepo.ListByGroup(ctx, groupID)
+}
+
+// RefreshCustomFields reads the custom fields again from the group's Notion
+// database, e.g. after columns were added there. Only admins may do so.
+func (s *Service) RefreshCustomFields(ctx context.Context, userID, groupID string) (repository.CustomFields, error) {
+	isAdmin, err := s.checkAdmin(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return nil, ErrNotAdmin
+	}
+	group, err := s.groupRepo.FindByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrGroupNotFound
+	}
+	if group.DatabaseID == nil || *group.DatabaseID == "" {
+		return nil, ErrNoDatabase
+	}
+	return s.discoverCustomFields(ctx, userID, groupID, *group.DatabaseID)
+}
+
+// discoverCustomFields stores the custom fields of database dbID as the
+// group's schema, read with the user's Notion token
+func (s *Service) discoverCustomFields(ctx context.Context, userID, groupID, dbID string) (repository.CustomFields, error) {
+	fields, err := s.notionService.DiscoverCustomFields(ctx, userID, dbID)
+	if err != nil {
+		return nil, err
+	}
+	if s.fieldRepo == nil {
+		return fields, nil
+	}
+	if err := s.fieldRepo.Replace(ctx, groupID, fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// clearCustomFields drops the schema of a group whose database was unbound
+func (s *Service) clearCustomFields(ctx context.Context, groupID string) {
+	if s.fieldRepo == nil {
+		return
+	}
+	if err := s.fieldRepo.Replace(ctx, groupID, nil); err != nil {
+		s.logger.Warn("failed to clear custom fields", zap.String("group_id", groupID), zap.Error(err))
+	}
+}
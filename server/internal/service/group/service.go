@@ -19,23 +19,28 @@ var (
 )
 
 type GroupSummary struct {
-	ID     string                     `json:"id"`
-	Title  string                     `json:"title"`
-	Status models.GroupStatus         `json:"status"`
-	DB     *notionsvc.DatabaseSummary `json:"db"`
-	Role   models.GroupRole           `json:"role"`
+	ID              string                     `json:"id"`
+	Title           string                     `json:"title"`
+	Status          models.GroupStatus         `json:"status"`
+	DB              *notionsvc.DatabaseSummary `json:"db"`
+	Role            models.GroupRole           `json:"role"`
+	AutoArchiveDays int                        `json:"auto_archive_days"`
 }
 
 type Service struct {
 	logger        *zap.Logger
 	groupRepo     repository.GroupRepository
+	workflowRepo  repository.WorkflowRepository
+	fieldRepo     repository.CustomFieldRepository
 	notionService *notionsvc.Service
 }
 
-func NewService(logger *zap.Logger, groupRepo repository.GroupRepository, notionService *notionsvc.Service) *Service {
+func NewService(logger *zap.Logger, groupRepo repository.GroupRepository, workflowRepo repository.WorkflowRepository, fieldRepo repository.CustomFieldRepository, notionService *notionsvc.Service) *Service {
 	return &Service{
 		logger:        logger,
 		groupRepo:     groupRepo,
+		workflowRepo:  workflowRepo,
+		fieldRepo:     fieldRepo,
 		notionService: notionService,
 	}
 }
@@ -66,11 +71,12 @@ func (s *Service) ListGroups(ctx context.Context, userID string) ([]GroupSummary
 		}
 
 		summaries = append(summaries, GroupSummary{
-			ID:     g.ID,
-			Title:  g.Title,
-			Status: g.Status,
-			DB:     dbSummary,
-			Role:   role,
+			ID:              g.ID,
+			Title:           g.Title,
+			Status:          g.Status,
+			DB:              dbSummary,
+			Role:            role,
+			AutoArchiveDays: g.AutoArchiveDays,
 		})
 	}
 
@@ -100,7 +106,12 @@ func (s *Service) InitDatabase(ctx context.Context, userID, groupID, dbID string
 		return nil, ErrNotAdmin
 	}
 
-	return s.notionService.InitializeDatabase(ctx, userID, dbID)
+	// The Status property gets one option per status of the group's workflow
+	workflow, err := s.workflow(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.notionService.InitializeDatabaseWithStatuses(ctx, userID, dbID, workflow)
 }
 
 func (s *Service) BindDatabase(ctx context.Context, userID, groupID, dbID string) (*models.Group, error) {
@@ -139,6 +150,11 @@ func (s *Service) BindDatabase(ctx context.Context, userID, groupID, dbID string
 		return nil, err
 	}
 
+	// Extra columns of the database become the group's custom fields
+	if _, err := s.discoverCustomFields(ctx, userID, groupID, dbID); err != nil {
+		s.logger.Warn("failed to discover custom fields", zap.String("group_id", groupID), zap.Error(err))
+	}
+
 	return group, nil
 }
 
@@ -169,7 +185,33 @@ func (s *Service) UnbindDatabase(ctx context.Context, userID, groupID string) (*
 	if err != nil {
 		return nil, err
 	}
+	s.clearCustomFields(ctx, groupID)
+
+	return group, nil
+}
 
+// UpdateSettings changes the group's task settings. Only admins may do so.
+func (s *Service) UpdateSettings(ctx context.Context, userID, groupID string, autoArchiveDays int) (*models.Group, error) {
+	isAdmin, err := s.checkAdmin(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return nil, ErrNotAdmin
+	}
+
+	group, err := s.groupRepo.FindByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrGroupNotFound
+	}
+
+	group.AutoArchiveDays = autoArchiveDays
+	if err := s.groupRepo.CreateOrUpdate(ctx, group); err != nil {
+		return nil, err
+	}
 	return group, nil
 }
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/dstotijn/go-notion"
 	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
 	notionsvc "github.com/layababa/tg_todo/server/internal/service/notion"
 	pkgnotion "github.com/layababa/tg_todo/server/pkg/notion"
 	"github.com/layababa/tg_todo/server/pkg/security"
@@ -117,6 +118,10 @@ func (m *MockNotionClient) UpdatePage(ctx context.Context, pageID string, params
 	return nil, nil
 }
 
+func (m *MockNotionClient) AppendBlockChildren(ctx context.Context, blockID string, children []notion.Block) error {
+	return nil
+}
+
 // Tests
 func TestBindDatabase_Success(t *testing.T) {
 	logger := zaptest.NewLogger(t)
@@ -128,7 +133,7 @@ func TestBindDatabase_Success(t *testing.T) {
 	notionService := notionsvc.NewService(logger, mockUserRepo, encKey)
 	notionService.ClientFactory = func(token string) pkgnotion.Client { return mockNotionClient }
 
-	service := NewService(logger, mockGroupRepo, notionService)
+	service := NewService(logger, mockGroupRepo, nil, nil, notionService)
 
 	// Setup: Admin check
 	mockGroupRepo.On("IsMember", mock.Anything, "user1", "group1").Return(true, models.GroupRoleAdmin, nil)
@@ -171,7 +176,7 @@ func TestBindDatabase_Success(t *testing.T) {
 func TestBindDatabase_NotAdmin(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	mockGroupRepo := new(MockGroupRepo)
-	service := NewService(logger, mockGroupRepo, nil)
+	service := NewService(logger, mockGroupRepo, nil, nil, nil)
 
 	// Setup: Member but not Admin
 	mockGroupRepo.On("IsMember", mock.Anything, "user1", "group1").Return(true, models.GroupRoleMember, nil)
@@ -183,7 +188,7 @@ func TestBindDatabase_NotAdmin(t *testing.T) {
 func TestListGroups_ReturnsSummaries(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	mockGroupRepo := new(MockGroupRepo)
-	service := NewService(logger, mockGroupRepo, nil)
+	service := NewService(logger, mockGroupRepo, nil, nil, nil)
 
 	group := models.Group{
 		ID:           "group1",
@@ -215,7 +220,7 @@ func TestValidateDatabase_ChecksAdmin(t *testing.T) {
 	encKey := "12345678901234567890123456789012"
 	notionService := notionsvc.NewService(logger, mockUserRepo, encKey)
 	notionService.ClientFactory = func(string) pkgnotion.Client { return mockNotionClient }
-	service := NewService(logger, mockGroupRepo, notionService)
+	service := NewService(logger, mockGroupRepo, nil, nil, notionService)
 
 	mockGroupRepo.On("IsMember", mock.Anything, "user1", "group1").Return(true, models.GroupRoleAdmin, nil)
 	tokenEnc, _ := security.Encrypt("token", encKey)
@@ -227,6 +232,8 @@ func TestValidateDatabase_ChecksAdmin(t *testing.T) {
 			"Status":   notion.DatabaseProperty{Type: notion.DBPropTypeStatus},
 			"Assignee": notion.DatabaseProperty{Type: notion.DBPropTypePeople},
 			"Date":     notion.DatabaseProperty{Type: notion.DBPropTypeDate},
+			"Priority": notion.DatabaseProperty{Type: notion.DBPropTypeSelect},
+			"Labels":   notion.DatabaseProperty{Type: notion.DBPropTypeMultiSelect},
 		},
 	}, nil)
 
@@ -244,7 +251,7 @@ func TestInitDatabase_RequiresAdmin(t *testing.T) {
 	encKey := "12345678901234567890123456789012"
 	notionService := notionsvc.NewService(logger, mockUserRepo, encKey)
 	notionService.ClientFactory = func(string) pkgnotion.Client { return mockNotionClient }
-	service := NewService(logger, mockGroupRepo, notionService)
+	service := NewService(logger, mockGroupRepo, nil, nil, notionService)
 
 	mockGroupRepo.On("IsMember", mock.Anything, "user1", "group1").Return(false, (*models.GroupRole)(nil), nil)
 
@@ -255,3 +262,50 @@ func TestInitDatabase_RequiresAdmin(t *testing.T) {
 func ptrString(s string) *string {
 	return &s
 }
+
+func TestUpdateSettings_SetsAutoArchiveDays(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockGroupRepo := new(MockGroupRepo)
+	service := NewService(logger, mockGroupRepo, nil, nil, nil)
+
+	mockGroupRepo.On("IsMember", mock.Anything, "user1", "group1").Return(true, models.GroupRoleAdmin, nil)
+	mockGroupRepo.On("FindByID", mock.Anything, "group1").Return(&models.Group{ID: "group1"}, nil)
+	mockGroupRepo.On("CreateOrUpdate", mock.Anything, mock.MatchedBy(func(g *models.Group) bool {
+		return g.ID == "group1" && g.AutoArchiveDays == 14
+	})).Return(nil)
+
+	group, err := service.UpdateSettings(context.Background(), "user1", "group1", 14)
+	assert.NoError(t, err)
+	assert.Equal(t, 14, group.AutoArchiveDays)
+	mockGroupRepo.AssertExpectations(t)
+
+	mockGroupRepo.On("IsMember", mock.Anything, "user2", "group1").Return(true, models.GroupRoleMember, nil)
+	_, err = service.UpdateSettings(context.Background(), "user2", "group1", 0)
+	assert.ErrorIs(t, err, ErrNotAdmin)
+}
+
+func TestBuildWorkflowValidates(t *testing.T) {
+	valid := []StatusInput{
+		{Name: "Backlog", Category: repository.StatusCategoryTodo, Next: []string{"review"}},
+		{Name: " Review ", Category: repository.StatusCategoryActive},
+		{Name: "Shipped", Category: repository.StatusCategoryDone},
+	}
+	workflow, err := buildWorkflow(valid)
+	assert.NoError(t, err)
+	assert.Len(t, workflow, 3)
+	assert.Equal(t, repository.TaskStatus("Review"), workflow[1].Name)
+	assert.Equal(t, []string{"Review"}, []string(workflow[0].Next))
+
+	tests := map[string][]StatusInput{
+		"duplicate":    {valid[0], valid[1], valid[2], {Name: "backlog", Category: repository.StatusCategoryTodo}},
+		"no done":      {valid[0], valid[1]},
+		"bad category": {valid[0], valid[1], {Name: "Shipped", Category: "closed"}},
+		"comma":        {valid[0], valid[1], {Name: "Done, really", Category: repository.StatusCategoryDone}},
+		"unknown next": {valid[0], {Name: "Review", Category: repository.StatusCategoryActive, Next: []string{"QA"}}, valid[2]},
+		"empty name":   {valid[0], valid[1], valid[2], {Name: " ", Category: repository.StatusCategoryTodo}},
+	}
+	for name, inputs := range tests {
+		_, err := buildWorkflow(inputs)
+		assert.ErrorIs(t, err, ErrInvalidWorkflow, name)
+	}
+}
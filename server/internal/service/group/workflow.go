@@ -0,0 +1,162 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"gorm.io/datatypes"
+)
+
+var (
+	ErrNotMember       = errors.New("user is not a member of this group")
+	ErrInvalidWorkflow = errors.New("invalid workflow")
+)
+
+const (
+	maxWorkflowStatuses = 20
+	// maxStatusNameLength is the longest option name Notion accepts
+	maxStatusNameLength = 100
+)
+
+// StatusInput is one status of a workflow, in display order
+type StatusInput struct {
+	Name     string                    `json:"name"`
+	Category repository.StatusCategory `json:"category"`
+	Next     []string                  `json:"next"` // Allowed next statuses; empty allows any
+}
+
+// ListStatuses returns the group's workflow, or the default one when the
+// group has not defined its own. Members only.
+func (s *Service) ListStatuses(ctx context.Context, userID, groupID string) (repository.Workflow, error) {
+	isMember, _, err := s.groupRepo.IsMember(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrNotMember
+	}
+	return s.workflow(ctx, groupID)
+}
+
+// UpdateStatuses replaces the group's workflow; an empty list restores the
+// default one. Tasks in removed statuses, including personal tasks in the
+// group's Notion database, move to the first status of the same category.
+// Only admins may do so.
+func (s *Service) UpdateStatuses(ctx context.Context, userID, groupID string, inputs []StatusInput) (repository.Workflow, error) {
+	isAdmin, err := s.checkAdmin(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return nil, ErrNotAdmin
+	}
+	group, err := s.groupRepo.FindByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrGroupNotFound
+	}
+
+	statuses, err := buildWorkflow(inputs)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.workflow(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	next := statuses
+	if len(next) == 0 {
+		next = repository.DefaultWorkflow
+	}
+
+	remap := make(map[repository.TaskStatus]repository.TaskStatus)
+	for _, old := range current {
+		st, ok := next.Find(string(old.Name))
+		if !ok {
+			st, _ = next.First(old.Category)
+		}
+		if st.Name != old.Name {
+			remap[old.Name] = st.Name
+		}
+	}
+
+	if err := s.workflowRepo.Replace(ctx, groupID, statuses, remap); err != nil {
+		return nil, err
+	}
+	return s.workflow(ctx, groupID)
+}
+
+// workflow returns the group's statuses, falling back to the default workflow
+func (s *Service) workflow(ctx context.Context, groupID string) (repository.Workflow, error) {
+	if s.workflowRepo == nil {
+		return repository.DefaultWorkflow, nil
+	}
+	statuses, err := s.workflowRepo.ListByGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return repository.DefaultWorkflow, nil
+	}
+	return statuses, nil
+}
+
+// buildWorkflow validates inputs: unique names that Notion accepts as options,
+// known categories with at least one status each, and transitions between
+// listed statuses
+func buildWorkflow(inputs []StatusInput) (repository.Workflow, error) {
+	if len(inputs) == 0 {
+		return repository.Workflow{}, nil
+	}
+	if len(inputs) > maxWorkflowStatuses {
+		return nil, fmt.Errorf("%w: at most %d statuses", ErrInvalidWorkflow, maxWorkflowStatuses)
+	}
+
+	statuses := make(repository.Workflow, 0, len(inputs))
+	categories := make(map[repository.StatusCategory]bool)
+	for _, in := range inputs {
+		name := strings.TrimSpace(in.Name)
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("%w: status name is required", ErrInvalidWorkflow)
+		case utf8.RuneCountInString(name) > maxStatusNameLength:
+			return nil, fmt.Errorf("%w: status %q is too long", ErrInvalidWorkflow, name)
+		case strings.Contains(name, ","):
+			return nil, fmt.Errorf("%w: status %q contains a comma", ErrInvalidWorkflow, name)
+		case !in.Category.IsValid():
+			return nil, fmt.Errorf("%w: unknown category %q", ErrInvalidWorkflow, in.Category)
+		}
+		if _, ok := statuses.Find(name); ok {
+			return nil, fmt.Errorf("%w: duplicate status %q", ErrInvalidWorkflow, name)
+		}
+		categories[in.Category] = true
+		statuses = append(statuses, repository.WorkflowStatus{
+			Name:     repository.TaskStatus(name),
+			Category: in.Category,
+		})
+	}
+	for _, c := range []repository.StatusCategory{repository.StatusCategoryTodo, repository.StatusCategoryActive, repository.StatusCategoryDone} {
+		if !categories[c] {
+			return nil, fmt.Errorf("%w: no %s status", ErrInvalidWorkflow, c)
+		}
+	}
+
+	for i, in := range inputs {
+		next := make([]string, 0, len(in.Next))
+		for _, name := range in.Next {
+			st, ok := statuses.Find(name)
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown next status %q", ErrInvalidWorkflow, name)
+			}
+			next = append(next, string(st.Name))
+		}
+		statuses[i].Next = datatypes.NewJSONSlice(next)
+	}
+	return statuses, nil
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/layababa/tg_todo/server/internal/models"
 	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/internal/service/telegram"
 	"go.uber.org/zap"
@@ -24,9 +25,10 @@ type Service struct {
 	tgClient     TelegramClient
 	botName      string
 	appShortName string
+	signer       *telegram.CallbackSigner // Signs the task action buttons; none when nil
 }
 
-func NewService(logger *zap.Logger, repo repository.TaskRepository, userRepo repository.UserRepository, tgClient TelegramClient, botName, appShortName string) *Service {
+func NewService(logger *zap.Logger, repo repository.TaskRepository, userRepo repository.UserRepository, tgClient TelegramClient, botName, appShortName string, signer *telegram.CallbackSigner) *Service {
 	return &Service{
 		logger:       logger,
 		repo:         repo,
@@ -34,11 +36,71 @@ func NewService(logger *zap.Logger, repo repository.TaskRepository, userRepo rep
 		tgClient:     tgClient,
 		botName:      botName,
 		appShortName: appShortName,
+		signer:       signer,
 	}
 }
 
+// taskMarkup is the keyboard of notifications about a single task: the link
+// to the Mini App followed by the task actions
+func (s *Service) taskMarkup(task *repository.Task) telegram.InlineKeyboardMarkup {
+	markup := BuildTaskMarkup(task.ID, s.botName, s.appShortName)
+	markup.InlineKeyboard = append(markup.InlineKeyboard, TaskActionRows(s.signer, task)...)
+	return markup
+}
+
 // Notify dispatches a notification for an event
 func (s *Service) Notify(ctx context.Context, event EventType, task *repository.Task, actorID string, comment *repository.TaskComment) {
+	s.notify(ctx, event, task, actorID, comment, nil)
+}
+
+// NotifyComment dispatches a new comment. The users @mentioned in it get a
+// mention notification instead of the comment notification the others involved
+// in the task get.
+func (s *Service) NotifyComment(ctx context.Context, task *repository.Task, actorID string, comment *repository.TaskComment, mentionedIDs []string) {
+	s.NotifyMentions(ctx, task, actorID, comment, mentionedIDs)
+	skip := make(map[string]bool, len(mentionedIDs))
+	for _, userID := range mentionedIDs {
+		skip[userID] = true
+	}
+	s.notify(ctx, EventCommentAdded, task, actorID, comment, skip)
+}
+
+// NotifyMentions tells the users @mentioned in a comment about it
+func (s *Service) NotifyMentions(ctx context.Context, task *repository.Task, actorID string, comment *repository.TaskComment, mentionedIDs []string) {
+	if len(mentionedIDs) == 0 {
+		return
+	}
+	data := TemplateData{Event: EventCommentMentioned, Task: task, Comment: comment, BotName: s.botName, AppShortName: s.appShortName}
+	if actorID != "" {
+		if act, err := s.userRepo.FindByID(ctx, actorID); err == nil {
+			data.Actor = act
+		}
+	}
+	msg := formatMessage(data)
+
+	for _, userID := range mentionedIDs {
+		if userID == actorID {
+			continue
+		}
+		user, err := s.userRepo.FindByID(ctx, userID)
+		if err != nil || user == nil || user.TgID == 0 {
+			continue
+		}
+		markup := s.taskMarkup(task)
+		if markup.InlineKeyboard != nil {
+			err = s.tgClient.SendMessageWithButtons(user.TgID, msg, markup)
+		} else {
+			err = s.tgClient.SendMessage(user.TgID, msg)
+		}
+		if err != nil {
+			s.logger.Error("failed to send mention notification", zap.Int64("chat_id", user.TgID), zap.Error(err))
+		}
+	}
+}
+
+// notify dispatches an event to everyone involved in the task except the
+// actor and the users in skip
+func (s *Service) notify(ctx context.Context, event EventType, task *repository.Task, actorID string, comment *repository.TaskComment, skip map[string]bool) {
 	// 1. Identify recipients
 	recipients := make(map[string]bool) // Set of UserIDs
 
@@ -54,6 +116,15 @@ func (s *Service) Notify(ctx context.Context, event EventType, task *repository.
 		}
 	}
 
+	// Add Watchers
+	if notifiesWatchers(event) {
+		for _, userID := range s.watcherIDs(ctx, task) {
+			if userID != actorID {
+				recipients[userID] = true
+			}
+		}
+	}
+
 	// Add Parent Comment Author (for replies)
 	if event == EventCommentAdded && comment != nil && comment.ParentID != nil {
 		parentComment, err := s.repo.GetCommentByID(ctx, *comment.ParentID)
@@ -62,6 +133,10 @@ func (s *Service) Notify(ctx context.Context, event EventType, task *repository.
 		}
 	}
 
+	for userID := range skip {
+		delete(recipients, userID)
+	}
+
 	// Don't notify anyone if empty (but continue to Group Sync)
 	// if len(recipients) == 0 {
 	// 	return
@@ -84,10 +159,7 @@ func (s *Service) Notify(ctx context.Context, event EventType, task *repository.
 		}
 	}
 
-	// 3. Render Message
-	msg := formatMessage(data)
-
-	// 4. Send to each recipient
+	// 3. Send to each recipient, with dates in their time zone
 	for userID := range recipients {
 		user, err := s.userRepo.FindByID(ctx, userID)
 		if err != nil {
@@ -98,8 +170,13 @@ func (s *Service) Notify(ctx context.Context, event EventType, task *repository.
 		if user.TgID == 0 {
 			continue
 		}
+		data.Location = user.Location()
+		msg := formatMessage(data)
 
-		markup := BuildTaskMarkup(task.ID, s.botName, s.appShortName)
+		markup := s.taskMarkup(task)
+		if event == EventStatusChanged && task.IsDone() && !task.Archived {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, ArchiveButtonRow(task.ID, false))
+		}
 		s.logger.Info("sending notification",
 			zap.Int64("chat_id", user.TgID),
 			zap.String("url", markup.InlineKeyboard[0][0].URL))
@@ -181,9 +258,96 @@ func (s *Service) Notify(ctx context.Context, event EventType, task *repository.
 	}
 }
 
+// NotifyBatch sends a single message per recipient for a bulk operation
+// instead of one message per task. Recipients are the creators and assignees
+// of the tasks, except the actor.
+func (s *Service) NotifyBatch(ctx context.Context, event EventType, tasks []*repository.Task, actorID string) {
+	byRecipient := make(map[string][]*repository.Task)
+	var order []string
+	add := func(userID string, task *repository.Task) {
+		if userID == "" || userID == actorID {
+			return
+		}
+		list := byRecipient[userID]
+		if len(list) > 0 && list[len(list)-1] == task {
+			return // Creator is also an assignee
+		}
+		if list == nil {
+			order = append(order, userID)
+		}
+		byRecipient[userID] = append(list, task)
+	}
+	for _, task := range tasks {
+		if task.CreatorID != nil {
+			add(*task.CreatorID, task)
+		}
+		for _, assignee := range task.Assignees {
+			add(assignee.ID, task)
+		}
+		if notifiesWatchers(event) {
+			for _, userID := range s.watcherIDs(ctx, task) {
+				add(userID, task)
+			}
+		}
+	}
+
+	var actor *models.User
+	if actorID != "" {
+		if act, err := s.userRepo.FindByID(ctx, actorID); err == nil {
+			actor = act
+		}
+	}
+
+	for _, userID := range order {
+		user, err := s.userRepo.FindByID(ctx, userID)
+		if err != nil || user == nil || user.TgID == 0 {
+			continue
+		}
+		list := byRecipient[userID]
+		msg := formatBatchMessage(event, list, actor, user.Location())
+
+		markup := BuildHomeMarkup(s.botName)
+		if len(list) == 1 && event != EventTaskDeleted {
+			markup = s.taskMarkup(list[0])
+		}
+		if markup.InlineKeyboard != nil {
+			err = s.tgClient.SendMessageWithButtons(user.TgID, msg, markup)
+		} else {
+			err = s.tgClient.SendMessage(user.TgID, msg)
+		}
+		if err != nil {
+			s.logger.Error("failed to send batch notification", zap.Int64("chat_id", user.TgID), zap.Error(err))
+		}
+	}
+
+	s.logger.Info("Batch notification dispatched",
+		zap.String("event", string(event)),
+		zap.Int("task_count", len(tasks)),
+		zap.Int("recipient_count", len(order)))
+}
+
+// notifiesWatchers reports whether watchers are told about an event. They
+// follow progress (status, comments, due date), not the task's bookkeeping.
+func notifiesWatchers(event EventType) bool {
+	switch event {
+	case EventStatusChanged, EventCommentAdded, EventDueChanged:
+		return true
+	}
+	return false
+}
+
+func (s *Service) watcherIDs(ctx context.Context, task *repository.Task) []string {
+	ids, err := s.repo.ListWatcherIDs(ctx, task.ID)
+	if err != nil {
+		s.logger.Warn("failed to list task watchers", zap.String("task_id", task.ID), zap.Error(err))
+		return nil
+	}
+	return ids
+}
+
 // NotifyReminder sends differentiated reminders to creator and assignees
 func (s *Service) NotifyReminder(ctx context.Context, event EventType, task *repository.Task) {
-	markup := BuildTaskMarkup(task.ID, s.botName, s.appShortName)
+	markup := s.taskMarkup(task)
 	s.logger.Info("preparing reminders", zap.String("url", markup.InlineKeyboard[0][0].URL))
 
 	// 1. Creator
@@ -260,7 +424,7 @@ func (s *Service) NotifyAssigneeChange(ctx context.Context, task *repository.Tas
 		AppShortName: s.appShortName,
 	})
 
-	markup := BuildTaskMarkup(task.ID, s.botName, s.appShortName)
+	markup := s.taskMarkup(task)
 
 	if markup.InlineKeyboard != nil {
 		_ = s.tgClient.SendMessageWithButtons(creator.TgID, msg, markup)
@@ -268,3 +432,37 @@ func (s *Service) NotifyAssigneeChange(ctx context.Context, task *repository.Tas
 		_ = s.tgClient.SendMessage(creator.TgID, msg)
 	}
 }
+
+// NotifyUnblocked tells the assignees of a task that its last blocker is Done
+func (s *Service) NotifyUnblocked(ctx context.Context, task *repository.Task, blocker *repository.Task) {
+	contextInfo := ""
+	if blocker != nil {
+		contextInfo = blocker.Title
+	}
+
+	msg := formatMessage(TemplateData{
+		Event:         EventTaskUnblocked,
+		Task:          task,
+		RecipientRole: RoleAssignee,
+		ContextInfo:   contextInfo,
+		BotName:       s.botName,
+		AppShortName:  s.appShortName,
+	})
+	markup := s.taskMarkup(task)
+
+	for _, assignee := range task.Assignees {
+		user, err := s.userRepo.FindByID(ctx, assignee.ID)
+		if err != nil || user == nil || user.TgID == 0 {
+			continue
+		}
+		if markup.InlineKeyboard != nil {
+			_ = s.tgClient.SendMessageWithButtons(user.TgID, msg, markup)
+		} else {
+			_ = s.tgClient.SendMessage(user.TgID, msg)
+		}
+	}
+
+	s.logger.Info("Unblocked notification dispatched",
+		zap.String("task_id", task.ID),
+		zap.Int("recipient_count", len(task.Assignees)))
+}
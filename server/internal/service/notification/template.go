@@ -3,10 +3,13 @@ package notification
 import (
 	"fmt"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/layababa/tg_todo/server/internal/models"
 	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/internal/service/telegram"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
 type EventType string
@@ -16,11 +19,19 @@ const (
 	EventTaskAssigned        EventType = "task_assigned"
 	EventStatusChanged       EventType = "status_changed"
 	EventCommentAdded        EventType = "comment_added"
+	EventCommentMentioned    EventType = "comment_mentioned"
 	EventTaskAssigneeChanged EventType = "assignee_changed" // New Event
 	EventReminder1h          EventType = "reminder_1h"
 	EventReminderDue         EventType = "reminder_due"
+	EventTaskUnblocked       EventType = "task_unblocked"
+	EventDueChanged          EventType = "due_changed"
+	EventTaskArchived        EventType = "task_archived"
+	EventTaskDeleted         EventType = "task_deleted"
 )
 
+// batchListLimit is the number of tasks listed in a batch notification
+const batchListLimit = 10
+
 type RecipientRole string
 
 const (
@@ -33,11 +44,12 @@ type TemplateData struct {
 	Event         EventType
 	Task          *repository.Task
 	Comment       *repository.TaskComment
-	Actor         *models.User  // Who performed the action
-	RecipientRole RecipientRole // Role of the person receiving the notification
-	BotName       string        // Telegram Bot Username
-	AppShortName  string        // Mini App Short Name (from BotFather)
-	ContextInfo   string        // Generic info (e.g. "From X to Y")
+	Actor         *models.User   // Who performed the action
+	RecipientRole RecipientRole  // Role of the person receiving the notification
+	BotName       string         // Telegram Bot Username
+	AppShortName  string         // Mini App Short Name (from BotFather)
+	ContextInfo   string         // Generic info (e.g. "From X to Y")
+	Location      *time.Location // Recipient's time zone for dates; UTC when nil
 }
 
 // formatMessage formats the notification message based on event type (HTML format)
@@ -75,7 +87,7 @@ func formatMessage(data TemplateData) string {
 		}
 
 	case EventStatusChanged:
-		statusText := formatStatusChinese(data.Task.Status)
+		statusText := formatStatusChinese(data.Task)
 		sb.WriteString("🔄 <b>任务状态已更新</b>\n\n")
 		sb.WriteString(fmt.Sprintf("<b>任务:</b> %s\n", taskTitle))
 		sb.WriteString(fmt.Sprintf("<b>新状态:</b> %s\n", statusText))
@@ -90,15 +102,25 @@ func formatMessage(data TemplateData) string {
 			sb.WriteString(fmt.Sprintf("评论者: %s\n", actorName))
 		}
 		if data.Comment != nil {
-			content := data.Comment.Content
-			if len(content) > 200 {
-				content = content[:197] + "..."
-			}
-			sb.WriteString(fmt.Sprintf("\n%s\n", escapeHTML(content)))
+			sb.WriteString(fmt.Sprintf("\n%s\n", escapeHTML(truncateRunes(data.Comment.Content, maxCommentPreview))))
+		}
+
+	case EventCommentMentioned:
+		sb.WriteString("📣 <b>有人在评论中提到了你</b>\n\n")
+		sb.WriteString(fmt.Sprintf("任务: %s\n", taskTitle))
+		if actorName != "" {
+			sb.WriteString(fmt.Sprintf("评论者: %s\n", actorName))
+		}
+		if data.Comment != nil {
+			sb.WriteString(fmt.Sprintf("\n%s\n", escapeHTML(truncateRunes(data.Comment.Content, maxCommentPreview))))
 		}
 
 	case EventReminder1h:
-		sb.WriteString("⏰ <b>任务即将到期</b> (1小时后)\n\n")
+		if data.Task.AllDay {
+			sb.WriteString("📅 <b>任务今天到期</b>\n\n")
+		} else {
+			sb.WriteString("⏰ <b>任务即将到期</b> (1小时后)\n\n")
+		}
 		sb.WriteString(fmt.Sprintf("<b>任务:</b> %s\n", taskTitle))
 		if data.RecipientRole == RoleCreator {
 			sb.WriteString("\n💡 请记得及时验收该任务。")
@@ -107,13 +129,37 @@ func formatMessage(data TemplateData) string {
 		}
 
 	case EventReminderDue:
-		sb.WriteString("🚨 <b>任务已到达截止时间</b>\n\n")
+		if data.Task.AllDay {
+			sb.WriteString("🚨 <b>任务已过截止日期</b>\n\n")
+		} else {
+			sb.WriteString("🚨 <b>任务已到达截止时间</b>\n\n")
+		}
 		sb.WriteString(fmt.Sprintf("<b>任务:</b> %s\n", taskTitle))
 		if data.RecipientRole == RoleCreator {
 			sb.WriteString("\n💡 该任务已到期，请检查进度或进行验收。")
 		} else {
 			sb.WriteString("\n💡 该任务已到期，请尽快完成并更新状态。")
 		}
+
+	case EventDueChanged:
+		sb.WriteString("📅 <b>截止时间已变更</b>\n\n")
+		sb.WriteString(fmt.Sprintf("<b>任务:</b> %s\n", taskTitle))
+		if data.Task.DueAt != nil {
+			sb.WriteString(fmt.Sprintf("<b>新截止时间:</b> %s\n", timezone.FormatDue(*data.Task.DueAt, data.Task.AllDay, locationOrUTC(data.Location), "2006-01-02")))
+		} else {
+			sb.WriteString("<b>新截止时间:</b> 无\n")
+		}
+		if actorName != "" {
+			sb.WriteString(fmt.Sprintf("<b>操作人:</b> %s\n", actorName))
+		}
+
+	case EventTaskUnblocked:
+		sb.WriteString("🔓 <b>任务已解除阻塞</b>\n\n")
+		sb.WriteString(fmt.Sprintf("<b>任务:</b> %s\n", taskTitle))
+		if data.ContextInfo != "" {
+			sb.WriteString(fmt.Sprintf("<b>前置任务:</b> %s 已完成\n", escapeHTML(data.ContextInfo)))
+		}
+		sb.WriteString("\n💡 所有前置任务均已完成，可以开始处理了。")
 	}
 
 	return sb.String()
@@ -145,18 +191,150 @@ func BuildTaskMarkup(taskID, botName, appShortName string) telegram.InlineKeyboa
 	}
 }
 
-// formatStatusChinese converts task status to Chinese
-func formatStatusChinese(status repository.TaskStatus) string {
-	switch status {
+// formatBatchMessage formats one notification covering several tasks changed
+// by the same bulk operation (HTML format)
+func formatBatchMessage(event EventType, tasks []*repository.Task, actor *models.User, loc *time.Location) string {
+	var sb strings.Builder
+	n := len(tasks)
+
+	switch event {
+	case EventStatusChanged:
+		sb.WriteString(fmt.Sprintf("🔄 <b>%d 个任务状态已更新</b>\n\n", n))
+	case EventTaskAssigned:
+		sb.WriteString(fmt.Sprintf("👤 <b>%d 个任务负责人已变更</b>\n\n", n))
+	case EventDueChanged:
+		sb.WriteString(fmt.Sprintf("📅 <b>%d 个任务截止时间已调整</b>\n\n", n))
+	case EventTaskArchived:
+		sb.WriteString(fmt.Sprintf("📦 <b>%d 个任务已归档</b>\n\n", n))
+	case EventTaskDeleted:
+		sb.WriteString(fmt.Sprintf("🗑 <b>%d 个任务已删除</b>\n\n", n))
+	default:
+		sb.WriteString(fmt.Sprintf("🔔 <b>%d 个任务已更新</b>\n\n", n))
+	}
+
+	for i, t := range tasks {
+		if i == batchListLimit {
+			sb.WriteString(fmt.Sprintf("…以及另外 %d 个任务\n", n-batchListLimit))
+			break
+		}
+		line := "• " + escapeHTML(t.Title)
+		switch event {
+		case EventStatusChanged:
+			line += " → " + formatStatusChinese(t)
+		case EventTaskAssigned:
+			if len(t.Assignees) > 0 {
+				line += " → " + escapeHTML(t.Assignees[0].Name)
+			}
+		case EventDueChanged:
+			if t.DueAt != nil {
+				line += " → " + timezone.FormatDue(*t.DueAt, t.AllDay, locationOrUTC(loc), "01-02")
+			}
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	if actor != nil && actor.Name != "" {
+		sb.WriteString(fmt.Sprintf("\n<b>操作人:</b> %s\n", escapeHTML(actor.Name)))
+	}
+	if event == EventTaskDeleted {
+		sb.WriteString("\n💡 可在回收站中恢复。")
+	}
+	return sb.String()
+}
+
+// BuildHomeMarkup creates the inline keyboard opening the Mini App home page
+func BuildHomeMarkup(botName string) telegram.InlineKeyboardMarkup {
+	if botName == "" {
+		return telegram.InlineKeyboardMarkup{}
+	}
+	url := fmt.Sprintf("https://t.me/%s?startapp", strings.TrimPrefix(botName, "@"))
+	return telegram.InlineKeyboardMarkup{
+		InlineKeyboard: [][]telegram.InlineKeyboardButton{{{Text: "🏠 查看所有待办", URL: url}}},
+	}
+}
+
+// FollowButtonRow returns the button row to follow or unfollow a task. It is
+// a toggle so it can sit on cards shared with a whole group.
+func FollowButtonRow(taskID string) []telegram.InlineKeyboardButton {
+	return []telegram.InlineKeyboardButton{{Text: "👀 关注", CallbackData: "follow_task:" + taskID}}
+}
+
+// TimerButtonRow returns the buttons starting and stopping the presser's
+// timer on a task
+func TimerButtonRow(taskID string) []telegram.InlineKeyboardButton {
+	return []telegram.InlineKeyboardButton{
+		{Text: "⏱ 开始计时", CallbackData: "start_timer:" + taskID},
+		{Text: "⏹ 停止计时", CallbackData: "stop_timer:" + taskID},
+	}
+}
+
+// maxCommentPreview is the number of characters of a comment notifications show
+const maxCommentPreview = 200
+
+// truncateRunes shortens s to at most max characters, ending it with "..."
+// when cut. It counts runes so multi-byte characters are never split.
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-3]) + "..."
+}
+
+// TaskActionRows returns the bot buttons acting on a task in its current
+// state: start or finish it, snooze a reminder that fired or push its due
+// date back a day, and take it over. Finished and archived tasks get none.
+func TaskActionRows(signer *telegram.CallbackSigner, task *repository.Task) [][]telegram.InlineKeyboardButton {
+	if signer == nil || task.IsDone() || task.Archived || task.DeletedAt.Valid {
+		return nil
+	}
+	if signer.Sign(telegram.TaskActionDone, task.ID) == "" {
+		return nil // Not a UUID
+	}
+	button := func(text string, action telegram.TaskAction) telegram.InlineKeyboardButton {
+		return telegram.InlineKeyboardButton{Text: text, CallbackData: signer.Sign(action, task.ID)}
+	}
+
+	var status []telegram.InlineKeyboardButton
+	if task.Category() != repository.StatusCategoryActive {
+		status = append(status, button("▶️ 开始处理", telegram.TaskActionStart))
+	}
+	rows := [][]telegram.InlineKeyboardButton{append(status, button("✅ 完成", telegram.TaskActionDone))}
+	if task.DueAt != nil {
+		var due []telegram.InlineKeyboardButton
+		// Snoozing repeats a reminder, so it is offered once one has fired
+		if task.Reminder1hSent || task.ReminderDueSent {
+			due = append(due, button("⏰ 1小时后提醒", telegram.TaskActionSnooze))
+		}
+		rows = append(rows, append(due, button("📅 延后一天", telegram.TaskActionPostpone)))
+	}
+	rows = append(rows, []telegram.InlineKeyboardButton{button("🙋 改派给我", telegram.TaskActionClaim)})
+	return rows
+}
+
+// ArchiveButtonRow creates the bot button that archives a finished task, or
+// unarchives it once archived
+func ArchiveButtonRow(taskID string, archived bool) []telegram.InlineKeyboardButton {
+	if archived {
+		return []telegram.InlineKeyboardButton{{Text: "↩️ 取消归档", CallbackData: "unarchive_task:" + taskID}}
+	}
+	return []telegram.InlineKeyboardButton{{Text: "📦 归档", CallbackData: "archive_task:" + taskID}}
+}
+
+// formatStatusChinese converts the default statuses to Chinese; statuses of
+// group workflows keep their name
+func formatStatusChinese(task *repository.Task) string {
+	switch task.Status {
 	case repository.TaskStatusToDo:
 		return "待办"
 	case repository.TaskStatusInProgress:
 		return "进行中"
 	case repository.TaskStatusDone:
 		return "已完成 ✅"
-	default:
-		return string(status)
 	}
+	if task.IsDone() {
+		return string(task.Status) + " ✅"
+	}
+	return string(task.Status)
 }
 
 // escapeHTML escapes special characters for Telegram HTML format
@@ -168,3 +346,10 @@ func escapeHTML(text string) string {
 	)
 	return replacer.Replace(text)
 }
+
+func locationOrUTC(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
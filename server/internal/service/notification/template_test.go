@@ -0,0 +1,26 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	if got := truncateRunes("短评论", maxCommentPreview); got != "短评论" {
+		t.Errorf("short comment changed to %q", got)
+	}
+
+	// 300 Chinese characters are 900 bytes; cutting bytes would split one
+	long := strings.Repeat("评", 300)
+	got := truncateRunes(long, maxCommentPreview)
+	if !utf8.ValidString(got) {
+		t.Fatalf("truncated comment is not valid UTF-8: %q", got)
+	}
+	if n := utf8.RuneCountInString(got); n != maxCommentPreview {
+		t.Errorf("truncated comment has %d characters, want %d", n, maxCommentPreview)
+	}
+	if !strings.HasSuffix(got, "评...") {
+		t.Errorf("truncated comment = %q, want it to end with ...", got)
+	}
+}
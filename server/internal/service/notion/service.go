@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/dstotijn/go-notion"
 	"github.com/layababa/tg_todo/server/internal/repository"
 	pkgnotion "github.com/layababa/tg_todo/server/pkg/notion"
 	"github.com/layababa/tg_todo/server/pkg/security"
 	"go.uber.org/zap"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -131,6 +134,7 @@ func (s *Service) ValidateDatabase(ctx context.Context, userID string, dbID stri
 		title = db.Title[0].PlainText
 	}
 
+	// Priority and Labels are optional and created by InitializeDatabase
 	required := []string{"Status", "Assignee", "Date"}
 	missing := []string{}
 
@@ -174,9 +178,40 @@ func (s *Service) ValidateDatabase(ctx context.Context, userID string, dbID stri
 type InitResult struct {
 	Initialized   bool     `json:"initialized"`
 	CreatedFields []string `json:"created_fields"`
+	// MissingStatuses are workflow statuses absent from an existing Notion
+	// Status property; Notion does not allow adding them through the API
+	MissingStatuses []string `json:"missing_statuses,omitempty"`
 }
 
+// statusColors colors the Notion options of each status category
+var statusColors = map[repository.StatusCategory]notion.Color{
+	repository.StatusCategoryTodo:   notion.ColorRed,
+	repository.StatusCategoryActive: notion.ColorBlue,
+	repository.StatusCategoryDone:   notion.ColorGreen,
+}
+
+// statusOptions maps the statuses of a workflow one-to-one to Notion options
+func statusOptions(workflow repository.Workflow) []notion.SelectOptions {
+	options := make([]notion.SelectOptions, 0, len(workflow))
+	for _, st := range workflow {
+		options = append(options, notion.SelectOptions{Name: string(st.Name), Color: statusColors[st.Category]})
+	}
+	return options
+}
+
+// InitializeDatabase adds the missing task properties to a database, with
+// the statuses of the default workflow
 func (s *Service) InitializeDatabase(ctx context.Context, userID string, dbID string) (*InitResult, error) {
+	return s.InitializeDatabaseWithStatuses(ctx, userID, dbID, repository.DefaultWorkflow)
+}
+
+// InitializeDatabaseWithStatuses adds the missing task properties to a
+// database. Each status of workflow becomes an option of the Status property;
+// options missing from an existing Select property are appended.
+func (s *Service) InitializeDatabaseWithStatuses(ctx context.Context, userID string, dbID string, workflow repository.Workflow) (*InitResult, error) {
+	if len(workflow) == 0 {
+		workflow = repository.DefaultWorkflow
+	}
 	client, err := s.getClient(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -188,21 +223,43 @@ func (s *Service) InitializeDatabase(ctx context.Context, userID string, dbID st
 	}
 
 	missing := []string{}
+	var missingStatuses []string
 	properties := make(map[string]*notion.DatabaseProperty)
 
 	// Status
-	if _, ok := db.Properties["Status"]; !ok {
+	if prop, ok := db.Properties["Status"]; !ok {
 		missing = append(missing, "Status")
 		properties["Status"] = &notion.DatabaseProperty{
 			Type: notion.DBPropTypeStatus,
 			Status: &notion.StatusMetadata{
-				Options: []notion.SelectOptions{
-					{Name: "To Do", Color: "red"},
-					{Name: "In Progress", Color: "blue"},
-					{Name: "Done", Color: "green"},
-				},
+				Options: statusOptions(workflow),
 			},
 		}
+	} else {
+		var existing []notion.SelectOptions
+		switch {
+		case prop.Type == notion.DBPropTypeSelect && prop.Select != nil:
+			existing = prop.Select.Options
+		case prop.Type == notion.DBPropTypeStatus && prop.Status != nil:
+			existing = prop.Status.Options
+		}
+		var added []notion.SelectOptions
+		for _, option := range statusOptions(workflow) {
+			if !hasOption(existing, option.Name) {
+				added = append(added, option)
+			}
+		}
+		if len(added) > 0 && prop.Type == notion.DBPropTypeSelect {
+			missing = append(missing, "Status")
+			properties["Status"] = &notion.DatabaseProperty{
+				Type:   notion.DBPropTypeSelect,
+				Select: &notion.SelectMetadata{Options: append(existing, added...)},
+			}
+		} else {
+			for _, option := range added {
+				missingStatuses = append(missingStatuses, option.Name)
+			}
+		}
 	}
 
 	// Assignee
@@ -223,8 +280,32 @@ func (s *Service) InitializeDatabase(ctx context.Context, userID string, dbID st
 		}
 	}
 
+	// Priority
+	if _, ok := db.Properties["Priority"]; !ok {
+		missing = append(missing, "Priority")
+		properties["Priority"] = &notion.DatabaseProperty{
+			Type: notion.DBPropTypeSelect,
+			Select: &notion.SelectMetadata{
+				Options: []notion.SelectOptions{
+					{Name: "High", Color: "red"},
+					{Name: "Medium", Color: "yellow"},
+					{Name: "Low", Color: "gray"},
+				},
+			},
+		}
+	}
+
+	// Labels (options are created by Notion on first use)
+	if _, ok := db.Properties["Labels"]; !ok {
+		missing = append(missing, "Labels")
+		properties["Labels"] = &notion.DatabaseProperty{
+			Type:        notion.DBPropTypeMultiSelect,
+			MultiSelect: &notion.SelectMetadata{Options: []notion.SelectOptions{}},
+		}
+	}
+
 	if len(missing) == 0 {
-		return &InitResult{Initialized: false, CreatedFields: []string{}}, nil
+		return &InitResult{Initialized: false, CreatedFields: []string{}, MissingStatuses: missingStatuses}, nil
 	}
 
 	_, err = client.UpdateDatabase(ctx, dbID, notion.UpdateDatabaseParams{
@@ -235,7 +316,63 @@ func (s *Service) InitializeDatabase(ctx context.Context, userID string, dbID st
 	}
 
 	return &InitResult{
-		Initialized:   true,
-		CreatedFields: missing,
+		Initialized:     true,
+		CreatedFields:   missing,
+		MissingStatuses: missingStatuses,
 	}, nil
 }
+
+// builtinProperties are the properties synced as task fields rather than
+// custom fields
+var builtinProperties = map[string]bool{
+	"Name": true, "Status": true, "Assignee": true, "Date": true, "Priority": true, "Labels": true,
+}
+
+// DiscoverCustomFields lists the properties of a database that can be synced
+// as custom fields: every property of a supported type (see
+// pkgnotion.SupportsProperty) apart from the built-in task properties,
+// sorted by name.
+func (s *Service) DiscoverCustomFields(ctx context.Context, userID string, dbID string) (repository.CustomFields, error) {
+	client, err := s.getClient(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := client.GetDatabase(ctx, dbID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database: %w", err)
+	}
+
+	fields := repository.CustomFields{}
+	for name, prop := range db.Properties {
+		if builtinProperties[name] || !pkgnotion.SupportsProperty(prop.Type) {
+			continue
+		}
+		field := repository.CustomField{Name: name, Type: repository.CustomFieldType(prop.Type)}
+		var options []notion.SelectOptions
+		switch {
+		case prop.Select != nil:
+			options = prop.Select.Options
+		case prop.MultiSelect != nil:
+			options = prop.MultiSelect.Options
+		}
+		names := make([]string, 0, len(options))
+		for _, option := range options {
+			names = append(names, option.Name)
+		}
+		field.Options = datatypes.NewJSONSlice(names)
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields, nil
+}
+
+// hasOption reports whether options contain name, ignoring case
+func hasOption(options []notion.SelectOptions, name string) bool {
+	for _, option := range options {
+		if strings.EqualFold(option.Name, name) {
+			return true
+		}
+	}
+	return false
+}
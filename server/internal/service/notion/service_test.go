@@ -188,6 +188,8 @@ func TestValidateDatabase_Invalid(t *testing.T) {
 	assert.False(t, res.Compatible)
 	assert.Contains(t, res.MissingFields, "Status (Expected Select/Status)")
 	assert.Contains(t, res.MissingFields, "Date")
+	assert.NotContains(t, res.MissingFields, "Priority")
+	assert.NotContains(t, res.MissingFields, "Labels")
 }
 
 func (m *MockClient) UpdateDatabase(ctx context.Context, id string, params notion.UpdateDatabaseParams) (*notion.Database, error) {
@@ -206,6 +208,10 @@ func (m *MockClient) UpdatePage(ctx context.Context, pageID string, params pkgno
 	return nil, nil
 }
 
+func (m *MockClient) AppendBlockChildren(ctx context.Context, blockID string, children []notion.Block) error {
+	return nil
+}
+
 func TestInitializeDatabase_NoMissing(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	mockUserRepo := new(MockUserRepo)
@@ -223,6 +229,8 @@ func TestInitializeDatabase_NoMissing(t *testing.T) {
 			"Status":   notion.DatabaseProperty{Type: notion.DBPropTypeStatus},
 			"Assignee": notion.DatabaseProperty{Type: notion.DBPropTypePeople},
 			"Date":     notion.DatabaseProperty{Type: notion.DBPropTypeDate},
+			"Priority": notion.DatabaseProperty{Type: notion.DBPropTypeSelect},
+			"Labels":   notion.DatabaseProperty{Type: notion.DBPropTypeMultiSelect},
 		},
 	}, nil)
 
@@ -256,7 +264,10 @@ func TestInitializeDatabase_CreatesMissing(t *testing.T) {
 		_, hasStatus := params.Properties["Status"]
 		_, hasDate := params.Properties["Date"]
 		_, hasAssignee := params.Properties["Assignee"]
-		return hasStatus && hasDate && !hasAssignee
+		priority, hasPriority := params.Properties["Priority"]
+		labels, hasLabels := params.Properties["Labels"]
+		return hasStatus && hasDate && !hasAssignee && hasPriority && priority.Type == notion.DBPropTypeSelect &&
+			hasLabels && labels.Type == notion.DBPropTypeMultiSelect
 	})).Return(&notion.Database{ID: "db1"}, nil)
 
 	res, err := service.InitializeDatabase(context.Background(), "user1", "db1")
@@ -264,5 +275,43 @@ func TestInitializeDatabase_CreatesMissing(t *testing.T) {
 	assert.True(t, res.Initialized)
 	assert.Contains(t, res.CreatedFields, "Status")
 	assert.Contains(t, res.CreatedFields, "Date")
+	assert.Contains(t, res.CreatedFields, "Priority")
+	assert.Contains(t, res.CreatedFields, "Labels")
 	assert.NotContains(t, res.CreatedFields, "Assignee")
 }
+
+func TestDiscoverCustomFields(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockUserRepo := new(MockUserRepo)
+	mockClient := new(MockClient)
+	encryptionKey := "12345678901234567890123456789012"
+	service := NewService(logger, mockUserRepo, encryptionKey)
+	service.ClientFactory = func(token string) pkgnotion.Client { return mockClient }
+
+	tokenEnc, _ := security.Encrypt("token", encryptionKey)
+	mockUserRepo.On("FindNotionToken", mock.Anything, "user1").Return(&models.UserNotionToken{AccessTokenEnc: tokenEnc}, nil)
+	mockClient.On("GetDatabase", mock.Anything, "db1").Return(&notion.Database{
+		ID: "db1",
+		Properties: notion.DatabaseProperties{
+			"Name":         notion.DatabaseProperty{Type: notion.DBPropTypeTitle},
+			"Status":       notion.DatabaseProperty{Type: notion.DBPropTypeStatus},
+			"Labels":       notion.DatabaseProperty{Type: notion.DBPropTypeMultiSelect},
+			"Customer":     notion.DatabaseProperty{Type: notion.DBPropTypeRichText},
+			"Story Points": notion.DatabaseProperty{Type: notion.DBPropTypeNumber},
+			"Sprint": notion.DatabaseProperty{Type: notion.DBPropTypeSelect, Select: &notion.SelectMetadata{
+				Options: []notion.SelectOptions{{Name: "S1"}, {Name: "S2"}},
+			}},
+			"Total": notion.DatabaseProperty{Type: notion.DBPropTypeFormula},
+		},
+	}, nil)
+
+	fields, err := service.DiscoverCustomFields(context.Background(), "user1", "db1")
+	assert.NoError(t, err)
+	names := []string{}
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"Customer", "Sprint", "Story Points"}, names)
+	assert.Equal(t, repository.CustomFieldSelect, fields[1].Type)
+	assert.Equal(t, []string{"S1", "S2"}, []string(fields[1].Options))
+}
@@ -168,14 +168,13 @@ func (p *Poller) syncPage(ctx context.Context, page notion.Page, dbID string) er
 		return nil // Skip empty title?
 	}
 
-	// Status
+	// Status; empty or unknown names leave the task's status alone (new tasks
+	// start in the first status of their workflow)
 	var status string
 	if prop, ok := props["Status"]; ok && prop.Status != nil {
 		status = prop.Status.Name
-	}
-	// Fallback to "To Do" if status missing? Or ignore?
-	if status == "" {
-		status = "To Do"
+	} else if ok && prop.Select != nil {
+		status = prop.Select.Name
 	}
 
 	// URL
@@ -185,5 +184,5 @@ func (p *Poller) syncPage(ctx context.Context, page notion.Page, dbID string) er
 
 	// Assignees?? (Skipped for now)
 
-	return p.taskService.SyncTaskFromNotion(ctx, page.ID, dbID, title, status, notionURL, nil, page.Archived)
+	return p.taskService.SyncTaskFromNotion(ctx, page.ID, dbID, title, status, notionURL, nil, props, page.Archived, page.LastEditedTime)
 }
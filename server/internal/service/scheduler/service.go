@@ -12,38 +12,55 @@ import (
 	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/internal/service/notification"
 	"github.com/layababa/tg_todo/server/internal/service/telegram"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
+// recurrenceSpawner generates the next instance of a recurring task
+type recurrenceSpawner interface {
+	SpawnNextOccurrence(ctx context.Context, task *repository.Task, now time.Time) (*repository.Task, error)
+}
+
+// doneArchiver archives tasks that have been Done for their group's
+// auto-archive period
+type doneArchiver interface {
+	AutoArchiveDoneTasks(ctx context.Context, now time.Time) (int, error)
+}
+
 type Service struct {
-	logger   *zap.Logger
-	cron     *cron.Cron
-	userRepo repository.UserRepository
-	taskRepo repository.TaskRepository
-	notifier *notification.Service
-	tgClient *telegram.Client
+	logger         *zap.Logger
+	cron           *cron.Cron
+	userRepo       repository.UserRepository
+	taskRepo       repository.TaskRepository
+	spawner        recurrenceSpawner
+	archiver       doneArchiver
+	notifier       *notification.Service
+	tgClient       *telegram.Client
+	trashRetention time.Duration // Deleted tasks older than this are purged; 0 disables
 }
 
-func NewService(logger *zap.Logger, userRepo repository.UserRepository, taskRepo repository.TaskRepository, notifier *notification.Service, tgClient *telegram.Client) *Service {
+func NewService(logger *zap.Logger, userRepo repository.UserRepository, taskRepo repository.TaskRepository, spawner recurrenceSpawner, archiver doneArchiver, notifier *notification.Service, tgClient *telegram.Client, trashRetention time.Duration) *Service {
 	return &Service{
-		logger:   logger,
-		cron:     cron.New(),
-		userRepo: userRepo,
-		taskRepo: taskRepo,
-		notifier: notifier,
-		tgClient: tgClient,
+		logger:         logger,
+		cron:           cron.New(),
+		userRepo:       userRepo,
+		taskRepo:       taskRepo,
+		spawner:        spawner,
+		archiver:       archiver,
+		notifier:       notifier,
+		tgClient:       tgClient,
+		trashRetention: trashRetention,
 	}
 }
 
 func (s *Service) Start() {
-	// Schedule Daily Digest at 9:00 AM
-	_, err := s.cron.AddFunc("0 9 * * *", func() {
-		s.logger.Info("running daily digest job")
-		s.SendDailyDigest(context.Background())
+	// Daily Digest at 9:00 AM in each user's time zone, checked hourly
+	_, err := s.cron.AddFunc("0 * * * *", func() {
+		s.SendDailyDigest(context.Background(), time.Now())
 	})
 	if err != nil {
 		s.logger.Error("failed to schedule daily digest", zap.Error(err))
 	} else {
-		s.logger.Info("daily digest scheduled for 09:00 AM")
+		s.logger.Info("daily digest scheduled for 09:00 AM local time")
 	}
 
 	// Schedule Task Reminders every minute
@@ -54,6 +71,32 @@ func (s *Service) Start() {
 		s.logger.Error("failed to schedule task reminders", zap.Error(err))
 	}
 
+	// Spawn recurring task instances every minute
+	_, err = s.cron.AddFunc("* * * * *", func() {
+		s.SpawnRecurringTasks(context.Background())
+	})
+	if err != nil {
+		s.logger.Error("failed to schedule recurring tasks", zap.Error(err))
+	}
+
+	// Auto-archive long-Done tasks daily at 2:00 AM
+	_, err = s.cron.AddFunc("0 2 * * *", func() {
+		s.AutoArchiveTasks(context.Background())
+	})
+	if err != nil {
+		s.logger.Error("failed to schedule auto-archive", zap.Error(err))
+	}
+
+	// Purge expired trash daily at 3:00 AM
+	if s.trashRetention > 0 {
+		_, err = s.cron.AddFunc("0 3 * * *", func() {
+			s.PurgeTrash(context.Background())
+		})
+		if err != nil {
+			s.logger.Error("failed to schedule trash purge", zap.Error(err))
+		}
+	}
+
 	s.cron.Start()
 }
 
@@ -61,22 +104,36 @@ func (s *Service) Stop() {
 	s.cron.Stop()
 }
 
-func (s *Service) SendDailyDigest(ctx context.Context) {
+// digestHour is the local hour the daily digest is sent at
+const digestHour = 9
+
+// SendDailyDigest sends the digest to the users for whom it is digestHour
+// o'clock at now
+func (s *Service) SendDailyDigest(ctx context.Context, now time.Time) {
 	users, err := s.userRepo.ListAll(ctx)
 	if err != nil {
 		s.logger.Error("failed to list users for digest", zap.Error(err))
 		return
 	}
 
+	sent := 0
 	for _, user := range users {
 		if user.TgID == 0 {
 			continue
 		}
-		s.processUserDigest(ctx, user.ID, user.TgID)
+		loc := user.Location()
+		if now.In(loc).Hour() != digestHour {
+			continue
+		}
+		s.processUserDigest(ctx, user.ID, user.TgID, now.In(loc))
+		sent++
+	}
+	if sent > 0 {
+		s.logger.Info("daily digest sent", zap.Int("users", sent))
 	}
 }
 
-func (s *Service) processUserDigest(ctx context.Context, userID string, chatID int64) {
+func (s *Service) processUserDigest(ctx context.Context, userID string, chatID int64, now time.Time) {
 	filter := repository.TaskListFilter{
 		View:  repository.TaskViewAll,
 		Limit: 100,
@@ -89,7 +146,7 @@ func (s *Service) processUserDigest(ctx context.Context, userID string, chatID i
 
 	var pendingTasks []repository.Task
 	for _, t := range tasks {
-		if t.Status != repository.TaskStatusDone {
+		if !t.IsDone() {
 			pendingTasks = append(pendingTasks, t)
 		}
 	}
@@ -109,11 +166,14 @@ func (s *Service) processUserDigest(ctx context.Context, userID string, chatID i
 		}
 
 		icon := "⬜"
-		if t.Status == repository.TaskStatusInProgress {
+		if t.Category() == repository.StatusCategoryActive {
 			icon = "🔄"
 		}
 
 		sb.WriteString(fmt.Sprintf("\n%d. %s %s", i+1, icon, t.Title))
+		if t.DueAt != nil {
+			sb.WriteString(" · 📅 " + formatDigestDue(t, now))
+		}
 	}
 
 	sb.WriteString("\n\n💪 加油！输入 /todo 添加新任务。")
@@ -123,6 +183,31 @@ func (s *Service) processUserDigest(ctx context.Context, userID string, chatID i
 	}
 }
 
+// formatDigestDue renders a due date relative to now's day: "今天 18:00",
+// "明天", or the date
+func formatDigestDue(t repository.Task, now time.Time) string {
+	loc := now.Location()
+	due := timezone.Date(*t.DueAt, loc)
+	if t.AllDay {
+		due = t.DueAt.UTC()
+	}
+	label := ""
+	switch today := timezone.Date(now, loc); {
+	case due.Equal(today):
+		label = "今天"
+	case due.Equal(today.AddDate(0, 0, 1)):
+		label = "明天"
+	case due.Before(today):
+		label = "已逾期 " + due.Format("01-02")
+	default:
+		label = due.Format("01-02")
+	}
+	if !t.AllDay {
+		label += " " + t.DueAt.In(loc).Format("15:04")
+	}
+	return label
+}
+
 // CheckReminders scans for tasks that need reminders
 func (s *Service) CheckReminders(ctx context.Context) {
 	now := time.Now()
@@ -146,13 +231,23 @@ func (s *Service) processTaskReminders(ctx context.Context, task repository.Task
 	}
 
 	// 1. Check 1h reminder
-	oneHourFromNow := now.Add(1 * time.Hour)
-	if task.DueAt.Before(oneHourFromNow) && !task.Reminder1hSent {
+	oneHourBefore, dueAt := task.DueAt.Add(-1*time.Hour), *task.DueAt
+	if task.AllDay {
+		// All-day tasks are reminded at 09:00 on the due date and are due
+		// once the date has passed, both in the creator's time zone
+		loc := time.UTC
+		if task.Creator != nil {
+			loc = task.Creator.Location()
+		}
+		start := timezone.StartOfDate(*task.DueAt, loc)
+		oneHourBefore, dueAt = start.Add(digestHour*time.Hour), start.AddDate(0, 0, 1)
+	}
+	if !now.Before(oneHourBefore) && !task.Reminder1hSent {
 		remind1h = true
 	}
 
 	// 2. Check due reminder
-	if task.DueAt.Before(now) && !task.ReminderDueSent {
+	if !now.Before(dueAt) && !task.ReminderDueSent {
 		remindDue = true
 	}
 
@@ -174,3 +269,58 @@ func (s *Service) processTaskReminders(ctx context.Context, task repository.Task
 		s.logger.Error("failed to update reminder flags", zap.String("task_id", task.ID), zap.Error(err))
 	}
 }
+
+// SpawnRecurringTasks generates the next instance for recurring tasks that were
+// marked Done or whose due date has passed
+func (s *Service) SpawnRecurringTasks(ctx context.Context) {
+	if s.spawner == nil {
+		return
+	}
+
+	now := time.Now()
+	tasks, err := s.taskRepo.ListRecurringToSpawn(ctx, now)
+	if err != nil {
+		s.logger.Error("failed to list recurring tasks", zap.Error(err))
+		return
+	}
+
+	for i := range tasks {
+		if _, err := s.spawner.SpawnNextOccurrence(ctx, &tasks[i], now); err != nil {
+			s.logger.Error("failed to spawn recurring task", zap.String("task_id", tasks[i].ID), zap.Error(err))
+		}
+	}
+}
+
+// AutoArchiveTasks archives tasks that have been Done for longer than their
+// group's auto_archive_days
+func (s *Service) AutoArchiveTasks(ctx context.Context) {
+	if s.archiver == nil {
+		return
+	}
+
+	archived, err := s.archiver.AutoArchiveDoneTasks(ctx, time.Now())
+	if err != nil {
+		s.logger.Error("failed to auto-archive tasks", zap.Error(err))
+		return
+	}
+	if archived > 0 {
+		s.logger.Info("auto-archived done tasks", zap.Int("tasks", archived))
+	}
+}
+
+// PurgeTrash permanently deletes tasks that have been in the trash longer than
+// the retention period
+func (s *Service) PurgeTrash(ctx context.Context) {
+	if s.trashRetention <= 0 {
+		return
+	}
+
+	purged, err := s.taskRepo.PurgeDeleted(ctx, time.Now().Add(-s.trashRetention))
+	if err != nil {
+		s.logger.Error("failed to purge trash", zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		s.logger.Info("purged expired trash", zap.Int64("tasks", purged), zap.Duration("retention", s.trashRetention))
+	}
+}
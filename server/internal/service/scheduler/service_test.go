@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/telegram"
+)
+
+type stubUserRepo struct {
+	repository.UserRepository
+	users []models.User
+}
+
+func (r *stubUserRepo) ListAll(context.Context) ([]models.User, error) {
+	return r.users, nil
+}
+
+type stubTaskRepo struct {
+	repository.TaskRepository
+	byUser map[string][]repository.Task
+}
+
+func (r *stubTaskRepo) ListByUser(_ context.Context, userID string, _ repository.TaskListFilter) ([]repository.Task, error) {
+	return r.byUser[userID], nil
+}
+
+// digestRecorder fakes the Bot API and records the text sent to each chat
+type digestRecorder struct {
+	mu   sync.Mutex
+	sent map[int64]string
+}
+
+func newDigestRecorder(t *testing.T) (*digestRecorder, *telegram.Client) {
+	rec := &digestRecorder{sent: make(map[int64]string)}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var msg struct {
+			ChatID int64  `json:"chat_id"`
+			Text   string `json:"text"`
+		}
+		json.Unmarshal(raw, &msg)
+		rec.mu.Lock()
+		rec.sent[msg.ChatID] = msg.Text
+		rec.mu.Unlock()
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(ts.Close)
+	client := telegram.NewClient("token")
+	client.SetBaseURL(ts.URL + "/")
+	return rec, client
+}
+
+func TestSendDailyDigestAtNineLocalTime(t *testing.T) {
+	rec, client := newDigestRecorder(t)
+	// 18:00 in Shanghai on 2026-03-10 is 10:00 UTC
+	due := time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC)
+	task := repository.Task{ID: "t1", Title: "Ship", DueAt: &due}
+	users := &stubUserRepo{users: []models.User{
+		{ID: "user-sh", TgID: 1, Timezone: "Asia/Shanghai"},
+		{ID: "user-ny", TgID: 2, Timezone: "America/New_York"},
+		{ID: "user-web", Timezone: "Asia/Shanghai"}, // Never talked to the bot
+	}}
+	tasks := &stubTaskRepo{byUser: map[string][]repository.Task{"user-sh": {task}, "user-ny": {task}, "user-web": {task}}}
+	svc := NewService(zap.NewNop(), users, tasks, nil, nil, nil, client, 0)
+
+	// 09:00 in Shanghai, 21:00 the day before in New York
+	svc.SendDailyDigest(context.Background(), time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC))
+	require.Len(t, rec.sent, 1)
+	assert.Contains(t, rec.sent[1], "1. ⬜ Ship · 📅 今天 18:00")
+
+	// 09:00 in New York, which is on daylight saving time since March 8
+	rec.sent = make(map[int64]string)
+	svc.SendDailyDigest(context.Background(), time.Date(2026, 3, 10, 13, 0, 0, 0, time.UTC))
+	require.Len(t, rec.sent, 1)
+	assert.Contains(t, rec.sent[2], "1. ⬜ Ship · 📅 今天 06:00")
+
+	// Nobody's 09:00
+	rec.sent = make(map[int64]string)
+	svc.SendDailyDigest(context.Background(), time.Date(2026, 3, 10, 2, 0, 0, 0, time.UTC))
+	assert.Empty(t, rec.sent)
+}
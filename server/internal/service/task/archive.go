@@ -0,0 +1,62 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+// SetArchived archives or unarchives a task on behalf of userID (empty for
+// system jobs) and mirrors the state to the task's Notion page
+func (s *Service) SetArchived(ctx context.Context, userID, id string, archived bool, source repository.TaskEventSource) (*repository.Task, error) {
+	task, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+	if task.Archived == archived {
+		return task, nil
+	}
+
+	if err := s.repo.SetArchived(ctx, id, archived); err != nil {
+		return nil, err
+	}
+	task.Archived = archived
+	s.recordEvent(ctx, task.ID, userID, repository.TaskEventUpdate, source,
+		map[string]interface{}{"archived": !archived}, map[string]interface{}{"archived": archived})
+
+	if task.NotionPageID != nil && *task.NotionPageID != "" {
+		go func() {
+			if err := s.setNotionPageArchived(context.Background(), task, notionOwnerOf(task, userID), archived); err != nil {
+				s.logger.Error("failed to sync archived state to notion", zap.String("task_id", task.ID), zap.Error(err))
+			}
+		}()
+	}
+
+	return task, nil
+}
+
+// AutoArchiveDoneTasks archives tasks that have been Done for longer than their
+// group's auto-archive period and returns how many were archived
+func (s *Service) AutoArchiveDoneTasks(ctx context.Context, now time.Time) (int, error) {
+	tasks, err := s.repo.ListAutoArchivable(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for i := range tasks {
+		if _, err := s.SetArchived(ctx, "", tasks[i].ID, true, repository.TaskEventSourceSystem); err != nil {
+			s.logger.Error("failed to auto-archive task", zap.String("task_id", tasks[i].ID), zap.Error(err))
+			continue
+		}
+		archived++
+	}
+	return archived, nil
+}
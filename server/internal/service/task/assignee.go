@@ -0,0 +1,169 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+// AddAssignee adds userID to the task assignees on behalf of actorID, keeping
+// the existing assignees. Adding oneself is a claim. It is a no-op if the user
+// is already assigned.
+func (s *Service) AddAssignee(ctx context.Context, actorID, taskID, userID string, source repository.TaskEventSource) (*repository.Task, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrAssigneeNotFound
+	}
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+
+	var assignedBy *string
+	if actorID != "" {
+		assignedBy = &actorID
+	}
+	added, err := s.repo.AddAssignee(ctx, taskID, userID, assignedBy)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return task, nil
+	}
+	return s.assigneesChanged(ctx, actorID, task, source)
+}
+
+// ReassignTask makes userID the only assignee of the task on behalf of
+// actorID. It is a no-op if the user already is.
+func (s *Service) ReassignTask(ctx context.Context, actorID, taskID, userID string, source repository.TaskEventSource) (*repository.Task, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrAssigneeNotFound
+	}
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+	if len(task.Assignees) == 1 && task.Assignees[0].ID == userID {
+		return task, nil
+	}
+
+	var assignedBy *string
+	if actorID != "" {
+		assignedBy = &actorID
+	}
+	if err := s.repo.SetAssignees(ctx, taskID, []string{userID}, assignedBy); err != nil {
+		return nil, err
+	}
+	return s.assigneesChanged(ctx, actorID, task, source)
+}
+
+// RemoveAssignee removes userID from the task assignees on behalf of actorID.
+// It is a no-op if the user is not assigned.
+func (s *Service) RemoveAssignee(ctx context.Context, actorID, taskID, userID string, source repository.TaskEventSource) (*repository.Task, error) {
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+
+	removed, err := s.repo.RemoveAssignee(ctx, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return task, nil
+	}
+	return s.assigneesChanged(ctx, actorID, task, source)
+}
+
+// assigneesChanged records, announces and syncs an assignee change. before is
+// the task as read before the change; the updated task is returned.
+func (s *Service) assigneesChanged(ctx context.Context, actorID string, before *repository.Task, source repository.TaskEventSource) (*repository.Task, error) {
+	task, err := s.repo.GetByID(ctx, before.ID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+
+	s.recordEvent(ctx, task.ID, actorID, repository.TaskEventAssign, source,
+		map[string]interface{}{"assignee_ids": assigneeIDs(before)}, map[string]interface{}{"assignee_ids": assigneeIDs(task)})
+
+	oldNames, newNames := assigneeNames(before), assigneeNames(task)
+	s.logger.Info("task assignees changed",
+		zap.String("task_id", task.ID),
+		zap.String("actor_id", actorID),
+		zap.String("old_assignees", oldNames),
+		zap.String("new_assignees", newNames))
+	// The creator is told about changes made by someone else
+	if s.notifier != nil && (task.CreatorID == nil || *task.CreatorID != actorID) {
+		s.notifier.NotifyAssigneeChange(ctx, task, oldNames, newNames)
+	}
+
+	task.SyncStatus = repository.TaskSyncStatusPending
+	if err := s.repo.UpdateStatus(ctx, task); err != nil {
+		s.logger.Warn("failed to mark task pending", zap.String("task_id", task.ID), zap.Error(err))
+	}
+	if task.DatabaseID != nil {
+		go func() {
+			if err := s.SyncToNotion(context.Background(), task, notionOwnerOf(task, actorID), *task.DatabaseID); err != nil {
+				s.logger.Error("failed to sync assignees to notion", zap.String("task_id", task.ID), zap.Error(err))
+			}
+		}()
+	}
+	return task, nil
+}
+
+// notionAssigneeIDs maps the task assignees to Notion users. Only users who
+// connected Notion are known to it; the others are left out. A task without
+// assignees gets an empty list, which clears the Notion property; when none
+// of the assignees is known to Notion the result is nil, leaving people
+// assigned in Notion alone.
+func (s *Service) notionAssigneeIDs(ctx context.Context, task *repository.Task) []string {
+	if len(task.Assignees) == 0 {
+		return []string{}
+	}
+	var ids []string
+	for _, a := range task.Assignees {
+		token, err := s.userRepo.FindNotionToken(ctx, a.ID)
+		if err != nil || token == nil || token.NotionUserID == "" {
+			continue
+		}
+		ids = append(ids, token.NotionUserID)
+	}
+	return ids
+}
+
+func assigneeIDs(task *repository.Task) []string {
+	ids := make([]string, 0, len(task.Assignees))
+	for _, a := range task.Assignees {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+// assigneeNames lists the assignee names for notifications, "无" if none
+func assigneeNames(task *repository.Task) string {
+	if len(task.Assignees) == 0 {
+		return "无"
+	}
+	names := make([]string, 0, len(task.Assignees))
+	for _, a := range task.Assignees {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, "、")
+}
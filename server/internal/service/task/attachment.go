@@ -0,0 +1,205 @@
+package task
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/dstotijn/go-notion"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+// FileDownloader fetches Telegram files by file_id
+type FileDownloader interface {
+	// DownloadFile returns the file content and its size, or -1 when unknown
+	DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, int64, error)
+}
+
+// MediaFile is a Telegram photo size, document, voice, audio or video
+type MediaFile struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileName     string `json:"file_name"`
+	MimeType     string `json:"mime_type"`
+	FileSize     int64  `json:"file_size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     int    `json:"duration"`
+}
+
+// MessageMedia is the media part of a Telegram message. It is embedded in
+// message payloads so they decode straight from the update JSON.
+type MessageMedia struct {
+	Photo    []MediaFile `json:"photo"` // Sizes, smallest first
+	Document *MediaFile  `json:"document"`
+	Voice    *MediaFile  `json:"voice"`
+	Audio    *MediaFile  `json:"audio"`
+	Video    *MediaFile  `json:"video"`
+}
+
+// file returns the media of the message and its kind, or nil
+func (m MessageMedia) file() (*MediaFile, repository.AttachmentKind) {
+	switch {
+	case len(m.Photo) > 0:
+		return &m.Photo[len(m.Photo)-1], repository.AttachmentKindPhoto
+	case m.Document != nil:
+		return m.Document, repository.AttachmentKindDocument
+	case m.Voice != nil:
+		return m.Voice, repository.AttachmentKindVoice
+	case m.Audio != nil:
+		return m.Audio, repository.AttachmentKindAudio
+	case m.Video != nil:
+		return m.Video, repository.AttachmentKindVideo
+	}
+	return nil, ""
+}
+
+// Attachment turns the media of message messageID into a task attachment,
+// or returns nil when the message carries none
+func (m MessageMedia) Attachment(messageID int64, caption string) *repository.TaskAttachment {
+	f, kind := m.file()
+	if f == nil || f.FileID == "" {
+		return nil
+	}
+	return &repository.TaskAttachment{
+		Kind:         kind,
+		FileID:       f.FileID,
+		FileUniqueID: f.FileUniqueID,
+		FileName:     f.FileName,
+		MimeType:     f.MimeType,
+		FileSize:     f.FileSize,
+		Width:        f.Width,
+		Height:       f.Height,
+		Duration:     f.Duration,
+		Caption:      caption,
+		TgMessageID:  messageID,
+	}
+}
+
+// Placeholder describes the media in text, e.g. "[图片]" or "[文件 log.txt]",
+// for context snapshots and titles; empty without media
+func (m MessageMedia) Placeholder() string {
+	f, kind := m.file()
+	if f == nil {
+		return ""
+	}
+	return mediaPlaceholder(kind, f.FileName)
+}
+
+func mediaPlaceholder(kind repository.AttachmentKind, fileName string) string {
+	switch kind {
+	case repository.AttachmentKindPhoto:
+		return "[图片]"
+	case repository.AttachmentKindDocument:
+		if fileName != "" {
+			return "[文件 " + fileName + "]"
+		}
+		return "[文件]"
+	case repository.AttachmentKindVoice:
+		return "[语音]"
+	case repository.AttachmentKindAudio:
+		return "[音频]"
+	default:
+		return "[视频]"
+	}
+}
+
+// ListAttachments lists the attachments of a task
+func (s *Service) ListAttachments(ctx context.Context, taskID string) ([]repository.TaskAttachment, error) {
+	if s.attachmentRepo == nil {
+		return nil, errors.New("attachment repository not configured")
+	}
+	return s.attachmentRepo.ListByTask(ctx, taskID)
+}
+
+// GetAttachment returns an attachment by ID, or nil if it does not exist
+func (s *Service) GetAttachment(ctx context.Context, id string) (*repository.TaskAttachment, error) {
+	if s.attachmentRepo == nil {
+		return nil, errors.New("attachment repository not configured")
+	}
+	return s.attachmentRepo.GetByID(ctx, id)
+}
+
+// OpenAttachment downloads the content of an attachment from Telegram
+func (s *Service) OpenAttachment(ctx context.Context, attachment *repository.TaskAttachment) (io.ReadCloser, int64, error) {
+	if s.files == nil {
+		return nil, 0, errors.New("telegram file downloader not configured")
+	}
+	return s.files.DownloadFile(ctx, attachment.FileID)
+}
+
+// AttachmentURL returns a public link to an attachment, signed so that it
+// works without Telegram login (e.g. from Notion). It is empty unless a
+// public base URL and link secret are configured.
+func (s *Service) AttachmentURL(attachmentID string) string {
+	if s.publicBaseURL == "" || s.fileLinkSecret == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/files/%s?sig=%s", s.publicBaseURL, url.PathEscape(attachmentID), s.signAttachment(attachmentID))
+}
+
+// VerifyAttachmentSignature checks the sig parameter of an AttachmentURL
+func (s *Service) VerifyAttachmentSignature(attachmentID, sig string) bool {
+	if s.fileLinkSecret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.signAttachment(attachmentID)))
+}
+
+func (s *Service) signAttachment(attachmentID string) string {
+	mac := hmac.New(sha256.New, []byte(s.fileLinkSecret))
+	mac.Write([]byte("attachment:" + attachmentID))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// attachmentBlocks renders attachments for a Notion page: photos as images
+// and other files as file blocks when public links are available, otherwise
+// as a plain list of names
+func (s *Service) attachmentBlocks(attachments []repository.TaskAttachment) []notion.Block {
+	if len(attachments) == 0 {
+		return nil
+	}
+	blocks := []notion.Block{notion.Heading3Block{
+		RichText: []notion.RichText{{Text: &notion.Text{Content: "Attachments"}}},
+	}}
+	for _, a := range attachments {
+		name := a.FileName
+		if name == "" {
+			name = string(a.Kind)
+		}
+		var caption []notion.RichText
+		if a.Caption != "" {
+			caption = []notion.RichText{{Text: &notion.Text{Content: a.Caption}}}
+		}
+
+		link := s.AttachmentURL(a.ID)
+		switch {
+		case link == "":
+			blocks = append(blocks, notion.BulletedListItemBlock{
+				RichText: []notion.RichText{{Text: &notion.Text{Content: "📎 " + name + " (Telegram)"}}},
+			})
+		case a.Kind == repository.AttachmentKindPhoto:
+			blocks = append(blocks, notion.ImageBlock{
+				Type:     notion.FileTypeExternal,
+				External: &notion.FileExternal{URL: link},
+				Caption:  caption,
+			})
+		default:
+			if caption == nil {
+				caption = []notion.RichText{{Text: &notion.Text{Content: name}}}
+			}
+			blocks = append(blocks, notion.FileBlock{
+				Type:     notion.FileTypeExternal,
+				External: &notion.FileExternal{URL: link},
+				Caption:  caption,
+			})
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,356 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/notification"
+)
+
+// MaxBatchSize bounds the number of tasks of a single bulk operation
+const MaxBatchSize = 500
+
+// notionSyncInterval spaces out the Notion requests queued by a bulk
+// operation; Notion allows an average of three requests per second
+var notionSyncInterval = 350 * time.Millisecond
+
+var (
+	// ErrInvalidBatch is returned when a bulk operation is malformed
+	ErrInvalidBatch = errors.New("invalid batch request")
+	// ErrAssigneeNotFound is returned when reassigning tasks to an unknown user
+	ErrAssigneeNotFound = errors.New("assignee not found")
+)
+
+// BatchAction is the operation applied by a bulk request
+type BatchAction string
+
+const (
+	BatchActionStatus   BatchAction = "status"    // Set Status
+	BatchActionAssign   BatchAction = "assign"    // Replace the assignees with AssigneeID
+	BatchActionShiftDue BatchAction = "shift_due" // Move due dates by DueShift
+	BatchActionArchive  BatchAction = "archive"
+	BatchActionDelete   BatchAction = "delete"
+)
+
+// BatchParams describes a bulk operation
+type BatchParams struct {
+	TaskIDs    []string
+	Action     BatchAction
+	Status     repository.TaskStatus
+	AssigneeID string
+	DueShift   time.Duration
+}
+
+// Per-item failure codes of a bulk operation
+const (
+	BatchErrNotFound  = "not_found"
+	BatchErrForbidden = "forbidden"
+	BatchErrBlocked   = "task_blocked"
+	BatchErrConflict  = "version_conflict"
+	BatchErrNoDueDate = "no_due_date"
+	// All-day tasks only move by whole days
+	BatchErrPartialDayShift = "partial_day_shift"
+	// The status is not part of the task's workflow, or not reachable from its status
+	BatchErrInvalidStatus = "invalid_status"
+	BatchErrTransition    = "transition_not_allowed"
+)
+
+// BatchItemResult is the outcome of a bulk operation for one task
+type BatchItemResult struct {
+	TaskID string `json:"task_id"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (p BatchParams) validate() error {
+	if len(p.TaskIDs) == 0 || len(p.TaskIDs) > MaxBatchSize {
+		return ErrInvalidBatch
+	}
+	switch p.Action {
+	case BatchActionStatus:
+		// Statuses are checked against the workflow of each task
+		if strings.TrimSpace(string(p.Status)) == "" {
+			return ErrInvalidBatch
+		}
+	case BatchActionAssign:
+		if p.AssigneeID == "" {
+			return ErrInvalidBatch
+		}
+	case BatchActionShiftDue:
+		if p.DueShift == 0 {
+			return ErrInvalidBatch
+		}
+	case BatchActionArchive, BatchActionDelete:
+	default:
+		return ErrInvalidBatch
+	}
+	return nil
+}
+
+// BatchUpdate applies one action to many tasks on behalf of userID. Tasks the
+// user may not modify, or that cannot take the change, are reported in the
+// results and skipped; the other changes are written in a single transaction.
+// Afterwards each affected user gets one notification and the Notion pages
+// are synced at a rate Notion accepts.
+func (s *Service) BatchUpdate(ctx context.Context, userID string, params BatchParams) ([]BatchItemResult, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if s.userGroupRepo == nil {
+		return nil, errors.New("user group repository not configured")
+	}
+
+	var assignee *models.User
+	if params.Action == BatchActionAssign {
+		user, err := s.userRepo.FindByID(ctx, params.AssigneeID)
+		if err != nil || user == nil {
+			return nil, ErrAssigneeNotFound
+		}
+		assignee = user
+	}
+
+	ids := make([]string, 0, len(params.TaskIDs))
+	seen := make(map[string]bool, len(params.TaskIDs))
+	for _, id := range params.TaskIDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	results := make([]BatchItemResult, len(ids))
+	var changed []*repository.Task
+	err := s.repo.Transaction(ctx, func(tx repository.TaskRepository) error {
+		changed = changed[:0]
+		for i, id := range ids {
+			results[i] = BatchItemResult{TaskID: id}
+
+			task, err := tx.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			if task == nil {
+				results[i].Error = BatchErrNotFound
+				continue
+			}
+			canModify, err := CanModifyTask(ctx, userID, task, s.userGroupRepo)
+			if err != nil {
+				return err
+			}
+			if !canModify {
+				results[i].Error = BatchErrForbidden
+				continue
+			}
+
+			modified, code, err := s.applyBatchAction(ctx, tx, userID, task, params, assignee)
+			if err != nil {
+				return err
+			}
+			if code != "" {
+				results[i].Error = code
+				continue
+			}
+			results[i].OK = true
+			if modified {
+				changed = append(changed, task)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("batch update applied",
+		zap.String("user_id", userID),
+		zap.String("action", string(params.Action)),
+		zap.Int("requested", len(ids)),
+		zap.Int("changed", len(changed)))
+	if len(changed) == 0 {
+		return results, nil
+	}
+
+	if s.notifier != nil {
+		s.notifier.NotifyBatch(ctx, batchNotificationEvent(params.Action), changed, userID)
+	}
+	if params.Action == BatchActionStatus {
+		for _, task := range changed {
+			if !task.IsDone() {
+				continue
+			}
+			if task.ParentID != nil {
+				s.completeParentIfDone(ctx, *task.ParentID)
+			}
+			s.notifyUnblocked(ctx, task)
+		}
+	}
+	s.queueNotionSyncs(userID, params.Action, changed)
+
+	return results, nil
+}
+
+// applyBatchAction changes one task inside the batch transaction. It returns
+// whether the task was modified, or a per-item failure code.
+func (s *Service) applyBatchAction(ctx context.Context, tx repository.TaskRepository, userID string, task *repository.Task, params BatchParams, assignee *models.User) (bool, string, error) {
+	record := func(event repository.TaskEventType, before, after interface{}) {
+		recordTaskEvent(ctx, s.logger, tx, task.ID, userID, event, repository.TaskEventSourceApp, before, after)
+	}
+	update := func() (string, error) {
+		task.SyncStatus = repository.TaskSyncStatusPending
+		if err := tx.Update(ctx, task); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return BatchErrConflict, nil
+			}
+			return "", err
+		}
+		return "", nil
+	}
+
+	switch params.Action {
+	case BatchActionStatus:
+		st, err := s.resolveStatus(ctx, task, params.Status)
+		switch {
+		case errors.Is(err, ErrUnknownStatus):
+			return false, BatchErrInvalidStatus, nil
+		case errors.Is(err, ErrTransitionNotAllowed):
+			return false, BatchErrTransition, nil
+		}
+		if task.Status == st.Name {
+			return false, "", nil
+		}
+		if st.Category == repository.StatusCategoryActive && task.Category() != repository.StatusCategoryActive {
+			blocked, err := s.isBlocked(ctx, task.ID)
+			if err != nil {
+				return false, "", err
+			}
+			if blocked {
+				return false, BatchErrBlocked, nil
+			}
+		}
+		oldStatus := task.Status
+		task.SetStatus(st)
+		setCompletedAt(task, time.Now())
+		if code, err := update(); code != "" || err != nil {
+			return false, code, err
+		}
+		record(repository.TaskEventStatus, map[string]interface{}{"status": oldStatus}, map[string]interface{}{"status": task.Status})
+
+	case BatchActionAssign:
+		oldAssigneeIDs := assigneeIDs(task)
+		if len(oldAssigneeIDs) == 1 && oldAssigneeIDs[0] == assignee.ID {
+			return false, "", nil
+		}
+		if err := tx.SetAssignees(ctx, task.ID, []string{assignee.ID}, &userID); err != nil {
+			return false, "", err
+		}
+		task.Assignees = []models.User{*assignee}
+		task.SyncStatus = repository.TaskSyncStatusPending
+		if err := tx.UpdateStatus(ctx, task); err != nil {
+			return false, "", err
+		}
+		record(repository.TaskEventAssign, map[string]interface{}{"assignee_ids": oldAssigneeIDs}, map[string]interface{}{"assignee_ids": []string{assignee.ID}})
+
+	case BatchActionShiftDue:
+		if task.DueAt == nil {
+			return false, BatchErrNoDueDate, nil
+		}
+		shift := params.DueShift
+		if task.AllDay && shift%(24*time.Hour) != 0 {
+			return false, BatchErrPartialDayShift, nil
+		}
+		oldDueAt := *task.DueAt
+		dueAt := oldDueAt.Add(shift)
+		task.DueAt = &dueAt
+		if task.StartAt != nil {
+			startAt := task.StartAt.Add(shift)
+			task.StartAt = &startAt
+		}
+		task.Reminder1hSent = false
+		task.ReminderDueSent = false
+		task.ReminderSnoozedUntil = nil
+		if code, err := update(); code != "" || err != nil {
+			return false, code, err
+		}
+		record(repository.TaskEventDue, map[string]interface{}{"due_at": oldDueAt}, map[string]interface{}{"due_at": dueAt})
+
+	case BatchActionArchive:
+		if task.Archived {
+			return false, "", nil
+		}
+		if err := tx.SetArchived(ctx, task.ID, true); err != nil {
+			return false, "", err
+		}
+		task.Archived = true
+		record(repository.TaskEventUpdate, map[string]interface{}{"archived": false}, map[string]interface{}{"archived": true})
+
+	case BatchActionDelete:
+		if err := tx.SoftDelete(ctx, task.ID); err != nil {
+			return false, "", err
+		}
+		record(repository.TaskEventDelete, taskEventState(task), nil)
+	}
+	return true, "", nil
+}
+
+func batchNotificationEvent(action BatchAction) notification.EventType {
+	switch action {
+	case BatchActionAssign:
+		return notification.EventTaskAssigned
+	case BatchActionShiftDue:
+		return notification.EventDueChanged
+	case BatchActionArchive:
+		return notification.EventTaskArchived
+	case BatchActionDelete:
+		return notification.EventTaskDeleted
+	default:
+		return notification.EventStatusChanged
+	}
+}
+
+// queueNotionSyncs pushes the changed tasks to Notion one at a time, spaced by
+// notionSyncInterval, in the background
+func (s *Service) queueNotionSyncs(userID string, action BatchAction, tasks []*repository.Task) {
+	var queue []*repository.Task
+	for _, task := range tasks {
+		switch action {
+		case BatchActionArchive, BatchActionDelete:
+			if task.NotionPageID != nil && *task.NotionPageID != "" {
+				queue = append(queue, task)
+			}
+		default:
+			if task.DatabaseID != nil {
+				queue = append(queue, task)
+			}
+		}
+	}
+	if len(queue) == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(notionSyncInterval)
+		defer ticker.Stop()
+		for i, task := range queue {
+			if i > 0 {
+				<-ticker.C
+			}
+			ctx := context.Background()
+			var err error
+			if action == BatchActionArchive || action == BatchActionDelete {
+				// Archived and deleted tasks both move their page to the Notion trash
+				err = s.setNotionPageArchived(ctx, task, notionOwnerOf(task, userID), true)
+			} else {
+				err = s.SyncToNotion(ctx, task, notionOwnerOf(task, userID), *task.DatabaseID)
+			}
+			if err != nil {
+				s.logger.Error("failed to sync batch change to notion", zap.String("task_id", task.ID), zap.Error(err))
+			}
+		}
+	}()
+}
@@ -0,0 +1,181 @@
+package task
+
+import (
+	"context"
+	"errors"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+var (
+	// ErrCommentNotFound is returned for comments that do not exist, are
+	// deleted or belong to another task
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrCommentForbidden is returned when editing or deleting another user's comment
+	ErrCommentForbidden = errors.New("comment belongs to another user")
+)
+
+// CreateComment creates a new comment, or a reply when parentID is set. Users
+// @mentioned in it follow the task and are notified of the mention.
+func (s *Service) CreateComment(ctx context.Context, taskID, userID, content string, parentID *string) (*repository.TaskComment, error) {
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+	if parentID != nil {
+		parent, err := s.repo.GetCommentByID(ctx, *parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil || parent.TaskID != taskID {
+			return nil, ErrCommentNotFound
+		}
+	}
+
+	comment := &repository.TaskComment{
+		TaskID:   taskID,
+		UserID:   userID,
+		Content:  content,
+		ParentID: parentID,
+	}
+	createdComment, err := s.repo.CreateComment(ctx, comment)
+	if err != nil {
+		return nil, err
+	}
+	s.recordEvent(ctx, taskID, userID, repository.TaskEventComment, repository.TaskEventSourceApp, nil, map[string]interface{}{
+		"comment_id": createdComment.ID,
+		"parent_id":  createdComment.ParentID,
+		"content":    createdComment.Content,
+	})
+
+	// Mentioned users follow the task from now on, starting with this comment
+	mentioned := s.mentionedUsers(ctx, userID, content)
+	s.watchMentioned(ctx, task, mentioned)
+
+	if s.notifier != nil {
+		s.notifier.NotifyComment(ctx, task, userID, createdComment, userIDs(mentioned))
+	}
+
+	return createdComment, nil
+}
+
+// UpdateComment changes the content of one of userID's comments. Users
+// mentioned for the first time are subscribed and notified.
+func (s *Service) UpdateComment(ctx context.Context, taskID, commentID, userID, content string) (*repository.TaskComment, error) {
+	comment, err := s.ownComment(ctx, taskID, commentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Content == content {
+		return comment, nil
+	}
+
+	before := comment.Content
+	known := map[string]bool{}
+	for _, u := range s.mentionedUsers(ctx, userID, before) {
+		known[u.ID] = true
+	}
+
+	comment.Content = content
+	if err := s.repo.UpdateComment(ctx, comment); err != nil {
+		return nil, err
+	}
+	s.recordEvent(ctx, taskID, userID, repository.TaskEventComment, repository.TaskEventSourceApp,
+		map[string]interface{}{"comment_id": comment.ID, "content": before},
+		map[string]interface{}{"comment_id": comment.ID, "content": content})
+
+	var added []models.User
+	for _, u := range s.mentionedUsers(ctx, userID, content) {
+		if !known[u.ID] {
+			added = append(added, u)
+		}
+	}
+	if len(added) > 0 {
+		if task, err := s.repo.GetByID(ctx, taskID); err == nil && task != nil {
+			s.watchMentioned(ctx, task, added)
+			if s.notifier != nil {
+				s.notifier.NotifyMentions(ctx, task, userID, comment, userIDs(added))
+			}
+		}
+	}
+	return comment, nil
+}
+
+// DeleteComment deletes one of userID's comments. A comment with replies
+// stays in the thread as a deleted placeholder.
+func (s *Service) DeleteComment(ctx context.Context, taskID, commentID, userID string) error {
+	comment, err := s.ownComment(ctx, taskID, commentID, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.DeleteComment(ctx, commentID); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, taskID, userID, repository.TaskEventComment, repository.TaskEventSourceApp,
+		map[string]interface{}{"comment_id": comment.ID, "content": comment.Content},
+		map[string]interface{}{"comment_id": comment.ID, "deleted": true})
+	return nil
+}
+
+// ListComments lists comments for a task
+func (s *Service) ListComments(ctx context.Context, taskID string) ([]repository.TaskComment, error) {
+	return s.repo.ListComments(ctx, taskID)
+}
+
+// ListCommentThreads lists the comments of a task as threads: top-level
+// comments with their replies nested under Replies, oldest first
+func (s *Service) ListCommentThreads(ctx context.Context, taskID string) ([]*repository.TaskComment, error) {
+	comments, err := s.repo.ListComments(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	return buildCommentTree(comments), nil
+}
+
+// ownComment loads a live comment of the task and checks that userID wrote it
+func (s *Service) ownComment(ctx context.Context, taskID, commentID, userID string) (*repository.TaskComment, error) {
+	comment, err := s.repo.GetCommentByID(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment == nil || comment.TaskID != taskID || comment.DeletedAt != nil {
+		return nil, ErrCommentNotFound
+	}
+	if comment.UserID != userID {
+		return nil, ErrCommentForbidden
+	}
+	return comment, nil
+}
+
+// buildCommentTree nests comments (in creation order) under their parents.
+// Replies whose parent is missing are kept at the top level.
+func buildCommentTree(comments []repository.TaskComment) []*repository.TaskComment {
+	byID := make(map[string]*repository.TaskComment, len(comments))
+	for i := range comments {
+		byID[comments[i].ID] = &comments[i]
+	}
+	roots := []*repository.TaskComment{}
+	for i := range comments {
+		c := &comments[i]
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok && parent != c {
+				parent.Replies = append(parent.Replies, c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+	return roots
+}
+
+func userIDs(users []models.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
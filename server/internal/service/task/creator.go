@@ -16,36 +16,45 @@ import (
 
 // Creator handles task creation logic
 type Creator struct {
-	logger      *zap.Logger
-	taskRepo    repository.TaskRepository
-	taskService *Service // Use Service for Sync
-	updateRepo  repository.TelegramUpdateRepository
-	userRepo    repository.UserRepository
-	groupRepo   repository.GroupRepository
-	pendingRepo repository.PendingAssignmentRepository
+	logger       *zap.Logger
+	taskRepo     repository.TaskRepository
+	taskService  *Service // Use Service for Sync
+	updateRepo   repository.TelegramUpdateRepository
+	userRepo     repository.UserRepository
+	groupRepo    repository.GroupRepository
+	pendingRepo  repository.PendingAssignmentRepository
+	labelRepo    repository.LabelRepository
+	templateRepo repository.TemplateRepository
+	workflowRepo repository.WorkflowRepository
 }
 
 // CreatorConfig holds configuration for Creator
 type CreatorConfig struct {
-	Logger      *zap.Logger
-	TaskRepo    repository.TaskRepository
-	TaskService *Service
-	UpdateRepo  repository.TelegramUpdateRepository
-	UserRepo    repository.UserRepository
-	GroupRepo   repository.GroupRepository
-	PendingRepo repository.PendingAssignmentRepository
+	Logger       *zap.Logger
+	TaskRepo     repository.TaskRepository
+	TaskService  *Service
+	UpdateRepo   repository.TelegramUpdateRepository
+	UserRepo     repository.UserRepository
+	GroupRepo    repository.GroupRepository
+	PendingRepo  repository.PendingAssignmentRepository
+	LabelRepo    repository.LabelRepository
+	TemplateRepo repository.TemplateRepository // Optional: enables tpl:<name>
+	WorkflowRepo repository.WorkflowRepository // Optional: per-group statuses
 }
 
 // NewCreator creates a new task creator
 func NewCreator(cfg CreatorConfig) *Creator {
 	return &Creator{
-		logger:      cfg.Logger,
-		taskRepo:    cfg.TaskRepo,
-		taskService: cfg.TaskService,
-		updateRepo:  cfg.UpdateRepo,
-		userRepo:    cfg.UserRepo,
-		groupRepo:   cfg.GroupRepo,
-		pendingRepo: cfg.PendingRepo,
+		logger:       cfg.Logger,
+		taskRepo:     cfg.TaskRepo,
+		taskService:  cfg.TaskService,
+		updateRepo:   cfg.UpdateRepo,
+		userRepo:     cfg.UserRepo,
+		groupRepo:    cfg.GroupRepo,
+		pendingRepo:  cfg.PendingRepo,
+		labelRepo:    cfg.LabelRepo,
+		templateRepo: cfg.TemplateRepo,
+		workflowRepo: cfg.WorkflowRepo,
 	}
 }
 
@@ -59,9 +68,13 @@ type CreateInput struct {
 	ReplyToID       int64 // Optional: Message ID being replied to
 	AnchorMessageID int64 // Optional: Message ID to anchor context (fetch messages before this)
 	ThreadID        int64 // Optional: For Telegram Topics
+	// Attachments holds the media of the command and of the replied message
+	Attachments []repository.TaskAttachment
 }
 
-// CreateTask creates a task from telegram input
+// CreateTask creates a task from telegram input. With "tpl:<name>" in the
+// text, the task is laid out from that template (see ExpandPlaceholders); it
+// returns ErrTemplateNotFound when the name does not resolve.
 func (c *Creator) CreateTask(ctx context.Context, input CreateInput) (*repository.Task, []string, error) {
 	// ... (rest of function, captureContext call needs update)
 	// Actually I can just update the captureContext call site in CreateTask function body and the function definition.
@@ -74,8 +87,13 @@ func (c *Creator) CreateTask(ctx context.Context, input CreateInput) (*repositor
 		return nil, nil, fmt.Errorf("failed to get creator: %w", err)
 	}
 
-	// 2. Parse Text (Title & Assignees)
-	title, assigneeNames := c.parseCommand(input.Text)
+	// 2. Parse Text (Title, Assignees, Priority & Labels)
+	parsed := c.parseCommand(input.Text)
+	title, assigneeNames := parsed.Title, parsed.Mentions
+	if title == "" && len(input.Attachments) > 0 {
+		// A bare "/todo" on a screenshot: name the task after the media
+		title = mediaPlaceholder(input.Attachments[0].Kind, input.Attachments[0].FileName)
+	}
 
 	// 3. Resolve Assignees
 	var assignees []models.User
@@ -95,11 +113,6 @@ func (c *Creator) CreateTask(ctx context.Context, input CreateInput) (*repositor
 		}
 	}
 
-	// 78. Fallback: If no assignees (and no pending), assign to creator
-	if len(assignees) == 0 && len(pendingAssignees) == 0 {
-		assignees = append(assignees, *creator)
-	}
-
 	// 4. Capture Context (Last 10 messages)
 	// If AnchorMessageID is set, use it. Otherwise use ReplyToID if present (old behavior?), or just current?
 	// The requirement: "Timeline save should be reference message above 10 entries".
@@ -157,16 +170,64 @@ func (c *Creator) CreateTask(ctx context.Context, input CreateInput) (*repositor
 		}
 	}
 
+	// Resolve Template (tpl:bug)
+	var tmpl *repository.TaskTemplate
+	if parsed.Template != "" {
+		tmpl, err = resolveTemplate(ctx, c.templateRepo, creator.ID, groupID, parsed.Template)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Default assignees apply when the command mentions nobody
+		if len(assigneeNames) == 0 {
+			for _, id := range tmpl.AssigneeIDs {
+				if user, err := c.userRepo.FindByID(ctx, id); err == nil && user != nil {
+					assignees = append(assignees, *user)
+				}
+			}
+		}
+	}
+
+	// Fallback: If no assignees (and no pending), assign to creator
+	if len(assignees) == 0 && len(pendingAssignees) == 0 {
+		assignees = append(assignees, *creator)
+	}
+
+	// Resolve Labels (#bug -> "bug"), on top of the template labels
+	var labels []repository.Label
+	labelNames := parsed.Labels
+	if tmpl != nil {
+		labelNames = mergeLabelNames(tmpl.Labels, parsed.Labels)
+	}
+	if len(labelNames) > 0 && c.labelRepo != nil {
+		labels, err = c.labelRepo.FindOrCreate(ctx, labelNames)
+		if err != nil {
+			c.logger.Warn("failed to resolve labels", zap.Strings("labels", labelNames), zap.Error(err))
+			labels = nil
+		}
+	}
+
 	// 6. Create Task in DB (DB FIRST)
 	task := &repository.Task{
-		Title:      title,
-		Status:     repository.TaskStatusToDo,
-		SyncStatus: repository.TaskSyncStatusPending,
-		CreatorID:  &creator.ID,
-		Assignees:  assignees,
-		Snapshots:  snapshots,
-		GroupID:    groupID,
-		DatabaseID: databaseID,
+		Title:       title,
+		Priority:    parsed.Priority,
+		SyncStatus:  repository.TaskSyncStatusPending,
+		CreatorID:   &creator.ID,
+		Assignees:   assignees,
+		Labels:      labels,
+		Snapshots:   snapshots,
+		Attachments: withUploader(input.Attachments, creator.ID),
+		GroupID:     groupID,
+		DatabaseID:  databaseID,
+	}
+	task.SetStatus(initialStatus(loadWorkflow(ctx, c.logger, c.workflowRepo, groupID, databaseID)))
+
+	vars := TemplateVars{Input: title, Chat: input.ChatTitle, User: creator.Name, Now: time.Now()}
+	if tmpl != nil {
+		applyTemplate(task, tmpl, vars)
+		// An explicit !high / p1 marker overrides the template priority
+		if parsed.HasPriority {
+			task.Priority = parsed.Priority
+		}
 	}
 
 	if input.ThreadID != 0 {
@@ -186,15 +247,21 @@ func (c *Creator) CreateTask(ctx context.Context, input CreateInput) (*repositor
 	if err := c.taskRepo.Create(ctx, task); err != nil {
 		return nil, nil, fmt.Errorf("failed to create task: %w", err)
 	}
+	after := taskEventState(task)
+	if len(pendingAssignees) > 0 {
+		after["pending_assignees"] = pendingAssignees
+	}
+	recordTaskEvent(ctx, c.logger, c.taskRepo, task.ID, creator.ID, repository.TaskEventCreate, repository.TaskEventSourceBot, nil, after)
+
+	var subtasks []*repository.Task
+	if tmpl != nil {
+		subtasks = createTemplateSubtasks(ctx, c.logger, c.taskRepo, task, tmpl, vars, creator.ID, repository.TaskEventSourceBot)
+	}
 
 	// 7. Sync to Notion
 	if creator.NotionConnected && databaseID != nil && *databaseID != "" {
 		// Use Service to Sync
-		go func() {
-			if err := c.taskService.SyncToNotion(context.Background(), task, creator.ID, *databaseID); err != nil {
-				c.logger.Error("async sync failed", zap.Error(err))
-			}
-		}()
+		go c.taskService.syncWithSubtasks(task, subtasks, creator.ID)
 	} else {
 		c.logger.Info("skipping notion sync",
 			zap.Bool("user_connected", creator.NotionConnected),
@@ -237,22 +304,122 @@ func (c *Creator) generateJumpURL(chatID int64, messageID int64) string {
 	return fmt.Sprintf("https://t.me/c/%s/%d", chatIDStr, messageID)
 }
 
-// parseCommand extracts title and mentions from text
-// Example: "@Bot fix bug @alice" -> Title: "fix bug", Mentions: ["@alice"]
-func (c *Creator) parseCommand(text string) (string, []string) {
-	// Regex to find mentions
-	re := regexp.MustCompile(`@\w+`)
-	mentions := re.FindAllString(text, -1)
+// parsedCommand holds the pieces extracted from a /todo text
+type parsedCommand struct {
+	Title    string
+	Mentions []string
+	Priority repository.TaskPriority
+	// HasPriority is set when the text carries a priority marker
+	HasPriority bool
+	Labels      []string // Normalized hashtags, e.g. "#Bug" -> "bug"
+	Template    string   // Name given as tpl:<name>
+}
+
+var (
+	mentionPattern = regexp.MustCompile(`@\w+`)
+	// hashtagPattern matches "#bug" / "#前端"; tags must start with a letter so "#123" stays in the title
+	hashtagPattern = regexp.MustCompile(`(?:^|\s)#(\p{L}[\p{L}\p{N}_-]*)`)
+	// priorityPattern matches standalone markers like "!high", "!紧急" or "p1"
+	priorityPattern = regexp.MustCompile(`(?i)(?:^|\s)(![\p{L}]+|p[1-3])(?:\s|$)`)
+	// templatePattern matches "tpl:bug", selecting a task template
+	templatePattern = regexp.MustCompile(`(?i)(?:^|\s)tpl:([\p{L}\p{N}_-]+)`)
+)
+
+// priorityMarkers maps lower-cased markers to priorities
+var priorityMarkers = map[string]repository.TaskPriority{
+	"!high":   repository.TaskPriorityHigh,
+	"!urgent": repository.TaskPriorityHigh,
+	"!高":      repository.TaskPriorityHigh,
+	"!紧急":     repository.TaskPriorityHigh,
+	"p1":      repository.TaskPriorityHigh,
+	"!medium": repository.TaskPriorityMedium,
+	"!med":    repository.TaskPriorityMedium,
+	"!中":      repository.TaskPriorityMedium,
+	"p2":      repository.TaskPriorityMedium,
+	"!low":    repository.TaskPriorityLow,
+	"!低":      repository.TaskPriorityLow,
+	"p3":      repository.TaskPriorityLow,
+}
+
+// parseCommand extracts title, mentions, priority and labels from text
+// Example: "@Bot fix bug @alice !high #backend" -> Title: "fix bug", Mentions: ["@alice"], Priority: High, Labels: ["backend"]
+func (c *Creator) parseCommand(text string) parsedCommand {
+	mentions := mentionPattern.FindAllString(text, -1)
 
 	// Remove mentions from text to get title
-	title := re.ReplaceAllString(text, "")
-	title = strings.TrimSpace(title)
+	title := mentionPattern.ReplaceAllString(text, "")
+
+	// Template: the first tpl:<name> selects it
+	template := ""
+	if m := templatePattern.FindStringSubmatch(title); m != nil {
+		template = repository.NormalizeTemplateName(m[1])
+	}
+	title = templatePattern.ReplaceAllString(title, " ")
+
+	// Labels: pull hashtags out of the title
+	var labels []string
+	seen := make(map[string]bool)
+	for _, m := range hashtagPattern.FindAllStringSubmatch(title, -1) {
+		label := repository.NormalizeLabel(m[1])
+		if !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+	}
+	title = hashtagPattern.ReplaceAllString(title, " ")
+
+	// Priority: the last recognised marker wins, unknown "!words" stay in the title
+	priority := repository.TaskPriorityMedium
+	hasPriority := false
+	title = priorityPattern.ReplaceAllStringFunc(title, func(match string) string {
+		marker := strings.ToLower(strings.TrimSpace(match))
+		p, ok := priorityMarkers[marker]
+		if !ok {
+			return match
+		}
+		priority, hasPriority = p, true
+		return " "
+	})
+	title = strings.Join(strings.Fields(title), " ")
 
 	// Remove leading command if present (e.g. /todo)
 	title = strings.TrimPrefix(title, "/todo")
 	title = strings.TrimSpace(title)
 
-	return title, mentions
+	return parsedCommand{
+		Title:       title,
+		Mentions:    mentions,
+		Priority:    priority,
+		HasPriority: hasPriority,
+		Labels:      labels,
+		Template:    template,
+	}
+}
+
+// mergeLabelNames appends the names of extra missing from base
+func mergeLabelNames(base, extra []string) []string {
+	merged := append([]string{}, base...)
+	for _, name := range extra {
+		found := false
+		for _, b := range merged {
+			if b == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
+
+// withUploader records who added the attachments
+func withUploader(attachments []repository.TaskAttachment, userID string) []repository.TaskAttachment {
+	for i := range attachments {
+		attachments[i].UploadedBy = &userID
+	}
+	return attachments
 }
 
 // captureContext retrieves recent messages from telegram_updates
@@ -274,7 +441,9 @@ func (c *Creator) captureContext(ctx context.Context, chatID int64, anchorID int
 					ID        int64  `json:"id"`
 					FirstName string `json:"first_name"`
 				} `json:"from"`
-				Text string `json:"text"`
+				Text    string `json:"text"`
+				Caption string `json:"caption"`
+				MessageMedia
 			} `json:"message"`
 		}
 
@@ -282,7 +451,12 @@ func (c *Creator) captureContext(ctx context.Context, chatID int64, anchorID int
 			continue // Skip broken updates
 		}
 
-		if payload.Message.Text == "" {
+		// Media messages keep their caption behind a placeholder like "[图片]"
+		text := payload.Message.Text
+		if text == "" {
+			text = strings.TrimSpace(payload.Message.Placeholder() + " " + payload.Message.Caption)
+		}
+		if text == "" {
 			continue
 		}
 
@@ -295,7 +469,7 @@ func (c *Creator) captureContext(ctx context.Context, chatID int64, anchorID int
 		snapshots = append(snapshots, repository.TaskContextSnapshot{
 			Role:        role,
 			Author:      payload.Message.From.FirstName,
-			Text:        payload.Message.Text,
+			Text:        text,
 			TgMessageID: payload.Message.MessageID,
 		})
 	}
@@ -319,14 +493,16 @@ func (c *Creator) CreatePersonalTask(ctx context.Context, input CreateInput, met
 
 	// 3. Create Task
 	task := &repository.Task{
-		Title:      input.Text,
-		Status:     repository.TaskStatusToDo,
-		SyncStatus: repository.TaskSyncStatusPending,
-		CreatorID:  &creator.ID,
-		DatabaseID: databaseID,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		Title:       input.Text,
+		Priority:    repository.TaskPriorityMedium,
+		SyncStatus:  repository.TaskSyncStatusPending,
+		CreatorID:   &creator.ID,
+		DatabaseID:  databaseID,
+		Attachments: withUploader(input.Attachments, creator.ID),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
+	task.SetStatus(initialStatus(loadWorkflow(ctx, c.logger, c.workflowRepo, nil, databaseID)))
 
 	if source, ok := meta["source"].(string); ok {
 		task.Description = "Forwarded source: " + source
@@ -335,6 +511,7 @@ func (c *Creator) CreatePersonalTask(ctx context.Context, input CreateInput, met
 	if err := c.taskRepo.Create(ctx, task); err != nil {
 		return nil, fmt.Errorf("failed to create task: %w", err)
 	}
+	recordTaskEvent(ctx, c.logger, c.taskRepo, task.ID, creator.ID, repository.TaskEventCreate, repository.TaskEventSourceBot, nil, taskEventState(task))
 
 	// 4. Sync to Notion (Optional)
 	if creator.NotionConnected && databaseID != nil && *databaseID != "" {
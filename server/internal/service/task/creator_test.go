@@ -3,6 +3,8 @@ package task
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/layababa/tg_todo/server/internal/models"
 	"github.com/layababa/tg_todo/server/internal/repository"
 	pkgnotion "github.com/layababa/tg_todo/server/pkg/notion"
+	"github.com/layababa/tg_todo/server/pkg/security"
 )
 
 func TestCreatorCreateTaskAssignsToMentionedUserOnly(t *testing.T) {
@@ -61,6 +64,7 @@ func TestCreatorCreateTaskAssignsToMentionedUserOnly(t *testing.T) {
 	created := mockTaskRepo.createdTasks[0]
 	assert.Equal(t, "修复Webhook", created.Title)
 	assert.Equal(t, repository.TaskStatusToDo, created.Status)
+	assert.Equal(t, repository.TaskPriorityMedium, created.Priority)
 	assert.Equal(t, repository.TaskSyncStatusPending, created.SyncStatus)
 	require.NotNil(t, created.CreatorID)
 	assert.Equal(t, "creator-uuid", *created.CreatorID)
@@ -68,6 +72,16 @@ func TestCreatorCreateTaskAssignsToMentionedUserOnly(t *testing.T) {
 	assert.Len(t, created.Assignees, 1)
 	assert.Equal(t, "alice-uuid", created.Assignees[0].ID)
 	assert.Empty(t, created.Snapshots)
+
+	require.Len(t, mockTaskRepo.events, 1)
+	ev := mockTaskRepo.events[0]
+	assert.Equal(t, repository.TaskEventCreate, ev.Event)
+	assert.Equal(t, repository.TaskEventSourceBot, ev.Source)
+	assert.Equal(t, created.ID, ev.TaskID)
+	require.NotNil(t, ev.ActorID)
+	assert.Equal(t, "creator-uuid", *ev.ActorID)
+	assert.Nil(t, ev.Before)
+	assert.Contains(t, string(ev.After), `"assignee_ids":["alice-uuid"]`)
 }
 
 func TestCreatorCreateTaskIgnoresUnknownMentions(t *testing.T) {
@@ -140,10 +154,373 @@ func TestParseCommandRemovesTodoPrefixAndMentions(t *testing.T) {
 	t.Parallel()
 
 	c := &Creator{}
-	title, mentions := c.parseCommand("/todo Deploy Preview @alice @bob")
+	parsed := c.parseCommand("/todo Deploy Preview @alice @bob")
+
+	assert.Equal(t, "Deploy Preview", parsed.Title)
+	assert.Equal(t, []string{"@alice", "@bob"}, parsed.Mentions)
+	assert.Equal(t, repository.TaskPriorityMedium, parsed.Priority)
+}
+
+func TestParseCommandExtractsPriority(t *testing.T) {
+	t.Parallel()
+
+	c := &Creator{}
+	cases := []struct {
+		text     string
+		title    string
+		priority repository.TaskPriority
+	}{
+		{"/todo Fix login !high @alice", "Fix login", repository.TaskPriorityHigh},
+		{"/todo p1 线上故障", "线上故障", repository.TaskPriorityHigh},
+		{"/todo 整理文档 !低", "整理文档", repository.TaskPriorityLow},
+		{"/todo Refactor P3", "Refactor", repository.TaskPriorityLow},
+		{"/todo Say hi!now", "Say hi!now", repository.TaskPriorityMedium},
+		{"/todo Upgrade p10 cluster", "Upgrade p10 cluster", repository.TaskPriorityMedium},
+	}
+
+	for _, tc := range cases {
+		parsed := c.parseCommand(tc.text)
+		assert.Equal(t, tc.title, parsed.Title, tc.text)
+		assert.Equal(t, tc.priority, parsed.Priority, tc.text)
+	}
+}
+
+func TestParseCommandExtractsLabels(t *testing.T) {
+	t.Parallel()
+
+	c := &Creator{}
+	parsed := c.parseCommand("/todo Fix crash #Bug #frontend #bug @alice")
+	assert.Equal(t, "Fix crash", parsed.Title)
+	assert.Equal(t, []string{"bug", "frontend"}, parsed.Labels)
+	assert.Equal(t, []string{"@alice"}, parsed.Mentions)
+
+	parsed = c.parseCommand("/todo 跟进 issue #123 #前端")
+	assert.Equal(t, "跟进 issue #123", parsed.Title)
+	assert.Equal(t, []string{"前端"}, parsed.Labels)
+}
+
+func TestParseCommandExtractsTemplate(t *testing.T) {
+	t.Parallel()
+
+	c := &Creator{}
+	parsed := c.parseCommand("/todo tpl:Bug Login broken !high")
+	assert.Equal(t, "bug", parsed.Template)
+	assert.Equal(t, "Login broken", parsed.Title)
+	assert.True(t, parsed.HasPriority)
+
+	parsed = c.parseCommand("/todo 看下 html:tpl:x")
+	assert.Empty(t, parsed.Template)
+	assert.False(t, parsed.HasPriority)
+}
+
+func TestCreatorCreateTaskFromTemplate(t *testing.T) {
+	t.Parallel()
+
+	groupID := "-1001"
+	offset := 60
+	templates := &fakeTemplateRepo{templates: []repository.TaskTemplate{{
+		ID:               "tpl-1",
+		Name:             "bug",
+		GroupID:          &groupID,
+		TitlePattern:     "[Bug] {input}（{chat}）",
+		Description:      "报告人：{user}",
+		Priority:         repository.TaskPriorityHigh,
+		AssigneeIDs:      []string{"qa-uuid"},
+		Labels:           []string{"bug"},
+		DueOffsetMinutes: &offset,
+		Subtasks:         []string{"复现 {input}", "修复"},
+	}}}
+	mockTaskRepo := &mockTaskRepo{}
+	creator := NewCreator(CreatorConfig{
+		Logger:       zap.NewNop(),
+		TaskRepo:     mockTaskRepo,
+		TaskService:  &Service{},
+		UpdateRepo:   &mockUpdateRepo{},
+		UserRepo:     &mockUserRepo{byTG: map[int64]*models.User{111: {ID: "creator-uuid", TgID: 111, Name: "Owner"}}},
+		GroupRepo:    &mockGroupRepo{group: &models.Group{ID: groupID, Title: "研发群"}},
+		TemplateRepo: templates,
+	})
+
+	before := time.Now()
+	created, _, err := creator.CreateTask(context.Background(), CreateInput{
+		ChatID:    -1001,
+		ChatTitle: "研发群",
+		CreatorID: 111,
+		Text:      "/todo tpl:bug Login broken",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "[Bug] Login broken（研发群）", created.Title)
+	assert.Equal(t, "报告人：Owner", created.Description)
+	assert.Equal(t, repository.TaskPriorityHigh, created.Priority)
+	require.NotNil(t, created.DueAt)
+	assert.WithinDuration(t, before.Add(time.Hour), *created.DueAt, time.Minute)
+	require.Len(t, created.Assignees, 1)
+	assert.Equal(t, "qa-uuid", created.Assignees[0].ID)
+
+	require.Len(t, mockTaskRepo.createdTasks, 3)
+	assert.Equal(t, "复现 Login broken", mockTaskRepo.createdTasks[1].Title)
+	assert.Equal(t, "修复", mockTaskRepo.createdTasks[2].Title)
+	require.NotNil(t, mockTaskRepo.createdTasks[1].ParentID)
+	assert.Equal(t, created.ID, *mockTaskRepo.createdTasks[1].ParentID)
+
+	_, _, err = creator.CreateTask(context.Background(), CreateInput{ChatID: -1001, CreatorID: 111, Text: "/todo tpl:nope x"})
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestCreatorCreateTaskWithAttachment(t *testing.T) {
+	t.Parallel()
+
+	mockTaskRepo := &mockTaskRepo{}
+	creator := NewCreator(CreatorConfig{
+		Logger:      zap.NewNop(),
+		TaskRepo:    mockTaskRepo,
+		TaskService: &Service{},
+		UpdateRepo:  &mockUpdateRepo{},
+		UserRepo:    &mockUserRepo{byTG: map[int64]*models.User{111: {ID: "creator-uuid", TgID: 111}}},
+		GroupRepo:   &mockGroupRepo{},
+	})
+
+	media := MessageMedia{Photo: []MediaFile{
+		{FileID: "small", Width: 90, Height: 90},
+		{FileID: "large", FileUniqueID: "uniq", Width: 1280, Height: 720, FileSize: 2048},
+	}}
+	attachment := media.Attachment(42, "登录页报错")
+	require.NotNil(t, attachment)
+
+	created, _, err := creator.CreateTask(context.Background(), CreateInput{
+		ChatID:      -1001,
+		CreatorID:   111,
+		Text:        "/todo",
+		Attachments: []repository.TaskAttachment{*attachment},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "[图片]", created.Title)
+	require.Len(t, created.Attachments, 1)
+	assert.Equal(t, "large", created.Attachments[0].FileID)
+	assert.Equal(t, repository.AttachmentKindPhoto, created.Attachments[0].Kind)
+	assert.Equal(t, int64(42), created.Attachments[0].TgMessageID)
+	require.NotNil(t, created.Attachments[0].UploadedBy)
+	assert.Equal(t, "creator-uuid", *created.Attachments[0].UploadedBy)
+}
+
+func TestMessageMediaPlaceholder(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", MessageMedia{}.Placeholder())
+	assert.Nil(t, MessageMedia{}.Attachment(1, ""))
+	assert.Equal(t, "[文件 report.pdf]", MessageMedia{Document: &MediaFile{FileID: "f", FileName: "report.pdf"}}.Placeholder())
+	assert.Equal(t, "[语音]", MessageMedia{Voice: &MediaFile{FileID: "v"}}.Placeholder())
+}
+
+func TestAttachmentURLSignature(t *testing.T) {
+	t.Parallel()
+
+	svc := NewService(ServiceConfig{Logger: zap.NewNop(), PublicBaseURL: "https://todo.example.com/", FileLinkSecret: "secret"})
+	link := svc.AttachmentURL("att-1")
+	require.True(t, strings.HasPrefix(link, "https://todo.example.com/files/att-1?sig="))
+	sig := strings.TrimPrefix(link, "https://todo.example.com/files/att-1?sig=")
+	assert.True(t, svc.VerifyAttachmentSignature("att-1", sig))
+	assert.False(t, svc.VerifyAttachmentSignature("att-2", sig))
+
+	assert.Empty(t, NewService(ServiceConfig{Logger: zap.NewNop()}).AttachmentURL("att-1"))
+}
+
+func TestExpandPlaceholders(t *testing.T) {
+	t.Parallel()
+
+	vars := TemplateVars{Input: "登录失败", Chat: "研发群", User: "Alice", Now: time.Date(2026, 3, 2, 9, 5, 0, 0, time.UTC)}
+	assert.Equal(t, "2026-03-02 周一 09:05 研发群 Alice 登录失败 {unknown}",
+		ExpandPlaceholders("{date} {weekday} {time} {chat} {user} {input} {unknown}", vars))
+}
+
+type fakeTemplateRepo struct {
+	templates []repository.TaskTemplate
+}
+
+func (f *fakeTemplateRepo) Create(_ context.Context, tmpl *repository.TaskTemplate) error {
+	f.templates = append(f.templates, *tmpl)
+	return nil
+}
+
+func (f *fakeTemplateRepo) Update(context.Context, *repository.TaskTemplate) error { return nil }
+
+func (f *fakeTemplateRepo) Delete(context.Context, string) error { return nil }
+
+func (f *fakeTemplateRepo) GetByID(_ context.Context, id string) (*repository.TaskTemplate, error) {
+	for i := range f.templates {
+		if f.templates[i].ID == id {
+			return &f.templates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeTemplateRepo) GetUserTemplate(_ context.Context, userID, name string) (*repository.TaskTemplate, error) {
+	for i, tmpl := range f.templates {
+		if tmpl.GroupID == nil && tmpl.UserID != nil && *tmpl.UserID == userID && tmpl.Name == name {
+			return &f.templates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeTemplateRepo) GetGroupTemplate(_ context.Context, groupID, name string) (*repository.TaskTemplate, error) {
+	for i, tmpl := range f.templates {
+		if tmpl.GroupID != nil && *tmpl.GroupID == groupID && tmpl.Name == name {
+			return &f.templates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeTemplateRepo) List(context.Context, string, string) ([]repository.TaskTemplate, error) {
+	return f.templates, nil
+}
+
+func TestCloneTaskCopiesContentAndSubtasks(t *testing.T) {
+	t.Parallel()
+
+	groupID := "group-1"
+	repo := &mockTaskRepo{createdTasks: []*repository.Task{
+		{
+			ID:          "src",
+			Title:       "修复登录",
+			Description: "登录页 500",
+			Status:      repository.TaskStatusDone,
+			GroupID:     &groupID,
+			Topic:       "42",
+			Labels:      []repository.Label{{ID: "l1", Name: "bug"}},
+			Snapshots:   []repository.TaskContextSnapshot{{ID: "s1", TaskID: "src", Role: repository.ContextRoleOther, Text: "报错截图"}},
+		},
+		{ID: "sub", Title: "复现", ParentID: strPtr("src"), GroupID: &groupID},
+	}}
+	svc := NewService(ServiceConfig{Logger: zap.NewNop(), Repo: repo, UserRepo: &mockUserRepo{}})
+
+	clone, err := svc.CloneTask(context.Background(), "user-1", "src", MoveParams{})
+	require.NoError(t, err)
+	assert.NotEqual(t, "src", clone.ID)
+	assert.Equal(t, "修复登录", clone.Title)
+	assert.Equal(t, "登录页 500", clone.Description)
+	assert.Equal(t, repository.TaskStatusToDo, clone.Status)
+	assert.Equal(t, "42", clone.Topic)
+	assert.Equal(t, "user-1", *clone.CreatorID)
+	require.Len(t, clone.Labels, 1)
+	assert.Equal(t, "bug", clone.Labels[0].Name)
+	require.Len(t, clone.Snapshots, 1)
+	assert.Empty(t, clone.Snapshots[0].ID)
+	assert.Equal(t, "报错截图", clone.Snapshots[0].Text)
+
+	subtasks, _ := repo.ListSubtasks(context.Background(), clone.ID)
+	require.Len(t, subtasks, 1)
+	assert.Equal(t, "复现", subtasks[0].Title)
+	assert.Contains(t, string(repo.events[0].After), `"cloned_from":"src"`)
+}
+
+func TestMoveTaskMovesSubtasksAndResetsNotion(t *testing.T) {
+	t.Parallel()
+
+	from, pageID, databaseID := "group-1", "page-1", "db-1"
+	repo := &mockTaskRepo{createdTasks: []*repository.Task{
+		{ID: "parent", GroupID: &from, DatabaseID: &databaseID, NotionPageID: &pageID, Topic: "42"},
+		{ID: "sub", ParentID: strPtr("parent"), GroupID: &from, DatabaseID: &databaseID},
+	}}
+	svc := NewService(ServiceConfig{
+		Logger:    zap.NewNop(),
+		Repo:      repo,
+		UserRepo:  &mockUserRepo{},
+		GroupRepo: &mockGroupRepo{group: &models.Group{ID: "group-2"}},
+	})
+
+	moved, err := svc.MoveTask(context.Background(), "user-1", "parent", MoveParams{GroupID: strPtr("group-2")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"parent", "sub"}, repo.movedIDs)
+	assert.Equal(t, "group-2", *moved.GroupID)
+	assert.Nil(t, moved.DatabaseID, "group-2 has no Notion database")
+	assert.Empty(t, moved.Topic)
+	assert.Nil(t, moved.NotionPageID)
+	assert.Equal(t, repository.TaskSyncStatusPending, moved.SyncStatus)
 
-	assert.Equal(t, "Deploy Preview", title)
-	assert.Equal(t, []string{"@alice", "@bob"}, mentions)
+	_, err = svc.MoveTask(context.Background(), "user-1", "sub", MoveParams{GroupID: strPtr("")})
+	assert.ErrorIs(t, err, ErrMoveSubtask)
+}
+
+func TestMoveTaskUnknownGroup(t *testing.T) {
+	t.Parallel()
+
+	repo := &mockTaskRepo{createdTasks: []*repository.Task{{ID: "task-1"}}}
+	svc := NewService(ServiceConfig{
+		Logger:    zap.NewNop(),
+		Repo:      repo,
+		GroupRepo: &mockGroupRepo{err: errors.New("not found")},
+	})
+
+	_, err := svc.MoveTask(context.Background(), "user-1", "task-1", MoveParams{GroupID: strPtr("missing")})
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+	assert.Empty(t, repo.movedIDs)
+}
+
+func TestMoveTaskToPersonalUsesCreatorDatabase(t *testing.T) {
+	t.Parallel()
+
+	groupID := "group-1"
+	repo := &mockTaskRepo{createdTasks: []*repository.Task{{ID: "task-1", CreatorID: strPtr("user-1"), GroupID: &groupID}}}
+	svc := NewService(ServiceConfig{
+		Logger: zap.NewNop(),
+		Repo:   repo,
+		UserRepo: &mockUserRepo{byID: map[string]*models.User{
+			"user-1": {ID: "user-1", DefaultDatabaseID: strPtr("db-creator")},
+			"user-2": {ID: "user-2", DefaultDatabaseID: strPtr("db-admin")},
+		}},
+	})
+
+	// A group admin moving the task out of the group hands it back to its creator
+	moved, err := svc.MoveTask(context.Background(), "user-2", "task-1", MoveParams{GroupID: strPtr("")})
+	require.NoError(t, err)
+	assert.Nil(t, moved.GroupID)
+	require.NotNil(t, moved.DatabaseID)
+	assert.Equal(t, "db-creator", *moved.DatabaseID)
+}
+
+func TestMoveTaskChecksDatabaseAccess(t *testing.T) {
+	t.Parallel()
+
+	encryptionKey := "test-key"
+	tokenEnc, _ := security.Encrypt("access-token", encryptionKey)
+	repo := &mockTaskRepo{createdTasks: []*repository.Task{
+		{ID: "task-1", CreatorID: strPtr("user-1")},
+		{ID: "task-2", CreatorID: strPtr("user-1")},
+	}}
+	users := &mockUserRepo{
+		byID: map[string]*models.User{"user-1": {ID: "user-1", DefaultDatabaseID: strPtr("db-default")}},
+		// Only user-2 connected Notion
+		notionTokens: map[string]*models.UserNotionToken{"user-2": {UserID: "user-2", AccessTokenEnc: tokenEnc}},
+	}
+	stub := &stubNotionClient{}
+	svc := NewService(ServiceConfig{Logger: zap.NewNop(), Repo: repo, UserRepo: users, EncryptionKey: encryptionKey})
+	svc.notionClient = func(string) pkgnotion.Client { return stub }
+
+	// Without a Notion connection only the user's default database is known
+	_, err := svc.MoveTask(context.Background(), "user-1", "task-1", MoveParams{DatabaseID: strPtr("db-other")})
+	assert.ErrorIs(t, err, ErrDatabaseForbidden)
+	_, err = svc.CloneTask(context.Background(), "user-1", "task-1", MoveParams{DatabaseID: strPtr("db-other")})
+	assert.ErrorIs(t, err, ErrDatabaseForbidden)
+	assert.Empty(t, repo.movedIDs)
+
+	// Other databases need to be readable with the user's Notion token
+	_, err = svc.MoveTask(context.Background(), "user-2", "task-1", MoveParams{DatabaseID: strPtr("db-other")})
+	assert.ErrorIs(t, err, ErrDatabaseForbidden, "the database cannot be read")
+	stub.database = &gonotion.Database{ID: "db-other"}
+	moved, err := svc.MoveTask(context.Background(), "user-2", "task-1", MoveParams{DatabaseID: strPtr("db-other")})
+	require.NoError(t, err)
+	assert.Equal(t, "db-other", *moved.DatabaseID)
+
+	moved, err = svc.MoveTask(context.Background(), "user-1", "task-2", MoveParams{DatabaseID: strPtr("db-default")})
+	require.NoError(t, err)
+	assert.Equal(t, "db-default", *moved.DatabaseID)
+}
+
+func strPtr(s string) *string {
+	return &s
 }
 
 type mockTaskRepo struct {
@@ -152,12 +529,17 @@ type mockTaskRepo struct {
 	updateErr         error
 	updateStatusCalls int
 	lastUpdatedTask   *repository.Task
+	events            []repository.TaskEvent
+	movedIDs          []string
 }
 
 func (m *mockTaskRepo) Create(_ context.Context, task *repository.Task) error {
 	if m.err != nil {
 		return m.err
 	}
+	if task.ID == "" {
+		task.ID = fmt.Sprintf("task-%d", len(m.createdTasks)+1)
+	}
 	m.createdTasks = append(m.createdTasks, task)
 	return nil
 }
@@ -200,10 +582,81 @@ func (m *mockTaskRepo) UpdateReminderFlags(ctx context.Context, id string, remin
 	return nil
 }
 
-func (m *mockTaskRepo) AssignTask(ctx context.Context, taskID, userID string) error {
+func (m *mockTaskRepo) SnoozeReminders(ctx context.Context, id string, until time.Time) error {
+	return nil
+}
+
+func (m *mockTaskRepo) UpdateRecurrence(_ context.Context, _ *repository.Task) error {
+	return nil
+}
+
+func (m *mockTaskRepo) ListRecurringToSpawn(_ context.Context, _ time.Time) ([]repository.Task, error) {
+	return nil, nil
+}
+
+func (m *mockTaskRepo) SetRecurrenceSpawned(_ context.Context, _ string, _ bool) (bool, error) {
+	return true, nil
+}
+
+func (m *mockTaskRepo) Search(_ context.Context, _ string, _ repository.TaskSearchFilter) ([]repository.TaskSearchHit, error) {
+	return nil, nil
+}
+
+func (m *mockTaskRepo) ListSubtasks(_ context.Context, parentID string) ([]repository.Task, error) {
+	var subtasks []repository.Task
+	for _, t := range m.createdTasks {
+		if t.ParentID != nil && *t.ParentID == parentID {
+			subtasks = append(subtasks, *t)
+		}
+	}
+	return subtasks, nil
+}
+
+func (m *mockTaskRepo) Move(_ context.Context, ids []string, groupID, databaseID *string, statuses map[string]repository.WorkflowStatus) error {
+	m.movedIDs = append(m.movedIDs, ids...)
+	for _, t := range m.createdTasks {
+		for _, id := range ids {
+			if t.ID == id {
+				t.GroupID = groupID
+				t.DatabaseID = databaseID
+				t.Topic = ""
+				if st, ok := statuses[id]; ok {
+					t.SetStatus(st)
+				}
+			}
+		}
+	}
 	return nil
 }
 
+func (m *mockTaskRepo) GetSubtaskProgress(_ context.Context, _ []string) (map[string]repository.SubtaskProgress, error) {
+	return map[string]repository.SubtaskProgress{}, nil
+}
+
+func (m *mockTaskRepo) AddAssignee(ctx context.Context, taskID, userID string, assignedBy *string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockTaskRepo) RemoveAssignee(ctx context.Context, taskID, userID string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockTaskRepo) SetAssignees(ctx context.Context, taskID string, userIDs []string, assignedBy *string) error {
+	return nil
+}
+
+func (m *mockTaskRepo) AddWatcher(ctx context.Context, taskID, userID string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockTaskRepo) RemoveWatcher(ctx context.Context, taskID, userID string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockTaskRepo) ListWatcherIDs(ctx context.Context, taskID string) ([]string, error) {
+	return nil, nil
+}
+
 func (m *mockTaskRepo) GetTaskCounts(ctx context.Context, userID string) (*repository.TaskCounts, error) {
 	return nil, nil
 }
@@ -212,6 +665,14 @@ func (m *mockTaskRepo) GetCommentByID(_ context.Context, _ string) (*repository.
 	return nil, nil
 }
 
+func (m *mockTaskRepo) UpdateComment(_ context.Context, _ *repository.TaskComment) error {
+	return nil
+}
+
+func (m *mockTaskRepo) DeleteComment(_ context.Context, _ string) (bool, error) {
+	return false, nil
+}
+
 func (m *mockTaskRepo) ListComments(_ context.Context, _ string) ([]repository.TaskComment, error) {
 	return nil, nil
 }
@@ -228,11 +689,49 @@ func (m *mockTaskRepo) SoftDelete(context.Context, string) error {
 	return nil
 }
 
+func (m *mockTaskRepo) ListDeleted(context.Context, string, int, int) ([]repository.Task, error) {
+	return nil, nil
+}
+
+func (m *mockTaskRepo) GetDeletedByID(context.Context, string) (*repository.Task, error) {
+	return nil, nil
+}
+
+func (m *mockTaskRepo) Restore(context.Context, string) error {
+	return nil
+}
+
+func (m *mockTaskRepo) PurgeDeleted(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockTaskRepo) SetArchived(context.Context, string, bool) error {
+	return nil
+}
+
+func (m *mockTaskRepo) ListAutoArchivable(context.Context, time.Time) ([]repository.Task, error) {
+	return nil, nil
+}
+
 func (m *mockTaskRepo) ListPendingByGroup(context.Context, string) ([]repository.Task, error) {
 	return nil, nil
 }
 
+func (m *mockTaskRepo) CreateEvent(_ context.Context, event *repository.TaskEvent) error {
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *mockTaskRepo) ListEvents(context.Context, string, int, int) ([]repository.TaskEvent, error) {
+	return nil, nil
+}
+
+func (m *mockTaskRepo) Transaction(ctx context.Context, fn func(tx repository.TaskRepository) error) error {
+	return fn(m)
+}
+
 type mockUserRepo struct {
+	byID           map[string]*models.User
 	byTG           map[int64]*models.User
 	byUsername     map[string]*models.User
 	findErr        error
@@ -252,6 +751,9 @@ func (m *mockUserRepo) FindByTgID(_ context.Context, tgID int64) (*models.User,
 }
 
 func (m *mockUserRepo) FindByID(ctx context.Context, id string) (*models.User, error) {
+	if user, ok := m.byID[id]; ok {
+		return user, nil
+	}
 	return &models.User{ID: id, Name: "Mock User"}, nil
 }
 
@@ -314,7 +816,10 @@ func (m *mockUpdateRepo) GetRecentMessages(context.Context, int64, int, int64) (
 type stubNotionClient struct {
 	calls      int
 	lastParams pkgnotion.CreatePageParams
+	lastUpdate pkgnotion.UpdatePageParams
 	createErr  error
+	appendedTo []string
+	database   *gonotion.Database
 }
 
 func (s *stubNotionClient) CreatePage(ctx context.Context, params pkgnotion.CreatePageParams) (*gonotion.Page, error) {
@@ -334,7 +839,7 @@ func (s *stubNotionClient) Search(context.Context, string) (*gonotion.SearchResp
 }
 
 func (s *stubNotionClient) GetDatabase(context.Context, string) (*gonotion.Database, error) {
-	return nil, nil
+	return s.database, nil
 }
 
 func (s *stubNotionClient) UpdateDatabase(context.Context, string, gonotion.UpdateDatabaseParams) (*gonotion.Database, error) {
@@ -347,9 +852,15 @@ func (s *stubNotionClient) QueryDatabase(context.Context, string, *gonotion.Data
 
 func (s *stubNotionClient) UpdatePage(ctx context.Context, pageID string, params pkgnotion.UpdatePageParams) (*gonotion.Page, error) {
 	s.calls++
+	s.lastUpdate = params
 	return &gonotion.Page{ID: pageID}, nil
 }
 
+func (s *stubNotionClient) AppendBlockChildren(ctx context.Context, blockID string, children []gonotion.Block) error {
+	s.appendedTo = append(s.appendedTo, blockID)
+	return nil
+}
+
 // ... Stub methods not used in remaining tests but struct might be used if I add back tests
 // Leave it or remove? Removed usage in deleted tests.
 // But Service uses pkgnotion.Client interface.
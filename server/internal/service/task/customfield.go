@@ -0,0 +1,174 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/dstotijn/go-notion"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+	pkgnotion "github.com/layababa/tg_todo/server/pkg/notion"
+)
+
+// ErrInvalidCustomField is returned for values of unknown fields or values
+// that do not fit the field's type
+var ErrInvalidCustomField = errors.New("invalid custom field")
+
+// customFieldsOf returns the custom fields of the task's group, or of the
+// group bound to its Notion database. The property receiving tracked hours is
+// written by timers only, so it is never a custom field.
+func (s *Service) customFieldsOf(ctx context.Context, task *repository.Task) repository.CustomFields {
+	if s.fieldRepo == nil {
+		return nil
+	}
+	var (
+		fields repository.CustomFields
+		err    error
+	)
+	switch {
+	case task.GroupID != nil && *task.GroupID != "":
+		fields, err = s.fieldRepo.ListByGroup(ctx, *task.GroupID)
+	case task.DatabaseID != nil && *task.DatabaseID != "":
+		fields, err = s.fieldRepo.ListByDatabase(ctx, *task.DatabaseID)
+	}
+	if err != nil {
+		s.logger.Warn("failed to load custom fields", zap.String("task_id", task.ID), zap.Error(err))
+		return nil
+	}
+	if s.notionTimeProperty == "" {
+		return fields
+	}
+	out := fields[:0:0]
+	for _, f := range fields {
+		if f.Name != s.notionTimeProperty {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// ListCustomFields returns the custom fields of the task, for edit forms
+func (s *Service) ListCustomFields(ctx context.Context, task *repository.Task) repository.CustomFields {
+	return s.customFieldsOf(ctx, task)
+}
+
+// normalizeCustomValue checks value against the field's type and returns it
+// in the stored format; nil clears the field
+func normalizeCustomValue(field repository.CustomField, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	prop, ok := pkgnotion.PropertyOf(notion.DatabasePropertyType(field.Type), value)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q expects a %s value", ErrInvalidCustomField, field.Name, field.Type)
+	}
+	return pkgnotion.PropertyValue(prop), nil
+}
+
+// setCustomFields merges values into the task's custom fields and returns the
+// previous and new values of the fields that changed
+func setCustomFields(fields repository.CustomFields, task *repository.Task, values map[string]interface{}) (before, after map[string]interface{}, err error) {
+	normalized := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		field, ok := fields.Find(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: unknown field %q", ErrInvalidCustomField, name)
+		}
+		if normalized[field.Name], err = normalizeCustomValue(field, value); err != nil {
+			return nil, nil, err
+		}
+	}
+	before, after = applyCustomValues(task, normalized)
+	return before, after, nil
+}
+
+// applyCustomValues stores normalized values on the task, nil values removing
+// the field, and returns the previous and new values of the changed fields
+func applyCustomValues(task *repository.Task, values map[string]interface{}) (before, after map[string]interface{}) {
+	if task.CustomFields == nil {
+		task.CustomFields = datatypes.JSONMap{}
+	}
+	before, after = map[string]interface{}{}, map[string]interface{}{}
+	for name, value := range values {
+		old := task.CustomFields[name]
+		if equalCustomValue(old, value) {
+			continue
+		}
+		before[name], after[name] = old, value
+		if value == nil {
+			delete(task.CustomFields, name)
+		} else {
+			task.CustomFields[name] = value
+		}
+	}
+	return before, after
+}
+
+// equalCustomValue compares values as stored, where values read back from the
+// database hold multi-select values as []interface{} and numbers as json.Number
+func equalCustomValue(a, b interface{}) bool {
+	return reflect.DeepEqual(plainCustomValue(a), plainCustomValue(b))
+}
+
+func plainCustomValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return stringSlice(v)
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return v
+		}
+		return n
+	}
+	return v
+}
+
+func stringSlice(items []interface{}) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, _ := item.(string)
+		out = append(out, s)
+	}
+	return out
+}
+
+// customFieldProperties builds the Notion properties of the task's custom
+// field values, and lists the fields without a value so that updates clear
+// them in Notion rather than leave a value the next poll would bring back
+func customFieldProperties(fields repository.CustomFields, task *repository.Task) (notion.DatabasePageProperties, map[string]notion.DatabasePropertyType) {
+	props := make(notion.DatabasePageProperties)
+	cleared := make(map[string]notion.DatabasePropertyType)
+	for _, field := range fields {
+		propType := notion.DatabasePropertyType(field.Type)
+		value, ok := task.CustomFields[field.Name]
+		if !ok {
+			cleared[field.Name] = propType
+			continue
+		}
+		if prop, ok := pkgnotion.PropertyOf(propType, value); ok {
+			props[field.Name] = prop
+		}
+	}
+	return props, cleared
+}
+
+// customValuesFromNotion picks the values of fields out of page properties;
+// fields that are empty in Notion map to nil
+func customValuesFromNotion(fields repository.CustomFields, props notion.DatabasePageProperties) map[string]interface{} {
+	values := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		prop, ok := props[field.Name]
+		if !ok || prop.Type != notion.DatabasePropertyType(field.Type) {
+			// Missing or retyped columns leave the stored value alone
+			continue
+		}
+		values[field.Name] = pkgnotion.PropertyValue(prop)
+	}
+	return values
+}
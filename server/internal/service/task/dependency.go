@@ -0,0 +1,156 @@
+package task
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+var (
+	// ErrSelfDependency is returned when a task is made to block itself
+	ErrSelfDependency = errors.New("task cannot depend on itself")
+	// ErrDependencyCycle is returned when a new dependency would create a cycle
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrTaskBlocked is returned when starting a task that still has open blockers
+	ErrTaskBlocked = errors.New("task is blocked by unfinished tasks")
+)
+
+// Dependencies is the DTO for the dependency edges of a task
+type Dependencies struct {
+	BlockedBy []repository.Task `json:"blocked_by"` // Tasks that must be Done first
+	Blocking  []repository.Task `json:"blocking"`   // Tasks waiting on this one
+}
+
+// ListDependencies returns the blockers of a task and the tasks it blocks
+func (s *Service) ListDependencies(ctx context.Context, taskID string) (*Dependencies, error) {
+	if s.depRepo == nil {
+		return nil, errors.New("dependency repository not configured")
+	}
+	blockedBy, err := s.depRepo.ListBlockers(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	blocking, err := s.depRepo.ListBlocked(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	return &Dependencies{BlockedBy: blockedBy, Blocking: blocking}, nil
+}
+
+// AddDependency marks taskID as blocked by blockedByID, refusing cycles
+func (s *Service) AddDependency(ctx context.Context, userID, taskID, blockedByID string) error {
+	if s.depRepo == nil {
+		return errors.New("dependency repository not configured")
+	}
+	if taskID == blockedByID {
+		return ErrSelfDependency
+	}
+
+	for _, id := range []string{taskID, blockedByID} {
+		t, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return errors.New("task not found")
+		}
+	}
+
+	cyclic, err := s.dependsOn(ctx, blockedByID, taskID)
+	if err != nil {
+		return err
+	}
+	if cyclic {
+		return ErrDependencyCycle
+	}
+
+	if err := s.depRepo.Add(ctx, &repository.TaskDependency{
+		TaskID:      taskID,
+		BlockedByID: blockedByID,
+		CreatedBy:   &userID,
+	}); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, taskID, userID, repository.TaskEventDependency, repository.TaskEventSourceApp,
+		nil, map[string]interface{}{"blocked_by_id": blockedByID})
+	return nil
+}
+
+// RemoveDependency removes the edge between taskID and blockedByID
+func (s *Service) RemoveDependency(ctx context.Context, userID, taskID, blockedByID string) error {
+	if s.depRepo == nil {
+		return errors.New("dependency repository not configured")
+	}
+	if err := s.depRepo.Remove(ctx, taskID, blockedByID); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, taskID, userID, repository.TaskEventDependency, repository.TaskEventSourceApp,
+		map[string]interface{}{"blocked_by_id": blockedByID}, nil)
+	return nil
+}
+
+// dependsOn reports whether taskID is (transitively) blocked by targetID,
+// walking the blocked-by edges breadth first
+func (s *Service) dependsOn(ctx context.Context, taskID, targetID string) (bool, error) {
+	visited := map[string]bool{taskID: true}
+	frontier := []string{taskID}
+	for len(frontier) > 0 {
+		ids, err := s.depRepo.ListBlockerIDs(ctx, frontier)
+		if err != nil {
+			return false, err
+		}
+		frontier = frontier[:0]
+		for _, id := range ids {
+			if id == targetID {
+				return true, nil
+			}
+			if !visited[id] {
+				visited[id] = true
+				frontier = append(frontier, id)
+			}
+		}
+	}
+	return false, nil
+}
+
+// isBlocked reports whether the task still has blockers that are not Done
+func (s *Service) isBlocked(ctx context.Context, taskID string) (bool, error) {
+	if s.depRepo == nil {
+		return false, nil
+	}
+	open, err := s.depRepo.CountOpenBlockers(ctx, taskID)
+	if err != nil {
+		return false, err
+	}
+	return open > 0, nil
+}
+
+// notifyUnblocked notifies the assignees of tasks whose last blocker was just completed
+func (s *Service) notifyUnblocked(ctx context.Context, blocker *repository.Task) {
+	if s.depRepo == nil {
+		return
+	}
+	blocked, err := s.depRepo.ListBlocked(ctx, blocker.ID)
+	if err != nil {
+		s.logger.Error("failed to list blocked tasks", zap.String("task_id", blocker.ID), zap.Error(err))
+		return
+	}
+
+	for i := range blocked {
+		t := &blocked[i]
+		if t.IsDone() {
+			continue
+		}
+		stillBlocked, err := s.isBlocked(ctx, t.ID)
+		if err != nil || stillBlocked {
+			continue
+		}
+		s.logger.Info("task unblocked", zap.String("task_id", t.ID), zap.String("blocker_id", blocker.ID))
+		if s.notifier != nil {
+			s.notifier.NotifyUnblocked(ctx, t, blocker)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+// fieldChanges collects the before/after values of the fields changed by a
+// mutation, keyed by JSON field name
+type fieldChanges struct {
+	before map[string]interface{}
+	after  map[string]interface{}
+}
+
+func newFieldChanges() *fieldChanges {
+	return &fieldChanges{before: map[string]interface{}{}, after: map[string]interface{}{}}
+}
+
+func (f *fieldChanges) set(field string, before, after interface{}) {
+	f.before[field] = before
+	f.after[field] = after
+}
+
+func (f *fieldChanges) empty() bool {
+	return len(f.after) == 0
+}
+
+// recordTaskEvent appends an audit event for a task. The audit trail is best
+// effort: failures are logged and never fail the mutation itself.
+func recordTaskEvent(ctx context.Context, logger *zap.Logger, repo repository.TaskRepository, taskID, actorID string, event repository.TaskEventType, source repository.TaskEventSource, before, after interface{}) {
+	if repo == nil || taskID == "" {
+		return
+	}
+	ev := &repository.TaskEvent{
+		TaskID:    taskID,
+		Event:     event,
+		Source:    source,
+		CreatedAt: time.Now(),
+	}
+	if actorID != "" {
+		ev.ActorID = &actorID
+	}
+	var err error
+	if ev.Before, err = marshalEventState(before); err == nil {
+		ev.After, err = marshalEventState(after)
+	}
+	if err == nil {
+		err = repo.CreateEvent(ctx, ev)
+	}
+	if err != nil {
+		logger.Warn("failed to record task event",
+			zap.String("task_id", taskID),
+			zap.String("event", string(event)),
+			zap.Error(err))
+	}
+}
+
+func marshalEventState(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// taskEventState is the snapshot recorded when a task is created or deleted
+func taskEventState(task *repository.Task) map[string]interface{} {
+	return map[string]interface{}{
+		"title":        task.Title,
+		"status":       task.Status,
+		"priority":     notionPriorityOf(task),
+		"due_at":       task.DueAt,
+		"group_id":     task.GroupID,
+		"parent_id":    task.ParentID,
+		"assignee_ids": assigneeIDs(task),
+		"labels":       labelNames(task),
+	}
+}
+
+func (s *Service) recordEvent(ctx context.Context, taskID, actorID string, event repository.TaskEventType, source repository.TaskEventSource, before, after interface{}) {
+	recordTaskEvent(ctx, s.logger, s.repo, taskID, actorID, event, source, before, after)
+}
+
+// ListEventsParams represents pagination of a task's history
+type ListEventsParams struct {
+	Limit  int
+	Offset int
+}
+
+// ListEvents returns the audit trail of a task, newest first
+func (s *Service) ListEvents(ctx context.Context, taskID string, params ListEventsParams) ([]repository.TaskEvent, error) {
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+	return s.repo.ListEvents(ctx, taskID, params.Limit, params.Offset)
+}
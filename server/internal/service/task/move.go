@@ -0,0 +1,359 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/notification"
+)
+
+var (
+	// ErrMoveSubtask is returned when moving a subtask on its own; subtasks
+	// move with their parent
+	ErrMoveSubtask = errors.New("subtasks move with their parent")
+	// ErrGroupNotFound is returned when the target group of a move or clone does not exist
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrDatabaseForbidden is returned when the Notion database chosen for a
+	// move or clone is not one the user can access
+	ErrDatabaseForbidden = errors.New("notion database not accessible")
+)
+
+// MoveParams selects where a task is moved or cloned to
+type MoveParams struct {
+	// GroupID is the target group; "" makes a personal task and nil keeps
+	// the current group
+	GroupID *string
+	// DatabaseID is the target Notion database; "" unlinks the task from
+	// Notion and nil uses the target group's database (the default database
+	// of the task's creator for personal tasks), or keeps the database when
+	// the group does not change
+	DatabaseID *string
+}
+
+// MoveTask moves a task and its subtasks to another group and/or Notion
+// database. When the database changes, the old Notion pages are archived and
+// new ones are created in the target database. Permissions in both groups are
+// checked by the caller.
+func (s *Service) MoveTask(ctx context.Context, userID, id string, params MoveParams) (*repository.Task, error) {
+	task, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+	if task.ParentID != nil {
+		return nil, ErrMoveSubtask
+	}
+
+	groupID, databaseID, err := s.resolveMoveTarget(ctx, userID, notionOwnerOf(task, userID), task, params)
+	if err != nil {
+		return nil, err
+	}
+	groupChanged := !equalStringPtr(task.GroupID, groupID)
+	databaseChanged := !equalStringPtr(task.DatabaseID, databaseID)
+	if !groupChanged && !databaseChanged {
+		return task, nil
+	}
+
+	subtasks, err := s.repo.ListSubtasks(ctx, task.ID)
+	if err != nil {
+		return nil, err
+	}
+	moved := append([]*repository.Task{task}, taskPtrs(subtasks)...)
+	ids := make([]string, 0, len(moved))
+	// Statuses the target group does not know fall back to the first status
+	// of the same category
+	statuses := make(map[string]repository.WorkflowStatus)
+	target := loadWorkflow(ctx, s.logger, s.workflowRepo, groupID, databaseID)
+	for _, t := range moved {
+		ids = append(ids, t.ID)
+		if _, ok := target.Find(string(t.Status)); ok {
+			continue
+		}
+		if st, ok := target.First(t.Category()); ok {
+			statuses[t.ID] = st
+		}
+	}
+	before := map[string]interface{}{"group_id": task.GroupID, "database_id": task.DatabaseID}
+	// The pages stay in the old database's trash; the task starts over in the
+	// new one. The links are dropped with the move, so a failure cannot leave
+	// tasks pointing at pages of their old database.
+	oldPages := make(map[string]string)
+	err = s.repo.Transaction(ctx, func(tx repository.TaskRepository) error {
+		if err := tx.Move(ctx, ids, groupID, databaseID, statuses); err != nil {
+			return err
+		}
+		if !databaseChanged {
+			return nil
+		}
+		for _, t := range moved {
+			if t.NotionPageID != nil && *t.NotionPageID != "" {
+				oldPages[t.ID] = *t.NotionPageID
+			}
+			t.NotionPageID = nil
+			t.NotionURL = nil
+			t.SyncStatus = repository.TaskSyncStatusPending
+			if err := tx.UpdateStatus(ctx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range moved {
+		t.GroupID, t.DatabaseID, t.Topic = groupID, databaseID, ""
+		if st, ok := statuses[t.ID]; ok {
+			t.SetStatus(st)
+		}
+	}
+	s.recordEvent(ctx, task.ID, userID, repository.TaskEventUpdate, repository.TaskEventSourceApp,
+		before, map[string]interface{}{"group_id": groupID, "database_id": databaseID})
+	s.logger.Info("task moved", zap.String("task_id", task.ID), zap.Int("subtasks", len(subtasks)), zap.Any("group_id", groupID), zap.Any("database_id", databaseID))
+
+	if databaseChanged {
+		go s.resyncMoved(moved, oldPages, notionOwnerOf(task, userID), databaseID)
+	}
+
+	return s.repo.GetByID(ctx, task.ID)
+}
+
+// resyncMoved archives the old Notion pages of moved tasks and creates pages
+// in the target database, parent first
+func (s *Service) resyncMoved(moved []*repository.Task, oldPages map[string]string, userID string, databaseID *string) {
+	ctx := context.Background()
+	for _, t := range moved {
+		pageID, ok := oldPages[t.ID]
+		if !ok {
+			continue
+		}
+		old := &repository.Task{ID: t.ID, NotionPageID: &pageID}
+		if err := s.setNotionPageArchived(ctx, old, userID, true); err != nil {
+			s.logger.Warn("failed to archive notion page of moved task", zap.String("task_id", t.ID), zap.String("page_id", pageID), zap.Error(err))
+		}
+	}
+	if databaseID != nil {
+		s.syncWithSubtasks(moved[0], moved[1:], userID)
+	}
+}
+
+// CloneTask copies a task into a new one owned by userID: title, description,
+// priority, start and due dates, estimate, labels, context snapshots and subtasks. The
+// copy goes to the group and database selected by params (the source's by
+// default) and starts in the first status of the target workflow without
+// assignees.
+func (s *Service) CloneTask(ctx context.Context, userID, id string, params MoveParams) (*repository.Task, error) {
+	source, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, errors.New("task not found")
+	}
+	// The copy belongs to userID, so personal copies use their default database
+	groupID, databaseID, err := s.resolveMoveTarget(ctx, userID, userID, source, params)
+	if err != nil {
+		return nil, err
+	}
+
+	topic := ""
+	if equalStringPtr(source.GroupID, groupID) {
+		topic = source.Topic
+	}
+	now := time.Now()
+	snapshots := make([]repository.TaskContextSnapshot, 0, len(source.Snapshots))
+	for _, snap := range source.Snapshots {
+		snap.ID = ""
+		snap.TaskID = ""
+		snapshots = append(snapshots, snap)
+	}
+	task := &repository.Task{
+		ParentID:        source.ParentID,
+		Title:           source.Title,
+		Description:     source.Description,
+		CreatorID:       &userID,
+		Priority:        notionPriorityOf(source),
+		SyncStatus:      repository.TaskSyncStatusPending,
+		GroupID:         groupID,
+		DatabaseID:      databaseID,
+		Topic:           topic,
+		DueAt:           source.DueAt,
+		StartAt:         source.StartAt,
+		AllDay:          source.AllDay,
+		ChatJumpURL:     source.ChatJumpURL,
+		AutoComplete:    source.AutoComplete,
+		EstimateMinutes: source.EstimateMinutes,
+		CustomFields:    maps.Clone(source.CustomFields),
+		Labels:          source.Labels,
+		Snapshots:       snapshots,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if task.ParentID != nil && !equalStringPtr(source.GroupID, groupID) {
+		// A subtask copied elsewhere becomes a standalone task
+		task.ParentID = nil
+	}
+	s.setInitialStatus(ctx, task)
+	if err := s.repo.Create(ctx, task); err != nil {
+		return nil, err
+	}
+	after := taskEventState(task)
+	after["cloned_from"] = source.ID
+	s.recordEvent(ctx, task.ID, userID, repository.TaskEventCreate, repository.TaskEventSourceApp, nil, after)
+
+	var subtasks []*repository.Task
+	if source.ParentID == nil {
+		sourceSubtasks, err := s.repo.ListSubtasks(ctx, source.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range sourceSubtasks {
+			subtask := &repository.Task{
+				ParentID:    &task.ID,
+				Title:       st.Title,
+				Description: st.Description,
+				CreatorID:   &userID,
+				Priority:    notionPriorityOf(&st),
+				SyncStatus:  repository.TaskSyncStatusPending,
+				GroupID:     groupID,
+				DatabaseID:  databaseID,
+				Topic:       topic,
+				ChatJumpURL: task.ChatJumpURL,
+				Labels:      st.Labels,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+			subtask.Status, subtask.StatusCategory = task.Status, task.StatusCategory
+			if err := s.repo.Create(ctx, subtask); err != nil {
+				return nil, err
+			}
+			s.recordEvent(ctx, subtask.ID, userID, repository.TaskEventCreate, repository.TaskEventSourceApp, nil, taskEventState(subtask))
+			subtasks = append(subtasks, subtask)
+		}
+	}
+	s.logger.Info("task cloned", zap.String("source_id", source.ID), zap.String("task_id", task.ID), zap.Int("subtasks", len(subtasks)))
+
+	if s.notifier != nil {
+		s.notifier.Notify(ctx, notification.EventTaskCreated, task, userID, nil)
+	}
+	if task.DatabaseID != nil {
+		go s.syncWithSubtasks(task, subtasks, userID)
+	}
+	return task, nil
+}
+
+// resolveMoveTarget works out the group and database a task is moved or
+// cloned to by userID, see MoveParams. Personal targets default to the
+// database of ownerID, who owns the task afterwards.
+func (s *Service) resolveMoveTarget(ctx context.Context, userID, ownerID string, task *repository.Task, params MoveParams) (*string, *string, error) {
+	groupID := task.GroupID
+	if params.GroupID != nil {
+		groupID = nil
+		if *params.GroupID != "" {
+			groupID = params.GroupID
+		}
+	}
+	var group *models.Group
+	if groupID != nil && (params.DatabaseID != nil || !equalStringPtr(groupID, task.GroupID)) {
+		var err error
+		if group, err = s.findTargetGroup(ctx, *groupID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if params.DatabaseID != nil {
+		if *params.DatabaseID == "" {
+			return groupID, nil, nil
+		}
+		if err := s.checkDatabaseAccess(ctx, userID, task, group, *params.DatabaseID); err != nil {
+			return nil, nil, err
+		}
+		return groupID, params.DatabaseID, nil
+	}
+	if equalStringPtr(groupID, task.GroupID) {
+		return groupID, task.DatabaseID, nil
+	}
+
+	// New group: use its Notion binding, or the owner's default database
+	if group != nil {
+		return groupID, nonEmpty(group.DatabaseID), nil
+	}
+	owner, err := s.userRepo.FindByID(ctx, ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, nonEmpty(owner.DefaultDatabaseID), nil
+}
+
+func (s *Service) findTargetGroup(ctx context.Context, groupID string) (*models.Group, error) {
+	if s.groupRepo == nil {
+		return nil, errors.New("group repository not configured")
+	}
+	group, err := s.groupRepo.FindByID(ctx, groupID)
+	if err != nil || group == nil {
+		return nil, ErrGroupNotFound
+	}
+	return group, nil
+}
+
+// checkDatabaseAccess makes sure userID may put a task into the Notion
+// database given for a move or clone: the task's current database, the
+// target group's, the user's default one, or another their Notion connection
+// can read.
+func (s *Service) checkDatabaseAccess(ctx context.Context, userID string, task *repository.Task, group *models.Group, databaseID string) error {
+	if equalStringPtr(task.DatabaseID, &databaseID) {
+		return nil
+	}
+	if group != nil && equalStringPtr(group.DatabaseID, &databaseID) {
+		return nil
+	}
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user != nil && equalStringPtr(user.DefaultDatabaseID, &databaseID) {
+		return nil
+	}
+
+	logger := s.logger.With(zap.String("user_id", userID), zap.String("database_id", databaseID))
+	client, err := s.notionClientFor(ctx, logger, userID)
+	if err != nil {
+		return ErrDatabaseForbidden
+	}
+	if db, err := client.GetDatabase(ctx, databaseID); err != nil || db == nil {
+		logger.Info("notion database not accessible for move", zap.Error(err))
+		return ErrDatabaseForbidden
+	}
+	return nil
+}
+
+// nonEmpty returns nil for nil or empty strings
+func nonEmpty(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return s
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func taskPtrs(tasks []repository.Task) []*repository.Task {
+	ptrs := make([]*repository.Task, len(tasks))
+	for i := range tasks {
+		ptrs[i] = &tasks[i]
+	}
+	return ptrs
+}
@@ -39,3 +39,46 @@ func CanModifyTask(ctx context.Context, userID string, task *repository.Task, us
 
 	return false, nil
 }
+
+// CanClaimTask checks if a user may add themselves to the task assignees.
+// Besides those who can modify the task, any member of the task's group can
+// claim it.
+func CanClaimTask(ctx context.Context, userID string, task *repository.Task, userGroupRepo UserGroupRepository) (bool, error) {
+	canModify, err := CanModifyTask(ctx, userID, task, userGroupRepo)
+	if err != nil || canModify {
+		return canModify, err
+	}
+	if task.GroupID != nil {
+		if userGroup, err := userGroupRepo.FindByUserAndGroup(ctx, userID, *task.GroupID); err == nil && userGroup != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CanUseTemplate checks if a user may create tasks from a template: the
+// owner of a personal template, or any member of a group template's group
+func CanUseTemplate(ctx context.Context, userID string, tmpl *repository.TaskTemplate, userGroupRepo UserGroupRepository) bool {
+	if tmpl.GroupID == nil {
+		return tmpl.UserID != nil && *tmpl.UserID == userID
+	}
+	userGroup, err := userGroupRepo.FindByUserAndGroup(ctx, userID, *tmpl.GroupID)
+	return err == nil && userGroup != nil
+}
+
+// CanManageTemplate checks if a user may edit or delete a template: the
+// owner of a personal template, or an admin of a group template's group
+func CanManageTemplate(ctx context.Context, userID string, tmpl *repository.TaskTemplate, userGroupRepo UserGroupRepository) bool {
+	if tmpl.GroupID == nil {
+		return tmpl.UserID != nil && *tmpl.UserID == userID
+	}
+	userGroup, err := userGroupRepo.FindByUserAndGroup(ctx, userID, *tmpl.GroupID)
+	return err == nil && userGroup != nil && userGroup.Role == models.GroupRoleAdmin
+}
+
+// CanMoveTaskTo checks if a user may move or copy tasks into a group: any
+// member of that group
+func CanMoveTaskTo(ctx context.Context, userID, groupID string, userGroupRepo UserGroupRepository) bool {
+	userGroup, err := userGroupRepo.FindByUserAndGroup(ctx, userID, groupID)
+	return err == nil && userGroup != nil
+}
@@ -0,0 +1,43 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+// ErrNoReminder is returned when snoozing the reminders of a task that has
+// not been reminded of yet: it has no due date, is finished, or its due
+// reminders have not fired
+var ErrNoReminder = errors.New("task has no reminder to snooze")
+
+// CanSnoozeReminders reports whether a reminder of the task has fired that
+// snoozing would send again
+func CanSnoozeReminders(task *repository.Task) bool {
+	return task.DueAt != nil && !task.IsDone() && (task.Reminder1hSent || task.ReminderDueSent)
+}
+
+// SnoozeReminders sends the task's fired reminders again after d: until
+// then reminders are held back, and the sent flags are reset.
+func (s *Service) SnoozeReminders(ctx context.Context, taskID string, d time.Duration) (*repository.Task, error) {
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+	if !CanSnoozeReminders(task) {
+		return nil, ErrNoReminder
+	}
+
+	until := time.Now().Add(d)
+	if err := s.repo.SnoozeReminders(ctx, taskID, until); err != nil {
+		return nil, err
+	}
+	task.ReminderSnoozedUntil = &until
+	task.Reminder1hSent, task.ReminderDueSent = false, false
+	return task, nil
+}
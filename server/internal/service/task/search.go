@@ -0,0 +1,153 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+// ErrEmptySearchQuery is returned when the search query has no terms
+var ErrEmptySearchQuery = errors.New("search query is empty")
+
+const (
+	// snippetRunes is the maximum length of a highlighted body snippet
+	snippetRunes = 80
+	// snippetLeadRunes is how much context is kept before the first match
+	snippetLeadRunes = 20
+)
+
+// SearchParams represents a full-text search request
+type SearchParams struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a ranked search hit. Highlights holds HTML-escaped snippets
+// keyed by field ("title", "description", "comment", "snapshot") with the
+// matched terms wrapped in <mark>.
+type SearchResult struct {
+	Task       *repository.Task  `json:"task"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchTasks searches the tasks visible to the user by title, description,
+// comments and context snapshots
+func (s *Service) SearchTasks(ctx context.Context, userID string, params SearchParams) ([]SearchResult, error) {
+	terms := repository.SplitSearchTerms(params.Query)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearchQuery
+	}
+
+	hits, err := s.repo.Search(ctx, userID, repository.TaskSearchFilter{
+		Terms:  terms,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for i := range hits {
+		hit := &hits[i]
+		highlights := make(map[string]string)
+		if h, ok := highlight(hit.Task.Title, terms, 0); ok {
+			highlights["title"] = h
+		}
+		if h, ok := highlight(hit.Task.Description, terms, snippetRunes); ok {
+			highlights["description"] = h
+		}
+		if h, ok := highlight(hit.CommentMatch, terms, snippetRunes); ok {
+			highlights["comment"] = h
+		}
+		if h, ok := highlight(hit.SnapshotMatch, terms, snippetRunes); ok {
+			highlights["snapshot"] = h
+		}
+		results = append(results, SearchResult{Task: &hit.Task, Rank: hit.Rank, Highlights: highlights})
+	}
+	return results, nil
+}
+
+// highlight wraps case-insensitive occurrences of terms in <mark>, escaping the
+// rest as HTML. When maxRunes > 0 and text is longer, a window around the first
+// match is returned with ellipses. ok is false when no term occurs in text.
+func highlight(text string, terms []string, maxRunes int) (string, bool) {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// Longest terms first so that "invoice" wins over "in"
+	termRunes := make([][]rune, 0, len(terms))
+	for _, t := range terms {
+		if t != "" {
+			termRunes = append(termRunes, []rune(strings.ToLower(t)))
+		}
+	}
+	sort.Slice(termRunes, func(i, j int) bool { return len(termRunes[i]) > len(termRunes[j]) })
+
+	matchAt := func(i int) int {
+		for _, t := range termRunes {
+			if i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == string(t) {
+				return len(t)
+			}
+		}
+		return 0
+	}
+
+	first := -1
+	for i := range lower {
+		if matchAt(i) > 0 {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	start, end := 0, len(runes)
+	if maxRunes > 0 && len(runes) > maxRunes {
+		start = first - snippetLeadRunes
+		if start < 0 {
+			start = 0
+		}
+		end = start + maxRunes
+		if end > len(runes) {
+			end = len(runes)
+			start = end - maxRunes
+		}
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	plain := start
+	for i := start; i < end; {
+		n := matchAt(i)
+		if n == 0 || i+n > end {
+			i++
+			continue
+		}
+		sb.WriteString(html.EscapeString(string(runes[plain:i])))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(string(runes[i : i+n])))
+		sb.WriteString("</mark>")
+		i += n
+		plain = i
+	}
+	sb.WriteString(html.EscapeString(string(runes[plain:end])))
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String(), true
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dstotijn/go-notion"
@@ -14,17 +15,59 @@ import (
 	"github.com/layababa/tg_todo/server/internal/repository"
 	"github.com/layababa/tg_todo/server/internal/service/notification"
 	pkgnotion "github.com/layababa/tg_todo/server/pkg/notion"
+	"github.com/layababa/tg_todo/server/pkg/rrule"
 	"github.com/layababa/tg_todo/server/pkg/security"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
+var (
+	// ErrRecurrenceRequiresDueDate is returned when a recurrence is set on a task without due date
+	ErrRecurrenceRequiresDueDate = errors.New("recurring task requires a due date")
+	// ErrNestedSubtask is returned when creating a subtask under another subtask
+	ErrNestedSubtask = errors.New("subtasks cannot be nested")
+	// ErrStartAfterDue is returned when a task would start after it is due
+	ErrStartAfterDue = errors.New("start date is after the due date")
+)
+
+// ConflictError is returned when an update is based on a stale version of the
+// task. Current is the task as stored.
+type ConflictError struct {
+	Current *repository.Task
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("task %s was modified concurrently (now at version %d)", e.Current.ID, e.Current.Version)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return repository.ErrVersionConflict
+}
+
 type Service struct {
 	logger        *zap.Logger
 	repo          repository.TaskRepository
 	userRepo      repository.UserRepository // Needed for Token
 	pendingRepo   repository.PendingAssignmentRepository
+	labelRepo     repository.LabelRepository
+	depRepo       repository.DependencyRepository
+	timeRepo      repository.TimeEntryRepository
+	templateRepo  repository.TemplateRepository
+	groupRepo     repository.GroupRepository // Group of tasks created from group templates
+	userGroupRepo UserGroupRepository        // Permission checks of bulk operations
+	workflowRepo  repository.WorkflowRepository
+	fieldRepo     repository.CustomFieldRepository
 	notifier      *notification.Service
 	encryptionKey string
 	notionClient  func(token string) pkgnotion.Client
+	// notionTimeProperty is the Notion number property receiving the hours
+	// logged on a task; empty disables the sync
+	notionTimeProperty string
+	attachmentRepo     repository.AttachmentRepository
+	files              FileDownloader
+	// publicBaseURL and fileLinkSecret build the signed attachment links
+	// embedded in Notion pages; see AttachmentURL
+	publicBaseURL  string
+	fileLinkSecret string
 }
 
 // ServiceConfig holds configuration for Service
@@ -33,36 +76,82 @@ type ServiceConfig struct {
 	Repo          repository.TaskRepository
 	UserRepo      repository.UserRepository
 	PendingRepo   repository.PendingAssignmentRepository
+	LabelRepo     repository.LabelRepository
+	DepRepo       repository.DependencyRepository
+	TimeRepo      repository.TimeEntryRepository
+	TemplateRepo  repository.TemplateRepository
+	GroupRepo     repository.GroupRepository
+	UserGroupRepo UserGroupRepository
+	WorkflowRepo  repository.WorkflowRepository    // Optional: per-group statuses
+	FieldRepo     repository.CustomFieldRepository // Optional: per-group custom fields
 	Notifier      *notification.Service
 	EncryptionKey string
+	// NotionTimeProperty names the Notion number property for tracked hours (optional)
+	NotionTimeProperty string
+	AttachmentRepo     repository.AttachmentRepository
+	Files              FileDownloader
+	// PublicBaseURL is the externally reachable API URL; with FileLinkSecret it
+	// enables attachment links in Notion pages (optional)
+	PublicBaseURL  string
+	FileLinkSecret string
 }
 
 // NewService creates a new task service
 func NewService(cfg ServiceConfig) *Service {
 	return &Service{
-		logger:        cfg.Logger,
-		repo:          cfg.Repo,
-		userRepo:      cfg.UserRepo,
-		pendingRepo:   cfg.PendingRepo,
-		notifier:      cfg.Notifier,
-		encryptionKey: cfg.EncryptionKey,
-		notionClient:  pkgnotion.NewClient,
+		logger:             cfg.Logger,
+		repo:               cfg.Repo,
+		userRepo:           cfg.UserRepo,
+		pendingRepo:        cfg.PendingRepo,
+		labelRepo:          cfg.LabelRepo,
+		depRepo:            cfg.DepRepo,
+		timeRepo:           cfg.TimeRepo,
+		templateRepo:       cfg.TemplateRepo,
+		groupRepo:          cfg.GroupRepo,
+		userGroupRepo:      cfg.UserGroupRepo,
+		workflowRepo:       cfg.WorkflowRepo,
+		fieldRepo:          cfg.FieldRepo,
+		notifier:           cfg.Notifier,
+		encryptionKey:      cfg.EncryptionKey,
+		notionClient:       pkgnotion.NewClient,
+		notionTimeProperty: cfg.NotionTimeProperty,
+		attachmentRepo:     cfg.AttachmentRepo,
+		files:              cfg.Files,
+		publicBaseURL:      strings.TrimRight(cfg.PublicBaseURL, "/"),
+		fileLinkSecret:     cfg.FileLinkSecret,
 	}
 }
 
 // UpdateParams represents parameters for updating a task
 type UpdateParams struct {
-	Title       *string
-	Description *string
-	Status      *repository.TaskStatus
-	DueAt       *time.Time
-	SyncStatus  *repository.TaskSyncStatus // Added to support manual sync reset if needed
+	Title        *string
+	Description  *string
+	Status       *repository.TaskStatus
+	Priority     *repository.TaskPriority
+	DueAt        *time.Time
+	StartAt      *time.Time                 // Zero time clears the start date
+	AllDay       *bool                      // Treat DueAt/StartAt as dates in Location
+	Location     *time.Location             // Zone of the caller, for all-day dates; UTC when nil
+	Recurrence   *string                    // RRULE value; empty string stops the recurrence
+	AutoComplete *bool                      // Complete the task once all its subtasks are done
+	Estimate     *int                       // Estimated effort in minutes; 0 clears the estimate
+	Labels       *[]string                  // Replaces the task labels; empty slice clears them
+	CustomFields map[string]interface{}     // Values by field name; nil values clear the field
+	SyncStatus   *repository.TaskSyncStatus // Added to support manual sync reset if needed
+	IfVersion    *int                       // Fail with ConflictError unless the task is at this version
 }
 
 // ListParams represents filters for listing tasks
 type ListParams struct {
 	View       repository.TaskView
 	DatabaseID *string
+	GroupID    *string // Group of repository.TaskViewGroup
+	Priorities []repository.TaskPriority
+	Labels     []string
+	Query      string         // Filter expression, see repository.ParseTaskQuery
+	Location   *time.Location // Zone relative dates in Query are resolved in; UTC when nil
+	Sort       repository.TaskSort
+	Cursor     string // Opaque next_cursor of the previous page
 	Limit      int
 	Offset     int
 }
@@ -72,25 +161,47 @@ type TaskDetail struct {
 	Task *repository.Task `json:"task"`
 }
 
-// ListTasks returns tasks for the user based on filter
-func (s *Service) ListTasks(ctx context.Context, userID string, params ListParams) ([]TaskDetail, error) {
+// ListTasks returns tasks for the user based on filter, along with the cursor
+// of the next page (empty when the page is not full)
+func (s *Service) ListTasks(ctx context.Context, userID string, params ListParams) ([]TaskDetail, string, error) {
 	filter := repository.TaskListFilter{
 		View:       params.View,
 		DatabaseID: params.DatabaseID,
+		GroupID:    params.GroupID,
+		Priorities: params.Priorities,
+		Labels:     params.Labels,
+		Sort:       params.Sort,
+		Cursor:     params.Cursor,
 		Limit:      params.Limit,
 		Offset:     params.Offset,
 	}
+	if strings.TrimSpace(params.Query) != "" {
+		now := time.Now().UTC()
+		if params.Location != nil {
+			now = now.In(params.Location)
+		}
+		query, err := repository.ParseTaskQuery(params.Query, now)
+		if err != nil {
+			return nil, "", err
+		}
+		filter.Query = query
+	}
 
 	tasks, err := s.repo.ListByUser(ctx, userID, filter)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	result := make([]TaskDetail, 0, len(tasks))
 	for i := range tasks {
 		result = append(result, TaskDetail{Task: &tasks[i]})
 	}
-	return result, nil
+
+	nextCursor := ""
+	if filter.Limit > 0 && len(tasks) == filter.Limit {
+		nextCursor = repository.EncodeTaskCursor(userID, filter, &tasks[len(tasks)-1])
+	}
+	return result, nextCursor, nil
 }
 
 // GetTaskCounts returns status counts for the user
@@ -104,7 +215,7 @@ func (s *Service) GetTask(ctx context.Context, id string) (*repository.Task, err
 }
 
 // DeleteTask soft deletes the task
-func (s *Service) DeleteTask(ctx context.Context, id string) error {
+func (s *Service) DeleteTask(ctx context.Context, userID, id string) error {
 	task, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
@@ -112,11 +223,36 @@ func (s *Service) DeleteTask(ctx context.Context, id string) error {
 	if task == nil {
 		return errors.New("task not found")
 	}
-	return s.repo.SoftDelete(ctx, id)
+	if err := s.repo.SoftDelete(ctx, id); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, id, userID, repository.TaskEventDelete, repository.TaskEventSourceApp, taskEventState(task), nil)
+
+	// Move the Notion page to the Notion trash as well; RestoreTask brings it back
+	if task.NotionPageID != nil && *task.NotionPageID != "" {
+		go func() {
+			if err := s.setNotionPageArchived(context.Background(), task, notionOwnerOf(task, userID), true); err != nil {
+				s.logger.Error("failed to archive notion page", zap.String("task_id", task.ID), zap.Error(err))
+			}
+		}()
+	}
+	return nil
+}
+
+// UpdateTask updates the task fields on behalf of userID
+func (s *Service) UpdateTask(ctx context.Context, userID, id string, params UpdateParams) (*repository.Task, error) {
+	return s.updateTask(ctx, userID, id, params, repository.TaskEventSourceApp)
+}
+
+// UpdateTaskFromBot is UpdateTask for bot commands, recorded as such in the
+// audit trail
+func (s *Service) UpdateTaskFromBot(ctx context.Context, userID, id string, params UpdateParams) (*repository.Task, error) {
+	return s.updateTask(ctx, userID, id, params, repository.TaskEventSourceBot)
 }
 
-// UpdateTask updates the task fields
-func (s *Service) UpdateTask(ctx context.Context, id string, params UpdateParams) (*repository.Task, error) {
+// updateTask applies params and records the changes in the audit trail as
+// coming from source
+func (s *Service) updateTask(ctx context.Context, userID, id string, params UpdateParams, source repository.TaskEventSource) (*repository.Task, error) {
 	task, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -124,32 +260,169 @@ func (s *Service) UpdateTask(ctx context.Context, id string, params UpdateParams
 	if task == nil {
 		return nil, errors.New("task not found")
 	}
+	if params.IfVersion != nil && *params.IfVersion != task.Version {
+		return nil, &ConflictError{Current: task}
+	}
+
+	var newStatus repository.WorkflowStatus
+	if params.Status != nil {
+		newStatus, err = s.resolveStatus(ctx, task, *params.Status)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Blocked tasks cannot be started until all their blockers are Done
+	if params.Status != nil && newStatus.Category == repository.StatusCategoryActive && task.Category() != repository.StatusCategoryActive {
+		blocked, err := s.isBlocked(ctx, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, ErrTaskBlocked
+		}
+	}
+
+	changes := newFieldChanges()
+	oldStatus, oldDueAt := task.Status, task.DueAt
 
 	if params.Title != nil {
+		if task.Title != *params.Title {
+			changes.set("title", task.Title, *params.Title)
+		}
 		task.Title = *params.Title
 	}
 
 	statusChanged := false
 	if params.Status != nil {
-		if task.Status != *params.Status {
+		if task.Status != newStatus.Name {
 			statusChanged = true
-			task.Status = *params.Status
+			task.SetStatus(newStatus)
+			setCompletedAt(task, time.Now())
 		}
 	}
 
 	if params.Description != nil {
+		if task.Description != *params.Description {
+			changes.set("description", task.Description, *params.Description)
+		}
 		task.Description = *params.Description
 	}
 
-	if params.DueAt != nil {
-		task.DueAt = params.DueAt
-		// Reset reminder flags when due date changes
-		task.Reminder1hSent = false
-		task.ReminderDueSent = false
+	if params.Priority != nil {
+		if task.Priority != *params.Priority {
+			changes.set("priority", task.Priority, *params.Priority)
+		}
+		task.Priority = *params.Priority
+	}
+
+	if params.AutoComplete != nil {
+		if task.AutoComplete != *params.AutoComplete {
+			changes.set("auto_complete", task.AutoComplete, *params.AutoComplete)
+		}
+		task.AutoComplete = *params.AutoComplete
+	}
+
+	if params.Estimate != nil {
+		var estimate *int
+		if *params.Estimate > 0 {
+			estimate = params.Estimate
+		}
+		if !equalIntPtr(task.EstimateMinutes, estimate) {
+			changes.set("estimate_minutes", task.EstimateMinutes, estimate)
+		}
+		task.EstimateMinutes = estimate
+	}
+
+	dueChanged := false
+	if params.DueAt != nil || params.StartAt != nil || params.AllDay != nil {
+		loc := params.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		allDay := task.AllDay
+		if params.AllDay != nil {
+			allDay = *params.AllDay
+		}
+		// Stored values switch mode with the task; new values are instants
+		dueAt := convertTaskTime(task.DueAt, task.AllDay, allDay, loc, true)
+		if params.DueAt != nil {
+			dueAt = convertTaskTime(params.DueAt, false, allDay, loc, true)
+		}
+		startAt := convertTaskTime(task.StartAt, task.AllDay, allDay, loc, false)
+		if params.StartAt != nil {
+			startAt = nil
+			if !params.StartAt.IsZero() {
+				startAt = convertTaskTime(params.StartAt, false, allDay, loc, false)
+			}
+		}
+		if startAt != nil && dueAt != nil && startAt.After(*dueAt) {
+			return nil, ErrStartAfterDue
+		}
+
+		dueChanged = !equalTimePtr(oldDueAt, dueAt)
+		if !equalTimePtr(task.StartAt, startAt) {
+			changes.set("start_at", task.StartAt, startAt)
+		}
+		if task.AllDay != allDay {
+			changes.set("all_day", task.AllDay, allDay)
+		}
+		if params.DueAt != nil || task.AllDay != allDay {
+			// Reset reminder flags when due date changes
+			task.Reminder1hSent = false
+			task.ReminderDueSent = false
+			task.ReminderSnoozedUntil = nil
+		}
+		task.DueAt, task.StartAt, task.AllDay = dueAt, startAt, allDay
+	}
+
+	recurrenceChanged := false
+	if params.Recurrence != nil {
+		recurrence := ""
+		if *params.Recurrence != "" {
+			rule, err := rrule.Parse(*params.Recurrence)
+			if err != nil {
+				return nil, err
+			}
+			if task.DueAt == nil {
+				return nil, ErrRecurrenceRequiresDueDate
+			}
+			recurrence = rule.String()
+		}
+		recurrenceChanged = task.Recurrence != recurrence
+		if recurrenceChanged {
+			changes.set("recurrence", task.Recurrence, recurrence)
+		}
+		task.Recurrence = recurrence
+	}
+
+	if params.Labels != nil {
+		if s.labelRepo == nil {
+			return nil, errors.New("label repository not configured")
+		}
+		labels, err := s.labelRepo.FindOrCreate(ctx, *params.Labels)
+		if err != nil {
+			return nil, err
+		}
+		oldLabels := labelNames(task)
+		task.Labels = labels
+		if newLabels := labelNames(task); strings.Join(oldLabels, ",") != strings.Join(newLabels, ",") {
+			changes.set("labels", oldLabels, newLabels)
+		}
+	}
+
+	if len(params.CustomFields) > 0 {
+		before, after, err := setCustomFields(s.customFieldsOf(ctx, task), task, params.CustomFields)
+		if err != nil {
+			return nil, err
+		}
+		if len(after) > 0 {
+			changes.set("custom_fields", before, after)
+		}
 	}
 
 	// Reset sync status if critical fields changed
-	if params.Title != nil || params.Status != nil || params.Description != nil || params.DueAt != nil {
+	if params.Title != nil || params.Status != nil || params.Description != nil || params.DueAt != nil || params.StartAt != nil || params.AllDay != nil || params.Priority != nil || params.Labels != nil || len(params.CustomFields) > 0 {
 		task.SyncStatus = repository.TaskSyncStatusPending
 	}
 	if params.SyncStatus != nil {
@@ -157,37 +430,173 @@ func (s *Service) UpdateTask(ctx context.Context, id string, params UpdateParams
 	}
 
 	if err := s.repo.Update(ctx, task); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, s.conflict(ctx, id)
+		}
 		return nil, err
 	}
+	// Labels live in a join table, so they are replaced once the versioned write succeeded
+	if params.Labels != nil {
+		if err := s.labelRepo.ReplaceTaskLabels(ctx, task.ID, task.Labels); err != nil {
+			return nil, err
+		}
+	}
+	if recurrenceChanged {
+		if err := s.repo.UpdateRecurrence(ctx, task); err != nil {
+			return nil, err
+		}
+	}
 
-	// Notify
-	// We need actorID. Context usually has user info, but Service methods passed explicit userID often?
-	// UpdateTask doesn't take userID!
-	// We should probably extract userID from context (set by auth middleware) or update signature.
-	// For now, let's skip actorID if not present, OR use a context helper.
-	// But NotificationService.Notify needs valid actorID for logic (not notifying self).
-	// If we don't have actorID, everyone gets notified.
-	// Let's rely on ContextKeyUser or pass userID.
-	// UpdateTask signature: `UpdateTask(ctx, id, params)`.
-	// We should update it to `UpdateTask(ctx, userID, id, params)` or get from ctx.
-	// Getting from ctx requires dependency on middleware package (cycle?) or using string key.
-	// Ideally service shouldn't depend on HTTP middleware keys.
-	// We should update the signature to accept userID.
-	// But that's a larger refactor in Handlers calling it.
-	// Let's assume we can notify "System" or anonymous if we don't have ID for now.
-	// Wait, if I change Status in UI, I am the actor. I shouldn't get notification.
-	// This is critical.
-	// Let's assume we update the signature in next step or use specific context key logic if safe.
-	// Actually, `CreateComment` takes `userID`. `CreateWebTask` takes `userID`.
-	// `ListTasks` takes `userID`.
-	// `UpdateTask` is the outlier!
-	// I will update `UpdateTask` signature to take `userID`.
+	// Audit trail
+	if statusChanged {
+		s.recordEvent(ctx, task.ID, userID, repository.TaskEventStatus, source,
+			map[string]interface{}{"status": oldStatus}, map[string]interface{}{"status": task.Status})
+	}
+	if dueChanged {
+		s.recordEvent(ctx, task.ID, userID, repository.TaskEventDue, source,
+			map[string]interface{}{"due_at": oldDueAt}, map[string]interface{}{"due_at": task.DueAt})
+	}
+	if !changes.empty() {
+		s.recordEvent(ctx, task.ID, userID, repository.TaskEventUpdate, source, changes.before, changes.after)
+	}
 
+	// Notify (the actor is skipped)
 	if statusChanged && s.notifier != nil {
-		// Mock Actor ID for now? Or pass emtpy string?
-		// Empty string -> Logic will notify everyone including me if I am creator/assignee.
-		// I will pass empty string for now and log TODO.
-		s.notifier.Notify(ctx, notification.EventStatusChanged, task, "", nil)
+		s.notifier.Notify(ctx, notification.EventStatusChanged, task, userID, nil)
+	}
+	if dueChanged && s.notifier != nil {
+		s.notifier.Notify(ctx, notification.EventDueChanged, task, userID, nil)
+	}
+
+	if statusChanged && task.IsDone() && task.ParentID != nil {
+		s.completeParentIfDone(ctx, *task.ParentID)
+	}
+
+	if statusChanged && task.IsDone() {
+		s.notifyUnblocked(ctx, task)
+	}
+
+	return task, nil
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// convertTaskTime brings a due (end) or start time stored with wasAllDay into
+// the allDay mode: instants become their date in loc, and dates become 23:59
+// (due) or midnight (start) of that date in loc
+func convertTaskTime(t *time.Time, wasAllDay, allDay bool, loc *time.Location, end bool) *time.Time {
+	if t == nil || wasAllDay == allDay {
+		return t
+	}
+	var converted time.Time
+	switch {
+	case allDay:
+		converted = timezone.Date(*t, loc)
+	case end:
+		converted = timezone.EndOfDate(*t, loc)
+	default:
+		converted = timezone.StartOfDate(*t, loc)
+	}
+	return &converted
+}
+
+// conflict builds the ConflictError for a task whose versioned write failed
+func (s *Service) conflict(ctx context.Context, id string) error {
+	current, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return errors.New("task not found")
+	}
+	return &ConflictError{Current: current}
+}
+
+// completeParentIfDone marks the parent Done when it opted into auto-complete
+// and all of its subtasks are Done
+func (s *Service) completeParentIfDone(ctx context.Context, parentID string) {
+	parent, err := s.repo.GetByID(ctx, parentID)
+	if err != nil || parent == nil {
+		return
+	}
+	if !parent.AutoComplete || parent.IsDone() {
+		return
+	}
+	if parent.SubtaskTotal == 0 || parent.SubtaskDone < parent.SubtaskTotal {
+		return
+	}
+
+	done := s.StatusForCategory(ctx, parent, repository.StatusCategoryDone)
+	if _, err := s.updateTask(ctx, "", parentID, UpdateParams{Status: &done}, repository.TaskEventSourceSystem); err != nil {
+		s.logger.Error("failed to auto-complete parent task", zap.String("task_id", parentID), zap.Error(err))
+		return
+	}
+	s.logger.Info("parent task auto-completed", zap.String("task_id", parentID))
+}
+
+// ListSubtasks lists the subtasks of a task
+func (s *Service) ListSubtasks(ctx context.Context, parentID string) ([]repository.Task, error) {
+	return s.repo.ListSubtasks(ctx, parentID)
+}
+
+// CreateSubtask creates a subtask under the given parent. The subtask inherits
+// the parent's group, database and priority; only one level of nesting is allowed.
+func (s *Service) CreateSubtask(ctx context.Context, userID, parentID, title, description string) (*repository.Task, error) {
+	parent, err := s.repo.GetByID(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, errors.New("task not found")
+	}
+	if parent.ParentID != nil {
+		return nil, ErrNestedSubtask
+	}
+
+	now := time.Now()
+	task := &repository.Task{
+		ParentID:    &parent.ID,
+		Title:       title,
+		Description: description,
+		CreatorID:   &userID,
+		Priority:    notionPriorityOf(parent),
+		SyncStatus:  repository.TaskSyncStatusPending,
+		GroupID:     parent.GroupID,
+		DatabaseID:  parent.DatabaseID,
+		Topic:       parent.Topic,
+		ChatJumpURL: parent.ChatJumpURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.setInitialStatus(ctx, task)
+
+	if err := s.repo.Create(ctx, task); err != nil {
+		return nil, err
+	}
+	s.recordEvent(ctx, task.ID, userID, repository.TaskEventCreate, repository.TaskEventSourceApp, nil, taskEventState(task))
+
+	if s.notifier != nil {
+		s.notifier.Notify(ctx, notification.EventTaskCreated, task, userID, nil)
+	}
+
+	if task.DatabaseID != nil {
+		go func() {
+			if err := s.SyncToNotion(context.Background(), task, userID, *task.DatabaseID); err != nil {
+				s.logger.Error("failed to sync subtask to notion", zap.String("task_id", task.ID), zap.Error(err))
+			}
+		}()
 	}
 
 	return task, nil
@@ -201,6 +610,7 @@ func (s *Service) CreateWebTask(ctx context.Context, userID, title, description
 		Description: description,
 		CreatorID:   &userID,
 		Status:      repository.TaskStatusToDo,
+		Priority:    repository.TaskPriorityMedium,
 		SyncStatus:  repository.TaskSyncStatusPending,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
@@ -209,6 +619,7 @@ func (s *Service) CreateWebTask(ctx context.Context, userID, title, description
 	if err := s.repo.Create(ctx, task); err != nil {
 		return nil, err
 	}
+	s.recordEvent(ctx, task.ID, userID, repository.TaskEventCreate, repository.TaskEventSourceApp, nil, taskEventState(task))
 
 	if s.notifier != nil {
 		s.notifier.Notify(ctx, notification.EventTaskCreated, task, userID, nil)
@@ -217,71 +628,190 @@ func (s *Service) CreateWebTask(ctx context.Context, userID, title, description
 	return task, nil
 }
 
-// CreateComment creates a new comment
-func (s *Service) CreateComment(ctx context.Context, taskID, userID, content string, parentID *string) (*repository.TaskComment, error) {
-	task, err := s.repo.GetByID(ctx, taskID)
+// creatorLocation returns the time zone of the task's creator, UTC when unknown
+func (s *Service) creatorLocation(ctx context.Context, task *repository.Task) *time.Location {
+	if task.Creator != nil {
+		return task.Creator.Location()
+	}
+	if task.CreatorID == nil || s.userRepo == nil {
+		return time.UTC
+	}
+	user, err := s.userRepo.FindByID(ctx, *task.CreatorID)
+	if err != nil || user == nil {
+		return time.UTC
+	}
+	return user.Location()
+}
+
+// SpawnNextOccurrence creates the next instance of a recurring task, copying
+// assignees, group and database. Occurrences whose due date has already passed
+// are skipped. Returns nil when the series has ended or another run already
+// spawned the instance.
+func (s *Service) SpawnNextOccurrence(ctx context.Context, task *repository.Task, now time.Time) (*repository.Task, error) {
+	rule, err := rrule.Parse(task.Recurrence)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recurrence on task %s: %w", task.ID, err)
+	}
+
+	claimed, err := s.repo.SetRecurrenceSpawned(ctx, task.ID, true)
 	if err != nil {
 		return nil, err
 	}
-	if task == nil {
-		return nil, errors.New("task not found")
+	if !claimed {
+		return nil, nil
 	}
 
-	comment := &repository.TaskComment{
-		TaskID:   taskID,
-		UserID:   userID,
-		Content:  content,
-		ParentID: parentID,
+	anchor := task.CreatedAt
+	if task.DueAt != nil {
+		anchor = *task.DueAt
 	}
-	createdComment, err := s.repo.CreateComment(ctx, comment)
-	if err != nil {
+	// Step in the creator's zone so BYDAY and month days match their calendar;
+	// all-day dates are already calendar dates
+	if !task.AllDay {
+		anchor = anchor.In(s.creatorLocation(ctx, task))
+	}
+	index := task.RecurrenceIndex
+	var nextDue time.Time
+	for {
+		next, ok := rule.Next(anchor)
+		index++
+		if !ok || (rule.Count > 0 && index >= rule.Count) {
+			s.logger.Info("recurrence ended", zap.String("task_id", task.ID), zap.String("rrule", task.Recurrence))
+			return nil, nil
+		}
+		if next.After(now) {
+			nextDue = next
+			break
+		}
+		anchor = next
+	}
+
+	parentID := task.ID
+	if task.RecurrenceParentID != nil {
+		parentID = *task.RecurrenceParentID
+	}
+	nextDue = nextDue.UTC()
+	var nextStart *time.Time
+	if task.StartAt != nil && task.DueAt != nil {
+		start := nextDue.Add(task.StartAt.Sub(*task.DueAt))
+		nextStart = &start
+	}
+
+	newTask := &repository.Task{
+		Title:              task.Title,
+		Description:        task.Description,
+		Priority:           notionPriorityOf(task),
+		SyncStatus:         repository.TaskSyncStatusPending,
+		GroupID:            task.GroupID,
+		DatabaseID:         task.DatabaseID,
+		Topic:              task.Topic,
+		DueAt:              &nextDue,
+		StartAt:            nextStart,
+		AllDay:             task.AllDay,
+		CreatorID:          task.CreatorID,
+		ChatJumpURL:        task.ChatJumpURL,
+		Recurrence:         task.Recurrence,
+		RecurrenceParentID: &parentID,
+		RecurrenceIndex:    index,
+		Assignees:          task.Assignees,
+		Labels:             task.Labels,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	s.setInitialStatus(ctx, newTask)
+
+	if err := s.repo.Create(ctx, newTask); err != nil {
+		// Release the claim so the next run retries
+		if _, releaseErr := s.repo.SetRecurrenceSpawned(ctx, task.ID, false); releaseErr != nil {
+			s.logger.Error("failed to release recurrence claim", zap.String("task_id", task.ID), zap.Error(releaseErr))
+		}
 		return nil, err
 	}
 
+	s.recordEvent(ctx, newTask.ID, "", repository.TaskEventCreate, repository.TaskEventSourceSystem, nil, map[string]interface{}{
+		"title":              newTask.Title,
+		"due_at":             newTask.DueAt,
+		"recurrence":         newTask.Recurrence,
+		"recurrence_index":   newTask.RecurrenceIndex,
+		"previous_task_id":   task.ID,
+		"recurrence_root_id": parentID,
+	})
+
+	s.logger.Info("recurring task spawned",
+		zap.String("task_id", newTask.ID),
+		zap.String("previous_task_id", task.ID),
+		zap.Time("due_at", nextDue))
+
 	if s.notifier != nil {
-		s.notifier.Notify(ctx, notification.EventCommentAdded, task, userID, createdComment)
+		s.notifier.Notify(ctx, notification.EventTaskCreated, newTask, "system", nil)
 	}
 
-	return createdComment, nil
-}
+	if newTask.DatabaseID != nil && newTask.CreatorID != nil {
+		go func() {
+			if err := s.SyncToNotion(context.Background(), newTask, *newTask.CreatorID, *newTask.DatabaseID); err != nil {
+				s.logger.Error("failed to sync recurring task to notion", zap.String("task_id", newTask.ID), zap.Error(err))
+			}
+		}()
+	}
 
-// ListComments lists comments for a task
-func (s *Service) ListComments(ctx context.Context, taskID string) ([]repository.TaskComment, error) {
-	return s.repo.ListComments(ctx, taskID)
+	return newTask, nil
 }
 
-// SyncTaskFromNotion upserts a task from Notion data
-func (s *Service) SyncTaskFromNotion(ctx context.Context, notionPageID, databaseID, title, status string, notionURL string, assignees []string, isArchived bool) error {
+// SyncTaskFromNotion upserts a task from Notion data. properties are the
+// page's properties, read for the group's custom fields. editedAt is the
+// page's last edit time; local changes that are newer and not yet pushed to
+// Notion win over it.
+func (s *Service) SyncTaskFromNotion(ctx context.Context, notionPageID, databaseID, title, status string, notionURL string, assignees []string, properties notion.DatabasePageProperties, isArchived bool, editedAt time.Time) error {
 	// Check if task exists by NotionPageID
 	existing, err := s.repo.GetByNotionPageID(ctx, notionPageID)
 	if err != nil {
 		return err
 	}
 
-	if isArchived {
-		if existing != nil {
-			s.logger.Info("syncing deletion from notion", zap.String("task_id", existing.ID), zap.String("notion_id", notionPageID))
-			return s.repo.SoftDelete(ctx, existing.ID)
+	// Archiving round-trips: a page in the Notion trash is an archived task
+	if existing != nil && existing.Archived != isArchived {
+		s.logger.Info("syncing archived state from notion", zap.String("task_id", existing.ID), zap.String("notion_id", notionPageID), zap.Bool("archived", isArchived))
+		if err := s.repo.SetArchived(ctx, existing.ID, isArchived); err != nil {
+			return err
 		}
-		// If not found locally, nothing to delete
+		s.recordEvent(ctx, existing.ID, "", repository.TaskEventUpdate, repository.TaskEventSourceNotion,
+			map[string]interface{}{"archived": existing.Archived}, map[string]interface{}{"archived": isArchived})
+		existing.Archived = isArchived
+	}
+	if isArchived {
+		// Archived pages are not imported, and archived tasks are not edited from Notion
 		return nil
 	}
 
 	now := time.Now()
 
 	if existing != nil {
+		if existing.SyncStatus != repository.TaskSyncStatusSynced && !editedAt.IsZero() && existing.UpdatedAt.After(editedAt) {
+			s.logger.Info("skipping notion update, local changes are newer", zap.String("task_id", existing.ID), zap.String("notion_id", notionPageID))
+			return nil
+		}
+
 		// Update
 		needsUpdate := false
+		oldTitle, oldStatus := existing.Title, existing.Status
 		if existing.Title != title {
 			existing.Title = title
 			needsUpdate = true
 		}
-		if existing.Status != repository.TaskStatus(status) {
-			existing.Status = repository.TaskStatus(status)
+		statusChanged := false
+		if st, ok := s.workflowOf(ctx, existing).Find(status); !ok {
+			s.logger.Warn("ignoring notion status missing from the workflow", zap.String("task_id", existing.ID), zap.String("status", status))
+		} else if existing.Status != st.Name {
+			existing.SetStatus(st)
+			setCompletedAt(existing, now)
 			needsUpdate = true
-			if s.notifier != nil {
-				// Notify status change
-				s.notifier.Notify(ctx, notification.EventStatusChanged, existing, "system", nil)
+			statusChanged = true
+		}
+		var fieldsBefore, fieldsAfter map[string]interface{}
+		if fields := s.customFieldsOf(ctx, existing); len(fields) > 0 && properties != nil {
+			fieldsBefore, fieldsAfter = applyCustomValues(existing, customValuesFromNotion(fields, properties))
+			if len(fieldsAfter) > 0 {
+				needsUpdate = true
 			}
 		}
 		// Todo: Handle Assignees update if we map Notion Users to Local Users
@@ -289,7 +819,32 @@ func (s *Service) SyncTaskFromNotion(ctx context.Context, notionPageID, database
 		if needsUpdate {
 			existing.UpdatedAt = now
 			existing.SyncStatus = repository.TaskSyncStatusSynced
-			return s.repo.Update(ctx, existing)
+			if err := s.repo.Update(ctx, existing); err != nil {
+				if errors.Is(err, repository.ErrVersionConflict) {
+					// Edited locally since it was read; the local change will be pushed to Notion
+					s.logger.Info("skipping notion update, task changed concurrently", zap.String("task_id", existing.ID), zap.String("notion_id", notionPageID))
+					return nil
+				}
+				return err
+			}
+			changes := newFieldChanges()
+			if oldTitle != title {
+				changes.set("title", oldTitle, title)
+			}
+			if len(fieldsAfter) > 0 {
+				changes.set("custom_fields", fieldsBefore, fieldsAfter)
+			}
+			if !changes.empty() {
+				s.recordEvent(ctx, existing.ID, "", repository.TaskEventUpdate, repository.TaskEventSourceNotion, changes.before, changes.after)
+			}
+			if statusChanged {
+				s.recordEvent(ctx, existing.ID, "", repository.TaskEventStatus, repository.TaskEventSourceNotion,
+					map[string]interface{}{"status": oldStatus}, map[string]interface{}{"status": existing.Status})
+				if s.notifier != nil {
+					// Notify status change
+					s.notifier.Notify(ctx, notification.EventStatusChanged, existing, "system", nil)
+				}
+			}
 		}
 		return nil
 	}
@@ -297,7 +852,7 @@ func (s *Service) SyncTaskFromNotion(ctx context.Context, notionPageID, database
 	// Create New
 	newTask := &repository.Task{
 		Title:        title,
-		Status:       repository.TaskStatus(status),
+		Priority:     repository.TaskPriorityMedium,
 		SyncStatus:   repository.TaskSyncStatusSynced,
 		NotionPageID: &notionPageID,
 		NotionURL:    &notionURL,
@@ -307,13 +862,26 @@ func (s *Service) SyncTaskFromNotion(ctx context.Context, notionPageID, database
 		// Assignees? We need to find UserID by Notion UserID. This requires a UserRepo lookup.
 		// For MVP, we might skip assignee sync or do best effort if we had a mapping service.
 	}
+	workflow := s.workflowOf(ctx, newTask)
+	if st, ok := workflow.Find(status); ok {
+		newTask.SetStatus(st)
+	} else {
+		newTask.SetStatus(initialStatus(workflow))
+	}
+	setCompletedAt(newTask, now)
+	if properties != nil {
+		applyCustomValues(newTask, customValuesFromNotion(s.customFieldsOf(ctx, newTask), properties))
+	}
 
-	err = s.repo.Create(ctx, newTask)
-	if err == nil && s.notifier != nil {
+	if err := s.repo.Create(ctx, newTask); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, newTask.ID, "", repository.TaskEventCreate, repository.TaskEventSourceNotion, nil, taskEventState(newTask))
+	if s.notifier != nil {
 		// Notify creation
 		s.notifier.Notify(ctx, notification.EventTaskCreated, newTask, "system", nil)
 	}
-	return err
+	return nil
 }
 
 // SyncToNotion syncs the task to Notion asynchronously
@@ -321,41 +889,47 @@ func (s *Service) SyncToNotion(ctx context.Context, task *repository.Task, userI
 	logger := s.logger.With(zap.String("task_id", task.ID), zap.String("user_id", userID))
 	logger.Info("syncing to Notion...")
 
-	// 1. Get Token
-	token, err := s.userRepo.FindNotionToken(ctx, userID)
+	// 1-3. Get Token, Decrypt and Create Client
+	client, err := s.notionClientFor(ctx, logger, userID)
 	if err != nil {
-		logger.Error("failed to find notion token", zap.Error(err))
 		return err
 	}
 
-	// 2. Decrypt Token
-	accessToken, err := security.Decrypt(token.AccessTokenEnc, s.encryptionKey)
-	if err != nil {
-		logger.Error("failed to decrypt token", zap.Error(err))
-		return err
+	// Optional properties are written only to databases that have them
+	dbProps := s.notionDatabaseProperties(ctx, logger, client, databaseID)
+	var notionPriority string
+	if hasNotionProperty(dbProps, "Priority", notion.DBPropTypeSelect) {
+		notionPriority = string(notionPriorityOf(task))
+	}
+	var notionLabels []string // nil leaves the property alone, empty clears it
+	if hasNotionProperty(dbProps, "Labels", notion.DBPropTypeMultiSelect) {
+		notionLabels = labelNames(task)
 	}
 
-	// 3. Create Client
-	client := s.notionClient(accessToken)
+	customProps, clearedProps := customFieldProperties(s.customFieldsOf(ctx, task), task)
 
 	// 4. Check if Task is Already Synced (Update vs Create)
 	if task.NotionPageID != nil && *task.NotionPageID != "" {
 		// UPDATE
 		pageID := *task.NotionPageID
-		// Convert status if needed (Assuming Notion uses "To Do", "In Progress", "Done")
-		// We need to map local status to Notion status.
-		// Local: ToDo, InProgress, Done
-		notionStatus := "To Do"
-		switch task.Status {
-		case repository.TaskStatusInProgress:
-			notionStatus = "In Progress"
-		case repository.TaskStatusDone:
-			notionStatus = "Done"
-		}
+		// Statuses map one-to-one to the options of the Notion Status property
+		notionStatus := string(task.Status)
 
+		var priority *string
+		if notionPriority != "" {
+			priority = &notionPriority
+		}
+		// The page's archived state mirrors the task; restored tasks leave the Notion trash
+		archived := task.Archived
 		_, err := client.UpdatePage(ctx, pageID, pkgnotion.UpdatePageParams{
-			Title:  &task.Title,
-			Status: &notionStatus,
+			Title:      &task.Title,
+			Status:     &notionStatus,
+			Priority:   priority,
+			Labels:     notionLabels,
+			Archived:   &archived,
+			Assignees:  s.notionAssigneeIDs(ctx, task),
+			Properties: customProps,
+			Cleared:    clearedProps,
 		})
 		if err != nil {
 			logger.Error("failed to update page in notion", zap.Error(err))
@@ -381,12 +955,25 @@ func (s *Service) SyncToNotion(ctx context.Context, task *repository.Task, userI
 	// 5. Build Content Blocks
 	children := s.buildContentBlocks(task)
 
+	// Subtasks link back to their parent page
+	var parentPageID string
+	if task.ParentID != nil {
+		if parent, err := s.repo.GetByID(ctx, *task.ParentID); err == nil && parent != nil && parent.NotionPageID != nil {
+			parentPageID = *parent.NotionPageID
+			children = append([]notion.Block{linkToPage(parentPageID)}, children...)
+		}
+	}
+
 	// 6. Create Page
 	page, err := client.CreatePage(ctx, pkgnotion.CreatePageParams{
 		DatabaseID: databaseID,
 		Title:      task.Title,
-		Status:     "To Do", // Default for new task
+		Status:     string(task.Status),
+		Priority:   notionPriority,
+		Labels:     notionLabels,
+		Assignees:  s.notionAssigneeIDs(ctx, task),
 		Children:   children,
+		Properties: customProps,
 	})
 	if err != nil {
 		logger.Error("failed to create page in notion", zap.Error(err))
@@ -402,9 +989,58 @@ func (s *Service) SyncToNotion(ctx context.Context, task *repository.Task, userI
 	s.repo.UpdateStatus(ctx, task)
 
 	logger.Info("notion page created", zap.String("page_id", page.ID))
+
+	// Link the new subtask from the parent page
+	if parentPageID != "" {
+		if err := client.AppendBlockChildren(ctx, parentPageID, []notion.Block{linkToPage(page.ID)}); err != nil {
+			logger.Warn("failed to link subtask in parent page", zap.String("parent_page_id", parentPageID), zap.Error(err))
+		}
+	}
 	return nil
 }
 
+// notionDatabaseProperties returns the properties of a Notion database, or
+// nil when it cannot be read
+func (s *Service) notionDatabaseProperties(ctx context.Context, logger *zap.Logger, client pkgnotion.Client, databaseID string) notion.DatabaseProperties {
+	db, err := client.GetDatabase(ctx, databaseID)
+	if err != nil || db == nil {
+		logger.Warn("failed to read notion database properties", zap.String("database_id", databaseID), zap.Error(err))
+		return nil
+	}
+	return db.Properties
+}
+
+// hasNotionProperty reports whether a database has the named property of the
+// given type. Databases bound before Priority and Labels existed lack them
+// until initialized again, and Notion rejects writes to unknown properties.
+func hasNotionProperty(props notion.DatabaseProperties, name string, t notion.DatabasePropertyType) bool {
+	prop, ok := props[name]
+	return ok && prop.Type == t
+}
+
+// notionClientFor returns a Notion client authorized with the user's token
+func (s *Service) notionClientFor(ctx context.Context, logger *zap.Logger, userID string) (pkgnotion.Client, error) {
+	token, err := s.userRepo.FindNotionToken(ctx, userID)
+	if err != nil {
+		logger.Error("failed to find notion token", zap.Error(err))
+		return nil, err
+	}
+	accessToken, err := security.Decrypt(token.AccessTokenEnc, s.encryptionKey)
+	if err != nil {
+		logger.Error("failed to decrypt token", zap.Error(err))
+		return nil, err
+	}
+	return s.notionClient(accessToken), nil
+}
+
+// linkToPage builds a block linking to another Notion page
+func linkToPage(pageID string) notion.Block {
+	return notion.LinkToPageBlock{
+		Type:   notion.LinkToPageTypePageID,
+		PageID: pageID,
+	}
+}
+
 // SyncPendingTasks finds all pending tasks for a group and syncs them
 func (s *Service) SyncPendingTasks(ctx context.Context, groupID string) error {
 	// We need an admin user ID for the group to get the token.
@@ -434,6 +1070,33 @@ func (s *Service) SyncPendingTasksForUser(ctx context.Context, userID, groupID,
 	return nil
 }
 
+// labelNames returns the label names of a task for Notion multi-select
+func labelNames(task *repository.Task) []string {
+	names := make([]string, 0, len(task.Labels))
+	for _, l := range task.Labels {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// setCompletedAt stamps when a task entered Done, or clears it when the task
+// leaves Done
+func setCompletedAt(task *repository.Task, now time.Time) {
+	if task.IsDone() {
+		task.CompletedAt = &now
+		return
+	}
+	task.CompletedAt = nil
+}
+
+// notionPriorityOf returns the task priority, falling back to Medium for legacy rows
+func notionPriorityOf(task *repository.Task) repository.TaskPriority {
+	if task.Priority.IsValid() {
+		return task.Priority
+	}
+	return repository.TaskPriorityMedium
+}
+
 // buildContentBlocks constructs Notion blocks from task data
 func (s *Service) buildContentBlocks(task *repository.Task) []notion.Block {
 	var children []notion.Block
@@ -469,6 +1132,9 @@ func (s *Service) buildContentBlocks(task *repository.Task) []notion.Block {
 		}
 	}
 
+	// Telegram media
+	children = append(children, s.attachmentBlocks(task.Attachments)...)
+
 	// Chat Jump URL
 	if task.ChatJumpURL != "" {
 		emoji := "💬"
@@ -514,51 +1180,13 @@ func (s *Service) AssignTaskToTelegramUser(ctx context.Context, taskID string, t
 	return s.AssignTask(ctx, taskID, userID)
 }
 
-// AssignTask assigns the task to a user (by internal UUID)
+// AssignTask lets a user claim a task (by internal UUID). The user is added
+// to the assignees; others already assigned stay assigned.
 func (s *Service) AssignTask(ctx context.Context, taskID, userID string) error {
-	task, err := s.repo.GetByID(ctx, taskID)
-	if err != nil {
-		return err
-	}
-	if task == nil {
-		return errors.New("task not found")
-	}
-
-	// Capture Old Assignee Name
-	oldAssigneeName := "无"
-	if len(task.Assignees) > 0 {
-		oldAssigneeName = task.Assignees[0].Name
-	}
-
-	// 1. Update DB
-	if err := s.repo.AssignTask(ctx, taskID, userID); err != nil {
-		return err
-	}
-
-	// 2. Fetch New Assignee Name
-	newUser, err := s.userRepo.FindByID(ctx, userID)
-	newAssigneeName := "未知用户"
-	if err == nil && newUser != nil {
-		newAssigneeName = newUser.Name
-	}
-
-	// 3. Notify Creator
-	// Refresh task to ensure latest state (though we pass task object, ID is constant)
-	// We pass the task object we fetched earlier as it contains Title/CreatorID correctly.
-	s.notifier.NotifyAssigneeChange(ctx, task, oldAssigneeName, newAssigneeName)
-
-	// Log
-	s.logger.Info("task assigned",
-		zap.String("task_id", taskID),
-		zap.String("user_id", userID),
-		zap.String("old_assignee", oldAssigneeName),
-		zap.String("new_assignee", newAssigneeName))
-
-	// Sync logic
-	task.SyncStatus = repository.TaskSyncStatusPending
-	s.repo.UpdateStatus(ctx, task)
-
-	return nil
+	// Claims come from the bot (claim button / pending mention), so the
+	// assignee is the actor
+	_, err := s.AddAssignee(ctx, userID, taskID, userID, repository.TaskEventSourceBot)
+	return err
 }
 
 // ClaimPendingAssignments checks for any pending assignments for the user and assigns them
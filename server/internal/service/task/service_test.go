@@ -3,21 +3,28 @@ package task
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	gonotion "github.com/dstotijn/go-notion"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"gorm.io/datatypes"
 
 	"github.com/layababa/tg_todo/server/internal/models"
 	"github.com/layababa/tg_todo/server/internal/repository"
 	pkgnotion "github.com/layababa/tg_todo/server/pkg/notion"
 	"github.com/layababa/tg_todo/server/pkg/security"
+	"github.com/layababa/tg_todo/server/pkg/timezone"
 )
 
 type mockTaskRepository struct {
 	mock.Mock
+	events []repository.TaskEvent // Audit events are recorded rather than expected
 }
 
 func (m *mockTaskRepository) Create(ctx context.Context, task *repository.Task) error {
@@ -48,6 +55,43 @@ func (m *mockTaskRepository) SoftDelete(ctx context.Context, id string) error {
 	return m.Called(ctx, id).Error(0)
 }
 
+func (m *mockTaskRepository) ListDeleted(ctx context.Context, userID string, limit, offset int) ([]repository.Task, error) {
+	args := m.Called(ctx, userID, limit, offset)
+	if v := args.Get(0); v != nil {
+		return v.([]repository.Task), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockTaskRepository) GetDeletedByID(ctx context.Context, id string) (*repository.Task, error) {
+	args := m.Called(ctx, id)
+	if v := args.Get(0); v != nil {
+		return v.(*repository.Task), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockTaskRepository) Restore(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *mockTaskRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockTaskRepository) SetArchived(ctx context.Context, id string, archived bool) error {
+	return m.Called(ctx, id, archived).Error(0)
+}
+
+func (m *mockTaskRepository) ListAutoArchivable(ctx context.Context, now time.Time) ([]repository.Task, error) {
+	args := m.Called(ctx, now)
+	if v := args.Get(0); v != nil {
+		return v.([]repository.Task), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *mockTaskRepository) Update(ctx context.Context, task *repository.Task) error {
 	return m.Called(ctx, task).Error(0)
 }
@@ -61,7 +105,20 @@ func (m *mockTaskRepository) CreateComment(ctx context.Context, comment *reposit
 }
 
 func (m *mockTaskRepository) GetCommentByID(ctx context.Context, id string) (*repository.TaskComment, error) {
-	return nil, nil
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.TaskComment), args.Error(1)
+}
+
+func (m *mockTaskRepository) UpdateComment(ctx context.Context, comment *repository.TaskComment) error {
+	return m.Called(ctx, comment).Error(0)
+}
+
+func (m *mockTaskRepository) DeleteComment(ctx context.Context, id string) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
 }
 
 func (m *mockTaskRepository) ListComments(ctx context.Context, taskID string) ([]repository.TaskComment, error) {
@@ -88,8 +145,36 @@ func (m *mockTaskRepository) ListPendingByGroup(ctx context.Context, groupID str
 	return args.Get(0).([]repository.Task), args.Error(1)
 }
 
-func (m *mockTaskRepository) AssignTask(ctx context.Context, taskID, userID string) error {
-	return m.Called(ctx, taskID, userID).Error(0)
+func (m *mockTaskRepository) AddAssignee(ctx context.Context, taskID, userID string, assignedBy *string) (bool, error) {
+	args := m.Called(ctx, taskID, userID, assignedBy)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockTaskRepository) RemoveAssignee(ctx context.Context, taskID, userID string) (bool, error) {
+	args := m.Called(ctx, taskID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockTaskRepository) SetAssignees(ctx context.Context, taskID string, userIDs []string, assignedBy *string) error {
+	return m.Called(ctx, taskID, userIDs, assignedBy).Error(0)
+}
+
+func (m *mockTaskRepository) AddWatcher(ctx context.Context, taskID, userID string) (bool, error) {
+	args := m.Called(ctx, taskID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockTaskRepository) RemoveWatcher(ctx context.Context, taskID, userID string) (bool, error) {
+	args := m.Called(ctx, taskID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockTaskRepository) ListWatcherIDs(ctx context.Context, taskID string) ([]string, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
 }
 
 func (m *mockTaskRepository) GetTaskCounts(ctx context.Context, userID string) (*repository.TaskCounts, error) {
@@ -112,6 +197,72 @@ func (m *mockTaskRepository) UpdateReminderFlags(ctx context.Context, id string,
 	return m.Called(ctx, id, reminder1h, reminderDue).Error(0)
 }
 
+func (m *mockTaskRepository) SnoozeReminders(ctx context.Context, id string, until time.Time) error {
+	return m.Called(ctx, id, until).Error(0)
+}
+
+func (m *mockTaskRepository) Move(ctx context.Context, ids []string, groupID, databaseID *string, statuses map[string]repository.WorkflowStatus) error {
+	return m.Called(ctx, ids, groupID, databaseID, statuses).Error(0)
+}
+
+func (m *mockTaskRepository) ListSubtasks(ctx context.Context, parentID string) ([]repository.Task, error) {
+	args := m.Called(ctx, parentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.Task), args.Error(1)
+}
+
+func (m *mockTaskRepository) GetSubtaskProgress(ctx context.Context, parentIDs []string) (map[string]repository.SubtaskProgress, error) {
+	args := m.Called(ctx, parentIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]repository.SubtaskProgress), args.Error(1)
+}
+
+func (m *mockTaskRepository) UpdateRecurrence(ctx context.Context, task *repository.Task) error {
+	return m.Called(ctx, task).Error(0)
+}
+
+func (m *mockTaskRepository) ListRecurringToSpawn(ctx context.Context, now time.Time) ([]repository.Task, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.Task), args.Error(1)
+}
+
+func (m *mockTaskRepository) SetRecurrenceSpawned(ctx context.Context, id string, spawned bool) (bool, error) {
+	args := m.Called(ctx, id, spawned)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockTaskRepository) Search(ctx context.Context, userID string, filter repository.TaskSearchFilter) ([]repository.TaskSearchHit, error) {
+	args := m.Called(ctx, userID, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.TaskSearchHit), args.Error(1)
+}
+
+func (m *mockTaskRepository) CreateEvent(ctx context.Context, event *repository.TaskEvent) error {
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *mockTaskRepository) ListEvents(ctx context.Context, taskID string, limit, offset int) ([]repository.TaskEvent, error) {
+	args := m.Called(ctx, taskID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.TaskEvent), args.Error(1)
+}
+
+func (m *mockTaskRepository) Transaction(ctx context.Context, fn func(tx repository.TaskRepository) error) error {
+	return fn(m)
+}
+
 func TestListTasksDelegatesToRepository(t *testing.T) {
 	repo := new(mockTaskRepository)
 	service := NewService(ServiceConfig{Repo: repo})
@@ -120,16 +271,34 @@ func TestListTasksDelegatesToRepository(t *testing.T) {
 	task := repository.Task{ID: "task-1", Title: "Test"}
 	repo.On("ListByUser", mock.Anything, "user-1", filter).Return([]repository.Task{task}, nil)
 
-	result, err := service.ListTasks(context.Background(), "user-1", ListParams{
+	result, nextCursor, err := service.ListTasks(context.Background(), "user-1", ListParams{
 		View:  repository.TaskViewAssigned,
 		Limit: 20,
 	})
 	assert.NoError(t, err)
+	assert.Empty(t, nextCursor)
 	assert.Len(t, result, 1)
 	assert.Equal(t, "task-1", result[0].Task.ID)
 	repo.AssertExpectations(t)
 }
 
+func TestListTasksReturnsNextCursorWhenPageFull(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo})
+
+	filter := repository.TaskListFilter{View: repository.TaskViewCreated, Sort: repository.TaskSortTitle, Limit: 2}
+	tasks := []repository.Task{{ID: "a", Title: "alpha"}, {ID: "b", Title: "bravo"}}
+	repo.On("ListByUser", mock.Anything, "user-1", filter).Return(tasks, nil)
+
+	_, nextCursor, err := service.ListTasks(context.Background(), "user-1", ListParams{
+		View:  repository.TaskViewCreated,
+		Sort:  repository.TaskSortTitle,
+		Limit: 2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, repository.EncodeTaskCursor("user-1", filter, &tasks[1]), nextCursor)
+}
+
 func TestGetTaskUsesRepository(t *testing.T) {
 	repo := new(mockTaskRepository)
 	service := NewService(ServiceConfig{Repo: repo})
@@ -149,7 +318,7 @@ func TestDeleteTaskChecksExistence(t *testing.T) {
 	repo.On("GetByID", mock.Anything, "task-1").Return(&repository.Task{ID: "task-1"}, nil)
 	repo.On("SoftDelete", mock.Anything, "task-1").Return(nil)
 
-	err := service.DeleteTask(context.Background(), "task-1")
+	err := service.DeleteTask(context.Background(), "user-1", "task-1")
 	assert.NoError(t, err)
 	repo.AssertExpectations(t)
 }
@@ -160,10 +329,42 @@ func TestDeleteTaskWhenMissing(t *testing.T) {
 
 	repo.On("GetByID", mock.Anything, "task-404").Return((*repository.Task)(nil), errors.New("not found"))
 
-	err := service.DeleteTask(context.Background(), "task-404")
+	err := service.DeleteTask(context.Background(), "user-1", "task-404")
 	assert.Error(t, err)
 }
 
+func TestRestoreTaskRecordsEvent(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	creator := "user-2"
+	task := &repository.Task{ID: "task-1", Title: "Oops", CreatorID: &creator}
+	repo.On("GetDeletedByID", mock.Anything, "task-1").Return(task, nil)
+	repo.On("Restore", mock.Anything, "task-1").Return(nil)
+	repo.On("GetByID", mock.Anything, "task-1").Return(task, nil)
+
+	restored, err := service.RestoreTask(context.Background(), "user-1", "task-1")
+	require.NoError(t, err)
+	assert.Equal(t, "task-1", restored.ID)
+	repo.AssertExpectations(t)
+
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, repository.TaskEventRestore, repo.events[0].Event)
+	assert.Equal(t, "user-1", *repo.events[0].ActorID)
+	assert.Nil(t, repo.events[0].Before)
+}
+
+func TestRestoreTaskWhenNotInTrash(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	repo.On("GetDeletedByID", mock.Anything, "task-1").Return(nil, nil)
+
+	_, err := service.RestoreTask(context.Background(), "user-1", "task-1")
+	assert.EqualError(t, err, "task not found")
+	repo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything)
+}
+
 func TestSyncToNotion_Create(t *testing.T) {
 	repo := new(mockTaskRepository)
 	userRepo := new(mockUserRepo) // Need a real mock here, mockTaskRepository is defined here but mockUserRepo is in creator_test.go
@@ -223,6 +424,9 @@ func TestSyncToNotion_Update(t *testing.T) {
 		return stub
 	}
 
+	// Only assignees who connected Notion can be set on the People property
+	userRepo.notionTokens["user-2"] = &models.UserNotionToken{UserID: "user-2", NotionUserID: "notion-user-2"}
+
 	pageID := "page-123"
 	task := &repository.Task{
 		ID:           "t1",
@@ -230,6 +434,7 @@ func TestSyncToNotion_Update(t *testing.T) {
 		Status:       repository.TaskStatusDone,
 		NotionPageID: &pageID,
 		SyncStatus:   repository.TaskSyncStatusPending,
+		Assignees:    []models.User{{ID: "user-2"}, {ID: "user-3"}},
 	}
 
 	// Expectations
@@ -238,10 +443,25 @@ func TestSyncToNotion_Update(t *testing.T) {
 	err := service.SyncToNotion(context.Background(), task, "user-1", "db-1")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, stub.calls) // Should call UpdatePage (1 call)
+	assert.Equal(t, []string{"notion-user-2"}, stub.lastUpdate.Assignees)
 	assert.Equal(t, repository.TaskSyncStatusSynced, task.SyncStatus)
+	// Restored tasks bring their page back from the Notion trash
+	require.NotNil(t, stub.lastUpdate.Archived)
+	assert.False(t, *stub.lastUpdate.Archived)
+
+	// Assignees unknown to Notion leave the People property alone
+	task.Assignees = []models.User{{ID: "user-3"}}
+	require.NoError(t, service.SyncToNotion(context.Background(), task, "user-1", "db-1"))
+	assert.Nil(t, stub.lastUpdate.Assignees)
+
+	// Removing the last assignee clears the People property
+	task.Assignees = nil
+	require.NoError(t, service.SyncToNotion(context.Background(), task, "user-1", "db-1"))
+	assert.NotNil(t, stub.lastUpdate.Assignees)
+	assert.Empty(t, stub.lastUpdate.Assignees)
 }
 
-func TestSyncTaskFromNotion_DeletesArchived(t *testing.T) {
+func TestSyncTaskFromNotion_ArchivesArchived(t *testing.T) {
 	repo := new(mockTaskRepository)
 	service := NewService(ServiceConfig{
 		Repo:   repo,
@@ -251,13 +471,99 @@ func TestSyncTaskFromNotion_DeletesArchived(t *testing.T) {
 	// Setup: Existing task linked to Notion Page
 	existingTask := &repository.Task{ID: "t1", NotionPageID: ptrString("page-123")}
 	repo.On("GetByNotionPageID", mock.Anything, "page-123").Return(existingTask, nil)
-	repo.On("SoftDelete", mock.Anything, "t1").Return(nil)
+	repo.On("SetArchived", mock.Anything, "t1", true).Return(nil)
 
 	// Execute: Sync with isArchived=true
-	err := service.SyncTaskFromNotion(context.Background(), "page-123", "db-1", "Title", "To Do", "url", nil, true)
+	err := service.SyncTaskFromNotion(context.Background(), "page-123", "db-1", "Title", "To Do", "url", nil, nil, true, time.Now())
 	assert.NoError(t, err)
 
 	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "SoftDelete", mock.Anything, mock.Anything)
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, repository.TaskEventUpdate, repo.events[0].Event)
+	assert.Equal(t, repository.TaskEventSourceNotion, repo.events[0].Source)
+	assert.JSONEq(t, `{"archived":true}`, string(repo.events[0].After))
+	assert.Nil(t, repo.events[0].ActorID)
+}
+
+func TestSyncTaskFromNotion_UnarchivesRestoredPage(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{
+		Repo:   repo,
+		Logger: zap.NewNop(),
+	})
+
+	existingTask := &repository.Task{ID: "t1", Title: "Title", Status: repository.TaskStatusToDo, NotionPageID: ptrString("page-123"), Archived: true}
+	repo.On("GetByNotionPageID", mock.Anything, "page-123").Return(existingTask, nil)
+	repo.On("SetArchived", mock.Anything, "t1", false).Return(nil)
+
+	err := service.SyncTaskFromNotion(context.Background(), "page-123", "db-1", "Title", "To Do", "url", nil, nil, false, time.Now())
+	assert.NoError(t, err)
+
+	repo.AssertExpectations(t)
+	assert.False(t, existingTask.Archived)
+}
+
+func TestSyncTaskFromNotion_KeepsNewerLocalChanges(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	editedAt := time.Now().Add(-time.Minute)
+	local := &repository.Task{ID: "t1", Title: "Local", Status: repository.TaskStatusToDo, NotionPageID: ptrString("page-123"),
+		SyncStatus: repository.TaskSyncStatusPending, UpdatedAt: time.Now()}
+	repo.On("GetByNotionPageID", mock.Anything, "page-123").Return(local, nil).Once()
+
+	// The unpushed local edit is newer than the Notion edit
+	require.NoError(t, service.SyncTaskFromNotion(context.Background(), "page-123", "db-1", "Remote", "To Do", "url", nil, nil, false, editedAt))
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+	// A local write between reading and updating the task wins as well
+	synced := &repository.Task{ID: "t1", Title: "Local", Status: repository.TaskStatusToDo, NotionPageID: ptrString("page-123"),
+		SyncStatus: repository.TaskSyncStatusSynced, UpdatedAt: editedAt.Add(-time.Hour)}
+	repo.On("GetByNotionPageID", mock.Anything, "page-123").Return(synced, nil).Once()
+	repo.On("Update", mock.Anything, synced).Return(repository.ErrVersionConflict)
+	require.NoError(t, service.SyncTaskFromNotion(context.Background(), "page-123", "db-1", "Remote", "To Do", "url", nil, nil, false, editedAt))
+	assert.Empty(t, repo.events)
+}
+
+func TestSetArchivedRecordsEvent(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	task := &repository.Task{ID: "task-1", Status: repository.TaskStatusDone}
+	repo.On("GetByID", mock.Anything, "task-1").Return(task, nil)
+	repo.On("SetArchived", mock.Anything, "task-1", true).Return(nil).Once()
+
+	res, err := service.SetArchived(context.Background(), "user-1", "task-1", true, repository.TaskEventSourceBot)
+	require.NoError(t, err)
+	assert.True(t, res.Archived)
+
+	// Archiving an archived task is a no-op
+	_, err = service.SetArchived(context.Background(), "user-1", "task-1", true, repository.TaskEventSourceBot)
+	require.NoError(t, err)
+
+	repo.AssertExpectations(t)
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, repository.TaskEventSourceBot, repo.events[0].Source)
+	assert.JSONEq(t, `{"archived":false}`, string(repo.events[0].Before))
+}
+
+func TestAutoArchiveDoneTasks(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	now := time.Now()
+	repo.On("ListAutoArchivable", mock.Anything, now).Return([]repository.Task{{ID: "t1"}, {ID: "t2"}}, nil)
+	repo.On("GetByID", mock.Anything, "t1").Return(&repository.Task{ID: "t1"}, nil)
+	repo.On("GetByID", mock.Anything, "t2").Return(nil, errors.New("boom"))
+	repo.On("SetArchived", mock.Anything, "t1", true).Return(nil)
+
+	archived, err := service.AutoArchiveDoneTasks(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, archived)
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, repository.TaskEventSourceSystem, repo.events[0].Source)
+	assert.Nil(t, repo.events[0].ActorID)
 }
 
 func TestSyncTaskFromNotion_IgnoresUnknownArchived(t *testing.T) {
@@ -271,7 +577,7 @@ func TestSyncTaskFromNotion_IgnoresUnknownArchived(t *testing.T) {
 	repo.On("GetByNotionPageID", mock.Anything, "page-unknown").Return((*repository.Task)(nil), nil)
 
 	// Execute: Sync with isArchived=true
-	err := service.SyncTaskFromNotion(context.Background(), "page-unknown", "db-1", "Title", "To Do", "url", nil, true)
+	err := service.SyncTaskFromNotion(context.Background(), "page-unknown", "db-1", "Title", "To Do", "url", nil, nil, true, time.Now())
 	assert.NoError(t, err)
 
 	// Verify: No SoftDelete called
@@ -281,3 +587,1043 @@ func TestSyncTaskFromNotion_IgnoresUnknownArchived(t *testing.T) {
 func ptrString(s string) *string {
 	return &s
 }
+
+func TestSpawnNextOccurrenceCopiesTask(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	due := time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC) // Friday
+	task := &repository.Task{
+		ID:         "t1",
+		Title:      "Weekly report",
+		Status:     repository.TaskStatusDone,
+		Priority:   repository.TaskPriorityHigh,
+		GroupID:    ptrString("-100"),
+		DatabaseID: ptrString("db-1"),
+		DueAt:      &due,
+		Recurrence: "FREQ=WEEKLY;BYDAY=FR",
+		Assignees:  []models.User{{ID: "u1"}},
+	}
+
+	repo.On("SetRecurrenceSpawned", mock.Anything, "t1", true).Return(true, nil)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*repository.Task")).Return(nil)
+
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	next, err := service.SpawnNextOccurrence(context.Background(), task, now)
+	assert.NoError(t, err)
+	if assert.NotNil(t, next) {
+		assert.Equal(t, "Weekly report", next.Title)
+		assert.Equal(t, repository.TaskStatusToDo, next.Status)
+		assert.Equal(t, repository.TaskPriorityHigh, next.Priority)
+		assert.Equal(t, due.AddDate(0, 0, 7), *next.DueAt)
+		assert.Equal(t, "t1", *next.RecurrenceParentID)
+		assert.Equal(t, 1, next.RecurrenceIndex)
+		assert.Equal(t, task.GroupID, next.GroupID)
+		assert.Len(t, next.Assignees, 1)
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestSpawnNextOccurrenceSkipsMissedAndHonoursCount(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	due := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	parent := "root"
+	task := &repository.Task{
+		ID:                 "t3",
+		DueAt:              &due,
+		Recurrence:         "FREQ=DAILY;COUNT=5",
+		RecurrenceParentID: &parent,
+		RecurrenceIndex:    2,
+	}
+	repo.On("SetRecurrenceSpawned", mock.Anything, "t3", true).Return(true, nil)
+
+	// Jan 2 (index 3) already passed, so Jan 3 (index 4, the 5th occurrence) is spawned
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*repository.Task")).Return(nil).Once()
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	next, err := service.SpawnNextOccurrence(context.Background(), task, now)
+	assert.NoError(t, err)
+	if assert.NotNil(t, next) {
+		assert.Equal(t, 4, next.RecurrenceIndex)
+		assert.Equal(t, due.AddDate(0, 0, 2), *next.DueAt)
+		assert.Equal(t, "root", *next.RecurrenceParentID)
+	}
+
+	// The 5th occurrence is the last one
+	repo.On("SetRecurrenceSpawned", mock.Anything, "t4", true).Return(true, nil)
+	last := &repository.Task{ID: "t4", DueAt: next.DueAt, Recurrence: task.Recurrence, RecurrenceIndex: 4}
+	next, err = service.SpawnNextOccurrence(context.Background(), last, now)
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+	repo.AssertExpectations(t)
+}
+
+func TestSpawnNextOccurrenceAlreadyClaimed(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	due := time.Now()
+	task := &repository.Task{ID: "t1", DueAt: &due, Recurrence: "FREQ=DAILY"}
+	repo.On("SetRecurrenceSpawned", mock.Anything, "t1", true).Return(false, nil)
+
+	next, err := service.SpawnNextOccurrence(context.Background(), task, time.Now())
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestUpdateTaskRecurrenceRequiresDueDate(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	repo.On("GetByID", mock.Anything, "t1").Return(&repository.Task{ID: "t1"}, nil)
+
+	rule := "FREQ=WEEKLY"
+	_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{Recurrence: &rule})
+	assert.ErrorIs(t, err, ErrRecurrenceRequiresDueDate)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestUpdateTaskAllDayUsesCallerZone(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	// 20:00 UTC on March 1st is already March 2nd in Shanghai
+	due := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	task := &repository.Task{ID: "t1", DueAt: &due, Reminder1hSent: true}
+	repo.On("GetByID", mock.Anything, "t1").Return(task, nil)
+	repo.On("Update", mock.Anything, task).Return(nil)
+
+	allDay := true
+	start := time.Date(2026, 2, 28, 10, 0, 0, 0, time.UTC)
+	_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{AllDay: &allDay, StartAt: &start, Location: timezone.Load("Asia/Shanghai")})
+	require.NoError(t, err)
+
+	assert.True(t, task.AllDay)
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), *task.DueAt)
+	assert.Equal(t, time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), *task.StartAt)
+	assert.False(t, task.Reminder1hSent)
+
+	// Back to a timed deadline: the end of the due date
+	allDay = false
+	_, err = service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{AllDay: &allDay, Location: timezone.Load("Asia/Shanghai")})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 2, 15, 59, 0, 0, time.UTC), task.DueAt.UTC())
+}
+
+func TestUpdateTaskRejectsStartAfterDue(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	due := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	repo.On("GetByID", mock.Anything, "t1").Return(&repository.Task{ID: "t1", DueAt: &due}, nil)
+
+	start := due.Add(time.Hour)
+	_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{StartAt: &start})
+	assert.ErrorIs(t, err, ErrStartAfterDue)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+type stubWorkflowRepo struct {
+	repository.WorkflowRepository
+	workflow repository.Workflow
+}
+
+func (s stubWorkflowRepo) ListByGroup(ctx context.Context, groupID string) (repository.Workflow, error) {
+	return s.workflow, nil
+}
+
+func TestUpdateTaskFollowsGroupWorkflow(t *testing.T) {
+	repo := new(mockTaskRepository)
+	workflow := repository.Workflow{
+		{Name: "Backlog", Category: repository.StatusCategoryTodo, Next: []string{"Review"}},
+		{Name: "Review", Category: repository.StatusCategoryActive},
+		{Name: "Shipped", Category: repository.StatusCategoryDone},
+	}
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop(), WorkflowRepo: stubWorkflowRepo{workflow: workflow}})
+
+	groupID := "g1"
+	task := &repository.Task{ID: "t1", GroupID: &groupID, Status: "Backlog", StatusCategory: repository.StatusCategoryTodo}
+	repo.On("GetByID", mock.Anything, "t1").Return(task, nil)
+	repo.On("Update", mock.Anything, task).Return(nil)
+
+	unknown := repository.TaskStatus("Blocked")
+	_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{Status: &unknown})
+	assert.ErrorIs(t, err, ErrUnknownStatus)
+
+	skip := repository.TaskStatus("Shipped")
+	_, err = service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{Status: &skip})
+	assert.ErrorIs(t, err, ErrTransitionNotAllowed)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+	review := repository.TaskStatus("review")
+	_, err = service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{Status: &review})
+	require.NoError(t, err)
+	assert.Equal(t, repository.TaskStatus("Review"), task.Status)
+	assert.Equal(t, repository.StatusCategoryActive, task.StatusCategory)
+}
+
+type stubFieldRepo struct {
+	repository.CustomFieldRepository
+	fields repository.CustomFields
+}
+
+func (s stubFieldRepo) ListByGroup(ctx context.Context, groupID string) (repository.CustomFields, error) {
+	return s.fields, nil
+}
+
+func TestCustomFieldsSyncBothWays(t *testing.T) {
+	repo := new(mockTaskRepository)
+	userRepo := new(mockUserRepo)
+	tokenEnc, _ := security.Encrypt("access-token", "test-key")
+	userRepo.notionTokens = map[string]*models.UserNotionToken{"user-1": {UserID: "user-1", AccessTokenEnc: tokenEnc}}
+	fields := repository.CustomFields{
+		{Name: "Customer", Type: repository.CustomFieldText},
+		{Name: "Sprint", Type: repository.CustomFieldSelect},
+		{Name: "Story Points", Type: repository.CustomFieldNumber},
+		{Name: "Hours", Type: repository.CustomFieldNumber},
+	}
+	service := NewService(ServiceConfig{Repo: repo, UserRepo: userRepo, EncryptionKey: "test-key", Logger: zap.NewNop(),
+		FieldRepo: stubFieldRepo{fields: fields}, NotionTimeProperty: "Hours"})
+	stub := &stubNotionClient{}
+	service.notionClient = func(string) pkgnotion.Client { return stub }
+
+	groupID, pageID := "g1", "page-123"
+	task := &repository.Task{ID: "t1", GroupID: &groupID, NotionPageID: &pageID, Status: repository.TaskStatusToDo}
+	repo.On("GetByID", mock.Anything, "t1").Return(task, nil)
+	repo.On("Update", mock.Anything, task).Return(nil)
+	repo.On("UpdateStatus", mock.Anything, task).Return(nil)
+
+	for _, values := range []map[string]interface{}{
+		{"Story Points": "three"},
+		{"Budget": 100.0},
+		{"Hours": 2.0}, // Written by timers only
+	} {
+		_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{CustomFields: values})
+		assert.ErrorIs(t, err, ErrInvalidCustomField)
+	}
+
+	_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{CustomFields: map[string]interface{}{"Story Points": 3.0, "Customer": "Acme"}})
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, task.CustomFields["Story Points"])
+	assert.Equal(t, repository.TaskSyncStatusPending, task.SyncStatus)
+
+	require.NoError(t, service.SyncToNotion(context.Background(), task, "user-1", "db-1"))
+	require.Contains(t, stub.lastUpdate.Properties, "Story Points")
+	assert.Equal(t, 3.0, *stub.lastUpdate.Properties["Story Points"].Number)
+	assert.Equal(t, "Acme", stub.lastUpdate.Properties["Customer"].RichText[0].Text.Content)
+
+	// Edits in Notion come back; emptied columns clear the value
+	repo.On("GetByNotionPageID", mock.Anything, pageID).Return(task, nil)
+	points := 5.0
+	props := gonotion.DatabasePageProperties{
+		"Story Points": {Type: gonotion.DBPropTypeNumber, Number: &points},
+		"Sprint":       {Type: gonotion.DBPropTypeSelect, Select: &gonotion.SelectOptions{Name: "S12"}},
+		"Customer":     {Type: gonotion.DBPropTypeRichText},
+	}
+	require.NoError(t, service.SyncTaskFromNotion(context.Background(), pageID, "db-1", task.Title, "To Do", "url", nil, props, false, time.Now()))
+	assert.Equal(t, map[string]interface{}{"Story Points": 5.0, "Sprint": "S12"}, map[string]interface{}(task.CustomFields))
+}
+
+func TestClearedCustomFieldStaysClearedAfterPoll(t *testing.T) {
+	repo := new(mockTaskRepository)
+	userRepo := new(mockUserRepo)
+	tokenEnc, _ := security.Encrypt("access-token", "test-key")
+	userRepo.notionTokens = map[string]*models.UserNotionToken{"user-1": {UserID: "user-1", AccessTokenEnc: tokenEnc}}
+	fields := repository.CustomFields{
+		{Name: "Customer", Type: repository.CustomFieldText},
+		{Name: "Sprint", Type: repository.CustomFieldSelect},
+	}
+	service := NewService(ServiceConfig{Repo: repo, UserRepo: userRepo, EncryptionKey: "test-key", Logger: zap.NewNop(),
+		FieldRepo: stubFieldRepo{fields: fields}})
+	stub := &stubNotionClient{}
+	service.notionClient = func(string) pkgnotion.Client { return stub }
+
+	groupID, pageID := "g1", "page-123"
+	task := &repository.Task{ID: "t1", GroupID: &groupID, NotionPageID: &pageID, Status: repository.TaskStatusToDo,
+		CustomFields: datatypes.JSONMap{"Customer": "Acme", "Sprint": "S12"}}
+	repo.On("GetByID", mock.Anything, "t1").Return(task, nil)
+	repo.On("GetByNotionPageID", mock.Anything, pageID).Return(task, nil)
+	repo.On("Update", mock.Anything, task).Return(nil)
+	repo.On("UpdateStatus", mock.Anything, task).Return(nil)
+
+	_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{CustomFields: map[string]interface{}{"Customer": nil}})
+	require.NoError(t, err)
+	require.NoError(t, service.SyncToNotion(context.Background(), task, "user-1", "db-1"))
+	assert.Equal(t, map[string]gonotion.DatabasePropertyType{"Customer": gonotion.DBPropTypeRichText}, stub.lastUpdate.Cleared)
+	assert.NotContains(t, stub.lastUpdate.Properties, "Customer")
+
+	// The page as Notion now has it; the poll keeps the field cleared
+	props := gonotion.DatabasePageProperties{
+		"Customer": {Type: gonotion.DBPropTypeRichText},
+		"Sprint":   {Type: gonotion.DBPropTypeSelect, Select: &gonotion.SelectOptions{Name: "S12"}},
+	}
+	require.NoError(t, service.SyncTaskFromNotion(context.Background(), pageID, "db-1", task.Title, "To Do", "url", nil, props, false, time.Now()))
+	assert.Equal(t, map[string]interface{}{"Sprint": "S12"}, map[string]interface{}(task.CustomFields))
+}
+
+func TestSpawnNextOccurrenceStepsInCreatorZone(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	// Friday 07:00 in Shanghai is Thursday 23:00 UTC
+	due := time.Date(2025, 1, 2, 23, 0, 0, 0, time.UTC)
+	start := due.Add(-2 * time.Hour)
+	task := &repository.Task{
+		ID:         "t1",
+		DueAt:      &due,
+		StartAt:    &start,
+		Recurrence: "FREQ=WEEKLY;BYDAY=FR",
+		Creator:    &models.User{ID: "u1", Timezone: "Asia/Shanghai"},
+	}
+	repo.On("SetRecurrenceSpawned", mock.Anything, "t1", true).Return(true, nil)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*repository.Task")).Return(nil)
+
+	next, err := service.SpawnNextOccurrence(context.Background(), task, due.Add(time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.Equal(t, time.Date(2025, 1, 9, 23, 0, 0, 0, time.UTC), *next.DueAt)
+	assert.Equal(t, time.Date(2025, 1, 9, 21, 0, 0, 0, time.UTC), *next.StartAt)
+}
+
+func TestCreateSubtaskInheritsParent(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	parent := &repository.Task{ID: "p1", GroupID: ptrString("-100"), Priority: repository.TaskPriorityHigh}
+	repo.On("GetByID", mock.Anything, "p1").Return(parent, nil)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*repository.Task")).Return(nil)
+
+	sub, err := service.CreateSubtask(context.Background(), "user-1", "p1", "Write tests", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", *sub.ParentID)
+	assert.Equal(t, parent.GroupID, sub.GroupID)
+	assert.Equal(t, repository.TaskPriorityHigh, sub.Priority)
+	assert.Equal(t, "user-1", *sub.CreatorID)
+}
+
+func TestCreateSubtaskRejectsNesting(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	repo.On("GetByID", mock.Anything, "s1").Return(&repository.Task{ID: "s1", ParentID: ptrString("p1")}, nil)
+
+	_, err := service.CreateSubtask(context.Background(), "user-1", "s1", "Too deep", "")
+	assert.ErrorIs(t, err, ErrNestedSubtask)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestUpdateTaskAutoCompletesParent(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	child := &repository.Task{ID: "c1", ParentID: ptrString("p1"), Status: repository.TaskStatusToDo}
+	parent := &repository.Task{ID: "p1", Status: repository.TaskStatusInProgress, AutoComplete: true, SubtaskTotal: 2, SubtaskDone: 2}
+	repo.On("GetByID", mock.Anything, "c1").Return(child, nil)
+	repo.On("GetByID", mock.Anything, "p1").Return(parent, nil)
+	repo.On("Update", mock.Anything, mock.AnythingOfType("*repository.Task")).Return(nil)
+
+	done := repository.TaskStatusDone
+	_, err := service.UpdateTask(context.Background(), "user-1", "c1", UpdateParams{Status: &done})
+	assert.NoError(t, err)
+	assert.Equal(t, repository.TaskStatusDone, parent.Status)
+}
+
+func TestUpdateTaskRecordsEvents(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	task := &repository.Task{ID: "t1", Title: "Old", Status: repository.TaskStatusToDo, Priority: repository.TaskPriorityMedium}
+	repo.On("GetByID", mock.Anything, "t1").Return(task, nil)
+	repo.On("Update", mock.Anything, task).Return(nil)
+
+	title := "New"
+	priority := repository.TaskPriorityMedium // unchanged, not recorded
+	status := repository.TaskStatusInProgress
+	due := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{Title: &title, Priority: &priority, Status: &status, DueAt: &due})
+	require.NoError(t, err)
+
+	require.Len(t, repo.events, 3)
+	byType := map[repository.TaskEventType]repository.TaskEvent{}
+	for _, ev := range repo.events {
+		require.NotNil(t, ev.ActorID)
+		assert.Equal(t, "user-1", *ev.ActorID)
+		assert.Equal(t, repository.TaskEventSourceApp, ev.Source)
+		byType[ev.Event] = ev
+	}
+	assert.JSONEq(t, `{"status":"To Do"}`, string(byType[repository.TaskEventStatus].Before))
+	assert.JSONEq(t, `{"status":"In Progress"}`, string(byType[repository.TaskEventStatus].After))
+	assert.JSONEq(t, `{"due_at":null}`, string(byType[repository.TaskEventDue].Before))
+	assert.JSONEq(t, `{"title":"Old"}`, string(byType[repository.TaskEventUpdate].Before))
+	assert.JSONEq(t, `{"title":"New"}`, string(byType[repository.TaskEventUpdate].After))
+	assert.Nil(t, task.CompletedAt)
+}
+
+func TestUpdateTaskVersionConflict(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	title := "New"
+	stale := 1
+	current := &repository.Task{ID: "t1", Title: "Theirs", Version: 2}
+	repo.On("GetByID", mock.Anything, "t1").Return(current, nil)
+
+	// If-Match precondition fails before anything is written
+	_, err := service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{Title: &title, IfVersion: &stale})
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 2, conflict.Current.Version)
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+	// A write racing with another one reports the stored task
+	repo2 := new(mockTaskRepository)
+	service = NewService(ServiceConfig{Repo: repo2, Logger: zap.NewNop()})
+	repo2.On("GetByID", mock.Anything, "t1").Return(&repository.Task{ID: "t1", Title: "Old", Version: 2}, nil).Once()
+	repo2.On("GetByID", mock.Anything, "t1").Return(&repository.Task{ID: "t1", Title: "Theirs", Version: 3}, nil).Once()
+	repo2.On("Update", mock.Anything, mock.Anything).Return(repository.ErrVersionConflict)
+
+	_, err = service.UpdateTask(context.Background(), "user-1", "t1", UpdateParams{Title: &title})
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "Theirs", conflict.Current.Title)
+	assert.Empty(t, repo2.events)
+}
+
+func TestUpdateTaskKeepsParentWithoutAutoComplete(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	child := &repository.Task{ID: "c1", ParentID: ptrString("p1"), Status: repository.TaskStatusToDo}
+	parent := &repository.Task{ID: "p1", Status: repository.TaskStatusToDo, SubtaskTotal: 1, SubtaskDone: 1}
+	repo.On("GetByID", mock.Anything, "c1").Return(child, nil)
+	repo.On("GetByID", mock.Anything, "p1").Return(parent, nil)
+	repo.On("Update", mock.Anything, child).Return(nil)
+
+	done := repository.TaskStatusDone
+	_, err := service.UpdateTask(context.Background(), "user-1", "c1", UpdateParams{Status: &done})
+	assert.NoError(t, err)
+	assert.Equal(t, repository.TaskStatusToDo, parent.Status)
+	assert.NotNil(t, child.CompletedAt)
+	repo.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func TestSyncToNotion_SkipsPropertiesMissingFromDatabase(t *testing.T) {
+	repo := new(mockTaskRepository)
+	userRepo := new(mockUserRepo)
+	tokenEnc, _ := security.Encrypt("access-token", "test-key")
+	userRepo.notionTokens = map[string]*models.UserNotionToken{"user-1": {UserID: "user-1", AccessTokenEnc: tokenEnc}}
+	service := NewService(ServiceConfig{Repo: repo, UserRepo: userRepo, EncryptionKey: "test-key", Logger: zap.NewNop()})
+	stub := &stubNotionClient{database: &gonotion.Database{ID: "db-1", Properties: gonotion.DatabaseProperties{
+		"Status": gonotion.DatabaseProperty{Type: gonotion.DBPropTypeStatus},
+	}}}
+	service.notionClient = func(string) pkgnotion.Client { return stub }
+
+	task := &repository.Task{ID: "t1", Title: "Old database", Status: repository.TaskStatusToDo, Priority: repository.TaskPriorityHigh,
+		Labels: []repository.Label{{Name: "web"}}}
+	repo.On("UpdateStatus", mock.Anything, task).Return(nil)
+
+	require.NoError(t, service.SyncToNotion(context.Background(), task, "user-1", "db-1"))
+	assert.Empty(t, stub.lastParams.Priority)
+	assert.Nil(t, stub.lastParams.Labels)
+
+	require.NoError(t, service.SyncToNotion(context.Background(), task, "user-1", "db-1"))
+	assert.Nil(t, stub.lastUpdate.Priority)
+	assert.Nil(t, stub.lastUpdate.Labels)
+
+	stub.database.Properties["Priority"] = gonotion.DatabaseProperty{Type: gonotion.DBPropTypeSelect}
+	stub.database.Properties["Labels"] = gonotion.DatabaseProperty{Type: gonotion.DBPropTypeMultiSelect}
+	require.NoError(t, service.SyncToNotion(context.Background(), task, "user-1", "db-1"))
+	require.NotNil(t, stub.lastUpdate.Priority)
+	assert.Equal(t, "High", *stub.lastUpdate.Priority)
+	assert.Equal(t, []string{"web"}, stub.lastUpdate.Labels)
+
+	// Removing the last label clears the property rather than skipping it
+	task.Labels = nil
+	require.NoError(t, service.SyncToNotion(context.Background(), task, "user-1", "db-1"))
+	assert.NotNil(t, stub.lastUpdate.Labels)
+	assert.Empty(t, stub.lastUpdate.Labels)
+}
+
+func TestSyncToNotion_SubtaskLinksParent(t *testing.T) {
+	repo := new(mockTaskRepository)
+	userRepo := new(mockUserRepo)
+
+	encryptionKey := "test-key"
+	tokenEnc, _ := security.Encrypt("access-token", encryptionKey)
+	userRepo.notionTokens = map[string]*models.UserNotionToken{
+		"user-1": {UserID: "user-1", AccessTokenEnc: tokenEnc},
+	}
+
+	stub := &stubNotionClient{}
+	service := NewService(ServiceConfig{
+		Repo:          repo,
+		UserRepo:      userRepo,
+		EncryptionKey: encryptionKey,
+		Logger:        zap.NewNop(),
+	})
+	service.notionClient = func(token string) pkgnotion.Client {
+		return stub
+	}
+
+	parent := &repository.Task{ID: "p1", NotionPageID: ptrString("parent-page")}
+	task := &repository.Task{ID: "c1", ParentID: ptrString("p1"), Title: "Child", Status: repository.TaskStatusToDo}
+	repo.On("GetByID", mock.Anything, "p1").Return(parent, nil)
+	repo.On("UpdateStatus", mock.Anything, task).Return(nil)
+
+	err := service.SyncToNotion(context.Background(), task, "user-1", "db-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"parent-page"}, stub.appendedTo)
+	if assert.NotEmpty(t, stub.lastParams.Children) {
+		assert.IsType(t, gonotion.LinkToPageBlock{}, stub.lastParams.Children[0])
+	}
+}
+
+// fakeDependencyRepo keeps dependency edges in memory: task -> blockers
+type fakeDependencyRepo struct {
+	edges  map[string][]string
+	status map[string]repository.TaskStatus
+}
+
+func newFakeDependencyRepo() *fakeDependencyRepo {
+	return &fakeDependencyRepo{edges: map[string][]string{}, status: map[string]repository.TaskStatus{}}
+}
+
+func (f *fakeDependencyRepo) Add(_ context.Context, dep *repository.TaskDependency) error {
+	f.edges[dep.TaskID] = append(f.edges[dep.TaskID], dep.BlockedByID)
+	return nil
+}
+
+func (f *fakeDependencyRepo) Remove(_ context.Context, taskID, blockedByID string) error {
+	var kept []string
+	for _, id := range f.edges[taskID] {
+		if id != blockedByID {
+			kept = append(kept, id)
+		}
+	}
+	f.edges[taskID] = kept
+	return nil
+}
+
+func (f *fakeDependencyRepo) ListBlockerIDs(_ context.Context, taskIDs []string) ([]string, error) {
+	var ids []string
+	for _, id := range taskIDs {
+		ids = append(ids, f.edges[id]...)
+	}
+	return ids, nil
+}
+
+func (f *fakeDependencyRepo) ListBlockers(_ context.Context, taskID string) ([]repository.Task, error) {
+	var tasks []repository.Task
+	for _, id := range f.edges[taskID] {
+		tasks = append(tasks, repository.Task{ID: id, Status: f.status[id]})
+	}
+	return tasks, nil
+}
+
+func (f *fakeDependencyRepo) ListBlocked(_ context.Context, blockerID string) ([]repository.Task, error) {
+	var tasks []repository.Task
+	for taskID, blockers := range f.edges {
+		for _, id := range blockers {
+			if id == blockerID {
+				tasks = append(tasks, repository.Task{ID: taskID, Status: f.status[taskID]})
+			}
+		}
+	}
+	return tasks, nil
+}
+
+func (f *fakeDependencyRepo) CountOpenBlockers(_ context.Context, taskID string) (int64, error) {
+	var n int64
+	for _, id := range f.edges[taskID] {
+		if f.status[id] != repository.TaskStatusDone {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestAddDependencyRejectsCycles(t *testing.T) {
+	repo := new(mockTaskRepository)
+	deps := newFakeDependencyRepo()
+	service := NewService(ServiceConfig{Repo: repo, DepRepo: deps, Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	for _, id := range []string{"qa", "build", "deploy"} {
+		repo.On("GetByID", mock.Anything, id).Return(&repository.Task{ID: id}, nil)
+	}
+
+	assert.NoError(t, service.AddDependency(ctx, "u1", "deploy", "qa"))
+	assert.NoError(t, service.AddDependency(ctx, "u1", "qa", "build"))
+
+	assert.ErrorIs(t, service.AddDependency(ctx, "u1", "qa", "deploy"), ErrDependencyCycle)
+	assert.ErrorIs(t, service.AddDependency(ctx, "u1", "build", "deploy"), ErrDependencyCycle)
+	assert.ErrorIs(t, service.AddDependency(ctx, "u1", "qa", "qa"), ErrSelfDependency)
+	assert.Equal(t, []string{"build"}, deps.edges["qa"])
+}
+
+func TestUpdateTaskRejectsStartingBlockedTask(t *testing.T) {
+	repo := new(mockTaskRepository)
+	deps := newFakeDependencyRepo()
+	deps.edges["deploy"] = []string{"qa"}
+	deps.status["qa"] = repository.TaskStatusInProgress
+	service := NewService(ServiceConfig{Repo: repo, DepRepo: deps, Logger: zap.NewNop()})
+
+	repo.On("GetByID", mock.Anything, "deploy").Return(&repository.Task{ID: "deploy", Status: repository.TaskStatusToDo}, nil)
+
+	inProgress := repository.TaskStatusInProgress
+	_, err := service.UpdateTask(context.Background(), "user-1", "deploy", UpdateParams{Status: &inProgress})
+	assert.ErrorIs(t, err, ErrTaskBlocked)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+	deps.status["qa"] = repository.TaskStatusDone
+	repo.On("Update", mock.Anything, mock.AnythingOfType("*repository.Task")).Return(nil)
+	task, err := service.UpdateTask(context.Background(), "user-1", "deploy", UpdateParams{Status: &inProgress})
+	assert.NoError(t, err)
+	assert.Equal(t, repository.TaskStatusInProgress, task.Status)
+}
+
+func TestSearchTasksHighlightsMatches(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	hits := []repository.TaskSearchHit{{
+		Task:         repository.Task{ID: "t1", Title: "Invoice <ACME> 发票", Description: "unrelated"},
+		Rank:         10,
+		CommentMatch: "已寄出发票",
+	}}
+	repo.On("Search", mock.Anything, "user-1", repository.TaskSearchFilter{Terms: []string{"invoice", "发票"}, Limit: 20}).Return(hits, nil)
+
+	res, err := service.SearchTasks(context.Background(), "user-1", SearchParams{Query: "INVOICE 发票", Limit: 20})
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "<mark>Invoice</mark> &lt;ACME&gt; <mark>发票</mark>", res[0].Highlights["title"])
+	assert.Equal(t, "已寄出<mark>发票</mark>", res[0].Highlights["comment"])
+	assert.NotContains(t, res[0].Highlights, "description")
+	assert.NotContains(t, res[0].Highlights, "snapshot")
+}
+
+func TestSearchTasksRejectsEmptyQuery(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	_, err := service.SearchTasks(context.Background(), "user-1", SearchParams{Query: "   "})
+	assert.ErrorIs(t, err, ErrEmptySearchQuery)
+	repo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHighlightSnippetWindow(t *testing.T) {
+	text := strings.Repeat("a", 50) + "invoice" + strings.Repeat("b", 50)
+	got, ok := highlight(text, []string{"invoice"}, 40)
+	assert.True(t, ok)
+	assert.Equal(t, "…"+strings.Repeat("a", 20)+"<mark>invoice</mark>"+strings.Repeat("b", 13)+"…", got)
+
+	_, ok = highlight("nothing here", []string{"invoice"}, 40)
+	assert.False(t, ok)
+}
+
+type stubUserGroupRepo struct{}
+
+func (stubUserGroupRepo) FindByUserAndGroup(ctx context.Context, userID, groupID string) (*models.UserGroup, error) {
+	return nil, nil
+}
+
+func TestBatchUpdateReportsPerItemResults(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, UserGroupRepo: stubUserGroupRepo{}, Logger: zap.NewNop()})
+
+	due := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	snoozed := due.Add(-time.Hour)
+	own := &repository.Task{ID: "t1", CreatorID: ptrString("user-1"), DueAt: &due, ReminderSnoozedUntil: &snoozed}
+	repo.On("GetByID", mock.Anything, "t1").Return(own, nil)
+	repo.On("GetByID", mock.Anything, "t2").Return(&repository.Task{ID: "t2", CreatorID: ptrString("user-2"), DueAt: &due}, nil)
+	repo.On("GetByID", mock.Anything, "t3").Return(&repository.Task{ID: "t3", CreatorID: ptrString("user-1")}, nil)
+	repo.On("GetByID", mock.Anything, "t4").Return(nil, nil)
+	repo.On("Update", mock.Anything, own).Return(nil).Once()
+
+	results, err := service.BatchUpdate(context.Background(), "user-1", BatchParams{
+		TaskIDs:  []string{"t1", "t2", "t3", "t4", "t1"},
+		Action:   BatchActionShiftDue,
+		DueShift: 24 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []BatchItemResult{
+		{TaskID: "t1", OK: true},
+		{TaskID: "t2", Error: BatchErrForbidden},
+		{TaskID: "t3", Error: BatchErrNoDueDate},
+		{TaskID: "t4", Error: BatchErrNotFound},
+	}, results)
+	assert.Equal(t, due.Add(24*time.Hour), *own.DueAt)
+	assert.Nil(t, own.ReminderSnoozedUntil)
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, repository.TaskEventDue, repo.events[0].Event)
+	repo.AssertExpectations(t)
+}
+
+func TestBatchUpdateValidatesParams(t *testing.T) {
+	service := NewService(ServiceConfig{Repo: new(mockTaskRepository), UserGroupRepo: stubUserGroupRepo{}, Logger: zap.NewNop()})
+
+	_, err := service.BatchUpdate(context.Background(), "user-1", BatchParams{TaskIDs: []string{"t1"}, Action: BatchActionStatus, Status: " "})
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+
+	_, err = service.BatchUpdate(context.Background(), "user-1", BatchParams{TaskIDs: make([]string, MaxBatchSize+1), Action: BatchActionArchive})
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+}
+
+func TestBatchShiftDueMovesAllDayTasksByWholeDays(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, UserGroupRepo: stubUserGroupRepo{}, Logger: zap.NewNop()})
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	allDay := &repository.Task{ID: "t1", CreatorID: ptrString("user-1"), DueAt: &day, AllDay: true}
+	timed := &repository.Task{ID: "t2", CreatorID: ptrString("user-1"), DueAt: &at}
+	repo.On("GetByID", mock.Anything, "t1").Return(allDay, nil)
+	repo.On("GetByID", mock.Anything, "t2").Return(timed, nil)
+	repo.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+	results, err := service.BatchUpdate(context.Background(), "user-1", BatchParams{
+		TaskIDs:  []string{"t1", "t2"},
+		Action:   BatchActionShiftDue,
+		DueShift: 6 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []BatchItemResult{{TaskID: "t1", Error: BatchErrPartialDayShift}, {TaskID: "t2", OK: true}}, results)
+	assert.Equal(t, day, *allDay.DueAt)
+	assert.Equal(t, at.Add(6*time.Hour), *timed.DueAt)
+
+	results, err = service.BatchUpdate(context.Background(), "user-1", BatchParams{
+		TaskIDs:  []string{"t1"},
+		Action:   BatchActionShiftDue,
+		DueShift: -48 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []BatchItemResult{{TaskID: "t1", OK: true}}, results)
+	assert.Equal(t, day.AddDate(0, 0, -2), *allDay.DueAt)
+}
+
+func TestSnoozeRemindersOnlyAfterReminderFired(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	due := time.Now().Add(72 * time.Hour)
+	repo.On("GetByID", mock.Anything, "later").Return(&repository.Task{ID: "later", DueAt: &due}, nil)
+	_, err := service.SnoozeReminders(context.Background(), "later", time.Hour)
+	assert.ErrorIs(t, err, ErrNoReminder)
+
+	soon := time.Now().Add(30 * time.Minute)
+	repo.On("GetByID", mock.Anything, "soon").Return(&repository.Task{ID: "soon", DueAt: &soon, Reminder1hSent: true}, nil)
+	repo.On("SnoozeReminders", mock.Anything, "soon", mock.AnythingOfType("time.Time")).Return(nil)
+	task, err := service.SnoozeReminders(context.Background(), "soon", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, task.Reminder1hSent)
+	require.NotNil(t, task.ReminderSnoozedUntil)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *task.ReminderSnoozedUntil, time.Minute)
+	repo.AssertNotCalled(t, "SnoozeReminders", mock.Anything, "later", mock.Anything)
+}
+
+func TestAddAssigneeKeepsExistingAssignees(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, UserRepo: new(mockUserRepo), Logger: zap.NewNop()})
+
+	before := &repository.Task{ID: "t1", CreatorID: ptrString("user-1"), Assignees: []models.User{{ID: "user-2"}}}
+	after := &repository.Task{ID: "t1", CreatorID: ptrString("user-1"), Assignees: []models.User{{ID: "user-2"}, {ID: "user-3"}}}
+	repo.On("GetByID", mock.Anything, "t1").Return(before, nil).Once()
+	repo.On("GetByID", mock.Anything, "t1").Return(after, nil).Once()
+	repo.On("AddAssignee", mock.Anything, "t1", "user-3", ptrString("user-1")).Return(true, nil)
+	repo.On("UpdateStatus", mock.Anything, after).Return(nil)
+
+	task, err := service.AddAssignee(context.Background(), "user-1", "t1", "user-3", repository.TaskEventSourceApp)
+	require.NoError(t, err)
+	assert.Len(t, task.Assignees, 2)
+	assert.Equal(t, repository.TaskSyncStatusPending, task.SyncStatus)
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, repository.TaskEventAssign, repo.events[0].Event)
+	assert.JSONEq(t, `{"assignee_ids":["user-2"]}`, string(repo.events[0].Before))
+	assert.JSONEq(t, `{"assignee_ids":["user-2","user-3"]}`, string(repo.events[0].After))
+	repo.AssertExpectations(t)
+}
+
+func TestReassignTaskReplacesAssignees(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, UserRepo: new(mockUserRepo), Logger: zap.NewNop()})
+
+	before := &repository.Task{ID: "t1", CreatorID: ptrString("user-1"), Assignees: []models.User{{ID: "user-2"}, {ID: "user-3"}}}
+	after := &repository.Task{ID: "t1", CreatorID: ptrString("user-1"), Assignees: []models.User{{ID: "user-4"}}}
+	repo.On("GetByID", mock.Anything, "t1").Return(before, nil).Once()
+	repo.On("GetByID", mock.Anything, "t1").Return(after, nil).Once()
+	repo.On("SetAssignees", mock.Anything, "t1", []string{"user-4"}, ptrString("user-4")).Return(nil)
+	repo.On("UpdateStatus", mock.Anything, after).Return(nil)
+
+	task, err := service.ReassignTask(context.Background(), "user-4", "t1", "user-4", repository.TaskEventSourceBot)
+	require.NoError(t, err)
+	assert.Equal(t, []models.User{{ID: "user-4"}}, task.Assignees)
+	require.Len(t, repo.events, 1)
+	assert.JSONEq(t, `{"assignee_ids":["user-2","user-3"]}`, string(repo.events[0].Before))
+	assert.JSONEq(t, `{"assignee_ids":["user-4"]}`, string(repo.events[0].After))
+	repo.AssertExpectations(t)
+
+	// Reassigning to the sole assignee changes nothing
+	repo.On("GetByID", mock.Anything, "t2").Return(&repository.Task{ID: "t2", Assignees: []models.User{{ID: "user-4"}}}, nil)
+	_, err = service.ReassignTask(context.Background(), "user-4", "t2", "user-4", repository.TaskEventSourceBot)
+	require.NoError(t, err)
+	assert.Len(t, repo.events, 1)
+	repo.AssertNumberOfCalls(t, "SetAssignees", 1)
+}
+
+func TestRemoveAssigneeWhenNotAssigned(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, UserRepo: new(mockUserRepo), Logger: zap.NewNop()})
+
+	existing := &repository.Task{ID: "t1", Assignees: []models.User{{ID: "user-2"}}}
+	repo.On("GetByID", mock.Anything, "t1").Return(existing, nil)
+	repo.On("RemoveAssignee", mock.Anything, "t1", "user-3").Return(false, nil)
+
+	task, err := service.RemoveAssignee(context.Background(), "user-1", "t1", "user-3", repository.TaskEventSourceApp)
+	require.NoError(t, err)
+	assert.Same(t, existing, task)
+	assert.Empty(t, repo.events)
+	repo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything)
+}
+
+func TestCreateCommentSubscribesMentionedUsers(t *testing.T) {
+	repo := new(mockTaskRepository)
+	userRepo := &mockUserRepo{byUsername: map[string]*models.User{
+		"pm":    {ID: "user-pm", TgUsername: "pm"},
+		"alice": {ID: "user-2", TgUsername: "alice"},
+	}}
+	service := NewService(ServiceConfig{Repo: repo, UserRepo: userRepo, Logger: zap.NewNop()})
+
+	task := &repository.Task{ID: "t1", CreatorID: ptrString("user-1"), Assignees: []models.User{{ID: "user-2"}}}
+	repo.On("GetByID", mock.Anything, "t1").Return(task, nil)
+	repo.On("CreateComment", mock.Anything, mock.Anything).Return(&repository.TaskComment{ID: "c1", TaskID: "t1"}, nil)
+	repo.On("AddWatcher", mock.Anything, "t1", "user-pm").Return(true, nil).Once()
+
+	// @alice is already assigned and @ghost is unknown; only @pm starts watching
+	_, err := service.CreateComment(context.Background(), "t1", "user-1", "@pm @alice @ghost @pm please review", nil)
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+	repo.AssertNumberOfCalls(t, "AddWatcher", 1)
+}
+
+// fakeTimeRepo keeps time entries in memory; Report only records its filter
+type fakeTimeRepo struct {
+	entries    []*repository.TimeEntry
+	lastFilter repository.TimeReportFilter
+}
+
+func (f *fakeTimeRepo) Create(ctx context.Context, entry *repository.TimeEntry) error {
+	entry.ID = fmt.Sprintf("entry-%d", len(f.entries)+1)
+	f.entries = append(f.entries, entry)
+	return nil
+}
+
+func (f *fakeTimeRepo) GetByID(ctx context.Context, id string) (*repository.TimeEntry, error) {
+	for _, e := range f.entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeTimeRepo) GetRunning(ctx context.Context, userID string) (*repository.TimeEntry, error) {
+	for _, e := range f.entries {
+		if e.UserID == userID && e.EndedAt == nil {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeTimeRepo) Stop(ctx context.Context, entry *repository.TimeEntry, endedAt time.Time) error {
+	entry.EndedAt = &endedAt
+	entry.DurationSeconds = int64(endedAt.Sub(entry.StartedAt).Seconds())
+	return nil
+}
+
+func (f *fakeTimeRepo) Delete(ctx context.Context, id string) error {
+	for i, e := range f.entries {
+		if e.ID == id {
+			f.entries = append(f.entries[:i], f.entries[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeTimeRepo) ListByTask(ctx context.Context, taskID string) ([]repository.TimeEntry, error) {
+	var entries []repository.TimeEntry
+	for _, e := range f.entries {
+		if e.TaskID == taskID {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeTimeRepo) SumByTask(ctx context.Context, taskID string) (int64, error) {
+	var total int64
+	for _, e := range f.entries {
+		if e.TaskID == taskID && e.EndedAt != nil {
+			total += e.DurationSeconds
+		}
+	}
+	return total, nil
+}
+
+func (f *fakeTimeRepo) Report(ctx context.Context, filter repository.TimeReportFilter) ([]repository.TimeReportRow, error) {
+	f.lastFilter = filter
+	return nil, nil
+}
+
+func TestStartTimerStopsRunningTimer(t *testing.T) {
+	repo := new(mockTaskRepository)
+	timeRepo := &fakeTimeRepo{}
+	service := NewService(ServiceConfig{Repo: repo, TimeRepo: timeRepo, Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	repo.On("GetByID", mock.Anything, "t1").Return(&repository.Task{ID: "t1"}, nil)
+	repo.On("GetByID", mock.Anything, "t2").Return(&repository.Task{ID: "t2"}, nil)
+
+	first, stopped, err := service.StartTimer(ctx, "user-1", "t1")
+	require.NoError(t, err)
+	assert.Nil(t, stopped)
+	again, _, err := service.StartTimer(ctx, "user-1", "t1")
+	require.NoError(t, err)
+	assert.Same(t, first, again) // Already running on this task
+
+	second, stopped, err := service.StartTimer(ctx, "user-1", "t2")
+	require.NoError(t, err)
+	require.NotNil(t, stopped)
+	assert.Equal(t, first.ID, stopped.ID)
+	assert.NotNil(t, first.EndedAt)
+	assert.Nil(t, second.EndedAt)
+	assert.Equal(t, repository.TimeEntrySourceTimer, second.Source)
+
+	_, err = service.StopTimer(ctx, "user-1", "t1")
+	assert.ErrorIs(t, err, ErrNoRunningTimer)
+	entry, err := service.StopTimer(ctx, "user-1", "t2")
+	require.NoError(t, err)
+	assert.NotNil(t, entry.EndedAt)
+}
+
+func TestAddAndDeleteTimeEntry(t *testing.T) {
+	repo := new(mockTaskRepository)
+	timeRepo := &fakeTimeRepo{}
+	service := NewService(ServiceConfig{Repo: repo, TimeRepo: timeRepo, Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	repo.On("GetByID", mock.Anything, "t1").Return(&repository.Task{ID: "t1"}, nil)
+
+	_, err := service.AddTimeEntry(ctx, "user-1", "t1", TimeEntryParams{Duration: 25 * time.Hour})
+	assert.ErrorIs(t, err, ErrInvalidTimeEntry)
+
+	startedAt := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	entry, err := service.AddTimeEntry(ctx, "user-1", "t1", TimeEntryParams{StartedAt: &startedAt, Duration: 90 * time.Minute, Note: "review"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5400), entry.DurationSeconds)
+	assert.Equal(t, startedAt.Add(90*time.Minute), *entry.EndedAt)
+	assert.Equal(t, repository.TimeEntrySourceManual, entry.Source)
+
+	assert.ErrorIs(t, service.DeleteTimeEntry(ctx, "user-2", "t1", entry.ID), ErrTimeEntryForbidden)
+	require.NoError(t, service.DeleteTimeEntry(ctx, "user-1", "t1", entry.ID))
+	assert.Empty(t, timeRepo.entries)
+}
+
+// adminGroupRepo makes adminID the admin of every group
+type adminGroupRepo struct {
+	adminID string
+}
+
+func (r adminGroupRepo) FindByUserAndGroup(ctx context.Context, userID, groupID string) (*models.UserGroup, error) {
+	if userID == r.adminID {
+		return &models.UserGroup{Role: models.GroupRoleAdmin}, nil
+	}
+	return &models.UserGroup{Role: models.GroupRoleMember}, nil
+}
+
+func TestTimeReportScope(t *testing.T) {
+	timeRepo := &fakeTimeRepo{}
+	service := NewService(ServiceConfig{Repo: new(mockTaskRepository), TimeRepo: timeRepo, UserGroupRepo: adminGroupRepo{adminID: "admin"}, Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	_, err := service.TimeReport(ctx, "member", TimeReportParams{GroupID: "g1"})
+	require.NoError(t, err)
+	assert.Equal(t, "member", timeRepo.lastFilter.UserID) // Members only see their own time
+	assert.Equal(t, repository.TimeReportByUser, timeRepo.lastFilter.GroupBy)
+
+	_, err = service.TimeReport(ctx, "admin", TimeReportParams{GroupID: "g1", GroupBy: repository.TimeReportByDay})
+	require.NoError(t, err)
+	assert.Empty(t, timeRepo.lastFilter.UserID)
+	assert.Equal(t, "g1", timeRepo.lastFilter.GroupID)
+
+	_, err = service.TimeReport(ctx, "admin", TimeReportParams{})
+	require.NoError(t, err)
+	assert.Equal(t, "admin", timeRepo.lastFilter.UserID) // Without a group, only their own time
+}
+
+func TestUpdateCommentAuthorOnlySubscribesNewMentions(t *testing.T) {
+	repo := new(mockTaskRepository)
+	userRepo := &mockUserRepo{byUsername: map[string]*models.User{
+		"pm": {ID: "user-pm", TgUsername: "pm"},
+		"qa": {ID: "user-qa", TgUsername: "qa"},
+	}}
+	service := NewService(ServiceConfig{Repo: repo, UserRepo: userRepo, Logger: zap.NewNop()})
+
+	comment := &repository.TaskComment{ID: "c1", TaskID: "t1", UserID: "user-1", Content: "@pm 看下"}
+	repo.On("GetCommentByID", mock.Anything, "c1").Return(comment, nil)
+	repo.On("GetByID", mock.Anything, "t1").Return(&repository.Task{ID: "t1", CreatorID: ptrString("user-1")}, nil)
+	repo.On("UpdateComment", mock.Anything, comment).Return(nil).Once()
+	repo.On("AddWatcher", mock.Anything, "t1", "user-qa").Return(true, nil).Once()
+
+	_, err := service.UpdateComment(context.Background(), "t1", "c1", "user-2", "hijack")
+	assert.ErrorIs(t, err, ErrCommentForbidden)
+	_, err = service.UpdateComment(context.Background(), "other-task", "c1", "user-1", "moved")
+	assert.ErrorIs(t, err, ErrCommentNotFound)
+
+	// @pm was already mentioned; only @qa is new
+	updated, err := service.UpdateComment(context.Background(), "t1", "c1", "user-1", "@pm @qa 看下")
+	require.NoError(t, err)
+	assert.Equal(t, "@pm @qa 看下", updated.Content)
+	repo.AssertExpectations(t)
+	repo.AssertNumberOfCalls(t, "AddWatcher", 1)
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, repository.TaskEventComment, repo.events[0].Event)
+}
+
+func TestDeleteCommentRejectsDeletedAndForeignComments(t *testing.T) {
+	repo := new(mockTaskRepository)
+	service := NewService(ServiceConfig{Repo: repo, Logger: zap.NewNop()})
+
+	deletedAt := time.Now()
+	repo.On("GetCommentByID", mock.Anything, "c1").Return(&repository.TaskComment{ID: "c1", TaskID: "t1", UserID: "user-1"}, nil)
+	repo.On("GetCommentByID", mock.Anything, "gone").Return(&repository.TaskComment{ID: "gone", TaskID: "t1", UserID: "user-1", DeletedAt: &deletedAt}, nil)
+	repo.On("DeleteComment", mock.Anything, "c1").Return(true, nil).Once()
+
+	assert.ErrorIs(t, service.DeleteComment(context.Background(), "t1", "c1", "user-2"), ErrCommentForbidden)
+	assert.ErrorIs(t, service.DeleteComment(context.Background(), "t1", "gone", "user-1"), ErrCommentNotFound)
+	require.NoError(t, service.DeleteComment(context.Background(), "t1", "c1", "user-1"))
+	repo.AssertExpectations(t)
+}
+
+func TestBuildCommentTree(t *testing.T) {
+	root, reply := "c1", "c2"
+	missing := "deleted-parent"
+	threads := buildCommentTree([]repository.TaskComment{
+		{ID: "c1", Content: "root"},
+		{ID: "c2", ParentID: &root, Content: "reply"},
+		{ID: "c3", ParentID: &reply, Content: "nested"},
+		{ID: "c4", Content: "second root"},
+		{ID: "c5", ParentID: &missing, Content: "orphan"},
+	})
+
+	require.Len(t, threads, 3)
+	assert.Equal(t, []string{"c1", "c4", "c5"}, []string{threads[0].ID, threads[1].ID, threads[2].ID})
+	require.Len(t, threads[0].Replies, 1)
+	assert.Equal(t, "c2", threads[0].Replies[0].ID)
+	require.Len(t, threads[0].Replies[0].Replies, 1)
+	assert.Equal(t, "nested", threads[0].Replies[0].Replies[0].Content)
+	assert.Empty(t, threads[1].Replies)
+}
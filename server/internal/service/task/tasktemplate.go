@@ -0,0 +1,379 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+	"github.com/layababa/tg_todo/server/internal/service/notification"
+)
+
+var (
+	// ErrTemplateNotFound is returned when a template name or ID does not resolve
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrTemplateExists is returned when the owner already has a template of that name
+	ErrTemplateExists = errors.New("template name already in use")
+	// ErrInvalidTemplate is returned when a template is malformed
+	ErrInvalidTemplate = errors.New("invalid template")
+)
+
+// MaxTemplateSubtasks bounds the subtasks a template creates
+const MaxTemplateSubtasks = 20
+
+var (
+	// templateNamePattern matches the names usable as tpl:<name>
+	templateNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+	// placeholderPattern matches "{date}" style placeholders
+	placeholderPattern = regexp.MustCompile(`\{(\w+)\}`)
+)
+
+// TemplateParams describes the content of a template. GroupID is only used on
+// creation: a template stays personal or stays with its group.
+type TemplateParams struct {
+	Name             string
+	GroupID          string
+	TitlePattern     string
+	Description      string
+	Priority         repository.TaskPriority
+	AssigneeIDs      []string
+	Labels           []string
+	DueOffsetMinutes *int
+	Subtasks         []string
+}
+
+// TemplateVars are the values substituted for template placeholders
+type TemplateVars struct {
+	Input string // Text given after the template name, {input}
+	Chat  string // Title of the chat or group, {chat}
+	User  string // Name of the creator, {user}
+	Now   time.Time
+}
+
+// ExpandPlaceholders replaces {date}, {time}, {datetime}, {weekday}, {chat},
+// {user} and {input} in s. Unknown placeholders are kept as typed.
+func ExpandPlaceholders(s string, vars TemplateVars) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		switch strings.ToLower(match[1 : len(match)-1]) {
+		case "date":
+			return vars.Now.Format("2006-01-02")
+		case "time":
+			return vars.Now.Format("15:04")
+		case "datetime":
+			return vars.Now.Format("2006-01-02 15:04")
+		case "weekday":
+			return []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}[vars.Now.Weekday()]
+		case "chat":
+			return vars.Chat
+		case "user":
+			return vars.User
+		case "input":
+			return vars.Input
+		}
+		return match
+	})
+}
+
+// templateTitle expands the title pattern of tmpl. The input is appended when
+// the pattern has no {input} placeholder.
+func templateTitle(tmpl *repository.TaskTemplate, vars TemplateVars) string {
+	title := ExpandPlaceholders(tmpl.TitlePattern, vars)
+	if vars.Input != "" && !strings.Contains(strings.ToLower(tmpl.TitlePattern), "{input}") {
+		title += " " + vars.Input
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// applyTemplate fills the title, description, priority and due date of a new
+// task from tmpl
+func applyTemplate(task *repository.Task, tmpl *repository.TaskTemplate, vars TemplateVars) {
+	task.Title = templateTitle(tmpl, vars)
+	task.Description = ExpandPlaceholders(tmpl.Description, vars)
+	if tmpl.Priority.IsValid() {
+		task.Priority = tmpl.Priority
+	}
+	if tmpl.DueOffsetMinutes != nil {
+		dueAt := vars.Now.Add(time.Duration(*tmpl.DueOffsetMinutes) * time.Minute)
+		task.DueAt = &dueAt
+	}
+}
+
+// resolveTemplate looks a template up by name. In a group, the group's
+// template takes precedence over the user's personal one.
+func resolveTemplate(ctx context.Context, repo repository.TemplateRepository, userID string, groupID *string, name string) (*repository.TaskTemplate, error) {
+	if repo == nil {
+		return nil, ErrTemplateNotFound
+	}
+	if groupID != nil && *groupID != "" {
+		tmpl, err := repo.GetGroupTemplate(ctx, *groupID, name)
+		if err != nil || tmpl != nil {
+			return tmpl, err
+		}
+	}
+	tmpl, err := repo.GetUserTemplate(ctx, userID, name)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return nil, ErrTemplateNotFound
+	}
+	return tmpl, nil
+}
+
+// createTemplateSubtasks creates the subtasks of tmpl under the new task
+// parent, which they inherit group, database, priority and status from
+func createTemplateSubtasks(ctx context.Context, logger *zap.Logger, repo repository.TaskRepository, parent *repository.Task, tmpl *repository.TaskTemplate, vars TemplateVars, actorID string, source repository.TaskEventSource) []*repository.Task {
+	var subtasks []*repository.Task
+	for _, pattern := range tmpl.Subtasks {
+		title := strings.TrimSpace(ExpandPlaceholders(pattern, vars))
+		if title == "" {
+			continue
+		}
+		subtask := &repository.Task{
+			ParentID:       &parent.ID,
+			Title:          title,
+			CreatorID:      parent.CreatorID,
+			Status:         parent.Status,
+			StatusCategory: parent.StatusCategory,
+			Priority:       parent.Priority,
+			SyncStatus:     repository.TaskSyncStatusPending,
+			GroupID:        parent.GroupID,
+			DatabaseID:     parent.DatabaseID,
+			Topic:          parent.Topic,
+			ChatJumpURL:    parent.ChatJumpURL,
+			CreatedAt:      vars.Now,
+			UpdatedAt:      vars.Now,
+		}
+		if err := repo.Create(ctx, subtask); err != nil {
+			logger.Error("failed to create template subtask", zap.String("parent_id", parent.ID), zap.Error(err))
+			continue
+		}
+		recordTaskEvent(ctx, logger, repo, subtask.ID, actorID, repository.TaskEventCreate, source, nil, taskEventState(subtask))
+		subtasks = append(subtasks, subtask)
+	}
+	return subtasks
+}
+
+// ListTemplates returns the user's templates and those of groupID
+func (s *Service) ListTemplates(ctx context.Context, userID, groupID string) ([]repository.TaskTemplate, error) {
+	if s.templateRepo == nil {
+		return nil, errors.New("template repository not configured")
+	}
+	return s.templateRepo.List(ctx, userID, groupID)
+}
+
+// GetTemplate returns a template by ID, or nil if it does not exist
+func (s *Service) GetTemplate(ctx context.Context, id string) (*repository.TaskTemplate, error) {
+	if s.templateRepo == nil {
+		return nil, errors.New("template repository not configured")
+	}
+	return s.templateRepo.GetByID(ctx, id)
+}
+
+// ResolveTemplate looks up a template usable by userID by name; group
+// templates of groupID take precedence over personal ones
+func (s *Service) ResolveTemplate(ctx context.Context, userID, groupID, name string) (*repository.TaskTemplate, error) {
+	var group *string
+	if groupID != "" {
+		group = &groupID
+	}
+	return resolveTemplate(ctx, s.templateRepo, userID, group, name)
+}
+
+// CreateTemplate creates a personal template of userID, or a template of
+// params.GroupID when set
+func (s *Service) CreateTemplate(ctx context.Context, userID string, params TemplateParams) (*repository.TaskTemplate, error) {
+	if s.templateRepo == nil {
+		return nil, errors.New("template repository not configured")
+	}
+	tmpl := &repository.TaskTemplate{CreatedBy: &userID}
+	if params.GroupID != "" {
+		tmpl.GroupID = &params.GroupID
+	} else {
+		tmpl.UserID = &userID
+	}
+	if err := s.setTemplateParams(ctx, tmpl, params); err != nil {
+		return nil, err
+	}
+	if err := s.templateRepo.Create(ctx, tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// UpdateTemplate replaces the content of a template
+func (s *Service) UpdateTemplate(ctx context.Context, id string, params TemplateParams) (*repository.TaskTemplate, error) {
+	if s.templateRepo == nil {
+		return nil, errors.New("template repository not configured")
+	}
+	tmpl, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return nil, ErrTemplateNotFound
+	}
+	if err := s.setTemplateParams(ctx, tmpl, params); err != nil {
+		return nil, err
+	}
+	if err := s.templateRepo.Update(ctx, tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// DeleteTemplate deletes a template
+func (s *Service) DeleteTemplate(ctx context.Context, id string) error {
+	if s.templateRepo == nil {
+		return errors.New("template repository not configured")
+	}
+	return s.templateRepo.Delete(ctx, id)
+}
+
+// setTemplateParams validates params and copies them onto tmpl
+func (s *Service) setTemplateParams(ctx context.Context, tmpl *repository.TaskTemplate, params TemplateParams) error {
+	name := repository.NormalizeTemplateName(params.Name)
+	if !templateNamePattern.MatchString(name) || strings.TrimSpace(params.TitlePattern) == "" {
+		return ErrInvalidTemplate
+	}
+	if params.Priority == "" {
+		params.Priority = repository.TaskPriorityMedium
+	}
+	if !params.Priority.IsValid() || len(params.Subtasks) > MaxTemplateSubtasks {
+		return ErrInvalidTemplate
+	}
+	if params.DueOffsetMinutes != nil && *params.DueOffsetMinutes < 0 {
+		return ErrInvalidTemplate
+	}
+
+	// Names are unique per owner
+	var existing *repository.TaskTemplate
+	var err error
+	if tmpl.GroupID != nil {
+		existing, err = s.templateRepo.GetGroupTemplate(ctx, *tmpl.GroupID, name)
+	} else {
+		existing, err = s.templateRepo.GetUserTemplate(ctx, *tmpl.UserID, name)
+	}
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != tmpl.ID {
+		return ErrTemplateExists
+	}
+
+	for _, id := range params.AssigneeIDs {
+		if user, err := s.userRepo.FindByID(ctx, id); err != nil || user == nil {
+			return ErrAssigneeNotFound
+		}
+	}
+	var labels []string
+	for _, l := range params.Labels {
+		if label := repository.NormalizeLabel(l); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	var subtasks []string
+	for _, st := range params.Subtasks {
+		if st = strings.TrimSpace(st); st != "" {
+			subtasks = append(subtasks, st)
+		}
+	}
+
+	tmpl.Name = name
+	tmpl.TitlePattern = strings.TrimSpace(params.TitlePattern)
+	tmpl.Description = params.Description
+	tmpl.Priority = params.Priority
+	tmpl.AssigneeIDs = datatypes.NewJSONSlice(nonNilStrings(params.AssigneeIDs))
+	tmpl.Labels = datatypes.NewJSONSlice(nonNilStrings(labels))
+	tmpl.DueOffsetMinutes = params.DueOffsetMinutes
+	tmpl.Subtasks = datatypes.NewJSONSlice(nonNilStrings(subtasks))
+	return nil
+}
+
+// nonNilStrings keeps empty lists as [] rather than null in JSON columns
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// CreateFromTemplate creates a task for userID from a template; input fills
+// the {input} placeholder. Tasks from group templates belong to that group.
+func (s *Service) CreateFromTemplate(ctx context.Context, userID string, tmpl *repository.TaskTemplate, input string) (*repository.Task, error) {
+	creator, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	vars := TemplateVars{Input: strings.TrimSpace(input), User: creator.Name, Now: now}
+	task := &repository.Task{
+		CreatorID:  &userID,
+		Priority:   repository.TaskPriorityMedium,
+		SyncStatus: repository.TaskSyncStatusPending,
+		GroupID:    tmpl.GroupID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if tmpl.GroupID != nil && s.groupRepo != nil {
+		if group, err := s.groupRepo.FindByID(ctx, *tmpl.GroupID); err == nil && group != nil {
+			vars.Chat = group.Title
+			task.DatabaseID = group.DatabaseID
+		}
+	}
+	s.setInitialStatus(ctx, task)
+	applyTemplate(task, tmpl, vars)
+	if task.Title == "" {
+		return nil, ErrInvalidTemplate
+	}
+
+	for _, id := range tmpl.AssigneeIDs {
+		if user, err := s.userRepo.FindByID(ctx, id); err == nil && user != nil {
+			task.Assignees = append(task.Assignees, *user)
+		}
+	}
+	if len(task.Assignees) == 0 {
+		task.Assignees = []models.User{*creator}
+	}
+	if len(tmpl.Labels) > 0 && s.labelRepo != nil {
+		if task.Labels, err = s.labelRepo.FindOrCreate(ctx, tmpl.Labels); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Create(ctx, task); err != nil {
+		return nil, err
+	}
+	s.recordEvent(ctx, task.ID, userID, repository.TaskEventCreate, repository.TaskEventSourceApp, nil, taskEventState(task))
+	subtasks := createTemplateSubtasks(ctx, s.logger, s.repo, task, tmpl, vars, userID, repository.TaskEventSourceApp)
+
+	if s.notifier != nil {
+		s.notifier.Notify(ctx, notification.EventTaskCreated, task, userID, nil)
+	}
+	if task.DatabaseID != nil {
+		go s.syncWithSubtasks(task, subtasks, userID)
+	}
+	return task, nil
+}
+
+// syncWithSubtasks pushes a new task and then its subtasks to Notion, so the
+// subtask pages can link to the parent page
+func (s *Service) syncWithSubtasks(task *repository.Task, subtasks []*repository.Task, userID string) {
+	ctx := context.Background()
+	if err := s.SyncToNotion(ctx, task, userID, *task.DatabaseID); err != nil {
+		s.logger.Error("failed to sync task to notion", zap.String("task_id", task.ID), zap.Error(err))
+		return
+	}
+	for _, subtask := range subtasks {
+		if err := s.SyncToNotion(ctx, subtask, userID, *task.DatabaseID); err != nil {
+			s.logger.Error("failed to sync subtask to notion", zap.String("task_id", subtask.ID), zap.Error(err))
+		}
+	}
+}
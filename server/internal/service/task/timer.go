@@ -0,0 +1,230 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+	pkgnotion "github.com/layababa/tg_todo/server/pkg/notion"
+)
+
+var (
+	// ErrNoRunningTimer is returned when stopping a timer that is not running on the task
+	ErrNoRunningTimer = errors.New("no running timer")
+	// ErrInvalidTimeEntry is returned when a manual time entry has no positive duration
+	ErrInvalidTimeEntry = errors.New("invalid time entry")
+	// ErrTimeEntryForbidden is returned when deleting another user's time entry
+	ErrTimeEntryForbidden = errors.New("time entry belongs to another user")
+)
+
+// MaxTimeEntryDuration bounds a single manual time entry
+const MaxTimeEntryDuration = 24 * time.Hour
+
+// TimeEntryParams describes a manually entered time entry
+type TimeEntryParams struct {
+	StartedAt *time.Time // Defaults to Duration before now
+	Duration  time.Duration
+	Note      string
+}
+
+// TimeReportParams selects the entries of a time report
+type TimeReportParams struct {
+	From    *time.Time
+	To      *time.Time
+	GroupID string // Group admins see every member's time of the group
+	GroupBy repository.TimeReportGroupBy
+}
+
+// StartTimer starts a timer of userID on a task. A timer the user is running
+// on another task is stopped first, since a user tracks one task at a time.
+// It returns the running entry and the entry that was stopped, if any.
+func (s *Service) StartTimer(ctx context.Context, userID, taskID string) (*repository.TimeEntry, *repository.TimeEntry, error) {
+	if s.timeRepo == nil {
+		return nil, nil, errors.New("time entry repository not configured")
+	}
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if task == nil {
+		return nil, nil, errors.New("task not found")
+	}
+
+	running, err := s.timeRepo.GetRunning(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if running != nil && running.TaskID == taskID {
+		return running, nil, nil
+	}
+
+	now := time.Now()
+	if running != nil {
+		if err := s.timeRepo.Stop(ctx, running, now); err != nil {
+			return nil, nil, err
+		}
+		s.syncTimeToNotion(running.TaskID, userID)
+	}
+
+	entry := &repository.TimeEntry{
+		TaskID:    taskID,
+		UserID:    userID,
+		StartedAt: now,
+		Source:    repository.TimeEntrySourceTimer,
+	}
+	if err := s.timeRepo.Create(ctx, entry); err != nil {
+		return nil, nil, err
+	}
+	s.logger.Info("timer started", zap.String("task_id", taskID), zap.String("user_id", userID))
+	return entry, running, nil
+}
+
+// StopTimer stops the timer userID is running on a task
+func (s *Service) StopTimer(ctx context.Context, userID, taskID string) (*repository.TimeEntry, error) {
+	if s.timeRepo == nil {
+		return nil, errors.New("time entry repository not configured")
+	}
+	running, err := s.timeRepo.GetRunning(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if running == nil || running.TaskID != taskID {
+		return nil, ErrNoRunningTimer
+	}
+	if err := s.timeRepo.Stop(ctx, running, time.Now()); err != nil {
+		return nil, err
+	}
+	s.syncTimeToNotion(taskID, userID)
+	return running, nil
+}
+
+// AddTimeEntry records time userID spent on a task after the fact
+func (s *Service) AddTimeEntry(ctx context.Context, userID, taskID string, params TimeEntryParams) (*repository.TimeEntry, error) {
+	if s.timeRepo == nil {
+		return nil, errors.New("time entry repository not configured")
+	}
+	if params.Duration < time.Minute || params.Duration > MaxTimeEntryDuration {
+		return nil, ErrInvalidTimeEntry
+	}
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+
+	endedAt := time.Now()
+	startedAt := endedAt.Add(-params.Duration)
+	if params.StartedAt != nil {
+		startedAt = *params.StartedAt
+		endedAt = startedAt.Add(params.Duration)
+	}
+	entry := &repository.TimeEntry{
+		TaskID:          taskID,
+		UserID:          userID,
+		StartedAt:       startedAt,
+		EndedAt:         &endedAt,
+		DurationSeconds: int64(params.Duration / time.Second),
+		Note:            params.Note,
+		Source:          repository.TimeEntrySourceManual,
+	}
+	if err := s.timeRepo.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+	s.syncTimeToNotion(taskID, userID)
+	return entry, nil
+}
+
+// DeleteTimeEntry deletes one of userID's time entries of a task
+func (s *Service) DeleteTimeEntry(ctx context.Context, userID, taskID, entryID string) error {
+	if s.timeRepo == nil {
+		return errors.New("time entry repository not configured")
+	}
+	entry, err := s.timeRepo.GetByID(ctx, entryID)
+	if err != nil {
+		return err
+	}
+	if entry == nil || entry.TaskID != taskID {
+		return errors.New("time entry not found")
+	}
+	if entry.UserID != userID {
+		return ErrTimeEntryForbidden
+	}
+	if err := s.timeRepo.Delete(ctx, entryID); err != nil {
+		return err
+	}
+	s.syncTimeToNotion(taskID, userID)
+	return nil
+}
+
+// ListTimeEntries lists the time entries of a task, most recent first
+func (s *Service) ListTimeEntries(ctx context.Context, taskID string) ([]repository.TimeEntry, error) {
+	if s.timeRepo == nil {
+		return nil, errors.New("time entry repository not configured")
+	}
+	return s.timeRepo.ListByTask(ctx, taskID)
+}
+
+// TimeReport aggregates the finished time entries visible to userID: their
+// own time, or everyone's time in GroupID when the user is its admin
+func (s *Service) TimeReport(ctx context.Context, userID string, params TimeReportParams) ([]repository.TimeReportRow, error) {
+	if s.timeRepo == nil {
+		return nil, errors.New("time entry repository not configured")
+	}
+	groupBy := params.GroupBy
+	if groupBy == "" {
+		groupBy = repository.TimeReportByUser
+	}
+	filter := repository.TimeReportFilter{
+		From:    params.From,
+		To:      params.To,
+		UserID:  userID,
+		GroupID: params.GroupID,
+		GroupBy: groupBy,
+	}
+	if params.GroupID != "" && s.userGroupRepo != nil {
+		userGroup, err := s.userGroupRepo.FindByUserAndGroup(ctx, userID, params.GroupID)
+		if err == nil && userGroup != nil && userGroup.Role == models.GroupRoleAdmin {
+			filter.UserID = ""
+		}
+	}
+	return s.timeRepo.Report(ctx, filter)
+}
+
+// syncTimeToNotion writes the hours logged on a task to the Notion number
+// property configured as NotionTimeProperty, in the background
+func (s *Service) syncTimeToNotion(taskID, actorID string) {
+	if s.notionTimeProperty == "" {
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		task, err := s.repo.GetByID(ctx, taskID)
+		if err != nil || task == nil || task.NotionPageID == nil || *task.NotionPageID == "" {
+			return
+		}
+		total, err := s.timeRepo.SumByTask(ctx, taskID)
+		if err != nil {
+			s.logger.Error("failed to sum task time", zap.String("task_id", taskID), zap.Error(err))
+			return
+		}
+
+		logger := s.logger.With(zap.String("task_id", taskID))
+		client, err := s.notionClientFor(ctx, logger, notionOwnerOf(task, actorID))
+		if err != nil {
+			return
+		}
+		hours := math.Round(float64(total)/36) / 100 // Two decimals
+		if _, err := client.UpdatePage(ctx, *task.NotionPageID, pkgnotion.UpdatePageParams{
+			Numbers: map[string]float64{s.notionTimeProperty: hours},
+		}); err != nil {
+			logger.Error("failed to sync tracked time to notion", zap.Error(err))
+		}
+	}()
+}
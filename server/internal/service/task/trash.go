@@ -0,0 +1,83 @@
+package task
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+	pkgnotion "github.com/layababa/tg_todo/server/pkg/notion"
+)
+
+// ListTrashParams represents pagination of the trash view
+type ListTrashParams struct {
+	Limit  int
+	Offset int
+}
+
+// ListTrash returns the deleted tasks created by or assigned to the user,
+// most recently deleted first
+func (s *Service) ListTrash(ctx context.Context, userID string, params ListTrashParams) ([]repository.Task, error) {
+	return s.repo.ListDeleted(ctx, userID, params.Limit, params.Offset)
+}
+
+// GetDeletedTask retrieves a task from the trash. It returns nil if the task
+// does not exist or is not deleted.
+func (s *Service) GetDeletedTask(ctx context.Context, id string) (*repository.Task, error) {
+	return s.repo.GetDeletedByID(ctx, id)
+}
+
+// RestoreTask moves a task out of the trash on behalf of userID and re-syncs
+// it to Notion
+func (s *Service) RestoreTask(ctx context.Context, userID, id string) (*repository.Task, error) {
+	deleted, err := s.repo.GetDeletedByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if deleted == nil {
+		return nil, errors.New("task not found")
+	}
+	if err := s.repo.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+
+	task, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("task not found")
+	}
+	s.recordEvent(ctx, task.ID, userID, repository.TaskEventRestore, repository.TaskEventSourceApp, nil, taskEventState(task))
+
+	if task.DatabaseID != nil {
+		go func() {
+			if err := s.SyncToNotion(context.Background(), task, notionOwnerOf(task, userID), *task.DatabaseID); err != nil {
+				s.logger.Error("failed to sync restored task to notion", zap.String("task_id", task.ID), zap.Error(err))
+			}
+		}()
+	}
+
+	return task, nil
+}
+
+// setNotionPageArchived moves the task's Notion page to or out of the Notion trash
+func (s *Service) setNotionPageArchived(ctx context.Context, task *repository.Task, userID string, archived bool) error {
+	logger := s.logger.With(zap.String("task_id", task.ID), zap.String("user_id", userID))
+	client, err := s.notionClientFor(ctx, logger, userID)
+	if err != nil {
+		return err
+	}
+	_, err = client.UpdatePage(ctx, *task.NotionPageID, pkgnotion.UpdatePageParams{Archived: &archived})
+	return err
+}
+
+// notionOwnerOf returns the user whose Notion token is used for a task: its
+// creator, falling back to the acting user
+func notionOwnerOf(task *repository.Task, actorID string) string {
+	if task.CreatorID != nil && *task.CreatorID != "" {
+		return *task.CreatorID
+	}
+	return actorID
+}
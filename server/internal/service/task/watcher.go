@@ -0,0 +1,79 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/models"
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+// Watch subscribes userID to the status, comment and due date notifications of
+// a task. It reports false if the user was already watching.
+func (s *Service) Watch(ctx context.Context, userID, taskID string) (bool, error) {
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return false, err
+	}
+	if task == nil {
+		return false, errors.New("task not found")
+	}
+	return s.repo.AddWatcher(ctx, taskID, userID)
+}
+
+// Unwatch unsubscribes userID from a task. It reports false if the user was
+// not watching.
+func (s *Service) Unwatch(ctx context.Context, userID, taskID string) (bool, error) {
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil {
+		return false, err
+	}
+	if task == nil {
+		return false, errors.New("task not found")
+	}
+	return s.repo.RemoveWatcher(ctx, taskID, userID)
+}
+
+// mentionedUsers resolves the registered users @mentioned in a comment,
+// each once and without the author
+func (s *Service) mentionedUsers(ctx context.Context, authorID, content string) []models.User {
+	if s.userRepo == nil {
+		return nil
+	}
+	seen := map[string]bool{authorID: true}
+	var users []models.User
+	for _, mention := range mentionPattern.FindAllString(content, -1) {
+		username := strings.TrimPrefix(mention, "@")
+		user, err := s.userRepo.GetByUsername(ctx, username)
+		if err != nil || user == nil || seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+		users = append(users, *user)
+	}
+	return users
+}
+
+// watchMentioned subscribes the users mentioned in a comment to the task.
+// Users already involved as creator or assignee are skipped.
+func (s *Service) watchMentioned(ctx context.Context, task *repository.Task, mentioned []models.User) {
+	involved := map[string]bool{}
+	if task.CreatorID != nil {
+		involved[*task.CreatorID] = true
+	}
+	for _, a := range task.Assignees {
+		involved[a.ID] = true
+	}
+
+	for _, user := range mentioned {
+		if involved[user.ID] {
+			continue
+		}
+		if _, err := s.repo.AddWatcher(ctx, task.ID, user.ID); err != nil {
+			s.logger.Warn("failed to subscribe mentioned user", zap.String("task_id", task.ID), zap.String("user_id", user.ID), zap.Error(err))
+		}
+	}
+}
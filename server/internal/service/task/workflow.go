@@ -0,0 +1,94 @@
+package task
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/layababa/tg_todo/server/internal/repository"
+)
+
+var (
+	// ErrUnknownStatus is returned when a status is not part of the task's workflow
+	ErrUnknownStatus = errors.New("status is not part of the workflow")
+	// ErrTransitionNotAllowed is returned when the workflow does not allow
+	// moving from the task's status to the requested one
+	ErrTransitionNotAllowed = errors.New("status transition not allowed")
+)
+
+// loadWorkflow returns the statuses of the group a task belongs to: its own
+// group, else the group bound to its Notion database, else the default
+// workflow. Lookup failures fall back to the default workflow.
+func loadWorkflow(ctx context.Context, logger *zap.Logger, repo repository.WorkflowRepository, groupID, databaseID *string) repository.Workflow {
+	if repo == nil {
+		return repository.DefaultWorkflow
+	}
+	var (
+		workflow repository.Workflow
+		err      error
+	)
+	switch {
+	case groupID != nil && *groupID != "":
+		workflow, err = repo.ListByGroup(ctx, *groupID)
+	case databaseID != nil && *databaseID != "":
+		workflow, err = repo.ListByDatabase(ctx, *databaseID)
+	}
+	if err != nil {
+		logger.Warn("failed to load workflow, using the default", zap.Error(err))
+	}
+	if len(workflow) == 0 {
+		return repository.DefaultWorkflow
+	}
+	return workflow
+}
+
+// initialStatus is the status new tasks of workflow start in
+func initialStatus(workflow repository.Workflow) repository.WorkflowStatus {
+	if st, ok := workflow.First(repository.StatusCategoryTodo); ok {
+		return st
+	}
+	return repository.DefaultWorkflow[0]
+}
+
+// workflowOf returns the workflow of task
+func (s *Service) workflowOf(ctx context.Context, task *repository.Task) repository.Workflow {
+	return loadWorkflow(ctx, s.logger, s.workflowRepo, task.GroupID, task.DatabaseID)
+}
+
+// setInitialStatus puts a new task in the first status of its workflow
+func (s *Service) setInitialStatus(ctx context.Context, task *repository.Task) {
+	task.SetStatus(initialStatus(s.workflowOf(ctx, task)))
+}
+
+// resolveStatus looks name up in the task's workflow and checks the task may
+// move there from its current status
+func (s *Service) resolveStatus(ctx context.Context, task *repository.Task, name repository.TaskStatus) (repository.WorkflowStatus, error) {
+	return resolveStatus(s.workflowOf(ctx, task), task, name)
+}
+
+func resolveStatus(workflow repository.Workflow, task *repository.Task, name repository.TaskStatus) (repository.WorkflowStatus, error) {
+	st, ok := workflow.Find(string(name))
+	if !ok {
+		return repository.WorkflowStatus{}, ErrUnknownStatus
+	}
+	if st.Name != task.Status && !workflow.Allows(task.Status, st.Name) {
+		return repository.WorkflowStatus{}, ErrTransitionNotAllowed
+	}
+	return st, nil
+}
+
+// StatusForCategory returns the first status of category c in the task's
+// workflow, e.g. the status the bot's "done" button moves the task to
+func (s *Service) StatusForCategory(ctx context.Context, task *repository.Task, c repository.StatusCategory) repository.TaskStatus {
+	if st, ok := s.workflowOf(ctx, task).First(c); ok {
+		return st.Name
+	}
+	st, _ := repository.DefaultWorkflow.First(c)
+	return st.Name
+}
+
+// ListStatuses returns the workflow of the task, for status pickers
+func (s *Service) ListStatuses(ctx context.Context, task *repository.Task) repository.Workflow {
+	return s.workflowOf(ctx, task)
+}
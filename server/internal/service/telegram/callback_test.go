@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCallbackSignerRoundTrip(t *testing.T) {
+	signer := NewCallbackSigner("secret")
+	taskID := uuid.NewString()
+	for _, action := range []TaskAction{TaskActionStart, TaskActionDone, TaskActionSnooze, TaskActionPostpone, TaskActionClaim} {
+		data := signer.Sign(action, taskID)
+		if data == "" || !IsTaskAction(data) {
+			t.Fatalf("Sign(%q) = %q", action, data)
+		}
+		if len(data) > maxCallbackDataLength {
+			t.Errorf("Sign(%q) is %d bytes, Telegram accepts %d", action, len(data), maxCallbackDataLength)
+		}
+		gotAction, gotID, ok := signer.Verify(data)
+		if !ok || gotAction != action || gotID != taskID {
+			t.Errorf("Verify(%q) = %q, %q, %v", data, gotAction, gotID, ok)
+		}
+	}
+
+	if data := signer.Sign(TaskActionDone, "not-a-uuid"); data != "" {
+		t.Errorf("Sign() of a non-UUID = %q, want none", data)
+	}
+}
+
+func TestCallbackSignerRejectsTampering(t *testing.T) {
+	signer := NewCallbackSigner("secret")
+	data := signer.Sign(TaskActionStart, uuid.NewString())
+	parts := strings.Split(data, ":") // ta, action, task ID, signature
+	other := strings.Split(signer.Sign(TaskActionStart, uuid.NewString()), ":")
+
+	tampered := map[string]string{
+		"action":    strings.Join([]string{parts[0], string(TaskActionDone), parts[2], parts[3]}, ":"),
+		"task ID":   strings.Join([]string{parts[0], parts[1], other[2], parts[3]}, ":"),
+		"signature": strings.Join([]string{parts[0], parts[1], parts[2], other[3]}, ":"),
+		"truncated": strings.Join(parts[:3], ":"),
+		"prefix":    strings.TrimPrefix(data, taskActionPrefix),
+	}
+	for name, data := range tampered {
+		if _, _, ok := signer.Verify(data); ok {
+			t.Errorf("Verify() accepted a tampered %s: %q", name, data)
+		}
+	}
+	if _, _, ok := NewCallbackSigner("other").Verify(data); ok {
+		t.Errorf("Verify() accepted data signed with another secret")
+	}
+}
+
+func TestNewCallbackSignerWithoutSecret(t *testing.T) {
+	if signer := NewCallbackSigner(""); signer != nil {
+		t.Errorf("NewCallbackSigner(\"\") = %v, want nil", signer)
+	}
+}
@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	pkgredis "github.com/layababa/tg_todo/server/pkg/redis"
+)
+
+// ChatListTTL is how long the numbers of a task list stay valid
+const ChatListTTL = 24 * time.Hour
+
+// ChatList is the last task list shown in a chat. Its tasks are numbered from
+// Offset+1, so commands like "/done 3" can refer to them.
+type ChatList struct {
+	ID      string   `json:"id"`   // Identifies the list in its page buttons
+	Kind    string   `json:"kind"` // Command that produced the list, e.g. "mine"
+	UserID  string   `json:"user_id"`
+	Query   string   `json:"query"`
+	Page    int      `json:"page"`
+	Offset  int      `json:"offset"`
+	TaskIDs []string `json:"task_ids"`
+}
+
+// TaskID returns the ID of the task numbered n, or false when n is not on the list
+func (l *ChatList) TaskID(n int) (string, bool) {
+	i := n - l.Offset - 1
+	if i < 0 || i >= len(l.TaskIDs) {
+		return "", false
+	}
+	return l.TaskIDs[i], true
+}
+
+// ChatListStore keeps the last task list of each chat
+type ChatListStore interface {
+	// Save replaces the chat's list
+	Save(ctx context.Context, chatID int64, list *ChatList) error
+	// Load returns the chat's list, or nil when there is none or it expired
+	Load(ctx context.Context, chatID int64) (*ChatList, error)
+}
+
+type redisChatListStore struct {
+	rdb *redis.Client
+}
+
+// NewChatListStore creates a new Redis-based chat list store
+func NewChatListStore(rdb *redis.Client) ChatListStore {
+	return &redisChatListStore{rdb: rdb}
+}
+
+func (s *redisChatListStore) Save(ctx context.Context, chatID int64, list *ChatList) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, pkgredis.GetTelegramChatListKey(chatID), data, ChatListTTL).Err()
+}
+
+func (s *redisChatListStore) Load(ctx context.Context, chatID int64) (*ChatList, error) {
+	data, err := s.rdb.Get(ctx, pkgredis.GetTelegramChatListKey(chatID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list ChatList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
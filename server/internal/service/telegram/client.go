@@ -2,7 +2,9 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,9 +15,10 @@ import (
 const telegramAPIBase = "https://api.telegram.org/bot"
 
 type Client struct {
-	token      string
-	baseURL    string
-	httpClient *http.Client
+	token          string
+	baseURL        string
+	httpClient     *http.Client
+	downloadClient *http.Client // Longer timeout for file downloads
 }
 
 func NewClient(token string) *Client {
@@ -25,6 +28,9 @@ func NewClient(token string) *Client {
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		downloadClient: &http.Client{
+			Timeout: 2 * time.Minute,
+		},
 	}
 }
 
@@ -228,6 +234,101 @@ func (c *Client) EditMessageText(inlineMessageID string, text string, markup *In
 	})
 }
 
+// EditChatMessageText replaces the text and inline keyboard of a chat message
+func (c *Client) EditChatMessageText(chatID int64, messageID int64, text string, markup *InlineKeyboardMarkup) error {
+	return c.sendJSON("editMessageText", editMessageTextReq{
+		ChatID:      chatID,
+		MessageID:   int(messageID),
+		Text:        text,
+		ParseMode:   "HTML",
+		ReplyMarkup: markup,
+	})
+}
+
+// EditMessageReplyMarkup replaces the inline keyboard of a chat message
+type editMessageReplyMarkupReq struct {
+	ChatID      int64                 `json:"chat_id"`
+	MessageID   int64                 `json:"message_id"`
+	ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+func (c *Client) EditMessageReplyMarkup(chatID int64, messageID int64, markup *InlineKeyboardMarkup) error {
+	return c.sendJSON("editMessageReplyMarkup", editMessageReplyMarkupReq{
+		ChatID:      chatID,
+		MessageID:   messageID,
+		ReplyMarkup: markup,
+	})
+}
+
+// File is a file ready for download, as returned by getFile
+type File struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileSize     int64  `json:"file_size"`
+	FilePath     string `json:"file_path"`
+}
+
+// ErrFileTooBig is returned by GetFile for files over the 20 MB bots may download
+var ErrFileTooBig = errors.New("telegram file is too big")
+
+type getFileReq struct {
+	FileID string `json:"file_id"`
+}
+
+// GetFile resolves a file_id to a temporary download path
+func (c *Client) GetFile(fileID string) (*File, error) {
+	url := fmt.Sprintf("%s%s/getFile", c.baseURL, c.token)
+	body, err := json.Marshal(getFileReq{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Post(url, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      File   `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("telegram api getFile: status %d: %w", resp.StatusCode, err)
+	}
+	if !result.OK {
+		if strings.Contains(result.Description, "file is too big") {
+			return nil, ErrFileTooBig
+		}
+		return nil, fmt.Errorf("telegram api error: status %d: %s", resp.StatusCode, result.Description)
+	}
+	return &result.Result, nil
+}
+
+// DownloadFile streams a file by its file_id. The caller closes the reader.
+// It also returns the file size, or -1 when unknown.
+func (c *Client) DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, int64, error) {
+	file, err := c.GetFile(fileID)
+	if err != nil {
+		return nil, 0, err
+	}
+	// https://api.telegram.org/bot<token>/... serves files from /file/bot<token>/<path>
+	url := fmt.Sprintf("%sfile/bot%s/%s", strings.TrimSuffix(c.baseURL, "bot"), c.token, file.FilePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := c.downloadClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("telegram file download: status %d", resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
 func (c *Client) sendJSON(method string, payload interface{}) error {
 	url := fmt.Sprintf("%s%s/%s", c.baseURL, c.token, method)
 
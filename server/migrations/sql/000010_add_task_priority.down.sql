@@ -0,0 +1,4 @@
+-- Remove priority from tasks
+DROP INDEX IF EXISTS idx_tasks_priority;
+ALTER TABLE tasks DROP COLUMN IF EXISTS priority;
+DROP TYPE IF EXISTS task_priority;
@@ -0,0 +1,6 @@
+-- Add priority to tasks (High / Medium / Low)
+CREATE TYPE task_priority AS ENUM ('High', 'Medium', 'Low');
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS priority task_priority NOT NULL DEFAULT 'Medium';
+
+CREATE INDEX IF NOT EXISTS idx_tasks_priority ON tasks(priority);
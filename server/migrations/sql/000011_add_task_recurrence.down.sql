@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_tasks_recurrence_pending;
+DROP INDEX IF EXISTS idx_tasks_recurrence_parent_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS recurrence_spawned;
+ALTER TABLE tasks DROP COLUMN IF EXISTS recurrence_index;
+ALTER TABLE tasks DROP COLUMN IF EXISTS recurrence_parent_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS recurrence;
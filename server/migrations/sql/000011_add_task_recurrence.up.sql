@@ -0,0 +1,8 @@
+-- Recurring tasks: RFC 5545 RRULE subset, instances linked to the first task of the series
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence_parent_id UUID REFERENCES tasks(id) ON DELETE SET NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence_index INT NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence_spawned BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_recurrence_parent_id ON tasks(recurrence_parent_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_recurrence_pending ON tasks(due_at) WHERE recurrence <> '' AND recurrence_spawned = FALSE;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tasks_parent_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS auto_complete;
+ALTER TABLE tasks DROP COLUMN IF EXISTS parent_id;
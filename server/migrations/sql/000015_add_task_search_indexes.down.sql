@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_task_context_snapshots_text_trgm;
+DROP INDEX IF EXISTS idx_task_comments_content_trgm;
+DROP INDEX IF EXISTS idx_tasks_description_trgm;
+DROP INDEX IF EXISTS idx_tasks_title_trgm;
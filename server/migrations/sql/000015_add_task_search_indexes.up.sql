@@ -0,0 +1,19 @@
+-- Full-text search: trigram indexes serve LOWER(col) LIKE '%term%' lookups,
+-- which also work for Chinese (no word segmentation needed)
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- task_comments is otherwise created by the ORM at startup, after migrations run
+CREATE TABLE IF NOT EXISTS task_comments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    task_id UUID NOT NULL,
+    parent_id UUID,
+    user_id UUID NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tasks_title_trgm ON tasks USING gin (LOWER(title) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_tasks_description_trgm ON tasks USING gin (LOWER(COALESCE(description, '')) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_task_comments_content_trgm ON task_comments USING gin (LOWER(content) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_task_context_snapshots_text_trgm ON task_context_snapshots USING gin (LOWER(text) gin_trgm_ops);
@@ -0,0 +1,5 @@
+-- Enum values cannot be dropped; 'Update' and 'Dependency' remain in task_event_type
+DROP INDEX IF EXISTS idx_events_task_id_created_at;
+CREATE INDEX IF NOT EXISTS idx_events_task_id ON task_events(task_id);
+
+ALTER TABLE task_events DROP COLUMN IF EXISTS source;
@@ -0,0 +1,10 @@
+-- Audit trail: event types for generic field edits and dependency changes,
+-- and where each change came from (app / bot / notion / system)
+ALTER TYPE task_event_type ADD VALUE IF NOT EXISTS 'Update';
+ALTER TYPE task_event_type ADD VALUE IF NOT EXISTS 'Dependency';
+
+ALTER TABLE task_events ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'app';
+
+-- Timeline queries read a task's events newest first
+DROP INDEX IF EXISTS idx_events_task_id;
+CREATE INDEX IF NOT EXISTS idx_events_task_id_created_at ON task_events(task_id, created_at DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tasks_auto_archive;
+ALTER TABLE tasks ALTER COLUMN archived DROP NOT NULL;
+ALTER TABLE tasks DROP COLUMN IF EXISTS completed_at;
@@ -0,0 +1,10 @@
+-- When a task was last marked Done; auto-archiving counts days from here
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS completed_at TIMESTAMPTZ;
+UPDATE tasks SET completed_at = updated_at WHERE status = 'Done' AND completed_at IS NULL;
+
+UPDATE tasks SET archived = FALSE WHERE archived IS NULL;
+ALTER TABLE tasks ALTER COLUMN archived SET NOT NULL;
+
+-- The auto-archive job scans Done tasks that are still visible
+CREATE INDEX IF NOT EXISTS idx_tasks_auto_archive ON tasks(completed_at)
+    WHERE status = 'Done' AND archived = FALSE AND deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- Optimistic concurrency: every update bumps version, and writers only apply
+-- their changes if the version they read is still current
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE user_notion_tokens DROP COLUMN IF EXISTS notion_user_id;
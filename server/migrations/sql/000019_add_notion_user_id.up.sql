@@ -0,0 +1,3 @@
+-- Notion user who authorized the integration, used to fill the Assignee
+-- (People) property of synced pages
+ALTER TABLE user_notion_tokens ADD COLUMN IF NOT EXISTS notion_user_id TEXT;
@@ -0,0 +1,10 @@
+-- Users following a task: they get status, comment and due date notifications
+-- without being assigned
+CREATE TABLE IF NOT EXISTS task_watchers (
+    task_id UUID REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (task_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_watchers_user_id ON task_watchers(user_id);
@@ -0,0 +1,22 @@
+-- Reusable task layouts, personal (user_id) or shared with a group (group_id)
+CREATE TABLE IF NOT EXISTS task_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    group_id TEXT, -- Telegram Chat ID (matches groups.id)
+    title_pattern TEXT NOT NULL,
+    description TEXT,
+    priority task_priority NOT NULL DEFAULT 'Medium',
+    assignee_ids JSONB NOT NULL DEFAULT '[]',
+    labels JSONB NOT NULL DEFAULT '[]',
+    due_offset_minutes INTEGER,
+    subtasks JSONB NOT NULL DEFAULT '[]',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (user_id IS NOT NULL OR group_id IS NOT NULL)
+);
+
+-- Template names are unique per owner
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_templates_user_name ON task_templates(user_id, name) WHERE group_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_templates_group_name ON task_templates(group_id, name) WHERE group_id IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE task_comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE task_comments DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,4 @@
+-- Comments can be edited and deleted by their author. A deleted comment that
+-- has replies is kept with empty content so the thread stays intact.
+ALTER TABLE task_comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+ALTER TABLE task_comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
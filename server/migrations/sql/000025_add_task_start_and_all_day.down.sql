@@ -0,0 +1,3 @@
+ALTER TABLE users ALTER COLUMN timezone SET DEFAULT 'UTC+0';
+ALTER TABLE tasks DROP COLUMN IF EXISTS all_day;
+ALTER TABLE tasks DROP COLUMN IF EXISTS start_at;
@@ -0,0 +1,14 @@
+-- Tasks get a start date and an explicit all-day flag. All-day dates are
+-- stored as midnight UTC of the calendar date.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS start_at TIMESTAMPTZ;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS all_day BOOLEAN NOT NULL DEFAULT false;
+
+-- User time zones become IANA names. Whole-hour offsets such as "UTC+8" map
+-- to Etc/GMT zones, whose sign is inverted; other offsets are still read as
+-- fixed offsets by the server.
+ALTER TABLE users ALTER COLUMN timezone SET DEFAULT 'UTC';
+UPDATE users SET timezone = 'UTC' WHERE timezone ~* '^(UTC|GMT)[+-]?0{0,2}(:?00)?$';
+UPDATE users
+SET timezone = CASE WHEN substring(timezone FROM 4 FOR 1) = '+' THEN 'Etc/GMT-' ELSE 'Etc/GMT+' END
+    || substring(timezone FROM '^...[+-]0?(\d{1,2})$')
+WHERE timezone ~* '^(UTC|GMT)([+]0?([1-9]|1[0-4])|-0?([1-9]|1[0-2]))$';
@@ -0,0 +1,20 @@
+DROP INDEX IF EXISTS idx_tasks_auto_archive;
+DROP INDEX IF EXISTS idx_tasks_status_category;
+
+-- Custom statuses fall back to the default status of their category
+UPDATE tasks SET status = CASE status_category
+    WHEN 'active' THEN 'In Progress'
+    WHEN 'done' THEN 'Done'
+    ELSE 'To Do'
+END
+WHERE status NOT IN ('To Do', 'In Progress', 'Done');
+ALTER TABLE tasks DROP COLUMN IF EXISTS status_category;
+
+CREATE TYPE task_status AS ENUM ('To Do', 'In Progress', 'Done');
+ALTER TABLE tasks ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE tasks ALTER COLUMN status TYPE task_status USING status::task_status;
+ALTER TABLE tasks ALTER COLUMN status SET DEFAULT 'To Do';
+CREATE INDEX IF NOT EXISTS idx_tasks_auto_archive ON tasks(completed_at)
+    WHERE status = 'Done' AND archived = FALSE AND deleted_at IS NULL;
+
+DROP TABLE IF EXISTS workflow_statuses;
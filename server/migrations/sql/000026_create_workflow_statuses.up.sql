@@ -0,0 +1,33 @@
+-- Groups define their own ordered statuses, each mapped to a category
+-- (todo / active / done). Statuses become free text; the category of a task's
+-- status is kept on the task so counts, views and digests need no join.
+CREATE TABLE IF NOT EXISTS workflow_statuses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id TEXT NOT NULL, -- Telegram Chat ID (matches groups.id)
+    name TEXT NOT NULL,
+    category TEXT NOT NULL CHECK (category IN ('todo', 'active', 'done')),
+    position INTEGER NOT NULL DEFAULT 0,
+    next JSONB NOT NULL DEFAULT '[]', -- Allowed next statuses; empty allows any
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_statuses_group_name ON workflow_statuses(group_id, LOWER(name));
+
+-- The auto-archive index filters on the enum, so it is rebuilt on the category
+DROP INDEX IF EXISTS idx_tasks_auto_archive;
+ALTER TABLE tasks ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE tasks ALTER COLUMN status TYPE TEXT USING status::TEXT;
+ALTER TABLE tasks ALTER COLUMN status SET DEFAULT 'To Do';
+DROP TYPE IF EXISTS task_status;
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS status_category TEXT NOT NULL DEFAULT 'todo'
+    CHECK (status_category IN ('todo', 'active', 'done'));
+UPDATE tasks SET status_category = CASE status
+    WHEN 'In Progress' THEN 'active'
+    WHEN 'Done' THEN 'done'
+    ELSE 'todo'
+END;
+CREATE INDEX IF NOT EXISTS idx_tasks_status_category ON tasks(status_category);
+CREATE INDEX IF NOT EXISTS idx_tasks_auto_archive ON tasks(completed_at)
+    WHERE status_category = 'done' AND archived = FALSE AND deleted_at IS NULL;
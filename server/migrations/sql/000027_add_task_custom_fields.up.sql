@@ -0,0 +1,17 @@
+-- Extra columns of a group's Notion database (Customer, Story Points, ...),
+-- discovered from the database properties. Values are stored on the task by
+-- property name and sync both ways.
+CREATE TABLE IF NOT EXISTS group_custom_fields (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id TEXT NOT NULL, -- Telegram Chat ID (matches groups.id)
+    name TEXT NOT NULL, -- Notion property name
+    type TEXT NOT NULL CHECK (type IN ('rich_text', 'number', 'select', 'multi_select', 'date', 'checkbox', 'url')),
+    position INTEGER NOT NULL DEFAULT 0,
+    options JSONB NOT NULL DEFAULT '[]', -- Select / multi-select options
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_custom_fields_group_name ON group_custom_fields(group_id, name);
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+-- Reminders can be snoozed from the bot; no reminder is sent for the task
+-- until reminder_snoozed_until has passed.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS reminder_snoozed_until TIMESTAMPTZ;
@@ -1,7 +1,13 @@
 package notion
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dstotijn/go-notion"
@@ -15,6 +21,7 @@ type Client interface {
 	UpdateDatabase(ctx context.Context, id string, params notion.UpdateDatabaseParams) (*notion.Database, error)
 	QueryDatabase(ctx context.Context, id string, params *notion.DatabaseQuery) (*notion.DatabaseQueryResponse, error)
 	UpdatePage(ctx context.Context, pageID string, params UpdatePageParams) (*notion.Page, error)
+	AppendBlockChildren(ctx context.Context, blockID string, children []notion.Block) error
 }
 
 // CreatePageParams holds parameters for creating a page
@@ -22,25 +29,50 @@ type CreatePageParams struct {
 	DatabaseID string
 	Title      string
 	Status     string         // "To Do", "In Progress", "Done"
+	Priority   string         // "High", "Medium", "Low" (optional)
+	Labels     []string       // Multi-select option names (optional)
 	Assignees  []string       // Notion User IDs
 	Children   []notion.Block // Page content (blocks)
+	// Properties are extra properties by name, e.g. custom fields (see PropertyOf)
+	Properties notion.DatabasePageProperties
 }
 
 // UpdatePageParams holds parameters for updating a page
 type UpdatePageParams struct {
-	Title  *string
-	Status *string // "To Do", "In Progress", "Done"
+	Title    *string
+	Status   *string  // "To Do", "In Progress", "Done"
+	Priority *string  // "High", "Medium", "Low"
+	Labels   []string // Multi-select option names; nil leaves them unchanged, empty clears them
+	Archived *bool    // Moves the page to or out of the Notion trash
+	// Assignee people property as Notion User IDs; nil leaves it unchanged, empty clears it
+	Assignees []string
+	Numbers   map[string]float64 // Number properties by name
+	// Properties are extra properties by name, e.g. custom fields (see PropertyOf)
+	Properties notion.DatabasePageProperties
+	// Cleared are properties to empty, by name and type
+	Cleared map[string]notion.DatabasePropertyType
 }
 
+const (
+	notionAPIURL     = "https://api.notion.com/v1"
+	notionAPIVersion = "2022-06-28" // The version go-notion speaks
+)
+
 // clientWrapper wraps dstotijn/go-notion client
 type clientWrapper struct {
-	api *notion.Client
+	api        *notion.Client
+	token      string
+	baseURL    string
+	httpClient *http.Client
 }
 
 // NewClient creates a new Notion client with the given access token
 func NewClient(accessToken string) Client {
 	return &clientWrapper{
-		api: notion.NewClient(accessToken),
+		api:        notion.NewClient(accessToken),
+		token:      accessToken,
+		baseURL:    notionAPIURL,
+		httpClient: http.DefaultClient,
 	}
 }
 
@@ -63,6 +95,29 @@ func (c *clientWrapper) CreatePage(ctx context.Context, params CreatePageParams)
 			},
 		},
 	}
+	for name, prop := range params.Properties {
+		props[name] = prop
+	}
+
+	if params.Priority != "" {
+		props["Priority"] = notion.DatabasePageProperty{
+			Select: &notion.SelectOptions{
+				Name: params.Priority,
+			},
+		}
+	}
+
+	if len(params.Labels) > 0 {
+		props["Labels"] = notion.DatabasePageProperty{
+			MultiSelect: multiSelectOptions(params.Labels),
+		}
+	}
+
+	if len(params.Assignees) > 0 {
+		props["Assignee"] = notion.DatabasePageProperty{
+			People: peopleOf(params.Assignees),
+		}
+	}
 
 	// 2. Use Children from params
 	children := params.Children
@@ -140,7 +195,7 @@ func (c *clientWrapper) QueryDatabase(ctx context.Context, id string, params *no
 
 // UpdatePage updates a page properties
 func (c *clientWrapper) UpdatePage(ctx context.Context, pageID string, params UpdatePageParams) (*notion.Page, error) {
-	props := make(notion.DatabasePageProperties)
+	props := make(map[string]interface{})
 
 	if params.Title != nil {
 		props["Name"] = notion.DatabasePageProperty{
@@ -162,17 +217,136 @@ func (c *clientWrapper) UpdatePage(ctx context.Context, pageID string, params Up
 		}
 	}
 
-	req := notion.UpdatePageParams{
-		DatabasePageProperties: props,
+	if params.Priority != nil {
+		props["Priority"] = notion.DatabasePageProperty{
+			Select: &notion.SelectOptions{
+				Name: *params.Priority,
+			},
+		}
+	}
+
+	if len(params.Labels) > 0 {
+		props["Labels"] = notion.DatabasePageProperty{
+			MultiSelect: multiSelectOptions(params.Labels),
+		}
+	} else if params.Labels != nil {
+		props["Labels"] = emptyProperty{Type: notion.DBPropTypeMultiSelect}
+	}
+
+	if len(params.Assignees) > 0 {
+		props["Assignee"] = notion.DatabasePageProperty{
+			People: peopleOf(params.Assignees),
+		}
+	} else if params.Assignees != nil {
+		props["Assignee"] = emptyProperty{Type: notion.DBPropTypePeople}
+	}
+
+	for name, prop := range params.Properties {
+		props[name] = prop
+	}
+	for name, value := range params.Numbers {
+		props[name] = notion.DatabasePageProperty{Number: &value}
+	}
+	for name, propType := range params.Cleared {
+		props[name] = emptyProperty{Type: propType}
+	}
+
+	req := updatePageRequest{
+		Properties: props,
+		Archived:   params.Archived,
 	}
 
 	return Retry(ctx, func() (*notion.Page, error) {
-		page, err := c.api.UpdatePage(ctx, pageID, req)
+		return c.patchPage(ctx, pageID, req)
+	})
+}
+
+// updatePageRequest is the body of a page update. Its properties may hold
+// emptyProperty values, which notion.UpdatePageParams cannot carry.
+type updatePageRequest struct {
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Archived   *bool                  `json:"archived,omitempty"`
+}
+
+// emptyProperty clears a page property. go-notion tags every property value
+// omitempty, so an empty multi_select or people list would never be sent.
+type emptyProperty struct {
+	Type notion.DatabasePropertyType
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p emptyProperty) MarshalJSON() ([]byte, error) {
+	var value interface{} // null clears single values
+	switch p.Type {
+	case notion.DBPropTypeRichText, notion.DBPropTypeMultiSelect, notion.DBPropTypePeople,
+		notion.DBPropTypeRelation, notion.DBPropTypeFiles:
+		value = []struct{}{}
+	case notion.DBPropTypeCheckbox:
+		value = false
+	}
+	return json.Marshal(map[string]interface{}{string(p.Type): value})
+}
+
+// patchPage sends a page update to the Notion API directly, since go-notion
+// marshals its own properties type only
+func (c *clientWrapper) patchPage(ctx context.Context, pageID string, body updatePageRequest) (*notion.Page, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("notion: failed to encode body params to JSON: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.baseURL+"/pages/"+pageID, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("notion: invalid request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Notion-Version", notionAPIVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("notion: failed to make HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("notion: failed to update page properties: status %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var page notion.Page
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("notion: failed to parse HTTP response: %w", err)
+	}
+	return &page, nil
+}
+
+// multiSelectOptions converts names to multi-select options
+func multiSelectOptions(names []string) []notion.SelectOptions {
+	options := make([]notion.SelectOptions, 0, len(names))
+	for _, name := range names {
+		options = append(options, notion.SelectOptions{Name: name})
+	}
+	return options
+}
+
+// peopleOf converts Notion User IDs to people property values
+func peopleOf(userIDs []string) []notion.User {
+	people := make([]notion.User, 0, len(userIDs))
+	for _, id := range userIDs {
+		people = append(people, notion.User{BaseUser: notion.BaseUser{ID: id}})
+	}
+	return people
+}
+
+// AppendBlockChildren appends blocks to the end of a page or block
+func (c *clientWrapper) AppendBlockChildren(ctx context.Context, blockID string, children []notion.Block) error {
+	_, err := Retry(ctx, func() (*notion.BlockChildrenResponse, error) {
+		resp, err := c.api.AppendBlockChildren(ctx, blockID, children)
 		if err != nil {
 			return nil, err
 		}
-		return &page, nil
+		return &resp, nil
 	})
+	return err
 }
 
 // Retry is a helper to retry operations with exponential backoff
@@ -0,0 +1,84 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dstotijn/go-notion"
+)
+
+func TestUpdatePageSendsClearedProperties(t *testing.T) {
+	var body map[string]map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/pages/page-1" {
+			t.Errorf("request = %s %s, want PATCH /pages/page-1", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("invalid body %s: %v", raw, err)
+		}
+		w.Write([]byte(`{"object":"page","id":"page-1","parent":{"type":"database_id","database_id":"db-1"},"properties":{}}`))
+	}))
+	defer server.Close()
+
+	client := &clientWrapper{token: "secret", baseURL: server.URL, httpClient: server.Client()}
+	page, err := client.UpdatePage(context.Background(), "page-1", UpdatePageParams{
+		Labels:    []string{},
+		Assignees: []string{},
+		Cleared:   map[string]notion.DatabasePropertyType{"Sprint": notion.DBPropTypeSelect},
+	})
+	if err != nil {
+		t.Fatalf("UpdatePage() error = %v", err)
+	}
+	if page.ID != "page-1" {
+		t.Errorf("page ID = %q, want page-1", page.ID)
+	}
+	if got := string(body["properties"]["Labels"]); got != `{"multi_select":[]}` {
+		t.Errorf("Labels = %s, want an empty multi_select", got)
+	}
+	if got := string(body["properties"]["Assignee"]); got != `{"people":[]}` {
+		t.Errorf("Assignee = %s, want an empty people list", got)
+	}
+	if got := string(body["properties"]["Sprint"]); got != `{"select":null}` {
+		t.Errorf("Sprint = %s, want a null select", got)
+	}
+
+	if _, err := client.UpdatePage(context.Background(), "page-1", UpdatePageParams{Labels: []string{"web"}}); err != nil {
+		t.Fatalf("UpdatePage() error = %v", err)
+	}
+	if got := string(body["properties"]["Labels"]); got != `{"multi_select":[{"name":"web"}]}` {
+		t.Errorf("Labels = %s", got)
+	}
+	if _, ok := body["properties"]["Assignee"]; ok {
+		t.Errorf("Assignee sent although unchanged")
+	}
+}
+
+func TestEmptyPropertyJSON(t *testing.T) {
+	tests := map[string]string{
+		"rich_text":    `{"rich_text":[]}`,
+		"multi_select": `{"multi_select":[]}`,
+		"people":       `{"people":[]}`,
+		"number":       `{"number":null}`,
+		"select":       `{"select":null}`,
+		"date":         `{"date":null}`,
+		"url":          `{"url":null}`,
+		"checkbox":     `{"checkbox":false}`,
+	}
+	for propType, want := range tests {
+		got, err := json.Marshal(emptyProperty{Type: notion.DatabasePropertyType(propType)})
+		if err != nil {
+			t.Fatalf("%s: %v", propType, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %s, want %s", propType, got, want)
+		}
+	}
+}
@@ -34,6 +34,21 @@ type TokenResponse struct {
 	DuplicatedTemplateID string          `json:"duplicated_template_id,omitempty"`
 }
 
+// OwnerUserID returns the Notion user ID of the user who authorized the
+// integration, or "" if the integration is owned by the workspace
+func (r *TokenResponse) OwnerUserID() string {
+	var owner struct {
+		Type string `json:"type"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(r.Owner, &owner); err != nil || owner.Type != "user" {
+		return ""
+	}
+	return owner.User.ID
+}
+
 // GenerateAuthURL generates the Notion OAuth authorization URL
 // state: A random string to prevent CSRF attacks
 func GenerateAuthURL(config OAuthConfig, state string) string {
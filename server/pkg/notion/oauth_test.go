@@ -178,3 +178,20 @@ func TestOAuthConfig(t *testing.T) {
 		t.Error("RedirectURI should not be empty")
 	}
 }
+
+func TestTokenResponseOwnerUserID(t *testing.T) {
+	tests := []struct {
+		owner string
+		want  string
+	}{
+		{`{"type":"user","user":{"object":"user","id":"u-123"}}`, "u-123"},
+		{`{"type":"workspace","workspace":true}`, ""},
+		{``, ""},
+	}
+	for _, tt := range tests {
+		resp := TokenResponse{Owner: json.RawMessage(tt.owner)}
+		if got := resp.OwnerUserID(); got != tt.want {
+			t.Errorf("OwnerUserID(%s) = %q, want %q", tt.owner, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package notion
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/dstotijn/go-notion"
+)
+
+// maxRichTextLength is the longest content of a single Notion rich text object
+const maxRichTextLength = 2000
+
+// SupportsProperty reports whether properties of type t can be read with
+// PropertyValue and written with PropertyOf
+func SupportsProperty(t notion.DatabasePropertyType) bool {
+	switch t {
+	case notion.DBPropTypeRichText, notion.DBPropTypeNumber, notion.DBPropTypeSelect,
+		notion.DBPropTypeMultiSelect, notion.DBPropTypeDate, notion.DBPropTypeCheckbox, notion.DBPropTypeURL:
+		return true
+	}
+	return false
+}
+
+// PropertyValue returns the value of a page property as JSON data: text,
+// select and URL as string, number as float64, multi-select as []string,
+// date as "2006-01-02" or RFC 3339 and checkbox as bool. Empty values and
+// unsupported types return nil.
+func PropertyValue(prop notion.DatabasePageProperty) interface{} {
+	switch prop.Type {
+	case notion.DBPropTypeRichText:
+		var b strings.Builder
+		for _, rt := range prop.RichText {
+			if rt.PlainText == "" && rt.Text != nil {
+				// Built locally rather than read from the API
+				b.WriteString(rt.Text.Content)
+				continue
+			}
+			b.WriteString(rt.PlainText)
+		}
+		if b.Len() == 0 {
+			return nil
+		}
+		return b.String()
+	case notion.DBPropTypeNumber:
+		if prop.Number == nil {
+			return nil
+		}
+		return *prop.Number
+	case notion.DBPropTypeSelect:
+		if prop.Select == nil || prop.Select.Name == "" {
+			return nil
+		}
+		return prop.Select.Name
+	case notion.DBPropTypeMultiSelect:
+		if len(prop.MultiSelect) == 0 {
+			return nil
+		}
+		names := make([]string, 0, len(prop.MultiSelect))
+		for _, option := range prop.MultiSelect {
+			names = append(names, option.Name)
+		}
+		return names
+	case notion.DBPropTypeDate:
+		if prop.Date == nil {
+			return nil
+		}
+		if prop.Date.Start.HasTime() {
+			return prop.Date.Start.Time.Format(time.RFC3339)
+		}
+		return prop.Date.Start.Time.Format("2006-01-02")
+	case notion.DBPropTypeCheckbox:
+		if prop.Checkbox == nil {
+			return nil
+		}
+		return *prop.Checkbox
+	case notion.DBPropTypeURL:
+		if prop.URL == nil || *prop.URL == "" {
+			return nil
+		}
+		return *prop.URL
+	}
+	return nil
+}
+
+// PropertyOf builds a page property of type t from a value in the format
+// returned by PropertyValue; multi-select values may also be []interface{}
+// and numbers json.Number, as decoded from JSON. ok is false for unsupported types and values that do
+// not fit the type.
+func PropertyOf(t notion.DatabasePropertyType, value interface{}) (prop notion.DatabasePageProperty, ok bool) {
+	prop.Type = t
+	switch t {
+	case notion.DBPropTypeRichText:
+		s, ok := value.(string)
+		if !ok || len([]rune(s)) > maxRichTextLength {
+			return prop, false
+		}
+		prop.RichText = []notion.RichText{{Text: &notion.Text{Content: s}}}
+	case notion.DBPropTypeNumber:
+		n, ok := numberOf(value)
+		if !ok {
+			return prop, false
+		}
+		prop.Number = &n
+	case notion.DBPropTypeSelect:
+		s, ok := value.(string)
+		if !ok || !validOption(s) {
+			return prop, false
+		}
+		prop.Select = &notion.SelectOptions{Name: s}
+	case notion.DBPropTypeMultiSelect:
+		names, ok := stringsOf(value)
+		if !ok {
+			return prop, false
+		}
+		for _, name := range names {
+			if !validOption(name) {
+				return prop, false
+			}
+		}
+		prop.MultiSelect = multiSelectOptions(names)
+	case notion.DBPropTypeDate:
+		s, ok := value.(string)
+		if !ok {
+			return prop, false
+		}
+		start, err := parseDate(s)
+		if err != nil {
+			return prop, false
+		}
+		prop.Date = &notion.Date{Start: start}
+	case notion.DBPropTypeCheckbox:
+		b, ok := value.(bool)
+		if !ok {
+			return prop, false
+		}
+		prop.Checkbox = &b
+	case notion.DBPropTypeURL:
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return prop, false
+		}
+		prop.URL = &s
+	default:
+		return prop, false
+	}
+	return prop, true
+}
+
+// parseDate accepts a date or an RFC 3339 time
+func parseDate(s string) (notion.DateTime, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return notion.NewDateTime(t, true), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return notion.DateTime{}, err
+	}
+	return notion.NewDateTime(t, false), nil
+}
+
+// validOption reports whether name can be a select option; Notion rejects
+// commas in option names
+func validOption(name string) bool {
+	return strings.TrimSpace(name) != "" && !strings.Contains(name, ",") && len([]rune(name)) <= 100
+}
+
+func numberOf(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func stringsOf(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, s)
+		}
+		return out, true
+	}
+	return nil, false
+}
@@ -0,0 +1,58 @@
+package notion
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dstotijn/go-notion"
+)
+
+func TestPropertyRoundTrip(t *testing.T) {
+	tests := []struct {
+		propType notion.DatabasePropertyType
+		in       interface{}
+		want     interface{}
+	}{
+		{notion.DBPropTypeRichText, "Acme Corp", "Acme Corp"},
+		{notion.DBPropTypeRichText, "", nil},
+		{notion.DBPropTypeNumber, 3.5, 3.5},
+		{notion.DBPropTypeSelect, "Sprint 12", "Sprint 12"},
+		{notion.DBPropTypeMultiSelect, []interface{}{"web", "api"}, []string{"web", "api"}},
+		{notion.DBPropTypeMultiSelect, []string{}, nil},
+		{notion.DBPropTypeDate, "2026-03-02", "2026-03-02"},
+		{notion.DBPropTypeDate, "2026-03-02T18:00:00+08:00", "2026-03-02T18:00:00+08:00"},
+		{notion.DBPropTypeCheckbox, false, false},
+		{notion.DBPropTypeURL, "https://example.com", "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		prop, ok := PropertyOf(tt.propType, tt.in)
+		if !ok {
+			t.Fatalf("PropertyOf(%s, %v) not ok", tt.propType, tt.in)
+		}
+		if got := PropertyValue(prop); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: PropertyValue = %#v, want %#v", tt.propType, got, tt.want)
+		}
+	}
+}
+
+func TestPropertyOfRejectsMismatchedValues(t *testing.T) {
+	tests := []struct {
+		propType notion.DatabasePropertyType
+		in       interface{}
+	}{
+		{notion.DBPropTypeNumber, "3"},
+		{notion.DBPropTypeSelect, "a,b"},
+		{notion.DBPropTypeMultiSelect, []interface{}{"ok", 1.0}},
+		{notion.DBPropTypeDate, "next friday"},
+		{notion.DBPropTypeCheckbox, "true"},
+		{notion.DBPropTypeURL, ""},
+		{notion.DBPropTypeFormula, "x"},
+	}
+
+	for _, tt := range tests {
+		if _, ok := PropertyOf(tt.propType, tt.in); ok {
+			t.Errorf("PropertyOf(%s, %#v) accepted", tt.propType, tt.in)
+		}
+	}
+}
@@ -12,3 +12,12 @@ const (
 func GetTelegramUpdateKey(updateID int64) string {
 	return fmt.Sprintf(TelegramUpdateKey, updateID)
 }
+
+// TelegramChatListKey is the key of the last task list shown in a chat
+// Format: telegram:chat_list:{chat_id}
+const TelegramChatListKey = "telegram:chat_list:%d"
+
+// GetTelegramChatListKey returns the redis key of a chat's task list
+func GetTelegramChatListKey(chatID int64) string {
+	return fmt.Sprintf(TelegramChatListKey, chatID)
+}
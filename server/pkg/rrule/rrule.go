@@ -0,0 +1,283 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used by
+// recurring tasks: FREQ (DAILY/WEEKLY/MONTHLY/YEARLY), INTERVAL, BYDAY
+// (weekly only), BYMONTHDAY (monthly only), COUNT and UNTIL.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ part of a rule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxIterations bounds the search for the next occurrence so that rules
+// that can never match (e.g. BYMONTHDAY=31 with a step of 2 from April)
+// terminate.
+const maxIterations = 1000
+
+var (
+	ErrEmptyRule       = errors.New("rrule: empty rule")
+	ErrUnsupportedFreq = errors.New("rrule: FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay int
+	Count      int
+	Until      *time.Time
+}
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,FR".
+// A leading "RRULE:" prefix is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	if s == "" {
+		return nil, ErrEmptyRule
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("rrule: malformed part %q", part)
+		}
+
+		switch key {
+		case "FREQ":
+			switch Frequency(val) {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = Frequency(val)
+			default:
+				return nil, ErrUnsupportedFreq
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("rrule: invalid INTERVAL %q", val)
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, ok := weekdayCodes[code]
+				if !ok {
+					return nil, fmt.Errorf("rrule: invalid BYDAY %q", code)
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > 31 {
+				return nil, fmt.Errorf("rrule: invalid BYMONTHDAY %q", val)
+			}
+			rule.ByMonthDay = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("rrule: invalid COUNT %q", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		default:
+			return nil, fmt.Errorf("rrule: unsupported part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("rrule: FREQ is required")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != Weekly {
+		return nil, errors.New("rrule: BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if rule.ByMonthDay > 0 && rule.Freq != Monthly {
+		return nil, errors.New("rrule: BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("rrule: COUNT and UNTIL are mutually exclusive")
+	}
+
+	sort.Slice(rule.ByDay, func(i, j int) bool {
+		return mondayIndex(rule.ByDay[i]) < mondayIndex(rule.ByDay[j])
+	})
+	return rule, nil
+}
+
+func parseUntil(val string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if t, err := time.Parse(layout, val); err == nil {
+			if layout == "20060102" {
+				// Date-only UNTIL is inclusive of the whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("rrule: invalid UNTIL %q", val)
+}
+
+// String returns the canonical RRULE value (without the "RRULE:" prefix)
+func (r *Rule) String() string {
+	return r.format("20060102T150405Z")
+}
+
+// DateString is like String but writes UNTIL as a DATE, as required when the
+// event's DTSTART is a VALUE=DATE (all-day) value
+func (r *Rule) DateString() string {
+	return r.format("20060102")
+}
+
+func (r *Rule) format(untilLayout string) string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, 0, len(r.ByDay))
+		for _, d := range r.ByDay {
+			codes = append(codes, weekdayNames[d])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.ByMonthDay > 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence strictly after dtstart, treating dtstart
+// as the first occurrence of the series. The time of day of dtstart is kept.
+// ok is false when the rule has no further occurrence (UNTIL passed or no
+// match found). COUNT is not applied here since it depends on how many
+// occurrences came before dtstart; callers track that themselves.
+func (r *Rule) Next(dtstart time.Time) (time.Time, bool) {
+	var next time.Time
+	switch r.Freq {
+	case Daily:
+		next = dtstart.AddDate(0, 0, r.Interval)
+	case Weekly:
+		next = r.nextWeekly(dtstart)
+	case Monthly:
+		next = r.nextMonthly(dtstart)
+	case Yearly:
+		next = r.nextYearly(dtstart)
+	}
+
+	if next.IsZero() {
+		return time.Time{}, false
+	}
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func (r *Rule) nextWeekly(dtstart time.Time) time.Time {
+	days := r.ByDay
+	if len(days) == 0 {
+		days = []time.Weekday{dtstart.Weekday()}
+	}
+
+	// Candidates in the week of dtstart first, then every INTERVAL weeks
+	weekStart := dtstart.AddDate(0, 0, -mondayIndex(dtstart.Weekday()))
+	for w := 0; w < maxIterations; w += r.Interval {
+		base := weekStart.AddDate(0, 0, 7*w)
+		for _, d := range days {
+			candidate := base.AddDate(0, 0, mondayIndex(d))
+			if candidate.After(dtstart) {
+				return candidate
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (r *Rule) nextMonthly(dtstart time.Time) time.Time {
+	day := r.ByMonthDay
+	if day == 0 {
+		day = dtstart.Day()
+	}
+
+	y, m, _ := dtstart.Date()
+	for i := 0; i < maxIterations; i += r.Interval {
+		// Months without the requested day are skipped, as per RFC 5545
+		first := time.Date(y, m+time.Month(i), 1, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+		if day > daysIn(first) {
+			continue
+		}
+		candidate := first.AddDate(0, 0, day-1)
+		if candidate.After(dtstart) {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
+
+func (r *Rule) nextYearly(dtstart time.Time) time.Time {
+	y, m, d := dtstart.Date()
+	for i := r.Interval; i < maxIterations; i += r.Interval {
+		candidate := time.Date(y+i, m, d, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+		// Feb 29 only recurs on leap years
+		if candidate.Day() == d {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
+
+// mondayIndex maps Monday..Sunday to 0..6 (RFC 5545 default WKST=MO)
+func mondayIndex(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
@@ -0,0 +1,102 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 9, 0, 0, 0, time.UTC)
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=weekly;byday=fr,mo", "FREQ=WEEKLY;BYDAY=MO,FR"},
+		{"FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=31", "FREQ=MONTHLY;BYMONTHDAY=31"},
+		{"FREQ=YEARLY;INTERVAL=2;COUNT=3", "FREQ=YEARLY;INTERVAL=2;COUNT=3"},
+		{"FREQ=DAILY;UNTIL=20250105T000000Z", "FREQ=DAILY;UNTIL=20250105T000000Z"},
+	}
+
+	for _, tt := range tests {
+		rule, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tt.in, err)
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDateString(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;UNTIL=20250131")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if got, want := rule.DateString(), "FREQ=WEEKLY;UNTIL=20250131"; got != want {
+		t.Errorf("DateString() = %q, want %q", got, want)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYMONTHDAY=3",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101",
+		"FREQ=DAILY;BYHOUR=9",
+	}
+
+	for _, in := range inputs {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) expected error", in)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  time.Time
+		ok    bool
+	}{
+		{"daily", "FREQ=DAILY", date(2025, 1, 31), date(2025, 2, 1), true},
+		{"daily interval", "FREQ=DAILY;INTERVAL=3", date(2025, 1, 1), date(2025, 1, 4), true},
+		{"weekly same weekday", "FREQ=WEEKLY", date(2025, 1, 3), date(2025, 1, 10), true},
+		{"weekly byday same week", "FREQ=WEEKLY;BYDAY=MO,WE", date(2025, 1, 6), date(2025, 1, 8), true},
+		{"weekly byday wraps", "FREQ=WEEKLY;BYDAY=MO,WE", date(2025, 1, 8), date(2025, 1, 13), true},
+		{"biweekly byday wraps", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", date(2025, 1, 8), date(2025, 1, 20), true},
+		{"monthly", "FREQ=MONTHLY", date(2025, 1, 15), date(2025, 2, 15), true},
+		{"monthly skips short months", "FREQ=MONTHLY", date(2025, 1, 31), date(2025, 3, 31), true},
+		{"monthly bymonthday", "FREQ=MONTHLY;BYMONTHDAY=5", date(2025, 1, 20), date(2025, 2, 5), true},
+		{"yearly leap day", "FREQ=YEARLY", date(2024, 2, 29), date(2028, 2, 29), true},
+		{"until reached", "FREQ=DAILY;UNTIL=20250101", date(2025, 1, 1), time.Time{}, false},
+		{"until inclusive", "FREQ=DAILY;UNTIL=20250102", date(2025, 1, 1), date(2025, 1, 2), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			got, ok := rule.Next(tt.start)
+			if ok != tt.ok {
+				t.Fatalf("Next ok = %v, want %v", ok, tt.ok)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Next = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
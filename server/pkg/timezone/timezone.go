@@ -0,0 +1,91 @@
+// Package timezone resolves the time zone setting of users and converts task
+// dates for them. Settings are IANA names such as "Asia/Shanghai"; the
+// "UTC+8" style offsets stored before zones were validated are still read.
+// All-day dates are stored as midnight UTC of the calendar date, so they do
+// not move when the zone of the reader changes.
+package timezone
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embedded zone database, so zones resolve in images without tzdata
+	_ "time/tzdata"
+)
+
+// Default is the zone of users who have not chosen one
+const Default = "UTC"
+
+// legacyOffset matches the old free-form settings: UTC+8, UTC-3, GMT+05:30
+var legacyOffset = regexp.MustCompile(`^(?i:UTC|GMT)([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// Validate checks that name is an IANA time zone and returns its canonical
+// spelling. Offsets like "UTC+8" and "Local" are rejected.
+func Validate(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "Local" {
+		return "", fmt.Errorf("timezone: %q is not an IANA time zone", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return "", fmt.Errorf("timezone: %q is not an IANA time zone", name)
+	}
+	return loc.String(), nil
+}
+
+// Load returns the location of a stored setting. Legacy offsets become fixed
+// zones; empty or unknown settings fall back to UTC.
+func Load(name string) *time.Location {
+	name = strings.TrimSpace(name)
+	if m := legacyOffset.FindStringSubmatch(name); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		offset := hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		if offset == 0 {
+			return time.UTC
+		}
+		return time.FixedZone(name, offset)
+	}
+	if name == "" || name == "Local" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Date returns the calendar date of t in loc, as stored for all-day tasks
+func Date(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// StartOfDate returns the instant an all-day date begins in loc
+func StartOfDate(date time.Time, loc *time.Location) time.Time {
+	y, m, d := date.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// EndOfDate returns 23:59 of an all-day date in loc, the deadline a date
+// becomes when a task switches to a timed due date
+func EndOfDate(date time.Time, loc *time.Location) time.Time {
+	y, m, d := date.UTC().Date()
+	return time.Date(y, m, d, 23, 59, 0, 0, loc)
+}
+
+// FormatDue renders a due date with dateLayout, adding the time of day in loc
+// unless the date is all-day, e.g. "03-02" or "03-02 18:00"
+func FormatDue(t time.Time, allDay bool, loc *time.Location, dateLayout string) string {
+	if allDay {
+		return t.UTC().Format(dateLayout)
+	}
+	return t.In(loc).Format(dateLayout + " 15:04")
+}
@@ -0,0 +1,82 @@
+package timezone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"Asia/Shanghai", "Asia/Shanghai", false},
+		{" Europe/Berlin ", "Europe/Berlin", false},
+		{"UTC", "UTC", false},
+		{"UTC+8", "", true},
+		{"Local", "", true},
+		{"", "", true},
+		{"Mars/Olympus", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Validate(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Validate(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("Validate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadReadsLegacyOffsets(t *testing.T) {
+	at := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in         string
+		wantOffset int
+	}{
+		{"UTC+8", 8 * 3600},
+		{"UTC-3", -3 * 3600},
+		{"GMT+05:30", 5*3600 + 30*60},
+		{"UTC+0", 0},
+		{"Asia/Tokyo", 9 * 3600},
+		{"", 0},
+		{"nonsense", 0},
+	}
+
+	for _, tt := range tests {
+		_, offset := at.In(Load(tt.in)).Zone()
+		if offset != tt.wantOffset {
+			t.Errorf("Load(%q) offset = %d, want %d", tt.in, offset, tt.wantOffset)
+		}
+	}
+}
+
+func TestDateUsesLocalCalendarDay(t *testing.T) {
+	shanghai := Load("Asia/Shanghai")
+	// 2026-03-01 20:00 UTC is already March 2nd in Shanghai
+	got := Date(time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC), shanghai)
+	if want := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Date = %v, want %v", got, want)
+	}
+
+	start := StartOfDate(got, shanghai)
+	if want := time.Date(2026, 3, 1, 16, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("StartOfDate = %v, want %v", start.UTC(), want)
+	}
+}
+
+func TestFormatDue(t *testing.T) {
+	due := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	shanghai := Load("Asia/Shanghai")
+
+	if got := FormatDue(due, false, shanghai, "01-02"); got != "03-02 18:00" {
+		t.Errorf("timed FormatDue = %q", got)
+	}
+	allDay := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	if got := FormatDue(allDay, true, Load("America/New_York"), "2006-01-02"); got != "2026-03-02" {
+		t.Errorf("all-day FormatDue = %q", got)
+	}
+}
@@ -8,7 +8,10 @@ export interface Comment {
   user_id: string;
   content: string;
   created_at: string;
+  edited_at?: string | null; // Set once the author edits the comment
+  deleted_at?: string | null; // Deleted comment kept for its replies; content is empty
   user?: UserProfile;
+  replies?: Comment[]; // Only in the tree view
 }
 
 export interface CreateCommentRequest {
@@ -28,6 +31,15 @@ export const listComments = async (taskId: string): Promise<Comment[]> => {
   return res.data.data;
 };
 
+// listCommentThreads returns top-level comments with replies nested
+export const listCommentThreads = async (taskId: string): Promise<Comment[]> => {
+  const res = await apiClient.get<ListCommentsResponse>(
+    `/tasks/${taskId}/comments`,
+    { params: { view: "tree" } }
+  );
+  return res.data.data;
+};
+
 export interface CreateCommentResponse {
   success: boolean;
   data: Comment;
@@ -43,3 +55,22 @@ export const createComment = async (
   );
   return res.data.data;
 };
+
+export const updateComment = async (
+  taskId: string,
+  commentId: string,
+  content: string
+): Promise<Comment> => {
+  const res = await apiClient.patch<CreateCommentResponse>(
+    `/tasks/${taskId}/comments/${commentId}`,
+    { content }
+  );
+  return res.data.data;
+};
+
+export const deleteComment = async (
+  taskId: string,
+  commentId: string
+): Promise<void> => {
+  await apiClient.delete(`/tasks/${taskId}/comments/${commentId}`);
+};
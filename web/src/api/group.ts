@@ -1,5 +1,11 @@
 import apiClient from "./client";
-import type { Group, ValidationResult, InitResult } from "@/types/group";
+import type {
+  Group,
+  ValidationResult,
+  InitResult,
+  WorkflowStatus,
+  CustomField,
+} from "@/types/group";
 
 export interface ListGroupsResponse {
   success: boolean;
@@ -45,3 +51,42 @@ export const initGroupDatabase = async (
   });
   return res.data.data;
 };
+
+export const updateGroupSettings = async (
+  groupID: string,
+  autoArchiveDays: number
+): Promise<{ group_id: string; auto_archive_days: number }> => {
+  const res = await apiClient.patch(`/groups/${groupID}/settings`, {
+    auto_archive_days: autoArchiveDays,
+  });
+  return res.data.data;
+};
+
+export const listGroupStatuses = async (
+  groupID: string
+): Promise<WorkflowStatus[]> => {
+  const res = await apiClient.get(`/groups/${groupID}/statuses`);
+  return res.data.data.items;
+};
+
+export const updateGroupStatuses = async (
+  groupID: string,
+  statuses: WorkflowStatus[]
+): Promise<WorkflowStatus[]> => {
+  const res = await apiClient.put(`/groups/${groupID}/statuses`, { statuses });
+  return res.data.data.items;
+};
+
+export const listGroupFields = async (
+  groupID: string
+): Promise<CustomField[]> => {
+  const res = await apiClient.get(`/groups/${groupID}/fields`);
+  return res.data.data.items;
+};
+
+export const refreshGroupFields = async (
+  groupID: string
+): Promise<CustomField[]> => {
+  const res = await apiClient.post(`/groups/${groupID}/fields/refresh`);
+  return res.data.data.items;
+};
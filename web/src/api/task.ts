@@ -1,10 +1,27 @@
 import apiClient from "./client";
-import type { Task, TaskDetail } from "@/types/task";
+import type {
+  BatchRequest,
+  BatchResult,
+  CustomFieldValue,
+  Task,
+  TaskAttachment,
+  TaskDetail,
+  TaskEvent,
+  TaskPriority,
+  TaskTemplate,
+  TaskTemplateInput,
+  TimeEntry,
+  TimeReport,
+  TimeReportGroupBy,
+  TimerStartResult,
+  WatchState,
+} from "@/types/task";
 
 export interface ListTasksResponse {
   success: boolean;
   data: {
     items: TaskDetail[];
+    next_cursor: string | null;
   };
 }
 
@@ -16,6 +33,12 @@ export interface GetTaskResponse {
 export interface ListParams {
   view?: string;
   database_id?: string;
+  priority?: string;
+  label?: string;
+  // Filter expression, e.g. `status:"In Progress" due:<7d -label:wontfix`
+  q?: string;
+  sort?: "created" | "updated" | "due" | "priority" | "title";
+  cursor?: string;
   limit?: number;
   offset?: number;
 }
@@ -25,6 +48,35 @@ export const listTasks = async (params?: ListParams): Promise<TaskDetail[]> => {
   return res.data.data.items;
 };
 
+export interface TaskSearchResult {
+  task: Task;
+  rank: number;
+  // HTML-escaped snippets with matches wrapped in <mark>
+  highlights: Partial<Record<"title" | "description" | "comment" | "snapshot", string>>;
+}
+
+export interface SearchTasksResponse {
+  success: boolean;
+  data: { items: TaskSearchResult[] };
+}
+
+export const searchTasks = async (
+  q: string,
+  params?: { limit?: number; offset?: number }
+): Promise<TaskSearchResult[]> => {
+  const res = await apiClient.get<SearchTasksResponse>("/tasks/search", {
+    params: { q, ...params },
+  });
+  return res.data.data.items;
+};
+
+export const listTasksPage = async (
+  params?: ListParams
+): Promise<ListTasksResponse["data"]> => {
+  const res = await apiClient.get<ListTasksResponse>("/tasks", { params });
+  return res.data.data;
+};
+
 export const getTask = async (id: string): Promise<Task> => {
   const res = await apiClient.get<GetTaskResponse>(`/tasks/${id}`);
   return res.data.data;
@@ -33,15 +85,26 @@ export const getTask = async (id: string): Promise<Task> => {
 export interface PatchTaskRequest {
   title?: string;
   status?: string;
-  due_at?: string | null;
+  priority?: TaskPriority;
+  labels?: string[];
+  due_at?: string | null; // RFC3339, "2006-01-02T15:04" or "2006-01-02"; "" clears
+  start_at?: string | null;
+  all_day?: boolean;
+  recurrence?: string;
+  estimate_minutes?: number; // 0 clears the estimate
   description?: string;
+  custom_fields?: Record<string, CustomFieldValue | null>; // merged by name; null clears
 }
 
+// Pass the Version of the task being edited to fail with 409 (and the current
+// task in the response body) if someone else changed it in the meantime.
 export const patchTask = async (
   id: string,
-  data: PatchTaskRequest
+  data: PatchTaskRequest,
+  version?: number
 ): Promise<Task> => {
-  const res = await apiClient.patch<GetTaskResponse>(`/tasks/${id}`, data);
+  const headers = version !== undefined ? { "If-Match": `"${version}"` } : undefined;
+  const res = await apiClient.patch<GetTaskResponse>(`/tasks/${id}`, data, { headers });
   return res.data.data;
 };
 
@@ -55,10 +118,267 @@ export const createTask = async (data: CreateTaskRequest): Promise<Task> => {
   return res.data.data;
 };
 
+// template is a template ID or name; title fills its {input} placeholder
+export const createTaskFromTemplate = async (
+  template: string,
+  data: { title?: string },
+  groupId?: string
+): Promise<Task> => {
+  const res = await apiClient.post<GetTaskResponse>("/tasks", data, {
+    params: { template, group_id: groupId },
+  });
+  return res.data.data;
+};
+
+export const listTemplates = async (groupId?: string): Promise<TaskTemplate[]> => {
+  const res = await apiClient.get<{ success: boolean; data: TaskTemplate[] }>("/templates", {
+    params: { group_id: groupId },
+  });
+  return res.data.data;
+};
+
+export const createTemplate = async (data: TaskTemplateInput): Promise<TaskTemplate> => {
+  const res = await apiClient.post<{ success: boolean; data: TaskTemplate }>("/templates", data);
+  return res.data.data;
+};
+
+export const updateTemplate = async (
+  id: string,
+  data: TaskTemplateInput
+): Promise<TaskTemplate> => {
+  const res = await apiClient.put<{ success: boolean; data: TaskTemplate }>(
+    `/templates/${id}`,
+    data
+  );
+  return res.data.data;
+};
+
+export const deleteTemplate = async (id: string): Promise<void> => {
+  await apiClient.delete(`/templates/${id}`);
+};
+
 export const deleteTask = async (id: string): Promise<void> => {
   await apiClient.delete(`/tasks/${id}`);
 };
 
+export const listTrash = async (params?: {
+  limit?: number;
+  offset?: number;
+}): Promise<Task[]> => {
+  const res = await apiClient.get<{ success: boolean; data: { items: Task[] } }>("/tasks/trash", {
+    params,
+  });
+  return res.data.data.items;
+};
+
+export const restoreTask = async (id: string): Promise<Task> => {
+  const res = await apiClient.post<GetTaskResponse>(`/tasks/${id}/restore`);
+  return res.data.data;
+};
+
+export const archiveTask = async (id: string): Promise<Task> => {
+  const res = await apiClient.post<GetTaskResponse>(`/tasks/${id}/archive`);
+  return res.data.data;
+};
+
+export const unarchiveTask = async (id: string): Promise<Task> => {
+  const res = await apiClient.post<GetTaskResponse>(`/tasks/${id}/unarchive`);
+  return res.data.data;
+};
+
+export interface MoveTaskRequest {
+  group_id?: string; // "" for a personal task
+  database_id?: string; // "" to unlink from Notion
+}
+
+export const moveTask = async (id: string, req: MoveTaskRequest): Promise<Task> => {
+  const res = await apiClient.post<GetTaskResponse>(`/tasks/${id}/move`, req);
+  return res.data.data;
+};
+
+export const cloneTask = async (id: string, req: MoveTaskRequest = {}): Promise<Task> => {
+  const res = await apiClient.post<GetTaskResponse>(`/tasks/${id}/clone`, req);
+  return res.data.data;
+};
+
+export const addAssignee = async (id: string, userId: string): Promise<Task> => {
+  const res = await apiClient.post<GetTaskResponse>(
+    `/tasks/${id}/assignees/${userId}`
+  );
+  return res.data.data;
+};
+
+export const removeAssignee = async (
+  id: string,
+  userId: string
+): Promise<Task> => {
+  const res = await apiClient.delete<GetTaskResponse>(
+    `/tasks/${id}/assignees/${userId}`
+  );
+  return res.data.data;
+};
+
+export const followTask = async (id: string): Promise<WatchState> => {
+  const res = await apiClient.post<{ success: boolean; data: WatchState }>(
+    `/tasks/${id}/watchers`
+  );
+  return res.data.data;
+};
+
+export const unfollowTask = async (id: string): Promise<WatchState> => {
+  const res = await apiClient.delete<{ success: boolean; data: WatchState }>(
+    `/tasks/${id}/watchers`
+  );
+  return res.data.data;
+};
+
+export const startTimer = async (id: string): Promise<TimerStartResult> => {
+  const res = await apiClient.post<{ success: boolean; data: TimerStartResult }>(
+    `/tasks/${id}/timer/start`
+  );
+  return res.data.data;
+};
+
+export const stopTimer = async (id: string): Promise<TimeEntry> => {
+  const res = await apiClient.post<{ success: boolean; data: TimeEntry }>(
+    `/tasks/${id}/timer/stop`
+  );
+  return res.data.data;
+};
+
+export const listTimeEntries = async (
+  id: string
+): Promise<{ items: TimeEntry[]; total_seconds: number }> => {
+  const res = await apiClient.get<{
+    success: boolean;
+    data: { items: TimeEntry[]; total_seconds: number };
+  }>(`/tasks/${id}/time-entries`);
+  return res.data.data;
+};
+
+export const addTimeEntry = async (
+  id: string,
+  data: { minutes: number; started_at?: string; note?: string }
+): Promise<TimeEntry> => {
+  const res = await apiClient.post<{ success: boolean; data: TimeEntry }>(
+    `/tasks/${id}/time-entries`,
+    data
+  );
+  return res.data.data;
+};
+
+export const deleteTimeEntry = async (id: string, entryId: string): Promise<void> => {
+  await apiClient.delete(`/tasks/${id}/time-entries/${entryId}`);
+};
+
+export const getTimeReport = async (params?: {
+  from?: string;
+  to?: string;
+  group_id?: string;
+  group_by?: TimeReportGroupBy;
+}): Promise<TimeReport> => {
+  const res = await apiClient.get<{ success: boolean; data: TimeReport }>("/time/report", {
+    params,
+  });
+  return res.data.data;
+};
+
+export const listAttachments = async (id: string): Promise<TaskAttachment[]> => {
+  const res = await apiClient.get<{ success: boolean; data: TaskAttachment[] }>(
+    `/tasks/${id}/attachments`
+  );
+  return res.data.data;
+};
+
+// The download needs the Telegram auth header, so fetch it as a blob
+// (e.g. for URL.createObjectURL) instead of linking to it directly
+export const downloadAttachment = async (id: string, attachmentId: string): Promise<Blob> => {
+  const res = await apiClient.get<Blob>(`/tasks/${id}/attachments/${attachmentId}/download`, {
+    responseType: "blob",
+  });
+  return res.data;
+};
+
+export const batchUpdateTasks = async (
+  data: BatchRequest
+): Promise<BatchResult> => {
+  const res = await apiClient.post<{ success: boolean; data: BatchResult }>(
+    "/tasks/batch",
+    data
+  );
+  return res.data.data;
+};
+
+export interface ListSubtasksResponse {
+  success: boolean;
+  data: {
+    items: Task[];
+    progress: { done: number; total: number };
+  };
+}
+
+export const listSubtasks = async (
+  id: string
+): Promise<ListSubtasksResponse["data"]> => {
+  const res = await apiClient.get<ListSubtasksResponse>(`/tasks/${id}/subtasks`);
+  return res.data.data;
+};
+
+export const createSubtask = async (
+  id: string,
+  data: CreateTaskRequest
+): Promise<Task> => {
+  const res = await apiClient.post<GetTaskResponse>(`/tasks/${id}/subtasks`, data);
+  return res.data.data;
+};
+
+export interface TaskDependencies {
+  blocked_by: Task[];
+  blocking: Task[];
+}
+
+export interface TaskDependenciesResponse {
+  success: boolean;
+  data: TaskDependencies;
+}
+
+export const listDependencies = async (
+  id: string
+): Promise<TaskDependencies> => {
+  const res = await apiClient.get<TaskDependenciesResponse>(`/tasks/${id}/dependencies`);
+  return res.data.data;
+};
+
+export const addDependency = async (
+  id: string,
+  blockedById: string
+): Promise<TaskDependencies> => {
+  const res = await apiClient.post<TaskDependenciesResponse>(`/tasks/${id}/dependencies`, {
+    blocked_by_id: blockedById,
+  });
+  return res.data.data;
+};
+
+export const removeDependency = async (
+  id: string,
+  blockedById: string
+): Promise<void> => {
+  await apiClient.delete(`/tasks/${id}/dependencies/${blockedById}`);
+};
+
+export interface ListTaskEventsResponse {
+  success: boolean;
+  data: TaskEvent[];
+}
+
+export const listTaskEvents = async (
+  id: string,
+  params?: { limit?: number; offset?: number }
+): Promise<TaskEvent[]> => {
+  const res = await apiClient.get<ListTaskEventsResponse>(`/tasks/${id}/events`, { params });
+  return res.data.data;
+};
+
 export interface TaskCounts {
   assigned: number;
   created: number;
@@ -1,9 +1,12 @@
+import type { StatusCategory } from "./task";
+
 export interface Group {
   id: string;
   title: string;
   status: "Connected" | "Unbound" | "Inactive";
   db?: DatabaseSummary;
   role: "Admin" | "Member";
+  auto_archive_days?: number;
 }
 
 export interface DatabaseSummary {
@@ -22,9 +25,33 @@ export interface ValidationResult {
 export interface InitResult {
   initialized: boolean;
   created_properties: string[];
+  missing_statuses?: string[];
 }
 
 export interface BindGroupRequest {
   db_id: string;
   mode?: string;
 }
+
+export interface WorkflowStatus {
+  id?: string;
+  name: string;
+  category: StatusCategory;
+  position?: number;
+  next?: string[];
+}
+
+export interface CustomField {
+  id: string;
+  name: string;
+  type:
+    | "rich_text"
+    | "number"
+    | "select"
+    | "multi_select"
+    | "date"
+    | "checkbox"
+    | "url";
+  position: number;
+  options: string[];
+}
@@ -1,3 +1,5 @@
+export type TaskPriority = "High" | "Medium" | "Low";
+
 export interface TaskContextSnapshot {
   ID: string;
   Role: "me" | "other" | "system";
@@ -6,6 +8,11 @@ export interface TaskContextSnapshot {
   CreatedAt: string;
 }
 
+export interface Label {
+  id: string;
+  name: string;
+}
+
 export interface User {
   id: string;
   name: string;
@@ -13,20 +20,43 @@ export interface User {
   photo_url?: string;
 }
 
+/** Text, select and URL values are strings; multi-select values string arrays; dates "2026-03-02" or RFC 3339 */
+export type CustomFieldValue = string | number | boolean | string[];
+
+export type StatusCategory = "todo" | "active" | "done";
+
 export interface Task {
   ID: string;
   Title: string;
   Status: string;
+  StatusCategory?: StatusCategory;
+  CustomFields?: Record<string, CustomFieldValue>;
+  Priority: TaskPriority;
+  Labels?: Label[];
+  Recurrence?: string;
+  ParentID?: string | null;
+  AutoComplete?: boolean;
+  EstimateMinutes?: number | null;
+  SubtaskTotal?: number;
+  SubtaskDone?: number;
   SyncStatus: "Synced" | "Pending" | "Failed";
   DatabaseID?: string;
   NotionURL?: string;
+  Version?: number;
   CreatedAt: string;
   DueAt?: string | null;
+  StartAt?: string | null;
+  AllDay?: boolean; // DueAt/StartAt are dates at UTC midnight
+  DeletedAt?: string | null;
+  Archived?: boolean;
+  CompletedAt?: string | null;
   // Details
   Description?: string;
   ChatJumpURL?: string;
   Snapshots?: TaskContextSnapshot[];
   Assignees?: User[];
+  Watchers?: User[];
+  Attachments?: TaskAttachment[];
   Creator?: User;
   Group?: {
     id: string;
@@ -34,6 +64,129 @@ export interface Task {
   };
 }
 
+export type AttachmentKind = "photo" | "document" | "voice" | "audio" | "video";
+
+export interface TaskAttachment {
+  id: string;
+  task_id: string;
+  kind: AttachmentKind;
+  file_id: string;
+  file_unique_id?: string;
+  file_name?: string;
+  mime_type?: string;
+  file_size: number;
+  width?: number;
+  height?: number;
+  duration?: number; // Seconds, for voice, audio and video
+  caption?: string;
+  tg_message_id: number;
+  uploaded_by: string | null;
+  created_at: string;
+}
+
 export interface TaskDetail {
   task: Task;
 }
+
+export type TaskEventType =
+  | "Create"
+  | "Assign"
+  | "Status"
+  | "Due"
+  | "Delete"
+  | "Restore"
+  | "Comment"
+  | "Update"
+  | "Dependency";
+
+export interface TaskEvent {
+  id: string;
+  task_id: string;
+  actor_id?: string | null;
+  actor?: User | null;
+  event: TaskEventType;
+  source: "app" | "bot" | "notion" | "system";
+  before?: Record<string, unknown> | null;
+  after?: Record<string, unknown> | null;
+  created_at: string;
+}
+
+export type BatchAction = "status" | "assign" | "shift_due" | "archive" | "delete";
+
+export interface BatchRequest {
+  task_ids: string[];
+  action: BatchAction;
+  status?: string;
+  assignee_id?: string;
+  shift_hours?: number;
+}
+
+export interface BatchItemResult {
+  task_id: string;
+  ok: boolean;
+  error?: "not_found" | "forbidden" | "task_blocked" | "version_conflict" | "no_due_date" | "invalid_status" | "transition_not_allowed";
+}
+
+export interface BatchResult {
+  items: BatchItemResult[];
+  succeeded: number;
+  failed: number;
+}
+
+export interface WatchState {
+  task_id: string;
+  watching: boolean;
+}
+
+export interface TimeEntry {
+  id: string;
+  task_id: string;
+  user_id: string;
+  started_at: string;
+  ended_at?: string | null; // null while the timer is running
+  duration_seconds: number;
+  note?: string;
+  source: "timer" | "manual";
+  created_at: string;
+  user?: User;
+}
+
+export interface TimerStartResult {
+  entry: TimeEntry;
+  stopped: TimeEntry | null; // Timer stopped on another task
+}
+
+export type TimeReportGroupBy = "user" | "group" | "day";
+
+export interface TimeReportRow {
+  key: string;
+  total_seconds: number;
+  entries: number;
+}
+
+export interface TimeReport {
+  group_by: TimeReportGroupBy;
+  rows: TimeReportRow[];
+  total_seconds: number;
+}
+
+export interface TaskTemplateInput {
+  name: string; // Used as tpl:<name>
+  group_id?: string; // Only on creation; omit for a personal template
+  title_pattern: string; // Supports {date} {time} {datetime} {weekday} {chat} {user} {input}
+  description?: string;
+  priority?: TaskPriority;
+  assignee_ids?: string[];
+  labels?: string[];
+  due_offset_minutes?: number | null;
+  subtasks?: string[];
+}
+
+export interface TaskTemplate extends TaskTemplateInput {
+  id: string;
+  user_id: string | null;
+  group_id: string | null;
+  created_by: string | null;
+  created_at: string;
+  updated_at: string;
+}